@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/png"
 	"io/ioutil"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -16,6 +20,7 @@ import (
 var (
 	userSvcURL   = "http://localhost:5454"
 	ledgerSvcURL = "http://localhost:5455"
+	mockIDPURL   = "http://localhost:8090"
 )
 
 func main() {
@@ -61,20 +66,29 @@ func main() {
 	}
 
 	var verifyCmd = &cobra.Command{
-		Use:   "verify [tag] [doc_type]",
-		Short: "Start verification for a user",
-		Args:  cobra.ExactArgs(2),
+		Use:   "verify [tag] [doc_type] [file]",
+		Short: "Upload a document and start verification for a user (a blank PNG when no file is given)",
+		Args:  cobra.RangeArgs(2, 3),
 		Run: func(cmd *cobra.Command, args []string) {
-			verifyUser(args[0], args[1])
+			file := ""
+			if len(args) == 3 {
+				file = args[2]
+			}
+			verifyUser(args[0], args[1], file)
 		},
 	}
 
 	var webhookCmd = &cobra.Command{
-		Use:   "webhook [tag] [status]",
-		Short: "Simulate identity webhook (status: passed/failed)",
-		Args:  cobra.ExactArgs(2),
+		Use:   "webhook [tag] [status] [reason]",
+		Short: "Complete the user's latest mock verification session (status: passed/failed)",
+		Long:  "Completes the user's latest session on the mock identity provider, which then calls the identity webhook. Failures need a reason: document_expired, document_unreadable, suspected_forgery, data_mismatch or face_mismatch.",
+		Args:  cobra.RangeArgs(2, 3),
 		Run: func(cmd *cobra.Command, args []string) {
-			triggerWebhook(args[0], args[1])
+			reason := ""
+			if len(args) == 3 {
+				reason = args[2]
+			}
+			triggerWebhook(args[0], args[1], reason)
 		},
 	}
 
@@ -87,7 +101,22 @@ func main() {
 		},
 	}
 
-	rootCmd.AddCommand(createCmd, balanceCmd, sendCmd, seedCmd, verifyCmd, webhookCmd, splitCmd)
+	var txCmd = &cobra.Command{
+		Use:   "tx",
+		Short: "Inspect transactions",
+	}
+
+	var txShowCmd = &cobra.Command{
+		Use:   "show [tag] [ref]",
+		Short: "Show both legs, events and status history of a transaction, as seen by a user",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			showTransaction(args[0], args[1])
+		},
+	}
+	txCmd.AddCommand(txShowCmd)
+
+	rootCmd.AddCommand(createCmd, balanceCmd, sendCmd, seedCmd, verifyCmd, webhookCmd, splitCmd, txCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -112,24 +141,74 @@ func checkBalance(tag string) {
 	fmt.Println("Balance:", resp)
 }
 
-func verifyUser(tag, docType string) {
-	userID, _ := resolveUser(tag)
-	if userID == 0 {
-		fmt.Printf("User %s not found\n", tag)
+func verifyUser(tag, docType, file string) {
+	uploadID := uploadDocument(tag, file)
+	if uploadID == 0 {
 		return
 	}
 
 	payload := map[string]interface{}{
-		"user_id":       userID,
 		"document_type": docType,
-		"document_url":  "http://s3.aws.com/fake-doc.jpg",
+		"upload_id":     uploadID,
 	}
 
-	resp := post(userSvcURL+"/verification/session", payload)
+	resp := postAs(userSvcURL+"/verification/session", tag, payload)
 	fmt.Println("Verify Response:", resp)
 }
 
-func triggerWebhook(tag, status string) {
+// uploadDocument uploads file as tag and returns the upload's ID, or 0 after
+// printing why it failed.
+func uploadDocument(tag, file string) int {
+	name := filepath.Base(file)
+	var data []byte
+	if file == "" {
+		name = "blank.png"
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+		data = buf.Bytes()
+	} else {
+		var err error
+		if data, err = ioutil.ReadFile(file); err != nil {
+			fmt.Println("Error:", err)
+			return 0
+		}
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", name)
+	part.Write(data)
+	w.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, userSvcURL+"/documents", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("X-User-Tag", tag)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 0
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+
+	var out struct {
+		Meta struct {
+			Data struct {
+				Upload struct {
+					ID int `json:"id"`
+				} `json:"upload"`
+			} `json:"data"`
+		} `json:"meta"`
+	}
+	json.Unmarshal(respBody, &out)
+	if out.Meta.Data.Upload.ID == 0 {
+		fmt.Println("Upload Response:", string(respBody))
+		return 0
+	}
+	return out.Meta.Data.Upload.ID
+}
+
+func triggerWebhook(tag, status, reason string) {
 	userID, _ := resolveUser(tag)
 	if userID == 0 {
 		fmt.Printf("User %s not found\n", tag)
@@ -137,12 +216,12 @@ func triggerWebhook(tag, status string) {
 	}
 
 	payload := map[string]interface{}{
-		"user_id": userID,
-		"status":  status,
+		"outcome": status,
+		"reason":  reason,
 	}
 
-	resp := post(userSvcURL+"/webhooks/identity", payload)
-	fmt.Println("Webhook Response:", resp)
+	resp := post(fmt.Sprintf("%s/v1/users/%d/complete", mockIDPURL, userID), payload)
+	fmt.Println("Mock IDP Response:", resp)
 }
 
 func sendMoney(fromTag, toTag, amountStr, desc string) {
@@ -180,6 +259,17 @@ func seedData() {
 	sendMoney("alice", "bob", "100", "test transfer")
 }
 
+func showTransaction(tag, ref string) {
+	resp := getAs(fmt.Sprintf("%s/transactions/%s", ledgerSvcURL, ref), tag)
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, []byte(resp), "", "  "); err != nil {
+		fmt.Println("Transaction:", resp)
+		return
+	}
+	fmt.Println(pretty.String())
+}
+
 func splitBill(txIDStr, requesterTag, friendsStr string) {
 	var txID int
 	fmt.Sscanf(txIDStr, "%d", &txID)
@@ -264,3 +354,31 @@ func get(url string) string {
 	body, _ := ioutil.ReadAll(resp.Body)
 	return string(body)
 }
+
+// postAs makes a POST request as the user with the given tag.
+func postAs(url, tag string, data interface{}) string {
+	jsonData, _ := json.Marshal(data)
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Tag", tag)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body)
+}
+
+// getAs makes a GET request as the user with the given tag.
+func getAs(url, tag string) string {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("X-User-Tag", tag)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body)
+}
@@ -3,10 +3,27 @@ package main
 import (
 	"cashapp/core"
 	"cashapp/core/database"
+	"cashapp/core/fieldcrypt"
+	amlapi "cashapp/internal/aml/api"
+	amlmodels "cashapp/internal/aml/models"
+	amlrepository "cashapp/internal/aml/repository"
+	amlservice "cashapp/internal/aml/service"
 	"cashapp/internal/ledger/api"
 	"cashapp/internal/ledger/models"
+	"cashapp/internal/ledger/realtime"
 	"cashapp/internal/ledger/repository"
 	"cashapp/internal/ledger/service"
+	"cashapp/internal/limits"
+	notificationmodels "cashapp/internal/notification/models"
+	notificationrepository "cashapp/internal/notification/repository"
+	notificationservice "cashapp/internal/notification/service"
+	"cashapp/internal/risk"
+	webhookapi "cashapp/internal/webhook/api"
+	webhookmodels "cashapp/internal/webhook/models"
+	webhookrepository "cashapp/internal/webhook/repository"
+	webhookservice "cashapp/internal/webhook/service"
+	"context"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -23,20 +40,73 @@ func main() {
 	// Ideally we set PORT env var differently for each service.
 	core.InitLogger(config.ENVIRONMENT)
 
+	if err := fieldcrypt.Init(config); err != nil {
+		core.Log.Fatal("failed to configure field encryption", zap.Error(err))
+	}
+
 	pg, err := database.NewPostgres(config)
 	if err != nil {
 		core.Log.Fatal("failed to initialize postgres database", zap.Error(err))
 	}
 
-	err = database.RunMigrations(pg, &models.Transaction{}, &models.TransactionEvent{}, &models.PaymentRequest{})
+	err = database.RunMigrations(pg, &models.Transaction{}, &models.TransactionEvent{}, &models.TransactionStatusChange{}, &models.PaymentRequest{}, &models.Comment{}, &models.Reaction{}, &models.PendingClaim{}, &models.StepUpChallenge{}, &models.Migration{}, &limits.Usage{}, &risk.Assessment{},
+		&notificationmodels.Notification{}, &notificationmodels.ChannelPreference{}, &notificationmodels.QuietHours{},
+		&amlmodels.Alert{}, &amlmodels.Case{}, &amlmodels.CaseNote{}, &amlmodels.MonitorState{},
+		&webhookmodels.WebhookEndpoint{}, &webhookmodels.WebhookDelivery{}, &webhookmodels.WebhookAttempt{})
 	if err != nil {
 		core.Log.Fatal("failed to run migrations", zap.Error(err))
 	}
 
+	if err := models.MigrateRequestAmounts(pg); err != nil {
+		core.Log.Fatal("failed to migrate payment request amounts", zap.Error(err))
+	}
+
+	if err := models.BackfillClaimIndexes(pg); err != nil {
+		core.Log.Fatal("failed to index claim targets", zap.Error(err))
+	}
+
+	rules, err := limits.LoadRules(config.LIMITS_FILE)
+	if err != nil {
+		core.Log.Fatal("failed to load limit rules", zap.Error(err))
+	}
+
+	riskRules, err := risk.LoadRules(config.RISK_FILE)
+	if err != nil {
+		core.Log.Fatal("failed to load risk rules", zap.Error(err))
+	}
+
+	amlRules, err := amlservice.LoadRules(config.AML_FILE)
+	if err != nil {
+		core.Log.Fatal("failed to load aml rules", zap.Error(err))
+	}
+
 	repo := repository.New(pg)
 	svc := service.New(repo, config)
+	notifications := notificationservice.New(notificationrepository.New(pg), config)
+	if err := notifications.ScrubClaimTargets(); err != nil {
+		core.Log.Error("failed to scrub contacts from claim notifications", zap.Error(err))
+	}
+	svc.SetNotifier(notifications)
+	svc.SetLimits(limits.New(pg, rules))
+	svc.SetRisk(risk.New(pg, riskRules))
+
+	broker := realtime.NewBroker(database.NewRedis(config))
+	go broker.Run(context.Background())
+	hooks := webhookservice.New(webhookrepository.New(pg), config)
+	hooks.SetNotifier(notifications)
+	go hooks.RunDeliveries(5 * time.Second)
+	svc.SetEventPublisher(service.Publishers{broker, hooks})
+	go svc.RunClaimWorker(time.Minute)
+	go svc.RunStepUpSweeper(time.Minute)
+
+	aml := amlservice.New(amlrepository.New(pg), amlRules)
+	go aml.RunMonitor(time.Minute)
 	server := core.NewHTTPServer(config)
 
 	api.RegisterPaymentRoutes(server.Engine, svc)
+	api.RegisterStreamRoutes(server.Engine, svc, broker)
+	api.RegisterRiskRoutes(server.Engine, svc, config)
+	amlapi.RegisterAMLRoutes(server.Engine, aml, core.RequireStaff(config))
+	webhookapi.RegisterWebhookRoutes(server.Engine, hooks, api.Authenticate(svc), api.ViewerID)
 	server.Start()
 }
@@ -0,0 +1,321 @@
+// Command mockidp is a stand-in identity verification provider for local
+// development and testing. The user service creates sessions on it like it
+// would with Onfido or Stripe Identity; testers open a session's page, pick
+// an outcome, and the provider calls the user service's webhook with a
+// signed result.
+package main
+
+import (
+	"bytes"
+	"cashapp/core"
+	"cashapp/core/webhooks"
+	"cashapp/internal/identity"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const webhookAttempts = 5
+
+// failureChecks maps each failure a tester can choose to the check that
+// flags it.
+var failureChecks = map[string]string{
+	"document_expired":    "document_authenticity",
+	"document_unreadable": "document_authenticity",
+	"suspected_forgery":   "document_authenticity",
+	"data_mismatch":       "data_consistency",
+	"face_mismatch":       "face_comparison",
+}
+
+var checkNames = []string{"document_authenticity", "data_consistency", "face_comparison"}
+
+type server struct {
+	publicURL  string
+	webhookURL string
+	secret     string
+	apiKey     string
+
+	mu       sync.Mutex
+	sessions map[string]*identity.MockSession
+}
+
+func main() {
+	core.InitLogger(core.Environment(env("ENV", "dev")))
+
+	s := &server{
+		publicURL:  strings.TrimRight(env("MOCK_IDP_PUBLIC_URL", "http://localhost:8090"), "/"),
+		webhookURL: env("MOCK_IDP_WEBHOOK_URL", "http://localhost:5454/webhooks/identity"),
+		secret:     env("MOCK_IDP_SECRET", "mock-secret"),
+		apiKey:     os.Getenv("MOCK_IDP_API_KEY"),
+		sessions:   map[string]*identity.MockSession{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/sessions", s.api(s.createSession))
+	mux.HandleFunc("GET /v1/sessions/{id}", s.api(s.getSession))
+	mux.HandleFunc("POST /v1/sessions/{id}/complete", s.api(s.completeSession))
+	mux.HandleFunc("POST /v1/users/{id}/complete", s.api(s.completeLatest))
+	mux.HandleFunc("GET /{$}", s.index)
+	mux.HandleFunc("GET /session/{id}", s.sessionPage)
+	mux.HandleFunc("POST /session/{id}", s.submitSession)
+
+	addr := ":" + env("MOCK_IDP_PORT", "8090")
+	core.Log.Info("mock identity provider listening", zap.String("addr", addr), zap.String("webhook_url", s.webhookURL))
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		core.Log.Fatal("mock identity provider stopped", zap.Error(err))
+	}
+}
+
+func env(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// api wraps a JSON API handler with the optional API key check.
+func (s *server) api(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.apiKey != "" && r.Header.Get("Authorization") != "Bearer "+s.apiKey {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid api key"})
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *server) createSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reference    string `json:"reference"`
+		UserID       int    `json:"user_id"`
+		FullName     string `json:"full_name"`
+		DocumentType string `json:"document_type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	id := "sess_" + randomHex(12)
+	session := &identity.MockSession{
+		ID:           id,
+		Reference:    req.Reference,
+		UserID:       req.UserID,
+		FullName:     req.FullName,
+		DocumentType: req.DocumentType,
+		Status:       "pending",
+		URL:          s.publicURL + "/session/" + id,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	s.sessions[id] = session
+	s.mu.Unlock()
+
+	core.Log.Info("session created", zap.String("session", id), zap.Int("user_id", req.UserID), zap.String("document_type", req.DocumentType))
+	writeJSON(w, http.StatusCreated, session)
+}
+
+func (s *server) getSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.find(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// completeSession lets scripts finish a session without the page. The body
+// is {"outcome": "passed"} or {"outcome": "failed", "reason": "face_mismatch"}.
+func (s *server) completeSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Outcome string `json:"outcome"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	session, err := s.complete(r.PathValue("id"), req.Outcome, req.Reason)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// completeLatest finishes a user's most recent pending session, for
+// cashapp-cli which only knows users by tag.
+func (s *server) completeLatest(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return
+	}
+
+	var latest *identity.MockSession
+	s.mu.Lock()
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.Status == "pending" && (latest == nil || session.CreatedAt.After(latest.CreatedAt)) {
+			latest = session
+		}
+	}
+	s.mu.Unlock()
+	if latest == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no pending session for user"})
+		return
+	}
+
+	r.SetPathValue("id", latest.ID)
+	s.completeSession(w, r)
+}
+
+func (s *server) find(id string) (identity.MockSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return identity.MockSession{}, false
+	}
+	return *session, true
+}
+
+// complete records an outcome and delivers the webhook in the background.
+func (s *server) complete(id, outcome, reason string) (identity.MockSession, error) {
+	if outcome != "passed" && outcome != "failed" {
+		return identity.MockSession{}, errors.New("outcome must be passed or failed")
+	}
+	if outcome == "failed" {
+		if _, ok := failureChecks[reason]; !ok {
+			return identity.MockSession{}, errors.New("unknown failure reason " + strconv.Quote(reason))
+		}
+	} else {
+		reason = ""
+	}
+
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	if !ok {
+		s.mu.Unlock()
+		return identity.MockSession{}, errors.New("session not found")
+	}
+	if session.Status != "pending" {
+		s.mu.Unlock()
+		return identity.MockSession{}, errors.New("session already " + session.Status)
+	}
+
+	now := time.Now().UTC()
+	session.Status = outcome
+	session.Reason = reason
+	session.CompletedAt = &now
+	session.Checks = nil
+	for _, name := range checkNames {
+		result := "clear"
+		if failureChecks[reason] == name {
+			result = "consider"
+		}
+		session.Checks = append(session.Checks, identity.MockCheck{Name: name, Result: result})
+	}
+	snapshot := *session
+	s.mu.Unlock()
+
+	go s.deliver(snapshot)
+	return snapshot, nil
+}
+
+// deliver posts a session.completed event to the webhook, retrying with
+// backoff like a real provider.
+func (s *server) deliver(session identity.MockSession) {
+	event := identity.MockEvent{ID: "evt_" + randomHex(12), Type: "session.completed", Created: time.Now().Unix()}
+	event.Data.Session = session
+	body, _ := json.Marshal(event)
+
+	backoff := time.Second
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, s.webhookURL, bytes.NewReader(body))
+		if err != nil {
+			core.Log.Error("failed to build webhook", zap.Error(err))
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(identity.MockSignatureHeader, webhooks.Sign(time.Now(), body, s.secret))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				core.Log.Info("webhook delivered", zap.String("event", event.ID), zap.String("session", session.ID), zap.String("outcome", session.Status))
+				return
+			}
+			err = errors.New(resp.Status)
+		}
+		core.Log.Warn("webhook delivery failed", zap.String("event", event.ID), zap.Int("attempt", attempt), zap.Error(err))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (s *server) index(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sessions := make([]identity.MockSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, *session)
+	}
+	s.mu.Unlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+
+	render(w, indexPage, sessions)
+}
+
+func (s *server) sessionPage(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.find(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	render(w, sessionPage, map[string]interface{}{
+		"Session":  session,
+		"Failures": sortedKeys(failureChecks),
+	})
+}
+
+func (s *server) submitSession(w http.ResponseWriter, r *http.Request) {
+	outcome, reason, _ := strings.Cut(r.FormValue("outcome"), ":")
+	if _, err := s.complete(r.PathValue("id"), outcome, reason); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Redirect(w, r, "/session/"+r.PathValue("id"), http.StatusSeeOther)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
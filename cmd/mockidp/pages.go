@@ -0,0 +1,78 @@
+package main
+
+import (
+	"cashapp/core"
+	"html/template"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const layout = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Mock identity provider</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 44rem; margin: 2rem auto; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: .4rem .6rem; border-bottom: 1px solid #ddd; }
+.passed { color: #0a7d32; } .failed { color: #b3261e; } .pending { color: #8a6d00; }
+button { font-size: 1rem; padding: .5rem 1rem; margin: .25rem 0; cursor: pointer; }
+</style>
+</head>
+<body>{{template "content" .}}</body>
+</html>`
+
+var indexPage = template.Must(template.Must(template.New("index").Parse(layout)).Parse(`{{define "content"}}
+<h1>Sessions</h1>
+{{if .}}
+<table>
+<tr><th>Session</th><th>User</th><th>Document</th><th>Status</th><th>Created</th></tr>
+{{range .}}
+<tr>
+<td><a href="/session/{{.ID}}">{{.ID}}</a></td>
+<td>{{.UserID}}{{with .FullName}} ({{.}}){{end}}</td>
+<td>{{.DocumentType}}</td>
+<td class="{{.Status}}">{{.Status}}{{with .Reason}}: {{.}}{{end}}</td>
+<td>{{.CreatedAt.Format "2006-01-02 15:04:05"}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No sessions yet. Start a verification in the user service to create one.</p>
+{{end}}
+{{end}}`))
+
+var sessionPage = template.Must(template.Must(template.New("session").Parse(layout)).Parse(`{{define "content"}}
+{{with .Session}}
+<p><a href="/">All sessions</a></p>
+<h1>Verify your identity</h1>
+<table>
+<tr><th>Session</th><td>{{.ID}}</td></tr>
+<tr><th>User</th><td>{{.UserID}}{{with .FullName}} ({{.}}){{end}}</td></tr>
+<tr><th>Document</th><td>{{.DocumentType}}</td></tr>
+<tr><th>Status</th><td class="{{.Status}}">{{.Status}}{{with .Reason}}: {{.}}{{end}}</td></tr>
+</table>
+{{if eq .Status "pending"}}
+<h2>Choose an outcome</h2>
+<form method="post">
+<button name="outcome" value="passed">Pass</button><br>
+{{range $.Failures}}<button name="outcome" value="failed:{{.}}">Fail: {{.}}</button><br>{{end}}
+</form>
+{{else}}
+<h2>Checks</h2>
+<table>
+{{range .Checks}}<tr><td>{{.Name}}</td><td class="{{if eq .Result "clear"}}passed{{else}}failed{{end}}">{{.Result}}</td></tr>{{end}}
+</table>
+<p>The result was sent to the webhook.</p>
+{{end}}
+{{end}}
+{{end}}`))
+
+func render(w http.ResponseWriter, t *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		core.Log.Error("failed to render page", zap.Error(err))
+	}
+}
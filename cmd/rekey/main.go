@@ -0,0 +1,73 @@
+// Command rekey re-encrypts the encrypted columns under the current master
+// key, a batch at a time, while the services keep running. It also
+// encrypts values written before a column was encrypted and fills in
+// missing blind indexes, so run it once after upgrading too.
+//
+// To rotate the local key file, run it with -new-key: it adds a key, makes
+// it current, and re-encrypts everything under it. Running services pick
+// the new key up within a minute. With KMS, point KMS_KEY_ID at the new key,
+// restart the services, then run it. Old master keys must stay available
+// until it finishes.
+package main
+
+import (
+	"cashapp/core"
+	"cashapp/core/database"
+	"cashapp/core/fieldcrypt"
+	ledgermodels "cashapp/internal/ledger/models"
+	usermodels "cashapp/internal/user/models"
+	"flag"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	newKey := flag.Bool("new-key", false, "add a key to the local key file and make it current first")
+	batchSize := flag.Int("batch", 500, "rows per batch")
+	pause := flag.Duration("pause", 200*time.Millisecond, "pause between batches")
+	flag.Parse()
+
+	config := core.NewConfig()
+	core.InitLogger(config.ENVIRONMENT)
+
+	if *newKey {
+		if config.KEY_PROVIDER != "" && config.KEY_PROVIDER != "local" {
+			core.Log.Fatal("-new-key only works with the local key provider; rotate the KMS key and set KMS_KEY_ID instead")
+		}
+		id, err := fieldcrypt.AddKeyfileKey(config.KEYFILE)
+		if err != nil {
+			core.Log.Fatal("failed to add a key", zap.String("keyfile", config.KEYFILE), zap.Error(err))
+		}
+		core.Log.Info("added a new current key", zap.String("key_id", id))
+	}
+
+	provider, err := fieldcrypt.NewKeyProvider(config)
+	if err != nil {
+		core.Log.Fatal("failed to configure field encryption", zap.Error(err))
+	}
+	keyring, err := fieldcrypt.NewKeyring(provider)
+	if err != nil {
+		core.Log.Fatal("failed to configure field encryption", zap.Error(err))
+	}
+	fieldcrypt.SetDefault(keyring)
+
+	pg, err := database.NewPostgres(config)
+	if err != nil {
+		core.Log.Fatal("failed to initialize postgres database", zap.Error(err))
+	}
+
+	tables := append(append([]fieldcrypt.Table{}, usermodels.EncryptedTables...), ledgermodels.EncryptedTables...)
+	for _, t := range tables {
+		if !pg.Migrator().HasTable(t.Name) {
+			core.Log.Info("skipping missing table", zap.String("table", t.Name))
+			continue
+		}
+
+		updated, err := fieldcrypt.Rekey(pg, keyring, t, *batchSize, *pause)
+		if err != nil {
+			core.Log.Fatal("failed to rekey table", zap.String("table", t.Name), zap.Int("updated", updated), zap.Error(err))
+		}
+		core.Log.Info("rekeyed table", zap.String("table", t.Name), zap.Int("updated", updated), zap.String("key_id", provider.CurrentKeyID()))
+	}
+}
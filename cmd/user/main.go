@@ -3,10 +3,22 @@ package main
 import (
 	"cashapp/core"
 	"cashapp/core/database"
+	"cashapp/core/fieldcrypt"
+	"cashapp/core/webhooks"
+	"cashapp/internal/identity"
+	"cashapp/internal/limits"
+	notificationapi "cashapp/internal/notification/api"
+	notificationmodels "cashapp/internal/notification/models"
+	notificationrepository "cashapp/internal/notification/repository"
+	notificationservice "cashapp/internal/notification/service"
+	"cashapp/internal/risk"
+	"cashapp/internal/screening"
+	"cashapp/internal/storage"
 	"cashapp/internal/user/api"
 	"cashapp/internal/user/models"
 	"cashapp/internal/user/repository"
 	"cashapp/internal/user/service"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -21,24 +33,95 @@ func main() {
 	config := core.NewConfig()
 	core.InitLogger(config.ENVIRONMENT)
 
+	if err := fieldcrypt.Init(config); err != nil {
+		core.Log.Fatal("failed to configure field encryption", zap.Error(err))
+	}
+
 	pg, err := database.NewPostgres(config)
 	if err != nil {
 		core.Log.Fatal("failed to initialize postgres database", zap.Error(err))
 	}
 
-	err = database.RunMigrations(pg, &models.User{}, &models.Wallet{}, &models.IdentityDocument{}, &models.FundingSource{}, &models.Friendship{})
+	err = database.RunMigrations(pg, &models.User{}, &models.Wallet{}, &models.IdentityDocument{}, &models.FundingSource{}, &models.Friendship{}, &models.FriendSuggestion{}, &models.SyncState{}, &models.UnsettledTransfer{}, &models.TagChange{}, &models.VerificationCode{}, &models.KYCRequirement{}, &models.Upload{}, &limits.Usage{}, &risk.Assessment{}, &screening.Hit{}, &screening.ListVersion{},
+		&notificationmodels.Notification{}, &notificationmodels.ChannelPreference{}, &notificationmodels.QuietHours{}, &webhooks.InboundEvent{})
 	if err != nil {
 		core.Log.Fatal("failed to run migrations", zap.Error(err))
 	}
 
+	if err := models.BackfillNormalizedTags(pg); err != nil {
+		core.Log.Fatal("failed to normalize cash tags", zap.Error(err))
+	}
+
+	if err := models.FillIndexes(pg); err != nil {
+		core.Log.Fatal("failed to fill in blind indexes", zap.Error(err))
+	}
+
+	if err := models.MigrateLegacyDocumentURLs(pg); err != nil {
+		core.Log.Fatal("failed to encrypt legacy document URLs", zap.Error(err))
+	}
+
+	if err := database.EnsureTrigramIndexes(pg, "users", "tag", "display_name"); err != nil {
+		core.Log.Fatal("failed to create search indexes", zap.Error(err))
+	}
+
 	if config.RUN_SEEDS {
 		models.RunSeeds(pg)
 	}
 
+	rules, err := limits.LoadRules(config.LIMITS_FILE)
+	if err != nil {
+		core.Log.Fatal("failed to load limit rules", zap.Error(err))
+	}
+
+	riskRules, err := risk.LoadRules(config.RISK_FILE)
+	if err != nil {
+		core.Log.Fatal("failed to load risk rules", zap.Error(err))
+	}
+
+	screener, err := screening.New(pg, screening.SplitPaths(config.SCREENING_LISTS), config.SCREENING_THRESHOLD)
+	if err != nil {
+		core.Log.Fatal("failed to load watchlists", zap.Error(err))
+	}
+
+	identityProvider, err := identity.New(config)
+	if err != nil {
+		core.Log.Fatal("failed to configure identity provider", zap.Error(err))
+	}
+
+	blobs, err := storage.New(config)
+	if err != nil {
+		core.Log.Fatal("failed to configure document storage", zap.Error(err))
+	}
+
+	links, err := storage.NewLinkSigner(config)
+	if err != nil {
+		core.Log.Fatal("failed to configure download links", zap.Error(err))
+	}
+
 	repo := repository.New(pg)
 	svc := service.New(repo, config)
+	notifications := notificationservice.New(notificationrepository.New(pg), config)
+	svc.SetNotifier(notifications)
+	svc.SetLimits(limits.New(pg, rules))
+	svc.SetRisk(risk.New(pg, riskRules))
+	svc.SetScreener(screener)
+	svc.SetIdentityProvider(identityProvider)
+	svc.SetDocumentStore(blobs, links)
+	go notifications.RunRetries(30 * time.Second)
+	go svc.RunSuggestionSync(time.Minute)
+	go svc.RunRescreens(10 * time.Minute)
+	go svc.RunIdentitySync(time.Minute)
+	go svc.RunDocumentPurge(time.Hour)
 	server := core.NewHTTPServer(config)
+	limiter := core.NewRateLimiter(database.NewRedis(config))
+	receiver := webhooks.NewReceiver(pg)
 
-	api.RegisterUserRoutes(server.Engine, svc)
+	api.RegisterUserRoutes(server.Engine, svc, limiter)
+	api.RegisterScreeningRoutes(server.Engine, svc, config)
+	api.RegisterKYCRoutes(server.Engine, svc, config)
+	api.RegisterDocumentRoutes(server.Engine, svc)
+	api.RegisterWebhookRoutes(server.Engine, svc, receiver)
+	webhooks.RegisterRoutes(server.Engine, receiver, core.RequireStaff(config))
+	notificationapi.RegisterNotificationRoutes(server.Engine, notifications, api.Authenticate(svc), api.CurrentUserID)
 	server.Start()
 }
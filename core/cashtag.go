@@ -0,0 +1,43 @@
+package core
+
+import "strings"
+
+// reservedTags can't be claimed because they impersonate the service or
+// its staff.
+var reservedTags = map[string]bool{
+	"admin": true, "administrator": true, "api": true, "billing": true,
+	"cash": true, "cashapp": true, "compliance": true, "help": true,
+	"me": true, "moderator": true, "official": true, "payments": true,
+	"root": true, "search": true, "security": true, "staff": true,
+	"support": true, "system": true, "team": true, "verified": true,
+}
+
+// blockedTagTerms may not appear anywhere in a tag.
+var blockedTagTerms = []string{
+	"fuck", "shit", "cunt", "bitch", "nazi", "whore",
+}
+
+// NormalizeTag returns the canonical form of a cash tag used for uniqueness
+// and lookups: no surrounding space, no leading "$", lower case.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "$"))
+}
+
+// DisplayTag strips the optional "$" prefix but keeps the user's casing.
+func DisplayTag(tag string) string {
+	return strings.TrimPrefix(strings.TrimSpace(tag), "$")
+}
+
+// IsReservedTag reports whether a normalized tag is reserved or contains
+// blocked language.
+func IsReservedTag(normalized string) bool {
+	if reservedTags[normalized] {
+		return true
+	}
+	for _, term := range blockedTagTerms {
+		if strings.Contains(normalized, term) {
+			return true
+		}
+	}
+	return false
+}
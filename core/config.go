@@ -2,6 +2,7 @@ package core
 
 import (
 	"log"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,20 +16,57 @@ const (
 )
 
 type Config struct {
-	PG_HOST        string `mapstructure:"PG_HOST"`
-	PG_PORT        string `mapstructure:"PG_PORT"`
-	PG_NAME        string `mapstructure:"PG_NAME"`
-	PG_USER        string `mapstructure:"PG_USER"`
-	PG_PASS        string `mapstructure:"PG_PASS"`
-	PG_SSLMODE     string `mapstructure:"PG_SSLMODE"`
-	REDIS_ADDRESS  string `mapstructure:"REDIS_ADDRESS"`
-	REDIS_PASSWORD string `mapstructure:"REDIS_PASSWORD"`
-	REDIS_DB       int    `mapstructure:"REDIS_DB"`
-	REDIS_URL      string `mapstructure:"REDIS_URL"`
-	DATABASE_URL   string `mapstructure:"DATABASE_URL"`
-	PORT           int    `mapstructure:"PORT"`
-	RUN_SEEDS      bool   `mapstructure:"RUN_SEEDS"`
-	ENVIRONMENT    Environment
+	PG_HOST             string        `mapstructure:"PG_HOST"`
+	PG_PORT             string        `mapstructure:"PG_PORT"`
+	PG_NAME             string        `mapstructure:"PG_NAME"`
+	PG_USER             string        `mapstructure:"PG_USER"`
+	PG_PASS             string        `mapstructure:"PG_PASS"`
+	PG_SSLMODE          string        `mapstructure:"PG_SSLMODE"`
+	REDIS_ADDRESS       string        `mapstructure:"REDIS_ADDRESS"`
+	REDIS_PASSWORD      string        `mapstructure:"REDIS_PASSWORD"`
+	REDIS_DB            int           `mapstructure:"REDIS_DB"`
+	REDIS_URL           string        `mapstructure:"REDIS_URL"`
+	DATABASE_URL        string        `mapstructure:"DATABASE_URL"`
+	PORT                int           `mapstructure:"PORT"`
+	RUN_SEEDS           bool          `mapstructure:"RUN_SEEDS"`
+	OTP_SINK            string        `mapstructure:"OTP_SINK"` // console, file
+	OTP_FILE            string        `mapstructure:"OTP_FILE"`
+	NOTIFY_SINK         string        `mapstructure:"NOTIFY_SINK"` // stdout, file
+	NOTIFY_FILE         string        `mapstructure:"NOTIFY_FILE"`
+	LIMITS_FILE         string        `mapstructure:"LIMITS_FILE"`         // JSON rules per KYC level; built-in defaults when empty
+	CLAIM_EXPIRY        time.Duration `mapstructure:"CLAIM_EXPIRY"`        // how long payments to unregistered contacts wait to be claimed
+	RISK_FILE           string        `mapstructure:"RISK_FILE"`           // JSON risk rules; built-in defaults when empty
+	STAFF_API_KEY       string        `mapstructure:"STAFF_API_KEY"`       // shared key for staff-only routes; unset disables them
+	STAFF_API_KEYS      string        `mapstructure:"STAFF_API_KEYS"`      // comma-separated name:key pairs identifying each staff member
+	SCREENING_LISTS     string        `mapstructure:"SCREENING_LISTS"`     // comma-separated watchlist files, CSV or XML
+	SCREENING_THRESHOLD float64       `mapstructure:"SCREENING_THRESHOLD"` // name match score, 0-1, that queues a hit
+	AML_FILE            string        `mapstructure:"AML_FILE"`            // JSON monitoring rules; built-in defaults when empty
+	IDENTITY_PROVIDER   string        `mapstructure:"IDENTITY_PROVIDER"`   // onfido, stripe, or mock in development
+	IDENTITY_API_URL    string        `mapstructure:"IDENTITY_API_URL"`    // the provider's own API when empty
+	IDENTITY_API_KEY    string        `mapstructure:"IDENTITY_API_KEY"`
+	IDENTITY_SECRET     string        `mapstructure:"IDENTITY_SECRET"`     // verifies the provider's webhook signatures
+	IDENTITY_WORKFLOW   string        `mapstructure:"IDENTITY_WORKFLOW"`   // Onfido Studio workflow ID
+	IDENTITY_RETURN_URL string        `mapstructure:"IDENTITY_RETURN_URL"` // where users land after a session
+	STORAGE_BACKEND     string        `mapstructure:"STORAGE_BACKEND"`     // local, s3
+	STORAGE_DIR         string        `mapstructure:"STORAGE_DIR"`         // where the local backend keeps files
+	STORAGE_KEY         string        `mapstructure:"STORAGE_KEY"`         // 32 bytes, base64; encrypts stored files
+	S3_ENDPOINT         string        `mapstructure:"S3_ENDPOINT"`         // AWS when empty; MinIO's URL in development
+	S3_REGION           string        `mapstructure:"S3_REGION"`
+	S3_BUCKET           string        `mapstructure:"S3_BUCKET"`
+	S3_ACCESS_KEY       string        `mapstructure:"S3_ACCESS_KEY"`
+	S3_SECRET_KEY       string        `mapstructure:"S3_SECRET_KEY"`
+	S3_PATH_STYLE       bool          `mapstructure:"S3_PATH_STYLE"` // bucket in the path rather than the host, for MinIO
+	UPLOAD_MAX_BYTES    int64         `mapstructure:"UPLOAD_MAX_BYTES"`
+	DOCUMENT_RETENTION  time.Duration `mapstructure:"DOCUMENT_RETENTION"` // how long documents are kept after a decision
+	KEY_PROVIDER        string        `mapstructure:"KEY_PROVIDER"`       // local, kms; wraps the keys encrypting PII columns
+	KEYFILE             string        `mapstructure:"KEYFILE"`            // the local provider's key file
+	KMS_ENDPOINT        string        `mapstructure:"KMS_ENDPOINT"`       // AWS when empty
+	KMS_REGION          string        `mapstructure:"KMS_REGION"`
+	KMS_KEY_ID          string        `mapstructure:"KMS_KEY_ID"` // key new data keys are wrapped with
+	KMS_ACCESS_KEY      string        `mapstructure:"KMS_ACCESS_KEY"`
+	KMS_SECRET_KEY      string        `mapstructure:"KMS_SECRET_KEY"`
+	FIELD_INDEX_KEY     string        `mapstructure:"FIELD_INDEX_KEY"` // blind index key, encrypted under KMS_KEY_ID
+	ENVIRONMENT         Environment
 }
 
 func NewConfig() *Config {
@@ -47,6 +85,20 @@ func NewConfig() *Config {
 	viper.SetDefault("PORT", 5454)
 	viper.SetDefault("ENV", "dev")
 	viper.SetDefault("RUN_SEEDS", true)
+	viper.SetDefault("OTP_SINK", "console")
+	viper.SetDefault("OTP_FILE", "otp.log")
+	viper.SetDefault("CLAIM_EXPIRY", "720h")
+	viper.SetDefault("NOTIFY_SINK", "stdout")
+	viper.SetDefault("NOTIFY_FILE", "notifications.log")
+	viper.SetDefault("SCREENING_THRESHOLD", 0.9)
+	viper.SetDefault("STORAGE_BACKEND", "local")
+	viper.SetDefault("STORAGE_DIR", "uploads")
+	viper.SetDefault("S3_REGION", "us-east-1")
+	viper.SetDefault("UPLOAD_MAX_BYTES", 10<<20)
+	viper.SetDefault("DOCUMENT_RETENTION", "43800h") // five years
+	viper.SetDefault("KEY_PROVIDER", "local")
+	viper.SetDefault("KEYFILE", "keys.json")
+	viper.SetDefault("KMS_REGION", "us-east-1")
 
 	if err := viper.ReadInConfig(); err != nil {
 		// It's okay if config file doesn't exist, we might be using ENV vars
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+var (
+	phonePattern   = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+	phoneSeparator = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+)
+
+// NormalizeEmail lower-cases and validates an email address.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", errors.New("invalid email address")
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// NormalizePhone strips formatting from a phone number and requires it in
+// international (E.164) form, e.g. "+233 24 123 4567" becomes "+233241234567".
+func NormalizePhone(phone string) (string, error) {
+	p := phoneSeparator.Replace(strings.TrimSpace(phone))
+	if strings.HasPrefix(p, "00") {
+		p = "+" + p[2:]
+	}
+	if !phonePattern.MatchString(p) {
+		return "", errors.New("phone number must be in international format, e.g. +233241234567")
+	}
+	return p, nil
+}
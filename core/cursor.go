@@ -0,0 +1,52 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultPageSize is used by cursor-paginated endpoints when no limit is given.
+const DefaultPageSize = 20
+
+// Cursor marks a position in a keyset-paginated list. Lists are ordered by
+// descending ID, so rows inserted after the first page is served never shift
+// later pages.
+type Cursor struct {
+	BeforeID int `json:"b"`
+}
+
+// Encode renders the cursor as an opaque token for clients.
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode. An empty token is
+// the start of the list.
+func DecodeCursor(token string) (Cursor, error) {
+	var c Cursor
+	if token == "" {
+		return c, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, Validation(fmt.Errorf("malformed cursor: %v", err))
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.BeforeID <= 0 {
+		return Cursor{}, Validation(fmt.Errorf("malformed cursor"))
+	}
+	return c, nil
+}
+
+// PageSize clamps a client supplied limit to a sane page size.
+func PageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > 100 {
+		return 100
+	}
+	return limit
+}
@@ -2,8 +2,10 @@ package database
 
 import (
 	"cashapp/core"
+	"errors"
 	"fmt"
 
+	"github.com/jackc/pgconn"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -13,6 +15,30 @@ func RunMigrations(db *gorm.DB, models ...interface{}) error {
 	return err
 }
 
+// EnsureTrigramIndexes enables pg_trgm and adds a GIN trigram index on the
+// lower-cased form of each column, for fuzzy and prefix search.
+func EnsureTrigramIndexes(db *gorm.DB, table string, columns ...string) error {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return err
+	}
+
+	for _, column := range columns {
+		stmt := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s_trgm ON %s USING gin (lower(%s) gin_trgm_ops)", table, column, table, column)
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsUniqueViolation reports whether err is Postgres rejecting a write that
+// would break a unique constraint, typically two requests racing for the
+// same value.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
 func GeneratePostgresURI(config *core.Config) string {
 	var (
 		dbUrl    = config.DATABASE_URL
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrorCode is the stable, machine-readable identifier returned to clients
+// alongside every failed response.
+type ErrorCode string
+
+const (
+	ErrCodeValidation        ErrorCode = "validation_failed"
+	ErrCodeUnauthorized      ErrorCode = "unauthorized"
+	ErrCodeForbidden         ErrorCode = "forbidden"
+	ErrCodeNotFound          ErrorCode = "not_found"
+	ErrCodeConflict          ErrorCode = "conflict"
+	ErrCodeInsufficientFunds ErrorCode = "insufficient_funds"
+	ErrCodeLimitExceeded     ErrorCode = "limit_exceeded"
+	ErrCodeRiskBlocked       ErrorCode = "risk_blocked"
+	ErrCodeStepUpRequired    ErrorCode = "step_up_required"
+	ErrCodeHeldForReview     ErrorCode = "held_for_review"
+	ErrCodeRateLimited       ErrorCode = "rate_limited"
+	ErrCodeUpstream          ErrorCode = "upstream_error"
+	ErrCodeInternal          ErrorCode = "internal_error"
+)
+
+var statusByCode = map[ErrorCode]int{
+	ErrCodeValidation:        http.StatusBadRequest,
+	ErrCodeUnauthorized:      http.StatusUnauthorized,
+	ErrCodeForbidden:         http.StatusForbidden,
+	ErrCodeNotFound:          http.StatusNotFound,
+	ErrCodeConflict:          http.StatusConflict,
+	ErrCodeInsufficientFunds: http.StatusUnprocessableEntity,
+	ErrCodeLimitExceeded:     http.StatusUnprocessableEntity,
+	ErrCodeRiskBlocked:       http.StatusForbidden,
+	ErrCodeStepUpRequired:    http.StatusPreconditionRequired,
+	ErrCodeHeldForReview:     http.StatusAccepted,
+	ErrCodeRateLimited:       http.StatusTooManyRequests,
+	ErrCodeUpstream:          http.StatusBadGateway,
+	ErrCodeInternal:          http.StatusInternalServerError,
+}
+
+// HTTPStatus maps an error code to the status code it is served with.
+func (c ErrorCode) HTTPStatus() int {
+	if status, ok := statusByCode[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// AppError is a domain error carrying the code it should be reported with.
+// Services return these so the HTTP layer never has to guess a status.
+type AppError struct {
+	Code    ErrorCode
+	Err     error
+	Details interface{}
+}
+
+func (e *AppError) Error() string {
+	if e.Err == nil {
+		return string(e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+func newAppError(code ErrorCode, err error) *AppError {
+	return &AppError{Code: code, Err: err}
+}
+
+func Validation(err error) error        { return newAppError(ErrCodeValidation, err) }
+func Unauthorized(err error) error      { return newAppError(ErrCodeUnauthorized, err) }
+func Forbidden(err error) error         { return newAppError(ErrCodeForbidden, err) }
+func NotFound(err error) error          { return newAppError(ErrCodeNotFound, err) }
+func Conflict(err error) error          { return newAppError(ErrCodeConflict, err) }
+func InsufficientFunds(err error) error { return newAppError(ErrCodeInsufficientFunds, err) }
+func RateLimited(err error) error       { return newAppError(ErrCodeRateLimited, err) }
+func Upstream(err error) error          { return newAppError(ErrCodeUpstream, err) }
+
+// LimitExceeded reports a transaction limit breach; details describe the
+// limit and the headroom left under it.
+func LimitExceeded(err error, details interface{}) error {
+	return &AppError{Code: ErrCodeLimitExceeded, Err: err, Details: details}
+}
+
+// RiskDeclined reports a movement of money that risk checks did not allow
+// straight through. code is one of ErrCodeRiskBlocked, ErrCodeStepUpRequired
+// or ErrCodeHeldForReview.
+func RiskDeclined(code ErrorCode, err error, details interface{}) error {
+	return &AppError{Code: code, Err: err, Details: details}
+}
+
+// CodeOf returns the code of the first AppError in err's chain, or
+// ErrCodeInternal when err carries no domain information.
+func CodeOf(err error) ErrorCode {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return ErrCodeInternal
+}
+
+// DetailsOf returns the details attached to the first AppError in err's chain.
+func DetailsOf(err error) interface{} {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.Details
+	}
+	return nil
+}
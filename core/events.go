@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Notification events raised by the services. Each has a template in the
+// notification service.
+const (
+	EventPaymentReceived     = "payment.received"
+	EventRequestCreated      = "request.created"
+	EventRequestReminder     = "request.reminder"
+	EventKYCResult           = "kyc.result"
+	EventKYCDocumentRejected = "kyc.document_rejected"
+	EventDepositSettled      = "deposit.settled"
+	EventCommentCreated      = "comment.created"
+	EventClaimCompleted      = "claim.completed"
+	EventClaimRefunded       = "claim.refunded"
+	EventWebhookDisabled     = "webhook.disabled"
+)
+
+// Notifier delivers user-facing notifications. The notification service
+// implements it; LogNotifier stands in where none is wired up.
+type Notifier interface {
+	Notify(userID int, event string, data map[string]interface{})
+}
+
+// LogNotifier only logs each notification.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(userID int, event string, data map[string]interface{}) {
+	Log.Info("notification raised without a notifier", zap.Int("user_id", userID), zap.String("event", event), zap.Any("data", data))
+}
+
+// FormatAmount renders an amount in minor units as "12.50".
+func FormatAmount(minor int64) string {
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/100, minor%100)
+}
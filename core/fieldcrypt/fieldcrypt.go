@@ -0,0 +1,278 @@
+// Package fieldcrypt encrypts sensitive columns with envelope encryption.
+// Each value is sealed with AES-256-GCM under a data key, and the data key,
+// wrapped under a master key held by a KeyProvider, is stored alongside it.
+// Equality lookups on encrypted columns go through blind indexes: a keyed
+// hash of the value kept in a column of its own.
+package fieldcrypt
+
+import (
+	"cashapp/core"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// version starts every encrypted value. Anything without it is read as
+	// plaintext written before the column was encrypted.
+	version = "v1"
+	// dataKeyTTL is how long one data key encrypts new values before the
+	// provider is asked for another.
+	dataKeyTTL = time.Hour
+	// maxCachedKeys bounds the unwrapped data keys kept to save a call to
+	// the provider on every read.
+	maxCachedKeys = 4096
+)
+
+var (
+	ErrNotConfigured = errors.New("field encryption is not configured")
+	errMalformed     = errors.New("malformed encrypted value")
+)
+
+// Keyring encrypts and decrypts values with data keys from a KeyProvider.
+type Keyring struct {
+	provider KeyProvider
+	index    []byte
+
+	mu       sync.Mutex
+	current  *dataKey
+	fetching *keyFetch         // a data key being fetched, if any
+	cache    map[string][]byte // unwrapped data keys by key ID and wrapped key
+}
+
+// keyFetch is a request to the provider for a new data key, shared by
+// everyone who needs one while it is in flight.
+type keyFetch struct {
+	keyID string
+	done  chan struct{}
+	dk    *dataKey
+	err   error
+}
+
+type dataKey struct {
+	keyID     string
+	plaintext []byte
+	wrapped   []byte
+	expires   time.Time
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultKeyring *Keyring
+)
+
+// Init sets up the keyring String columns use, from KEY_PROVIDER. Call it
+// before touching the database.
+func Init(config *core.Config) error {
+	provider, err := NewKeyProvider(config)
+	if err != nil {
+		return err
+	}
+	k, err := NewKeyring(provider)
+	if err != nil {
+		return err
+	}
+	SetDefault(k)
+	return nil
+}
+
+func NewKeyring(provider KeyProvider) (*Keyring, error) {
+	index, err := provider.IndexKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(index) != 32 {
+		return nil, errors.New("blind index key must be 32 bytes")
+	}
+	return &Keyring{provider: provider, index: index, cache: map[string][]byte{}}, nil
+}
+
+// SetDefault replaces the keyring String columns use.
+func SetDefault(k *Keyring) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultKeyring = k
+}
+
+// Default returns the keyring set by Init, or nil.
+func Default() *Keyring {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultKeyring
+}
+
+// Encrypt seals plaintext as
+// "v1.<master key ID>.<wrapped data key>.<nonce and ciphertext>", each part
+// base64 encoded. The header is authenticated along with the value.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if k == nil {
+		return "", ErrNotConfigured
+	}
+	dk, err := k.dataKey()
+	if err != nil {
+		return "", err
+	}
+
+	header := version + "." + encode([]byte(dk.keyID)) + "." + encode(dk.wrapped)
+	sealed, err := seal(dk.plaintext, []byte(plaintext), []byte(header))
+	if err != nil {
+		return "", err
+	}
+	return header + "." + encode(sealed), nil
+}
+
+// Decrypt opens a value sealed by Encrypt. Plaintext left over from before
+// a column was encrypted comes back unchanged.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrNotConfigured
+	}
+
+	keyID, wrapped, sealed, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	key, err := k.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(key, sealed, []byte(value[:strings.LastIndexByte(value, '.')]))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Stale reports whether value needs encrypting again: it is plaintext, or
+// its data key is wrapped under a master key that is no longer current.
+func (k *Keyring) Stale(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	keyID, _, _, err := parse(value)
+	return err != nil || keyID != k.provider.CurrentKeyID()
+}
+
+// BlindIndex returns a keyed hash of value for equality lookups. scope,
+// such as "users.email", keeps equal values in different columns from
+// hashing alike.
+func (k *Keyring) BlindIndex(scope, value string) (string, error) {
+	if k == nil {
+		return "", ErrNotConfigured
+	}
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(scope))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// dataKey returns the data key for new values, fetching a new one when it
+// has expired or the master key has rotated. The provider may be a network
+// call away, so k.mu isn't held while fetching: decryption carries on, and
+// callers needing the new key wait on one shared fetch.
+func (k *Keyring) dataKey() (*dataKey, error) {
+	keyID := k.provider.CurrentKeyID()
+
+	k.mu.Lock()
+	if dk := k.current; dk != nil && dk.keyID == keyID && time.Now().Before(dk.expires) {
+		k.mu.Unlock()
+		return dk, nil
+	}
+	if f := k.fetching; f != nil && f.keyID == keyID {
+		k.mu.Unlock()
+		<-f.done
+		return f.dk, f.err
+	}
+	f := &keyFetch{keyID: keyID, done: make(chan struct{})}
+	k.fetching = f
+	k.mu.Unlock()
+
+	plaintext, wrapped, err := k.provider.GenerateDataKey(keyID)
+
+	k.mu.Lock()
+	if err != nil {
+		f.err = err
+	} else {
+		f.dk = &dataKey{keyID: keyID, plaintext: plaintext, wrapped: wrapped, expires: time.Now().Add(dataKeyTTL)}
+		k.current = f.dk
+		k.remember(keyID, wrapped, plaintext)
+	}
+	if k.fetching == f {
+		k.fetching = nil
+	}
+	k.mu.Unlock()
+	close(f.done)
+	return f.dk, f.err
+}
+
+func (k *Keyring) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	cacheKey := keyID + "." + string(wrapped)
+
+	k.mu.Lock()
+	key, ok := k.cache[cacheKey]
+	k.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	key, err := k.provider.Decrypt(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	k.remember(keyID, wrapped, key)
+	k.mu.Unlock()
+	return key, nil
+}
+
+// remember caches an unwrapped data key. k.mu must be held.
+func (k *Keyring) remember(keyID string, wrapped, key []byte) {
+	if len(k.cache) >= maxCachedKeys {
+		k.cache = map[string][]byte{}
+	}
+	k.cache[keyID+"."+string(wrapped)] = key
+}
+
+// IsEncrypted reports whether value looks like the output of Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, version+".") && strings.Count(value, ".") == 3
+}
+
+func parse(value string) (keyID string, wrapped, sealed []byte, err error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 4 || parts[0] != version {
+		return "", nil, nil, errMalformed
+	}
+	id, err := decode(parts[1])
+	if err != nil {
+		return "", nil, nil, errMalformed
+	}
+	if wrapped, err = decode(parts[2]); err != nil {
+		return "", nil, nil, errMalformed
+	}
+	if sealed, err = decode(parts[3]); err != nil {
+		return "", nil, nil, errMalformed
+	}
+	return string(id), wrapped, sealed, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
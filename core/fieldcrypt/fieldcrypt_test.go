@@ -0,0 +1,337 @@
+package fieldcrypt
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestKeyring returns a keyring over a fresh key file, and the file's
+// path.
+func newTestKeyring(t *testing.T) (*Keyring, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := createKeyfile(path); err != nil {
+		t.Fatal(err)
+	}
+	return openTestKeyring(t, path), path
+}
+
+func openTestKeyring(t *testing.T, path string) *Keyring {
+	t.Helper()
+	provider, err := OpenKeyfile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewKeyring(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	k, _ := newTestKeyring(t)
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"empty", ""},
+		{"email", "ama@example.com"},
+		{"phone", "+233201234567"},
+		{"unicode", "Kwame Nkrumah — Ɔsagyefo"},
+		{"dots", "a.b.c.d"},
+		{"long", strings.Repeat("x", 10000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := k.Encrypt(tt.plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsEncrypted(encrypted) {
+				t.Fatalf("IsEncrypted(%q) = false", encrypted)
+			}
+			if tt.plaintext != "" && strings.Contains(encrypted, tt.plaintext) {
+				t.Fatalf("ciphertext %q contains the plaintext", encrypted)
+			}
+
+			decrypted, err := k.Decrypt(encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decrypted != tt.plaintext {
+				t.Fatalf("Decrypt = %q, want %q", decrypted, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestEncryptUsesFreshNonces(t *testing.T) {
+	k, _ := newTestKeyring(t)
+
+	a, err := k.Encrypt("same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := k.Encrypt("same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Fatal("encrypting the same value twice gave the same ciphertext")
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	k, _ := newTestKeyring(t)
+	encrypted, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(encrypted, ".")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plaintext passes through", "ama@example.com", "ama@example.com", false},
+		{"empty", "", "", false},
+		{"encrypted", encrypted, "secret", false},
+		{"tampered ciphertext", strings.Join([]string{parts[0], parts[1], parts[2], flip(parts[3])}, "."), "", true},
+		{"tampered key ID", strings.Join([]string{parts[0], encode([]byte("other")), parts[2], parts[3]}, "."), "", true},
+		{"swapped data key", strings.Join([]string{parts[0], parts[1], flip(parts[2]), parts[3]}, "."), "", true},
+		{"bad base64", strings.Join([]string{parts[0], parts[1], parts[2], "!!!"}, "."), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.Decrypt(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decrypt error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("Decrypt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptWithoutKeyring(t *testing.T) {
+	var k *Keyring
+
+	if got, err := k.Decrypt("plain"); err != nil || got != "plain" {
+		t.Fatalf("Decrypt of plaintext = %q, %v", got, err)
+	}
+	if _, err := k.Decrypt("v1.a.b.c"); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("Decrypt error = %v, want ErrNotConfigured", err)
+	}
+	if _, err := k.Encrypt("x"); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("Encrypt error = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	k, path := newTestKeyring(t)
+	old, err := k.Encrypt("before rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AddKeyfileKey(path); err != nil {
+		t.Fatal(err)
+	}
+	rotated := openTestKeyring(t, path)
+	fresh, err := rotated.Encrypt("after rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		value     string
+		want      string
+		wantStale bool
+	}{
+		{"under the old key", old, "before rotation", true},
+		{"under the new key", fresh, "after rotation", false},
+		{"plaintext", "never encrypted", "never encrypted", true},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rotated.Stale(tt.value); got != tt.wantStale {
+				t.Fatalf("Stale = %v, want %v", got, tt.wantStale)
+			}
+			got, err := rotated.Decrypt(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("Decrypt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A keyring that loaded the file before the rotation finds the new key
+	// when it meets a value wrapped under it.
+	if got, err := k.Decrypt(fresh); err != nil || got != "after rotation" {
+		t.Fatalf("Decrypt with the old keyring = %q, %v", got, err)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	k, path := newTestKeyring(t)
+	other := openTestKeyring(t, path)
+	unrelated, _ := newTestKeyring(t)
+
+	index := func(k *Keyring, scope, value string) string {
+		t.Helper()
+		got, err := k.BlindIndex(scope, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	base := index(k, "users.email", "ama@example.com")
+
+	tests := []struct {
+		name  string
+		k     *Keyring
+		scope string
+		value string
+		same  bool
+	}{
+		{"same value and scope", k, "users.email", "ama@example.com", true},
+		{"another keyring over the same key", other, "users.email", "ama@example.com", true},
+		{"different value", k, "users.email", "kofi@example.com", false},
+		{"different scope", k, "users.phone", "ama@example.com", false},
+		{"scope and value boundary", k, "users.emailama@example.com", "", false},
+		{"different index key", unrelated, "users.email", "ama@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := index(tt.k, tt.scope, tt.value); (got == base) != tt.same {
+				t.Fatalf("index %q against %q: same = %v, want %v", got, base, got == base, tt.same)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	k, _ := newTestKeyring(t)
+	previous := Default()
+	SetDefault(k)
+	defer SetDefault(previous)
+
+	tests := []struct {
+		name  string
+		value String
+	}{
+		{"empty stays empty", ""},
+		{"value", "ama@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored, err := tt.value.Value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.value == "" && stored != "" {
+				t.Fatalf("Value = %q, want empty", stored)
+			}
+			if tt.value != "" && !IsEncrypted(stored.(string)) {
+				t.Fatalf("Value = %q, want ciphertext", stored)
+			}
+
+			var scanned String
+			if err := scanned.Scan([]byte(stored.(string))); err != nil {
+				t.Fatal(err)
+			}
+			if scanned != tt.value {
+				t.Fatalf("Scan = %q, want %q", scanned, tt.value)
+			}
+		})
+	}
+
+	var scanned String = "left over"
+	if err := scanned.Scan(nil); err != nil || scanned != "" {
+		t.Fatalf("Scan(nil) = %q, %v", scanned, err)
+	}
+}
+
+// slowProvider wraps a provider, holding GenerateDataKey until release is
+// closed.
+type slowProvider struct {
+	KeyProvider
+	release chan struct{}
+	calls   int32
+}
+
+func (p *slowProvider) GenerateDataKey(keyID string) ([]byte, []byte, error) {
+	atomic.AddInt32(&p.calls, 1)
+	<-p.release
+	return p.KeyProvider.GenerateDataKey(keyID)
+}
+
+func TestDataKeyFetchDoesNotBlockDecrypt(t *testing.T) {
+	k, path := newTestKeyring(t)
+	encrypted, err := k.Encrypt("cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := OpenKeyfile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow := &slowProvider{KeyProvider: base, release: make(chan struct{})}
+	k.provider = slow
+	k.current.expires = time.Now() // force a fetch
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := k.Encrypt("new"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// Decrypting with a cached data key doesn't wait on the fetch.
+	done := make(chan error, 1)
+	go func() {
+		_, err := k.Decrypt(encrypted)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Decrypt waited on the data key fetch")
+	}
+
+	close(slow.release)
+	wg.Wait()
+	if calls := atomic.LoadInt32(&slow.calls); calls != 1 {
+		t.Fatalf("GenerateDataKey called %d times, want 1", calls)
+	}
+}
+
+// flip changes the first character of a base64 string.
+func flip(s string) string {
+	if s[0] == 'A' {
+		return "B" + s[1:]
+	}
+	return "A" + s[1:]
+}
@@ -0,0 +1,261 @@
+package fieldcrypt
+
+import (
+	"cashapp/core"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// keyfileRecheck is how often a Keyfile looks for a new current key, so
+// running services start using a rotated key without a restart.
+const keyfileRecheck = 30 * time.Second
+
+// keyfileData is the key file's format. Keys are 32 bytes, base64 encoded.
+type keyfileData struct {
+	Current  string            `json:"current"`
+	Keys     map[string]string `json:"keys"`
+	IndexKey string            `json:"index_key"`
+}
+
+// Keyfile is a KeyProvider backed by a JSON file of master keys, for
+// development. Anyone who can read the file can read every encrypted
+// column.
+type Keyfile struct {
+	path string
+
+	mu      sync.Mutex
+	keys    map[string][]byte
+	current string
+	index   []byte
+	modTime time.Time
+	checked time.Time
+}
+
+// OpenKeyfile loads the key file at path. When create is set and there is
+// no file yet, one is written with a fresh key.
+func OpenKeyfile(path string, create bool) (*Keyfile, error) {
+	k := &Keyfile{path: path}
+	err := k.load()
+	if errors.Is(err, os.ErrNotExist) && create {
+		if err = createKeyfile(path); err != nil {
+			return nil, err
+		}
+		core.Log.Warn("created a new key file for field encryption", zap.String("path", path))
+		err = k.load()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *Keyfile) CurrentKeyID() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.refresh(false)
+	return k.current
+}
+
+func (k *Keyfile) GenerateDataKey(keyID string) ([]byte, []byte, error) {
+	master, err := k.key(keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey, err := randomKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	wrapped, err := seal(master, dataKey, []byte(keyID))
+	if err != nil {
+		return nil, nil, err
+	}
+	return dataKey, wrapped, nil
+}
+
+func (k *Keyfile) Decrypt(keyID string, wrapped []byte) ([]byte, error) {
+	master, err := k.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return open(master, wrapped, []byte(keyID))
+}
+
+func (k *Keyfile) IndexKey() ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.index, nil
+}
+
+// key returns the master key keyID, reading the file again if it's new to
+// us: another process may have rotated.
+func (k *Keyfile) key(keyID string) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.keys[keyID]; ok {
+		return key, nil
+	}
+	k.refresh(true)
+	if key, ok := k.keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key %q is not in %s", keyID, k.path)
+}
+
+// refresh reloads the file if it changed. k.mu must be held.
+func (k *Keyfile) refresh(force bool) {
+	if !force && time.Since(k.checked) < keyfileRecheck {
+		return
+	}
+	k.checked = time.Now()
+
+	info, err := os.Stat(k.path)
+	if err != nil || info.ModTime().Equal(k.modTime) {
+		return
+	}
+	if err := k.loadLocked(); err != nil {
+		core.Log.Error("failed to reload key file", zap.String("path", k.path), zap.Error(err))
+	}
+}
+
+func (k *Keyfile) load() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.loadLocked()
+}
+
+func (k *Keyfile) loadLocked() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return err
+	}
+	data, err := readKeyfile(k.path)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string][]byte, len(data.Keys))
+	for id, encoded := range data.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("%s: key %q must be 32 bytes, base64 encoded", k.path, id)
+		}
+		keys[id] = key
+	}
+	if _, ok := keys[data.Current]; !ok {
+		return fmt.Errorf("%s: current key %q is not in keys", k.path, data.Current)
+	}
+	index, err := base64.StdEncoding.DecodeString(data.IndexKey)
+	if err != nil || len(index) != 32 {
+		return fmt.Errorf("%s: index_key must be 32 bytes, base64 encoded", k.path)
+	}
+
+	k.keys, k.current, k.index = keys, data.Current, index
+	k.modTime, k.checked = info.ModTime(), time.Now()
+	return nil
+}
+
+// AddKeyfileKey adds a new master key to the key file at path and makes it
+// current, returning its ID. Older keys stay so existing data still opens.
+func AddKeyfileKey(path string) (string, error) {
+	data, err := readKeyfile(path)
+	if err != nil {
+		return "", err
+	}
+	key, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+
+	id := newKeyID()
+	data.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	data.Current = id
+	return id, writeKeyfile(path, data, false)
+}
+
+func createKeyfile(path string) error {
+	key, err := randomKey()
+	if err != nil {
+		return err
+	}
+	index, err := randomKey()
+	if err != nil {
+		return err
+	}
+
+	id := newKeyID()
+	err = writeKeyfile(path, &keyfileData{
+		Current:  id,
+		Keys:     map[string]string{id: base64.StdEncoding.EncodeToString(key)},
+		IndexKey: base64.StdEncoding.EncodeToString(index),
+	}, true)
+	if errors.Is(err, os.ErrExist) {
+		// Another service sharing the file created it first.
+		return nil
+	}
+	return err
+}
+
+func readKeyfile(path string) (*keyfileData, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var data keyfileData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if data.Keys == nil {
+		data.Keys = map[string]string{}
+	}
+	return &data, nil
+}
+
+// writeKeyfile writes the file in one step, so readers never see half of
+// it. With exclusive set it fails with os.ErrExist rather than replace an
+// existing file.
+func writeKeyfile(path string, data *keyfileData, exclusive bool) error {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".keys-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if exclusive {
+		return os.Link(tmp.Name(), path)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// newKeyID names a key after the day it was made, with a random suffix so
+// two made the same day differ.
+func newKeyID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return "local-" + time.Now().UTC().Format("20060102") + "-" + hex.EncodeToString(suffix)
+}
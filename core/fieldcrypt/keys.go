@@ -0,0 +1,82 @@
+package fieldcrypt
+
+import (
+	"cashapp/core"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeyProvider holds the master keys. It never hands them out: it wraps
+// fresh data keys under the current one and unwraps data keys wrapped under
+// any it still has, the way a KMS does.
+type KeyProvider interface {
+	// CurrentKeyID names the master key new data keys are wrapped with.
+	CurrentKeyID() string
+	// GenerateDataKey returns a new 32-byte data key, in the clear and
+	// wrapped under the master key keyID.
+	GenerateDataKey(keyID string) (plaintext, wrapped []byte, err error)
+	// Decrypt unwraps a data key wrapped under keyID.
+	Decrypt(keyID string, wrapped []byte) ([]byte, error)
+	// IndexKey returns the key blind indexes are computed with. Unlike the
+	// master keys it never rotates, or every index would have to be
+	// rebuilt.
+	IndexKey() ([]byte, error)
+}
+
+// NewKeyProvider returns the provider selected by KEY_PROVIDER, local (the
+// default) or kms.
+func NewKeyProvider(config *core.Config) (KeyProvider, error) {
+	switch config.KEY_PROVIDER {
+	case "", "local":
+		return OpenKeyfile(config.KEYFILE, config.ENVIRONMENT == core.Development)
+	case "kms":
+		return NewKMS(config.KMS_ENDPOINT, config.KMS_REGION, config.KMS_KEY_ID, config.KMS_ACCESS_KEY, config.KMS_SECRET_KEY, config.FIELD_INDEX_KEY)
+	default:
+		return nil, fmt.Errorf("unknown key provider %q", config.KEY_PROVIDER)
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext under key, returning the nonce followed by the
+// ciphertext.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, sealed, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], aad)
+}
+
+func randomKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,137 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"cashapp/core/awsv4"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var kmsClient = &http.Client{Timeout: 10 * time.Second}
+
+// KMS is a KeyProvider backed by AWS KMS, or anything that speaks its API.
+// The master keys never leave it.
+type KMS struct {
+	endpoint string
+	region   string
+	keyID    string
+	creds    awsv4.Credentials
+	index    []byte
+}
+
+// NewKMS wraps data keys under keyID. indexKey is the blind index key as
+// KMS ciphertext, base64 encoded; it is decrypted once here.
+func NewKMS(endpoint, region, keyID, accessKey, secretKey, indexKey string) (*KMS, error) {
+	if keyID == "" {
+		return nil, errors.New("KMS_KEY_ID is required for the kms key provider")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = "https://kms." + region + ".amazonaws.com"
+	}
+	if u, err := url.Parse(endpoint); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid KMS_ENDPOINT %q", endpoint)
+	}
+
+	k := &KMS{
+		endpoint: strings.TrimRight(endpoint, "/") + "/",
+		region:   region,
+		keyID:    keyID,
+		creds:    awsv4.Credentials{AccessKey: accessKey, SecretKey: secretKey},
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(indexKey)
+	if err != nil || indexKey == "" {
+		return nil, errors.New("FIELD_INDEX_KEY must be a base64 KMS ciphertext")
+	}
+	if k.index, err = k.Decrypt(keyID, wrapped); err != nil {
+		return nil, fmt.Errorf("failed to decrypt FIELD_INDEX_KEY: %w", err)
+	}
+	if len(k.index) != 32 {
+		return nil, errors.New("FIELD_INDEX_KEY must decrypt to 32 bytes")
+	}
+	return k, nil
+}
+
+func (k *KMS) CurrentKeyID() string {
+	return k.keyID
+}
+
+func (k *KMS) GenerateDataKey(keyID string) ([]byte, []byte, error) {
+	var out struct {
+		CiphertextBlob []byte
+		Plaintext      []byte
+	}
+	err := k.call("GenerateDataKey", map[string]interface{}{
+		"KeyId":   keyID,
+		"KeySpec": "AES_256",
+	}, &out)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+func (k *KMS) Decrypt(keyID string, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte
+	}
+	err := k.call("Decrypt", map[string]interface{}{
+		"KeyId":          keyID,
+		"CiphertextBlob": wrapped,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+func (k *KMS) IndexKey() ([]byte, error) {
+	return k.index, nil
+}
+
+// call makes a KMS API request. []byte fields travel as base64, which is
+// what encoding/json does with them anyway.
+func (k *KMS) call(action string, in interface{}, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, k.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	awsv4.Sign(req, body, k.creds, k.region, "kms", time.Now())
+
+	resp, err := kmsClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("kms %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("kms %s: %w", action, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(raw, &failure)
+		return fmt.Errorf("kms %s: %s: %s %s", action, resp.Status, failure.Type, failure.Message)
+	}
+	return json.Unmarshal(raw, out)
+}
@@ -0,0 +1,202 @@
+package fieldcrypt
+
+import (
+	"cashapp/core"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Column is an encrypted column and, if it has one, the column holding its
+// blind index.
+type Column struct {
+	Name  string
+	Index string
+}
+
+// Table lists a table's encrypted columns. A column's blind index is scoped
+// to "table.column".
+type Table struct {
+	Name    string
+	Columns []Column
+}
+
+// Scope is the blind index scope of column.
+func (t Table) Scope(column string) string {
+	return t.Name + "." + column
+}
+
+// Rekey walks t in batches, encrypting under the current master key every
+// value that is plaintext or under an older one, and filling in missing
+// blind indexes. It pauses between batches so the services sharing the
+// database aren't starved. A row changed while it was being rekeyed is
+// left alone; its new value is already under the current key. It returns
+// the number of rows updated.
+func Rekey(db *gorm.DB, k *Keyring, t Table, batchSize int, pause time.Duration) (int, error) {
+	columns := []string{"id"}
+	for _, c := range t.Columns {
+		columns = append(columns, c.Name)
+		if c.Index != "" {
+			columns = append(columns, c.Index)
+		}
+	}
+
+	updated, lastID := 0, 0
+	for {
+		rows, err := db.Table(t.Name).Select(columns).Where("id > ?", lastID).Order("id").Limit(batchSize).Rows()
+		if err != nil {
+			return updated, err
+		}
+
+		var batch [][]sql.NullString
+		for rows.Next() {
+			values := make([]sql.NullString, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return updated, err
+			}
+			batch = append(batch, values)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, err
+		}
+		if len(batch) == 0 {
+			return updated, nil
+		}
+
+		for _, values := range batch {
+			id, err := strconv.Atoi(values[0].String)
+			if err != nil {
+				return updated, err
+			}
+			lastID = id
+
+			changed, err := rekeyRow(db, k, t, id, values[1:])
+			if err != nil {
+				return updated, err
+			}
+			if changed {
+				updated++
+			}
+		}
+
+		core.Log.Info("rekeyed batch", zap.String("table", t.Name), zap.Int("last_id", lastID), zap.Int("updated", updated))
+		time.Sleep(pause)
+	}
+}
+
+// rekeyRow updates one row; values follow t.Columns, each column followed
+// by its index column if it has one.
+func rekeyRow(db *gorm.DB, k *Keyring, t Table, id int, values []sql.NullString) (bool, error) {
+	updates := map[string]interface{}{}
+	query := db.Table(t.Name).Where("id = ?", id)
+
+	i := 0
+	for _, c := range t.Columns {
+		value := values[i]
+		i++
+		var index sql.NullString
+		if c.Index != "" {
+			index = values[i]
+			i++
+		}
+
+		if !value.Valid || value.String == "" {
+			continue
+		}
+		stale := k.Stale(value.String)
+		if !stale && (c.Index == "" || index.Valid) {
+			continue
+		}
+
+		plaintext, err := k.Decrypt(value.String)
+		if err != nil {
+			core.Log.Error("failed to decrypt value for rekeying", zap.String("table", t.Name), zap.String("column", c.Name), zap.Int("id", id), zap.Error(err))
+			continue
+		}
+		// Whatever is written was derived from the value read, so it only
+		// lands if the value hasn't changed since.
+		query = query.Where(c.Name+" = ?", value.String)
+		if stale {
+			encrypted, err := k.Encrypt(plaintext)
+			if err != nil {
+				return false, err
+			}
+			updates[c.Name] = encrypted
+		}
+		if c.Index != "" {
+			if updates[c.Index], err = k.BlindIndex(t.Scope(c.Name), plaintext); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if len(updates) == 0 {
+		return false, nil
+	}
+	result := query.Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// FillIndexes fills in the blind indexes missing from t, as on rows written
+// before their column was encrypted, without re-encrypting anything.
+// Lookups by blind index miss those rows until it has run, so services run
+// it at startup; once every index is filled it only costs a query per
+// column. It returns the number of rows updated.
+func FillIndexes(db *gorm.DB, k *Keyring, t Table, batchSize int) (int, error) {
+	updated := 0
+	for _, c := range t.Columns {
+		if c.Index == "" {
+			continue
+		}
+
+		lastID := 0
+		for {
+			var rows []struct {
+				ID    int
+				Value string
+			}
+			err := db.Table(t.Name).
+				Select("id, "+c.Name+" AS value").
+				Where("id > ? AND "+c.Index+" IS NULL AND "+c.Name+" IS NOT NULL AND "+c.Name+" <> ''", lastID).
+				Order("id").Limit(batchSize).
+				Scan(&rows).Error
+			if err != nil {
+				return updated, err
+			}
+			if len(rows) == 0 {
+				break
+			}
+
+			for _, row := range rows {
+				lastID = row.ID
+				plaintext, err := k.Decrypt(row.Value)
+				if err != nil {
+					core.Log.Error("failed to decrypt value for indexing", zap.String("table", t.Name), zap.String("column", c.Name), zap.Int("id", row.ID), zap.Error(err))
+					continue
+				}
+				index, err := k.BlindIndex(t.Scope(c.Name), plaintext)
+				if err != nil {
+					return updated, err
+				}
+				// A row saved meanwhile has its index set already.
+				result := db.Table(t.Name).
+					Where("id = ? AND "+c.Name+" = ? AND "+c.Index+" IS NULL", row.ID, row.Value).
+					Update(c.Index, index)
+				if result.Error != nil {
+					return updated, result.Error
+				}
+				updated += int(result.RowsAffected)
+			}
+		}
+	}
+	return updated, nil
+}
@@ -0,0 +1,69 @@
+package fieldcrypt
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// String is a string column stored encrypted with the default keyring.
+// Code works with the plaintext; only the database sees ciphertext. Empty
+// strings are stored as they are, so checks for an empty column still work.
+type String string
+
+func (s String) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+	return Default().Encrypt(string(s))
+}
+
+func (s *String) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into an encrypted string", src)
+	}
+
+	plaintext, err := Default().Decrypt(value)
+	if err != nil {
+		return err
+	}
+	*s = String(plaintext)
+	return nil
+}
+
+// GormDataType keeps the column text whatever size the ciphertext grows to.
+func (String) GormDataType() string {
+	return "text"
+}
+
+// Ptr returns a pointer to s, for nullable columns.
+func Ptr(s string) *String {
+	v := String(s)
+	return &v
+}
+
+// Index returns the default keyring's blind index of value. scope names
+// the column, such as "users.email".
+func Index(scope, value string) (string, error) {
+	return Default().BlindIndex(scope, value)
+}
+
+// IndexOf is Index for nullable columns: nil in, nil out.
+func IndexOf(scope string, value *String) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	index, err := Index(scope, string(*value))
+	if err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
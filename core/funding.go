@@ -1,13 +1,18 @@
 package core
 
+const (
+	FundingTypeCard        = "card"
+	FundingTypeBankAccount = "bank_account"
+)
+
 type LinkFundingSourceRequest struct {
-	UserID          int    `json:"user_id"`
-	PaymentMethodID string `json:"payment_method_id"` // "pm_card_visa"
-	Type            string `json:"type"`              // card
+	UserID          int    `json:"user_id" binding:"required,gt=0"`
+	PaymentMethodID string `json:"payment_method_id" binding:"required,max=255"` // "pm_card_visa"
+	Type            string `json:"type" binding:"required,funding_type"`         // card
 }
 
 type DepositRequest struct {
-	UserID          int   `json:"user_id"`
-	Amount          int64 `json:"amount"` // in cents
-	FundingSourceID int   `json:"funding_source_id"`
+	UserID          int   `json:"user_id" binding:"required,gt=0"`
+	Amount          int64 `json:"amount" binding:"required,gt=0"` // in cents
+	FundingSourceID int   `json:"funding_source_id" binding:"required,gt=0"`
 }
@@ -0,0 +1,30 @@
+package core
+
+// WebhookEventTypes are the ledger events partners can receive by webhook.
+// They are the ledger's real-time event types; feed items stay in the app.
+var WebhookEventTypes = []string{"payment.received", "payment.sent", "request.updated", "balance.updated"}
+
+// WebhookAllEvents subscribes an endpoint to every event type, including
+// ones added later.
+const WebhookAllEvents = "*"
+
+type WebhookEndpointRequest struct {
+	URL         string   `json:"url" binding:"required,url,max=500"`
+	Events      []string `json:"events" binding:"required,min=1,max=10,unique,dive,webhook_event"`
+	Description string   `json:"description" binding:"max=200"`
+}
+
+// UpdateWebhookEndpointRequest changes the fields that are set. Enabling an
+// endpoint clears its failure history.
+type UpdateWebhookEndpointRequest struct {
+	URL         *string  `json:"url" binding:"omitempty,url,max=500"`
+	Events      []string `json:"events" binding:"omitempty,min=1,max=10,unique,dive,webhook_event"`
+	Description *string  `json:"description" binding:"omitempty,max=200"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+type WebhookDeliveryQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending succeeded failed"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
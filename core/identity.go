@@ -1,13 +1,31 @@
 package core
 
+const (
+	DocumentTypePassport       = "passport"
+	DocumentTypeDriversLicense = "drivers_license"
+	DocumentTypeNationalID     = "national_id"
+	DocumentTypeSelfie         = "selfie"
+	DocumentTypeProofOfAddress = "proof_of_address" // utility bill, bank statement
+)
+
+// VerifyIdentityRequest submits a document the caller uploaded to
+// POST /documents.
 type VerifyIdentityRequest struct {
-	UserID       int    `json:"user_id"`
-	DocumentType string `json:"document_type"` // passport, drivers_license
-	DocumentURL  string `json:"document_url"`
+	DocumentType string `json:"document_type" binding:"required,document_type"`
+	UploadID     int    `json:"upload_id" binding:"required,gt=0"`
+}
+
+// KYCReviewQuery filters the document review queue. Status defaults to
+// in_review.
+type KYCReviewQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending in_review verified rejected"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
 }
 
-type IdentityWebhookRequest struct {
-	UserID     int    `json:"user_id"`
-	DocumentID int    `json:"document_id"`
-	Status     string `json:"status"` // passed, failed
+// KYCDocumentDecision approves or rejects a document. Rejections carry a
+// reason, which the user sees.
+type KYCDocumentDecision struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject"`
+	Reason   string `json:"reason" binding:"required_if=Decision reject,max=500"`
 }
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// RateLimiter enforces fixed-window request limits shared across instances
+// through Redis.
+type RateLimiter struct {
+	redis *redis.Client
+}
+
+func NewRateLimiter(r *redis.Client) *RateLimiter {
+	return &RateLimiter{redis: r}
+}
+
+// Allow counts a hit against key in the current window and reports whether
+// it is within limit, along with how long until the window resets. It fails
+// open when Redis is unavailable.
+func (rl *RateLimiter) Allow(c *gin.Context, key string, limit int, window time.Duration) (bool, time.Duration) {
+	bucket := time.Now().UnixNano() / int64(window)
+	redisKey := fmt.Sprintf("ratelimit:%s:%d", key, bucket)
+	ctx := c.Request.Context()
+
+	var incr *redis.IntCmd
+	_, err := rl.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, redisKey)
+		pipe.Expire(ctx, redisKey, window)
+		return nil
+	})
+	if err != nil {
+		Log.Warn("rate limiter unavailable", zap.Error(err))
+		return true, 0
+	}
+
+	reset := time.Duration((bucket+1)*int64(window) - time.Now().UnixNano())
+	return incr.Val() <= int64(limit), reset
+}
+
+// Limit returns middleware allowing limit requests per window for each
+// caller, as identified by keyFn.
+func (rl *RateLimiter) Limit(name string, limit int, window time.Duration, keyFn func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, reset := rl.Allow(c, name+":"+keyFn(c), limit, window)
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(reset.Seconds())+1))
+			Abort(c, Error(RateLimited(errors.New("rate limit exceeded")), String("too many requests, slow down")))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	ErrorCode ErrorCode   `json:"error_code"`
+	Errors    interface{} `json:"errors,omitempty"`
+}
+
+// Respond writes a service Response. Successful responses render their Meta;
+// failures render the message and error code, or a problem document when the
+// client asks for application/problem+json.
+func Respond(c *gin.Context, response Response) {
+	if !response.Error {
+		c.JSON(response.Code, response.Meta)
+		return
+	}
+
+	if strings.Contains(c.GetHeader("Accept"), problemContentType) {
+		c.Header("Content-Type", problemContentType)
+		c.JSON(response.Code, Problem{
+			Type:      "urn:cashapp:error:" + string(response.ErrorCode),
+			Title:     http.StatusText(response.Code),
+			Status:    response.Code,
+			Detail:    response.Meta.Message,
+			Instance:  c.Request.URL.Path,
+			ErrorCode: response.ErrorCode,
+			Errors:    response.Details,
+		})
+		return
+	}
+
+	body := gin.H{
+		"message":    response.Meta.Message,
+		"error_code": response.ErrorCode,
+	}
+	if response.Details != nil {
+		body["errors"] = response.Details
+	}
+	c.JSON(response.Code, body)
+}
+
+// Abort stops the handler chain and writes the response. Used by middleware.
+func Abort(c *gin.Context, response Response) {
+	c.Abort()
+	Respond(c, response)
+}
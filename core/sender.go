@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MessageSender delivers a plain text message to an email address or phone
+// number. Real providers (SMTP, SMS gateways) plug in behind it.
+type MessageSender interface {
+	Send(channel, to, body string) error
+}
+
+// NewMessageSender returns the sender selected by OTP_SINK. Only local sinks
+// ship with the services; they're meant for development.
+func NewMessageSender(config *Config) MessageSender {
+	if config.OTP_SINK == "file" {
+		return &FileSender{Path: config.OTP_FILE}
+	}
+	return ConsoleSender{}
+}
+
+// ConsoleSender logs each message.
+type ConsoleSender struct{}
+
+func (ConsoleSender) Send(channel, to, body string) error {
+	Log.Info("message sent", zap.String("channel", channel), zap.String("to", to), zap.String("body", body))
+	return nil
+}
+
+// FileSender appends each message as a line to a file, so local tooling
+// can read codes back.
+type FileSender struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (f *FileSender) Send(channel, to, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\t%s\t%s\t%s\n", time.Now().Format(time.RFC3339), channel, to, body)
+	return err
+}
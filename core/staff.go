@@ -0,0 +1,60 @@
+package core
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const staffContextKey = "staff_member"
+
+// RequireStaff guards back-office routes with a staff key sent in the
+// X-Staff-Key header: one of the named keys in STAFF_API_KEYS, or the shared
+// STAFF_API_KEY. With no key configured every request is refused.
+func RequireStaff(config *Config) gin.HandlerFunc {
+	members := parseStaffKeys(config.STAFF_API_KEYS)
+
+	return func(c *gin.Context) {
+		key := []byte(c.GetHeader("X-Staff-Key"))
+		name := ""
+		for member, memberKey := range members {
+			if subtle.ConstantTimeCompare(key, []byte(memberKey)) == 1 {
+				name = member
+			}
+		}
+		if name != "" {
+			c.Set(staffContextKey, name)
+			c.Next()
+			return
+		}
+
+		if config.STAFF_API_KEY == "" || subtle.ConstantTimeCompare(key, []byte(config.STAFF_API_KEY)) != 1 {
+			Abort(c, Error(Forbidden(errors.New("staff key missing or invalid")), String("staff access required")))
+			return
+		}
+		c.Next()
+	}
+}
+
+// StaffMember returns the name behind the staff key a request was let in
+// with. The shared key has no name, so it reports false.
+func StaffMember(c *gin.Context) (string, bool) {
+	name := c.GetString(staffContextKey)
+	return name, name != ""
+}
+
+// parseStaffKeys reads STAFF_API_KEYS, comma-separated name:key pairs.
+// Malformed entries are skipped.
+func parseStaffKeys(raw string) map[string]string {
+	members := map[string]string{}
+	for _, entry := range strings.Split(raw, ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || name == "" || key == "" {
+			continue
+		}
+		members[name] = key
+	}
+	return members
+}
@@ -1,10 +1,13 @@
 package core
 
+import "time"
+
 type Pagination struct {
-	CurrentPage  int   `json:"current_page,omitempty"`
-	NextPage     int   `json:"next_page,omitempty"`
-	PreviousPage int   `json:"previous_page,omitempty"`
-	Count        int64 `json:"count"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	NextPage     int    `json:"next_page,omitempty"`
+	PreviousPage int    `json:"previous_page,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	Count        int64  `json:"count"`
 }
 
 type Meta struct {
@@ -14,37 +17,219 @@ type Meta struct {
 }
 
 type Response struct {
-	Error bool `json:"error"`
-	Code  int  `json:"code"`
-	Meta  Meta `json:"meta"`
+	Error     bool        `json:"error"`
+	Code      int         `json:"code"`
+	ErrorCode ErrorCode   `json:"error_code,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+	Meta      Meta        `json:"meta"`
 }
 
 type CreateUserRequest struct {
-	Tag string `json:"tag"`
+	Tag         string `json:"tag" binding:"required,cashtag"`
+	DisplayName string `json:"display_name" binding:"max=60"`
+}
+
+type UpdateProfileRequest struct {
+	FullName    *string `json:"full_name" binding:"omitempty,max=100"`
+	AvatarURL   *string `json:"avatar_url" binding:"omitempty,url,max=500"`
+	DateOfBirth *string `json:"date_of_birth" binding:"omitempty,datetime=2006-01-02"`
+}
+
+type StartVerificationRequest struct {
+	Channel string `json:"channel" binding:"required,oneof=email phone"`
+	Target  string `json:"target" binding:"required,max=254"`
+}
+
+type ConfirmVerificationRequest struct {
+	Channel string `json:"channel" binding:"required,oneof=email phone"`
+	Code    string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// ContactLookupRequest matches address book entries against verified
+// contacts.
+type ContactLookupRequest struct {
+	Emails []string `json:"emails" binding:"max=100"`
+	Phones []string `json:"phones" binding:"max=100"`
+}
+
+type ChangeTagRequest struct {
+	Tag string `json:"tag" binding:"required,cashtag"`
+}
+
+type DirectorySettingsRequest struct {
+	DisplayName  *string `json:"display_name" binding:"omitempty,max=60"`
+	Discoverable *bool   `json:"discoverable"`
+}
+
+type UserSearchQuery struct {
+	Q     string `form:"q" binding:"required,min=2,max=40"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=25"`
+}
+
+type UpdatePrivacyRequest struct {
+	Privacy string `json:"privacy" binding:"required,privacy"` // public, friends, private
 }
 
-type CreateFriendshipRequest struct {
-	UserID   int `json:"user_id"`
-	FriendID int `json:"friend_id"`
+type FriendRequestDTO struct {
+	FriendID int `json:"friend_id" binding:"required,gt=0"`
+}
+
+type SuggestionQuery struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+type BlockUserRequest struct {
+	UserID int `json:"user_id" binding:"required,gt=0"`
+}
+
+type FriendRequestQuery struct {
+	Direction string `form:"direction" binding:"omitempty,oneof=incoming outgoing"`
 }
 
 type CreatePaymentRequest struct {
-	From        int    `json:"from"`
-	To          int    `json:"to"`
-	Amount      int64  `json:"amount"`
-	Description string `json:"description"`
-	Privacy     string `json:"privacy,omitempty"` // public, friends, private
+	From        int    `json:"from" binding:"required,gt=0"`
+	To          int    `json:"to" binding:"required,gt=0,nefield=From"`
+	Amount      int64  `json:"amount" binding:"money"`
+	Description string `json:"description" binding:"max=140"`
+	Privacy     string `json:"privacy,omitempty" binding:"omitempty,privacy"` // public, friends, private
+}
+
+// SendToContactRequest pays an email address or phone number that may not
+// belong to a user yet.
+type SendToContactRequest struct {
+	Channel     string `json:"channel" binding:"required,oneof=email phone"`
+	Target      string `json:"target" binding:"required,max=254"`
+	Amount      int64  `json:"amount" binding:"money"`
+	Description string `json:"description" binding:"max=140"`
 }
 
 type CreateRequestDTO struct {
-	RequesterID int    `json:"requester_id"`
-	PayerID     int    `json:"payer_id"`
-	Amount      int64  `json:"amount"`
-	Description string `json:"description"`
+	RequesterID int    `json:"requester_id" binding:"required,gt=0"`
+	PayerID     int    `json:"payer_id" binding:"required,gt=0,nefield=RequesterID"`
+	Amount      int64  `json:"amount" binding:"money"`
+	Description string `json:"description" binding:"max=140"`
+}
+
+type TransactionHistoryQuery struct {
+	Since        time.Time `form:"since"`
+	Until        time.Time `form:"until"`
+	Direction    string    `form:"direction" binding:"omitempty,oneof=incoming outgoing"`
+	Purpose      string    `form:"purpose" binding:"omitempty,oneof=transfer deposit withdrawal reversal"`
+	Status       string    `form:"status" binding:"omitempty,oneof=pending held success failed"`
+	Counterparty int       `form:"counterparty" binding:"omitempty,gt=0"`
+	MinAmount    int64     `form:"min_amount" binding:"omitempty,gt=0"`
+	MaxAmount    int64     `form:"max_amount" binding:"omitempty,gt=0,gtefield=MinAmount"`
+	Cursor       string    `form:"cursor"`
+	Limit        int       `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type FeedQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type CreateCommentRequest struct {
+	Body     string `json:"body" binding:"required,max=500"`
+	ParentID int    `json:"parent_id" binding:"omitempty,gt=0"`
+}
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required,reaction"`
 }
 
 type SplitBillDTO struct {
-	OriginalTransactionID int   `json:"original_transaction_id"`
-	RequesterID           int   `json:"requester_id"`
-	FriendIDs             []int `json:"friend_ids"`
+	OriginalTransactionID int   `json:"original_transaction_id" binding:"required,gt=0"`
+	RequesterID           int   `json:"requester_id" binding:"required,gt=0"`
+	FriendIDs             []int `json:"friend_ids" binding:"required,min=1,max=20,unique,dive,gt=0"`
+}
+
+type InboxQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Unread bool   `form:"unread"`
+}
+
+type ChannelPreferenceDTO struct {
+	Event   string `json:"event" binding:"required,max=50"` // "*" for every event
+	Channel string `json:"channel" binding:"required,oneof=push email sms"`
+	Enabled *bool  `json:"enabled" binding:"required"`
+}
+
+type QuietHoursDTO struct {
+	StartTime string `json:"start_time" binding:"required,datetime=15:04"`
+	EndTime   string `json:"end_time" binding:"required,datetime=15:04"`
+	Timezone  string `json:"timezone" binding:"required,max=64"`
+}
+
+// NotificationPreferencesRequest updates the listed channel preferences.
+// Quiet hours are replaced when given and removed when ClearQuietHours is set.
+type NotificationPreferencesRequest struct {
+	Channels        []ChannelPreferenceDTO `json:"channels" binding:"max=50,dive"`
+	QuietHours      *QuietHoursDTO         `json:"quiet_hours"`
+	ClearQuietHours bool                   `json:"clear_quiet_hours"`
+}
+
+type RiskReviewQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type ConfirmTransferRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type RiskReviewDecision struct {
+	Approve *bool  `json:"approve" binding:"required"`
+	Note    string `json:"note" binding:"max=500"`
+}
+
+type ScreeningHitQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending confirmed dismissed"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type ScreeningDecisionRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=confirm dismiss"`
+	Note     string `json:"note" binding:"max=500"`
+}
+
+type AMLAlertQuery struct {
+	Rule   string `form:"rule" binding:"omitempty,oneof=structuring rapid_movement fan_in fan_out dormant_reactivation"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type AMLCaseQuery struct {
+	Status   string `form:"status" binding:"omitempty,oneof=open investigating escalated closed"`
+	Assignee string `form:"assignee" binding:"max=100"`
+	Cursor   string `form:"cursor"`
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type AMLAssignRequest struct {
+	Assignee string `json:"assignee" binding:"required,max=100"`
+}
+
+// AMLCaseStatusRequest moves a case along. Closing a case requires a
+// disposition.
+type AMLCaseStatusRequest struct {
+	Status      string `json:"status" binding:"required,oneof=open investigating escalated closed"`
+	Disposition string `json:"disposition" binding:"required_if=Status closed,omitempty,oneof=no_action false_positive sar_filed account_restricted"`
+}
+
+type AMLCaseNoteRequest struct {
+	Body string `json:"body" binding:"required,max=2000"`
+}
+
+type AMLExportQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=json csv"`
+}
+
+// InboundWebhookQuery filters stored inbound webhook events.
+type InboundWebhookQuery struct {
+	Source string `form:"source" binding:"omitempty,max=50"`
+	Status string `form:"status" binding:"omitempty,oneof=received processed failed"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
 }
@@ -14,6 +14,9 @@ var (
 	StatusFailed  Status = "failed"
 	StatusPending Status = "pending"
 	StatusSuccess Status = "success"
+	// StatusHeld is an outgoing leg stopped by risk checks until the sender
+	// confirms it or a reviewer approves it.
+	StatusHeld Status = "held"
 
 	DirectionIncoming Direction = "incoming"
 	DirectionOutgoing Direction = "outgoing"
@@ -22,8 +25,42 @@ var (
 	PurposeDeposit    Purpose = "deposit"
 	PurposeWithdrawal Purpose = "withdrawal"
 	PurposeReversal   Purpose = "reversal"
+	// PurposeEscrow holds a payment to an unregistered contact in escrow;
+	// PurposeEscrowRelease pays it out to the claimant or back to the sender.
+	PurposeEscrow        Purpose = "escrow"
+	PurposeEscrowRelease Purpose = "escrow_release"
 )
 
+const (
+	PrivacyPublic  = "public"
+	PrivacyFriends = "friends"
+	PrivacyPrivate = "private"
+)
+
+// ReactionEmojis is the fixed set of reactions allowed on feed items.
+var ReactionEmojis = []string{"❤️", "😂", "🔥", "👏", "😮", "💸"}
+
+var privacyRank = map[string]int{
+	PrivacyPublic:  1,
+	PrivacyFriends: 2,
+	PrivacyPrivate: 3,
+}
+
+// MostRestrictivePrivacy returns the most restrictive of the given privacy
+// settings, ignoring unset ones. It falls back to private when none is set.
+func MostRestrictivePrivacy(settings ...string) string {
+	result := ""
+	for _, s := range settings {
+		if privacyRank[s] > privacyRank[result] {
+			result = s
+		}
+	}
+	if result == "" {
+		return PrivacyPrivate
+	}
+	return result
+}
+
 type Model struct {
 	ID        int        `gorm:"primary_key" json:"id"`
 	CreatedAt time.Time  `json:"created_at"`
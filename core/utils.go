@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/rs/xid"
@@ -23,8 +24,6 @@ func GenerateRef() string {
 }
 
 func Error(err error, m *string) Response {
-	Log.Error("request failed", zap.Error(err))
-
 	var message string
 	if m == nil {
 		message = "request failed"
@@ -32,9 +31,27 @@ func Error(err error, m *string) Response {
 		message = StringValue(m)
 	}
 
+	if err == nil {
+		err = errors.New(message)
+	}
+
+	code := CodeOf(err)
+	if m == nil && code != ErrCodeInternal {
+		// Domain errors are safe to surface as-is.
+		message = err.Error()
+	}
+	status := code.HTTPStatus()
+	if status >= http.StatusInternalServerError {
+		Log.Error("request failed", zap.Error(err), zap.String("error_code", string(code)))
+	} else {
+		Log.Warn("request rejected", zap.Error(err), zap.String("error_code", string(code)))
+	}
+
 	return Response{
-		Error: true,
-		Code:  http.StatusInternalServerError,
+		Error:     true,
+		Code:      status,
+		ErrorCode: code,
+		Details:   DetailsOf(err),
 		Meta: Meta{
 			Data:    nil,
 			Message: message,
@@ -42,6 +59,13 @@ func Error(err error, m *string) Response {
 	}
 }
 
+// Paginated is Success with pagination metadata attached.
+func Paginated(data *map[string]interface{}, p *Pagination, m *string) Response {
+	response := Success(data, m)
+	response.Meta.Pagination = p
+	return response
+}
+
 func Success(data *map[string]interface{}, m *string) Response {
 
 	var message string
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// MaxMoneyAmount is the largest amount, in major units, a single request may carry.
+const MaxMoneyAmount int64 = 1000000
+
+var (
+	cashTagPattern = regexp.MustCompile(`^\$?[A-Za-z][A-Za-z0-9_]{2,19}$`)
+
+	privacyValues      = []string{PrivacyPublic, PrivacyFriends, PrivacyPrivate}
+	documentTypeValues = []string{DocumentTypePassport, DocumentTypeDriversLicense, DocumentTypeNationalID, DocumentTypeSelfie, DocumentTypeProofOfAddress}
+	fundingTypeValues  = []string{FundingTypeCard, FundingTypeBankAccount}
+	webhookEventValues = append([]string{WebhookAllEvents}, WebhookEventTypes...)
+
+	registerOnce sync.Once
+)
+
+// FieldError describes a single invalid field in a request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// RegisterValidators installs the custom validation rules used in DTO
+// `binding` tags on gin's validator engine. Safe to call more than once.
+func RegisterValidators() {
+	registerOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "-" || name == "" {
+				return f.Name
+			}
+			return name
+		})
+
+		v.RegisterValidation("money", func(fl validator.FieldLevel) bool {
+			amount := fl.Field().Int()
+			return amount > 0 && amount <= MaxMoneyAmount
+		})
+		v.RegisterValidation("cashtag", func(fl validator.FieldLevel) bool {
+			return cashTagPattern.MatchString(fl.Field().String())
+		})
+		v.RegisterValidation("privacy", oneOf(privacyValues))
+		v.RegisterValidation("document_type", oneOf(documentTypeValues))
+		v.RegisterValidation("funding_type", oneOf(fundingTypeValues))
+		v.RegisterValidation("reaction", oneOf(ReactionEmojis))
+		v.RegisterValidation("webhook_event", oneOf(webhookEventValues))
+	})
+}
+
+func oneOf(values []string) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		field := fl.Field().String()
+		for _, v := range values {
+			if field == v {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// BindJSON decodes and validates the request body into obj. On failure it
+// writes a validation error listing every bad field and returns false.
+func BindJSON(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		Respond(c, ValidationResponse(err))
+		return false
+	}
+	return true
+}
+
+// BindQuery is BindJSON for query string parameters.
+func BindQuery(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindQuery(obj); err != nil {
+		Respond(c, ValidationResponse(err))
+		return false
+	}
+	return true
+}
+
+// ValidationResponse converts a binding error into a validation failure
+// with field-level details.
+func ValidationResponse(err error) Response {
+	appErr := &AppError{Code: ErrCodeValidation, Err: err}
+
+	var verrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &verrs):
+		fields := make([]FieldError, 0, len(verrs))
+		for _, fe := range verrs {
+			fields = append(fields, FieldError{
+				Field:   fieldPath(fe),
+				Rule:    fe.Tag(),
+				Message: fieldMessage(fe),
+			})
+		}
+		appErr.Details = fields
+	case errors.As(err, &typeErr):
+		appErr.Details = []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type.String()),
+		}}
+	}
+
+	return Error(appErr, String("invalid request"))
+}
+
+// fieldPath strips the struct name from the namespace, so
+// "SplitBillDTO.friend_ids[0]" becomes "friend_ids[0]".
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	field := fieldPath(fe)
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "required_if":
+		if parts := strings.Fields(fe.Param()); len(parts) == 2 {
+			return fmt.Sprintf("%s is required when %s is %s", field, snakeCase(parts[0]), parts[1])
+		}
+		return fmt.Sprintf("%s is required", field)
+	case "money":
+		return fmt.Sprintf("%s must be greater than 0 and at most %d", field, MaxMoneyAmount)
+	case "cashtag":
+		return fmt.Sprintf("%s must be 3-20 letters, digits or underscores and start with a letter", field)
+	case "privacy":
+		return fmt.Sprintf("%s must be one of %s", field, strings.Join(privacyValues, ", "))
+	case "document_type":
+		return fmt.Sprintf("%s must be one of %s", field, strings.Join(documentTypeValues, ", "))
+	case "funding_type":
+		return fmt.Sprintf("%s must be one of %s", field, strings.Join(fundingTypeValues, ", "))
+	case "reaction":
+		return fmt.Sprintf("%s must be one of %s", field, strings.Join(ReactionEmojis, " "))
+	case "webhook_event":
+		return fmt.Sprintf("%s must be one of %s", field, strings.Join(webhookEventValues, ", "))
+	case "nefield":
+		return fmt.Sprintf("%s must differ from %s", field, snakeCase(fe.Param()))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, fe.Param())
+	case "min":
+		return fmt.Sprintf("%s must have at least %s item(s)", field, fe.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s long", field, fe.Param())
+	case "unique":
+		return fmt.Sprintf("%s must not contain duplicates", field)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fe.Param())
+	case "len":
+		return fmt.Sprintf("%s must be exactly %s characters", field, fe.Param())
+	case "numeric":
+		return fmt.Sprintf("%s must contain only digits", field)
+	case "datetime":
+		return fmt.Sprintf("%s must match the format %s", field, fe.Param())
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", field)
+	default:
+		return fmt.Sprintf("%s failed the %s rule", field, fe.Tag())
+	}
+}
+
+// snakeCase turns a Go field name such as "RequesterID" into "requester_id"
+// so messages refer to fields the way clients send them.
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		upper := r >= 'A' && r <= 'Z'
+		if upper && i > 0 && (runes[i-1] < 'A' || runes[i-1] > 'Z' || (i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z')) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
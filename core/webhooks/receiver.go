@@ -0,0 +1,301 @@
+// Package webhooks receives webhooks from third parties. Every source has a
+// Verifier for its signature scheme; verified deliveries are stored raw,
+// deduplicated by event ID and handed to the source's Handler. Stored
+// deliveries can be replayed from the back office.
+package webhooks
+
+import (
+	"cashapp/core"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxBody caps the size of a delivery we read.
+const maxBody = 1 << 20
+
+// handleLease is how long a delivery being handled holds its event. A
+// redelivery after that takes the event over, in case whoever was handling
+// it died.
+const handleLease = 5 * time.Minute
+
+type Status string
+
+const (
+	StatusReceived  Status = "received" // stored, being handled
+	StatusProcessed Status = "processed"
+	StatusFailed    Status = "failed" // the handler failed; a redelivery or replay runs it again
+)
+
+// InboundEvent is a verified delivery, stored as received.
+type InboundEvent struct {
+	core.Model
+	Source       string     `json:"source" gorm:"uniqueIndex:idx_inbound_events_source_event"`
+	EventID      string     `json:"event_id" gorm:"uniqueIndex:idx_inbound_events_source_event"`
+	Headers      string     `json:"headers"` // JSON
+	Payload      string     `json:"payload"`
+	Status       Status     `json:"status" gorm:"index"`
+	ClaimedAt    *time.Time `json:"claimed_at,omitempty"` // when handling last started
+	Attempts     int        `json:"attempts"`
+	ResponseCode int        `json:"response_code"`
+	LastError    string     `json:"last_error,omitempty"`
+	ProcessedAt  *time.Time `json:"processed_at,omitempty"`
+}
+
+// Source is a third party that sends us webhooks. EventID reads the
+// provider's ID for a delivery; without one, identical bodies count as the
+// same event.
+type Source struct {
+	Name     string
+	Verifier Verifier
+	EventID  func(header http.Header, body []byte) string
+}
+
+// Handler acts on a verified event. Its response is returned to the sender,
+// so errors make the provider retry.
+type Handler func(event *InboundEvent) core.Response
+
+type registration struct {
+	source  Source
+	handler Handler
+}
+
+type Receiver struct {
+	db *gorm.DB
+
+	mu      sync.RWMutex
+	sources map[string]registration
+}
+
+func NewReceiver(db *gorm.DB) *Receiver {
+	return &Receiver{db: db, sources: map[string]registration{}}
+}
+
+// Register adds a source and returns the route handler for its deliveries.
+func (r *Receiver) Register(src Source, h Handler) gin.HandlerFunc {
+	r.mu.Lock()
+	r.sources[src.Name] = registration{source: src, handler: h}
+	r.mu.Unlock()
+
+	return func(c *gin.Context) {
+		core.Respond(c, r.receive(src, h, c.Request))
+	}
+}
+
+func (r *Receiver) receive(src Source, h Handler, req *http.Request) core.Response {
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBody))
+	if err != nil {
+		return core.Error(core.Validation(err), core.String("invalid webhook payload"))
+	}
+
+	if err := src.Verifier.Verify(req.Header, body); err != nil {
+		core.Log.Warn("webhook rejected", zap.String("source", src.Name), zap.String("remote", req.RemoteAddr), zap.Error(err))
+		return core.Error(core.Unauthorized(err), core.String("invalid webhook signature"))
+	}
+
+	eventID := ""
+	if src.EventID != nil {
+		eventID = src.EventID(req.Header, body)
+	}
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	headers, _ := json.Marshal(req.Header)
+	now := time.Now()
+	event := &InboundEvent{
+		Source:    src.Name,
+		EventID:   eventID,
+		Headers:   string(headers),
+		Payload:   string(body),
+		Status:    StatusReceived,
+		ClaimedAt: &now,
+	}
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	if res.Error != nil {
+		return core.Error(res.Error, nil)
+	}
+
+	if res.RowsAffected == 0 {
+		// Seen before. Only a delivery whose handling failed, or whose
+		// handler's lease ran out, runs again.
+		if err := r.db.Where("source = ? AND event_id = ?", src.Name, eventID).First(event).Error; err != nil {
+			return core.Error(err, nil)
+		}
+		if event.Status == StatusProcessed {
+			return core.Success(nil, core.String("duplicate event ignored"))
+		}
+		claimed := r.db.Model(&InboundEvent{}).
+			Where("id = ? AND (status = ? OR (status = ? AND (claimed_at IS NULL OR claimed_at < ?)))",
+				event.ID, StatusFailed, StatusReceived, now.Add(-handleLease)).
+			Updates(map[string]interface{}{"status": StatusReceived, "claimed_at": now})
+		if claimed.Error != nil {
+			return core.Error(claimed.Error, nil)
+		}
+		if claimed.RowsAffected == 0 {
+			return core.Error(core.Conflict(errors.New("event is being handled")), core.String("event is being handled"))
+		}
+	}
+
+	return r.handle(event, h)
+}
+
+// handle runs the handler and records how it went. A handler that panics
+// counts as failed, so the event can run again.
+func (r *Receiver) handle(event *InboundEvent, h Handler) core.Response {
+	resp := run(event, h)
+
+	now := time.Now()
+	event.Attempts++
+	event.ResponseCode = resp.Code
+	event.Status = StatusProcessed
+	event.LastError = ""
+	event.ProcessedAt = &now
+	if resp.Error {
+		event.Status = StatusFailed
+		event.LastError = resp.Meta.Message
+		event.ProcessedAt = nil
+	}
+
+	err := r.db.Model(&InboundEvent{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+		"status":        event.Status,
+		"attempts":      event.Attempts,
+		"response_code": event.ResponseCode,
+		"last_error":    event.LastError,
+		"processed_at":  event.ProcessedAt,
+	}).Error
+	if err != nil {
+		core.Log.Error("failed to record webhook outcome", zap.String("source", event.Source), zap.String("event_id", event.EventID), zap.Error(err))
+	}
+	return resp
+}
+
+func run(event *InboundEvent, h Handler) (resp core.Response) {
+	defer func() {
+		if p := recover(); p != nil {
+			core.Log.Error("webhook handler panicked", zap.String("source", event.Source), zap.String("event_id", event.EventID), zap.Any("panic", p))
+			resp = core.Error(fmt.Errorf("handler panicked: %v", p), core.String("failed to handle event"))
+		}
+	}()
+	return h(event)
+}
+
+// Replay runs a stored event through its source's handler again. The
+// signature was checked when it arrived.
+func (r *Receiver) Replay(id int) core.Response {
+	var event InboundEvent
+	if err := r.db.First(&event, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return core.Error(core.NotFound(err), core.String("webhook event not found"))
+		}
+		return core.Error(err, nil)
+	}
+
+	r.mu.RLock()
+	reg, ok := r.sources[event.Source]
+	r.mu.RUnlock()
+	if !ok {
+		return core.Error(core.Conflict(errors.New("unknown source")), core.String("no handler for "+event.Source+" in this service"))
+	}
+
+	resp := r.handle(&event, reg.handler)
+	return core.Success(&map[string]interface{}{
+		"event":  event,
+		"result": resp.Meta,
+	}, core.String("event replayed"))
+}
+
+// List returns stored events newest first, optionally from one source or in
+// one status.
+func (r *Receiver) List(q core.InboundWebhookQuery) core.Response {
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	limit := core.PageSize(q.Limit)
+	query := r.db.Model(&InboundEvent{})
+	if q.Source != "" {
+		query = query.Where("source = ?", q.Source)
+	}
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	if cursor.BeforeID > 0 {
+		query = query.Where("id < ?", cursor.BeforeID)
+	}
+
+	var events []InboundEvent
+	if err := query.Order("id desc").Limit(limit + 1).Find(&events).Error; err != nil {
+		return core.Error(err, core.String("failed to load webhook events"))
+	}
+
+	pagination := &core.Pagination{}
+	if len(events) > limit {
+		events = events[:limit]
+		pagination.NextCursor = core.Cursor{BeforeID: events[limit-1].ID}.Encode()
+	}
+	pagination.Count = int64(len(events))
+
+	return core.Paginated(&map[string]interface{}{
+		"events": events,
+	}, pagination, nil)
+}
+
+// RegisterRoutes mounts the back-office routes for stored events behind
+// guard.
+func RegisterRoutes(e *gin.Engine, r *Receiver, guard gin.HandlerFunc) {
+	staff := e.Group("/webhooks/inbound", guard)
+
+	// ListInboundWebhooks lists received webhook events
+	// @Router /webhooks/inbound [get]
+	staff.GET("", func(c *gin.Context) {
+		var q core.InboundWebhookQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, r.List(q))
+	})
+
+	// ReplayInboundWebhook runs a stored webhook event again
+	// @Router /webhooks/inbound/:id/replay [post]
+	staff.POST("/:id/replay", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			core.Respond(c, core.Error(core.Validation(err), core.String("invalid event id")))
+			return
+		}
+
+		core.Respond(c, r.Replay(id))
+	})
+}
+
+// JSONEventID reads a delivery's event ID from a top-level string field of
+// its JSON body.
+func JSONEventID(field string) func(http.Header, []byte) string {
+	return func(_ http.Header, body []byte) string {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) != nil {
+			return ""
+		}
+		var id string
+		if json.Unmarshal(fields[field], &id) != nil {
+			return ""
+		}
+		return id
+	}
+}
@@ -0,0 +1,31 @@
+package webhooks
+
+import (
+	"cashapp/core"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRun(t *testing.T) {
+	core.Log = zap.NewNop()
+
+	tests := []struct {
+		name      string
+		handler   Handler
+		wantError bool
+	}{
+		{"handled", func(*InboundEvent) core.Response { return core.Success(nil, nil) }, false},
+		{"failed", func(*InboundEvent) core.Response { return core.Error(core.Upstream(http.ErrHandlerTimeout), nil) }, true},
+		{"panicked", func(*InboundEvent) core.Response { panic("boom") }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := run(&InboundEvent{Source: "test", EventID: "evt_1"}, tt.handler)
+			if resp.Error != tt.wantError {
+				t.Fatalf("run error = %v, want %v", resp.Error, tt.wantError)
+			}
+		})
+	}
+}
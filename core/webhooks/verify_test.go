@@ -0,0 +1,191 @@
+package webhooks
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testBody = []byte(`{"id":"evt_1","type":"check.completed"}`)
+
+func hmacHex(h func() hash.Hash, secret string, message []byte) string {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(message)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func headers(pairs ...string) http.Header {
+	h := http.Header{}
+	for i := 0; i < len(pairs); i += 2 {
+		h.Set(pairs[i], pairs[i+1])
+	}
+	return h
+}
+
+func unix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func TestHMAC(t *testing.T) {
+	now := time.Now()
+	plain := HMAC{Header: "X-SHA2-Signature", Secrets: []string{"secret"}}
+	rotating := HMAC{Header: "X-SHA2-Signature", Secrets: []string{"new", "old"}}
+	timestamped := HMAC{Header: "X-Signature", Secrets: []string{"secret"}, TimestampHeader: "X-Timestamp"}
+
+	tests := []struct {
+		name     string
+		verifier HMAC
+		header   http.Header
+		want     error
+	}{
+		{"valid", plain, headers("X-SHA2-Signature", hmacHex(sha256.New, "secret", testBody)), nil},
+		{"wrong secret", plain, headers("X-SHA2-Signature", hmacHex(sha256.New, "other", testBody)), ErrBadSignature},
+		{"missing header", plain, headers(), ErrBadSignature},
+		{"not hex", plain, headers("X-SHA2-Signature", "zz"), ErrBadSignature},
+		{"old secret while rotating", rotating, headers("X-SHA2-Signature", hmacHex(sha256.New, "old", testBody)), nil},
+		{"empty secret never matches", HMAC{Header: "X-SHA2-Signature", Secrets: []string{""}}, headers("X-SHA2-Signature", hmacHex(sha256.New, "", testBody)), ErrBadSignature},
+		{"prefix", HMAC{Header: "X-Hub-Signature-256", Secrets: []string{"secret"}, Prefix: "sha256="},
+			headers("X-Hub-Signature-256", "sha256="+hmacHex(sha256.New, "secret", testBody)), nil},
+		{"sha512", HMAC{Header: "X-Paystack-Signature", Secrets: []string{"secret"}, Hash: SHA512},
+			headers("X-Paystack-Signature", hmacHex(SHA512, "secret", testBody)), nil},
+		{"sha256 signature for a sha512 source", HMAC{Header: "X-Paystack-Signature", Secrets: []string{"secret"}, Hash: SHA512},
+			headers("X-Paystack-Signature", hmacHex(sha256.New, "secret", testBody)), ErrBadSignature},
+		{"base64", HMAC{Header: "X-Signature", Secrets: []string{"secret"}, Encoding: Base64},
+			headers("X-Signature", base64Of(hmacHex(sha256.New, "secret", testBody))), nil},
+		{"timestamped", timestamped,
+			headers("X-Signature", hmacHex(sha256.New, "secret", signedMessage(unix(now), testBody)), "X-Timestamp", unix(now)), nil},
+		{"timestamp not signed", timestamped,
+			headers("X-Signature", hmacHex(sha256.New, "secret", testBody), "X-Timestamp", unix(now)), ErrBadSignature},
+		{"stale timestamp", timestamped,
+			headers("X-Signature", hmacHex(sha256.New, "secret", signedMessage(unix(now.Add(-time.Hour)), testBody)), "X-Timestamp", unix(now.Add(-time.Hour))), ErrStale},
+		{"missing timestamp", timestamped,
+			headers("X-Signature", hmacHex(sha256.New, "secret", signedMessage("", testBody))), ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.verifier.Verify(tt.header, testBody); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampedHMAC(t *testing.T) {
+	now := time.Now()
+	v := TimestampedHMAC{Header: "Stripe-Signature", Secrets: []string{"secret"}}
+
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+		want   error
+	}{
+		{"signed", Sign(now, testBody, "secret"), testBody, nil},
+		{"one of several signatures", Sign(now, testBody, "retired", "secret"), testBody, nil},
+		{"wrong secret", Sign(now, testBody, "other"), testBody, ErrBadSignature},
+		{"body changed", Sign(now, testBody, "secret"), []byte(`{"id":"evt_2"}`), ErrBadSignature},
+		{"too old", Sign(now.Add(-10*time.Minute), testBody, "secret"), testBody, ErrStale},
+		{"too far ahead", Sign(now.Add(10*time.Minute), testBody, "secret"), testBody, ErrStale},
+		{"no signature", "t=" + unix(now), testBody, ErrBadSignature},
+		{"no timestamp", "v1=" + hmacHex(sha256.New, "secret", testBody), testBody, ErrBadSignature},
+		{"empty", "", testBody, ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.Verify(headers("Stripe-Signature", tt.header), tt.body); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampedHMACTolerance(t *testing.T) {
+	v := TimestampedHMAC{Header: "Stripe-Signature", Secrets: []string{"secret"}, Tolerance: time.Hour}
+	header := headers("Stripe-Signature", Sign(time.Now().Add(-30*time.Minute), testBody, "secret"))
+	if err := v.Verify(header, testBody); err != nil {
+		t.Fatalf("Verify = %v, want nil within a custom tolerance", err)
+	}
+}
+
+func TestEd25519(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := unix(time.Now())
+	sign := func(message []byte) string {
+		return hex.EncodeToString(ed25519.Sign(private, message))
+	}
+
+	tests := []struct {
+		name     string
+		verifier Ed25519
+		header   http.Header
+		want     error
+	}{
+		{"body", Ed25519{Header: "X-Signature", PublicKeys: []ed25519.PublicKey{public}},
+			headers("X-Signature", sign(testBody)), nil},
+		{"second key", Ed25519{Header: "X-Signature", PublicKeys: []ed25519.PublicKey{otherPublic, public}},
+			headers("X-Signature", sign(testBody)), nil},
+		{"wrong key", Ed25519{Header: "X-Signature", PublicKeys: []ed25519.PublicKey{otherPublic}},
+			headers("X-Signature", sign(testBody)), ErrBadSignature},
+		{"short signature", Ed25519{Header: "X-Signature", PublicKeys: []ed25519.PublicKey{public}},
+			headers("X-Signature", "abcd"), ErrBadSignature},
+		{"timestamped", Ed25519{Header: "X-Signature", TimestampHeader: "X-Timestamp", PublicKeys: []ed25519.PublicKey{public}},
+			headers("X-Signature", sign(signedMessage(now, testBody)), "X-Timestamp", now), nil},
+		{"timestamp not signed", Ed25519{Header: "X-Signature", TimestampHeader: "X-Timestamp", PublicKeys: []ed25519.PublicKey{public}},
+			headers("X-Signature", sign(testBody), "X-Timestamp", now), ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.verifier.Verify(tt.header, testBody); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeny(t *testing.T) {
+	if err := (Deny{}).Verify(headers(), testBody); err == nil {
+		t.Fatal("Deny accepted a delivery")
+	}
+}
+
+func TestJSONEventID(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"string field", `{"id":"evt_1"}`, "evt_1"},
+		{"missing field", `{"type":"x"}`, ""},
+		{"not a string", `{"id":42}`, ""},
+		{"nested only", `{"data":{"id":"evt_1"}}`, ""},
+		{"not JSON", `id=evt_1`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JSONEventID("id")(nil, []byte(tt.body)); got != tt.want {
+				t.Fatalf("JSONEventID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func base64Of(hexSig string) string {
+	raw, _ := hex.DecodeString(hexSig)
+	return base64.StdEncoding.EncodeToString(raw)
+}
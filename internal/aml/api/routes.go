@@ -0,0 +1,155 @@
+package api
+
+import (
+	"cashapp/core"
+	"cashapp/internal/aml/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAMLRoutes mounts the compliance endpoints for working alerts and
+// cases. The hosting service supplies the guard that limits them to staff.
+func RegisterAMLRoutes(e *gin.Engine, s *service.AMLService, guard gin.HandlerFunc) {
+	staff := e.Group("/aml", guard)
+
+	// ListAlerts lists monitoring alerts, newest first
+	// @Router /aml/alerts [get]
+	staff.GET("/alerts", func(c *gin.Context) {
+		var q core.AMLAlertQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, s.ListAlerts(q))
+	})
+
+	// ListCases lists cases, optionally by status or assignee
+	// @Router /aml/cases [get]
+	staff.GET("/cases", func(c *gin.Context) {
+		var q core.AMLCaseQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, s.ListCases(q))
+	})
+
+	// GetCase returns a case with its alerts and notes
+	// @Router /aml/cases/:id [get]
+	staff.GET("/cases/:id", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.GetCase(id))
+	})
+
+	// AssignCase hands a case to an investigator
+	// @Router /aml/cases/:id/assign [put]
+	staff.PUT("/cases/:id/assign", func(c *gin.Context) {
+		actor, ok := staffMember(c)
+		if !ok {
+			return
+		}
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+		var req core.AMLAssignRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.AssignCase(id, actor, req))
+	})
+
+	// UpdateCaseStatus moves a case along or closes it with a disposition
+	// @Router /aml/cases/:id/status [put]
+	staff.PUT("/cases/:id/status", func(c *gin.Context) {
+		actor, ok := staffMember(c)
+		if !ok {
+			return
+		}
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+		var req core.AMLCaseStatusRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.UpdateCaseStatus(id, actor, req))
+	})
+
+	// AddCaseNote adds an investigator's note to a case
+	// @Router /aml/cases/:id/notes [post]
+	staff.POST("/cases/:id/notes", func(c *gin.Context) {
+		actor, ok := staffMember(c)
+		if !ok {
+			return
+		}
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+		var req core.AMLCaseNoteRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.AddCaseNote(id, actor, req))
+	})
+
+	// ExportCase exports a case for regulatory reporting as JSON or CSV
+	// @Router /aml/cases/:id/export [get]
+	staff.GET("/cases/:id/export", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+		var q core.AMLExportQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		if q.Format != "csv" {
+			core.Respond(c, s.ExportCase(id))
+			return
+		}
+
+		data, err := s.ExportCaseCSV(id)
+		if err != nil {
+			core.Respond(c, core.Error(err, nil))
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename=aml-case-"+strconv.Itoa(id)+".csv")
+		c.Data(http.StatusOK, "text/csv", data)
+	})
+}
+
+// intParam parses a numeric path parameter, responding with a validation
+// error when it isn't one.
+func intParam(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		core.Respond(c, core.Error(core.Validation(err), core.String("invalid "+name)))
+		return 0, false
+	}
+	return id, true
+}
+
+// staffMember returns the staff member making a change to a case. Changes
+// are attributed, so the shared staff key can only read.
+func staffMember(c *gin.Context) (string, bool) {
+	name, ok := core.StaffMember(c)
+	if !ok {
+		core.Respond(c, core.Error(core.Forbidden(errors.New("shared staff key used for a case change")), core.String("case changes need your own staff key")))
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"cashapp/core"
+	"time"
+)
+
+type Rule string
+
+const (
+	RuleStructuring         Rule = "structuring"          // repeated amounts just under the reporting threshold
+	RuleRapidMovement       Rule = "rapid_movement"       // money received and sent straight back out
+	RuleFanIn               Rule = "fan_in"               // many senders paying one account
+	RuleFanOut              Rule = "fan_out"              // one account paying many recipients
+	RuleDormantReactivation Rule = "dormant_reactivation" // a large movement on a long-idle account
+)
+
+type CaseStatus string
+
+const (
+	CaseOpen          CaseStatus = "open"
+	CaseInvestigating CaseStatus = "investigating"
+	CaseEscalated     CaseStatus = "escalated"
+	CaseClosed        CaseStatus = "closed"
+)
+
+// Disposition is how a closed case was concluded.
+type Disposition string
+
+const (
+	DispositionNoAction          Disposition = "no_action"
+	DispositionFalsePositive     Disposition = "false_positive"
+	DispositionSARFiled          Disposition = "sar_filed" // suspicious activity report filed
+	DispositionAccountRestricted Disposition = "account_restricted"
+)
+
+// Alert is one rule firing for one user. WindowKey stops the same pattern
+// alerting again for every transaction that continues it.
+type Alert struct {
+	core.Model
+	UserID          int    `json:"user_id" gorm:"uniqueIndex:idx_aml_alerts_window"`
+	Rule            Rule   `json:"rule" gorm:"uniqueIndex:idx_aml_alerts_window"`
+	WindowKey       string `json:"window_key" gorm:"uniqueIndex:idx_aml_alerts_window"`
+	Summary         string `json:"summary"`
+	Details         string `json:"details,omitempty"`          // JSON figures behind the alert
+	TransactionRefs string `json:"transaction_refs,omitempty"` // comma-separated
+	CaseID          int    `json:"case_id" gorm:"index"`
+}
+
+// Case groups a user's alerts for investigation. A user has at most one
+// case that isn't closed; new alerts join it.
+type Case struct {
+	core.Model
+	UserID      int         `json:"user_id" gorm:"index"`
+	Status      CaseStatus  `json:"status" gorm:"index"`
+	Disposition Disposition `json:"disposition,omitempty"`
+	Assignee    string      `json:"assignee,omitempty" gorm:"index"`
+	AlertCount  int         `json:"alert_count"`
+	ClosedAt    *time.Time  `json:"closed_at,omitempty"`
+}
+
+// CaseNote is an investigator's note or a record of a change to the case.
+type CaseNote struct {
+	core.Model
+	CaseID int    `json:"case_id" gorm:"index"`
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+// MonitorState is how far through the ledger's settlements the monitor has
+// read, as a transaction status change ID.
+type MonitorState struct {
+	core.Model
+	Name     string `json:"name" gorm:"uniqueIndex"`
+	Position int    `json:"position"`
+}
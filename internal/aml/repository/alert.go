@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"cashapp/internal/aml/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type alertLayer struct {
+	db *gorm.DB
+}
+
+type AlertRepo interface {
+	Create(a *models.Alert) (bool, error)
+	SetCase(a *models.Alert, caseID int) error
+	List(rule models.Rule, beforeID, limit int) ([]models.Alert, error)
+	ListByCase(caseID int) ([]models.Alert, error)
+}
+
+func newAlertLayer(db *gorm.DB) *alertLayer {
+	return &alertLayer{
+		db: db,
+	}
+}
+
+// Create stores an alert unless the same rule already alerted for the user
+// in the same window. It reports whether a row was written.
+func (l *alertLayer) Create(a *models.Alert) (bool, error) {
+	result := l.db.Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (l *alertLayer) SetCase(a *models.Alert, caseID int) error {
+	a.CaseID = caseID
+	return l.db.Model(a).Update("case_id", caseID).Error
+}
+
+func (l *alertLayer) List(rule models.Rule, beforeID, limit int) ([]models.Alert, error) {
+	var alerts []models.Alert
+	q := l.db.Model(&models.Alert{})
+	if rule != "" {
+		q = q.Where("rule = ?", rule)
+	}
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	err := q.Order("id desc").Limit(limit).Find(&alerts).Error
+	return alerts, err
+}
+
+func (l *alertLayer) ListByCase(caseID int) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := l.db.Where("case_id = ?", caseID).Order("id asc").Find(&alerts).Error
+	return alerts, err
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"cashapp/internal/aml/models"
+
+	"gorm.io/gorm"
+)
+
+type caseLayer struct {
+	db *gorm.DB
+}
+
+type CaseRepo interface {
+	FindByID(id int) (*models.Case, error)
+	FindActive(userID int) (*models.Case, error)
+	Create(c *models.Case) error
+	Update(c *models.Case) error
+	AttachAlert(c *models.Case) error
+	List(status models.CaseStatus, assignee string, beforeID, limit int) ([]models.Case, error)
+	AddNote(n *models.CaseNote) error
+	Notes(caseID int) ([]models.CaseNote, error)
+}
+
+func newCaseLayer(db *gorm.DB) *caseLayer {
+	return &caseLayer{
+		db: db,
+	}
+}
+
+func (l *caseLayer) FindByID(id int) (*models.Case, error) {
+	var c models.Case
+	err := l.db.First(&c, id).Error
+	return &c, err
+}
+
+// FindActive returns the user's case that isn't closed.
+func (l *caseLayer) FindActive(userID int) (*models.Case, error) {
+	var c models.Case
+	err := l.db.Where("user_id = ? AND status <> ?", userID, models.CaseClosed).Order("id desc").First(&c).Error
+	return &c, err
+}
+
+func (l *caseLayer) Create(c *models.Case) error {
+	return l.db.Create(c).Error
+}
+
+func (l *caseLayer) Update(c *models.Case) error {
+	return l.db.Save(c).Error
+}
+
+// AttachAlert counts one more alert against the case.
+func (l *caseLayer) AttachAlert(c *models.Case) error {
+	c.AlertCount++
+	return l.db.Model(c).Update("alert_count", gorm.Expr("alert_count + 1")).Error
+}
+
+func (l *caseLayer) List(status models.CaseStatus, assignee string, beforeID, limit int) ([]models.Case, error) {
+	var cases []models.Case
+	q := l.db.Model(&models.Case{})
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if assignee != "" {
+		q = q.Where("assignee = ?", assignee)
+	}
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	err := q.Order("id desc").Limit(limit).Find(&cases).Error
+	return cases, err
+}
+
+func (l *caseLayer) AddNote(n *models.CaseNote) error {
+	return l.db.Create(n).Error
+}
+
+func (l *caseLayer) Notes(caseID int) ([]models.CaseNote, error) {
+	var notes []models.CaseNote
+	err := l.db.Where("case_id = ?", caseID).Order("id asc").Find(&notes).Error
+	return notes, err
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"cashapp/core"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Movement is the outgoing leg of a settled ledger transfer.
+type Movement struct {
+	ID        int       `json:"id"`
+	Ref       string    `json:"ref"`
+	From      int       `json:"from"`
+	To        int       `json:"to"`
+	Amount    int64     `json:"amount"` // minor units
+	Purpose   string    `json:"purpose"`
+	CreatedAt time.Time `json:"created_at"`
+	Seq       int       `json:"-"` // position in the settlement stream; see After
+}
+
+type movementLayer struct {
+	db *gorm.DB
+}
+
+// MovementRepo reads settled user-to-user transfers from the ledger.
+type MovementRepo interface {
+	After(afterID, limit int) ([]Movement, error)
+	Recent(since time.Time, afterID, throughID, limit int) ([]Movement, error)
+	Sent(userID int, since, until time.Time) ([]Movement, error)
+	Received(userID int, since, until time.Time) ([]Movement, error)
+	LastBefore(userID int, before time.Time) (*Movement, error)
+	ByRefs(refs []string) ([]Movement, error)
+}
+
+func newMovementLayer(db *gorm.DB) *movementLayer {
+	return &movementLayer{
+		db: db,
+	}
+}
+
+// scope limits a query to the outgoing legs of settled transfers. Escrow
+// legs have no user on one side and are left to the claim flow.
+func (l *movementLayer) scope() *gorm.DB {
+	return l.db.Table("transactions").
+		Select(`id, ref, "from", "to", amount, purpose, created_at`).
+		Where("direction = ? AND status = ? AND purpose = ? AND deleted_at IS NULL",
+			core.DirectionOutgoing, core.StatusSuccess, core.PurposeTransfer)
+}
+
+// After returns transfers that settled after the status change with ID
+// afterID, in the order they settled. Seq on each is the cursor to resume
+// from. Reading settlements rather than legs means a transfer held for a
+// while before it settled is still seen.
+func (l *movementLayer) After(afterID, limit int) ([]Movement, error) {
+	var ms []Movement
+	err := l.settlements().
+		Where("sc.id > ?", afterID).
+		Order("sc.id asc").
+		Limit(limit).
+		Scan(&ms).Error
+	return ms, err
+}
+
+// Recent returns transfers that settled since a point in time, with status
+// change IDs after afterID and up to throughID, in the order they settled.
+// Status change IDs are handed out before their transaction commits, so a
+// settlement can turn up behind the cursor; reading recent ones again
+// catches it.
+func (l *movementLayer) Recent(since time.Time, afterID, throughID, limit int) ([]Movement, error) {
+	var ms []Movement
+	err := l.settlements().
+		Where("sc.id > ? AND sc.id <= ? AND sc.created_at >= ?", afterID, throughID, since).
+		Order("sc.id asc").
+		Limit(limit).
+		Scan(&ms).Error
+	return ms, err
+}
+
+// settlements reads the status changes that settled transfers, with the
+// transfers' outgoing legs.
+func (l *movementLayer) settlements() *gorm.DB {
+	return l.db.Table("transaction_status_changes AS sc").
+		Select(`t.id, t.ref, t."from", t."to", t.amount, t.purpose, t.created_at, sc.id AS seq`).
+		Joins("JOIN transactions t ON t.id = sc.transaction_id").
+		Where("sc.status = ?", core.StatusSuccess).
+		Where("t.direction = ? AND t.purpose = ? AND t.deleted_at IS NULL", core.DirectionOutgoing, core.PurposeTransfer)
+}
+
+func (l *movementLayer) Sent(userID int, since, until time.Time) ([]Movement, error) {
+	var ms []Movement
+	err := l.scope().Where(`"from" = ? AND created_at > ? AND created_at <= ?`, userID, since, until).Order("id asc").Scan(&ms).Error
+	return ms, err
+}
+
+func (l *movementLayer) Received(userID int, since, until time.Time) ([]Movement, error) {
+	var ms []Movement
+	err := l.scope().Where(`"to" = ? AND created_at > ? AND created_at <= ?`, userID, since, until).Order("id asc").Scan(&ms).Error
+	return ms, err
+}
+
+// LastBefore returns the latest transfer either way involving userID before
+// a point in time.
+func (l *movementLayer) LastBefore(userID int, before time.Time) (*Movement, error) {
+	var ms []Movement
+	err := l.scope().Where(`("from" = ? OR "to" = ?) AND created_at < ?`, userID, userID, before).
+		Order("created_at desc").Limit(1).Scan(&ms).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(ms) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &ms[0], nil
+}
+
+func (l *movementLayer) ByRefs(refs []string) ([]Movement, error) {
+	var ms []Movement
+	if len(refs) == 0 {
+		return ms, nil
+	}
+	err := l.scope().Where("ref IN ?", refs).Order("id asc").Scan(&ms).Error
+	return ms, err
+}
@@ -0,0 +1,21 @@
+package repository
+
+import "gorm.io/gorm"
+
+type Repo struct {
+	Movements MovementRepo
+	Alerts    AlertRepo
+	Cases     CaseRepo
+	State     StateRepo
+	Subjects  SubjectRepo
+}
+
+func New(db *gorm.DB) Repo {
+	return Repo{
+		Movements: newMovementLayer(db),
+		Alerts:    newAlertLayer(db),
+		Cases:     newCaseLayer(db),
+		State:     newStateLayer(db),
+		Subjects:  newSubjectLayer(db),
+	}
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"cashapp/internal/aml/models"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type stateLayer struct {
+	db *gorm.DB
+}
+
+// StateRepo tracks the monitor's position in the ledger.
+type StateRepo interface {
+	Advance(name string, f func(afterID int) (int, error)) error
+}
+
+func newStateLayer(db *gorm.DB) *stateLayer {
+	return &stateLayer{
+		db: db,
+	}
+}
+
+// Advance locks the named cursor, passes its position to f and saves the
+// position f returns. When another instance holds the lock it returns
+// without calling f, so only one monitor runs at a time.
+func (l *stateLayer) Advance(name string, f func(afterID int) (int, error)) error {
+	err := l.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.MonitorState{Name: name}).Error
+	if err != nil {
+		return err
+	}
+
+	return l.db.Transaction(func(tx *gorm.DB) error {
+		var state models.MonitorState
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("name = ?", name).
+			First(&state).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		last, err := f(state.Position)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&state).Update("position", last).Error
+	})
+}
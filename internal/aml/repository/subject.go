@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"cashapp/core/fieldcrypt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Subject is what a case export says about the user under investigation.
+// Closed accounts are included; ClosedAt says when they were closed.
+type Subject struct {
+	ID        int               `json:"id"`
+	Tag       string            `json:"tag"`
+	FullName  fieldcrypt.String `json:"full_name"`
+	KYCLevel  int               `json:"kyc_level"`
+	KYCStatus string            `json:"kyc_status"`
+	RiskScore int               `json:"risk_score"`
+	ClosedAt  *time.Time        `json:"closed_at,omitempty" gorm:"column:deleted_at"`
+}
+
+type subjectLayer struct {
+	db *gorm.DB
+}
+
+// SubjectRepo reads the users the user service keeps.
+type SubjectRepo interface {
+	Find(userID int) (*Subject, error)
+}
+
+func newSubjectLayer(db *gorm.DB) *subjectLayer {
+	return &subjectLayer{
+		db: db,
+	}
+}
+
+func (l *subjectLayer) Find(userID int) (*Subject, error) {
+	var s Subject
+	err := l.db.Table("users").
+		Select("id, tag, full_name, kyc_level, kyc_status, risk_score, deleted_at").
+		Where("id = ?", userID).
+		Take(&s).Error
+	return &s, err
+}
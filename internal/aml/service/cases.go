@@ -0,0 +1,305 @@
+package service
+
+import (
+	"bytes"
+	"cashapp/core"
+	"cashapp/internal/aml/models"
+	"cashapp/internal/aml/repository"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CaseExport is everything held on a case, for regulatory reporting.
+type CaseExport struct {
+	Case         models.Case           `json:"case"`
+	Subject      *repository.Subject   `json:"subject"`
+	Alerts       []models.Alert        `json:"alerts"`
+	Notes        []models.CaseNote     `json:"notes"`
+	Transactions []repository.Movement `json:"transactions"`
+	ExportedAt   time.Time             `json:"exported_at"`
+}
+
+func (s *AMLService) ListAlerts(q core.AMLAlertQuery) core.Response {
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	limit := core.PageSize(q.Limit)
+	alerts, err := s.repository.Alerts.List(models.Rule(q.Rule), cursor.BeforeID, limit+1)
+	if err != nil {
+		return core.Error(err, core.String("failed to load alerts"))
+	}
+
+	pagination := &core.Pagination{}
+	if len(alerts) > limit {
+		alerts = alerts[:limit]
+		pagination.NextCursor = core.Cursor{BeforeID: alerts[limit-1].ID}.Encode()
+	}
+	pagination.Count = int64(len(alerts))
+
+	return core.Paginated(&map[string]interface{}{
+		"alerts": alerts,
+	}, pagination, nil)
+}
+
+func (s *AMLService) ListCases(q core.AMLCaseQuery) core.Response {
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	limit := core.PageSize(q.Limit)
+	cases, err := s.repository.Cases.List(models.CaseStatus(q.Status), q.Assignee, cursor.BeforeID, limit+1)
+	if err != nil {
+		return core.Error(err, core.String("failed to load cases"))
+	}
+
+	pagination := &core.Pagination{}
+	if len(cases) > limit {
+		cases = cases[:limit]
+		pagination.NextCursor = core.Cursor{BeforeID: cases[limit-1].ID}.Encode()
+	}
+	pagination.Count = int64(len(cases))
+
+	return core.Paginated(&map[string]interface{}{
+		"cases": cases,
+	}, pagination, nil)
+}
+
+// GetCase returns a case with its alerts and notes.
+func (s *AMLService) GetCase(id int) core.Response {
+	c, err := s.repository.Cases.FindByID(id)
+	if err != nil {
+		return notFoundOr(err, "case not found")
+	}
+
+	alerts, err := s.repository.Alerts.ListByCase(c.ID)
+	if err != nil {
+		return core.Error(err, core.String("failed to load alerts"))
+	}
+	notes, err := s.repository.Cases.Notes(c.ID)
+	if err != nil {
+		return core.Error(err, core.String("failed to load notes"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"case":   c,
+		"alerts": alerts,
+		"notes":  notes,
+	}, nil)
+}
+
+// AssignCase hands a case to an investigator. actor is the staff member
+// making the change.
+func (s *AMLService) AssignCase(id int, actor string, req core.AMLAssignRequest) core.Response {
+	c, err := s.repository.Cases.FindByID(id)
+	if err != nil {
+		return notFoundOr(err, "case not found")
+	}
+	if c.Status == models.CaseClosed {
+		return core.Error(core.Conflict(errors.New("case closed")), core.String("closed cases can't be reassigned"))
+	}
+
+	previous := c.Assignee
+	c.Assignee = req.Assignee
+	if err := s.repository.Cases.Update(c); err != nil {
+		return core.Error(err, core.String("failed to assign case"))
+	}
+	s.logChange(c, actor, fmt.Sprintf("assigned to %s (was %s)", req.Assignee, orNone(previous)))
+
+	return core.Success(&map[string]interface{}{
+		"case": c,
+	}, core.String("case assigned"))
+}
+
+// UpdateCaseStatus moves a case through investigation. Closing records the
+// disposition; a closed case is final and new alerts open a fresh one.
+func (s *AMLService) UpdateCaseStatus(id int, actor string, req core.AMLCaseStatusRequest) core.Response {
+	c, err := s.repository.Cases.FindByID(id)
+	if err != nil {
+		return notFoundOr(err, "case not found")
+	}
+	if c.Status == models.CaseClosed {
+		return core.Error(core.Conflict(errors.New("case closed")), core.String("this case is already closed"))
+	}
+
+	previous := c.Status
+	c.Status = models.CaseStatus(req.Status)
+	change := fmt.Sprintf("status changed from %s to %s", previous, c.Status)
+	if c.Status == models.CaseClosed {
+		now := time.Now()
+		c.Disposition = models.Disposition(req.Disposition)
+		c.ClosedAt = &now
+		change += fmt.Sprintf(" with disposition %s", c.Disposition)
+	}
+
+	if err := s.repository.Cases.Update(c); err != nil {
+		return core.Error(err, core.String("failed to update case"))
+	}
+	s.logChange(c, actor, change)
+
+	return core.Success(&map[string]interface{}{
+		"case": c,
+	}, core.String("case updated"))
+}
+
+func (s *AMLService) AddCaseNote(id int, author string, req core.AMLCaseNoteRequest) core.Response {
+	c, err := s.repository.Cases.FindByID(id)
+	if err != nil {
+		return notFoundOr(err, "case not found")
+	}
+
+	note := models.CaseNote{CaseID: c.ID, Author: author, Body: req.Body}
+	if err := s.repository.Cases.AddNote(&note); err != nil {
+		return core.Error(err, core.String("failed to add note"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"note": note,
+	}, core.String("note added"))
+}
+
+// ExportCase returns a case with its subject, alerts, notes and the
+// transfers behind the alerts.
+func (s *AMLService) ExportCase(id int) core.Response {
+	export, err := s.export(id)
+	if err != nil {
+		return notFoundOr(err, "case not found")
+	}
+
+	return core.Success(&map[string]interface{}{
+		"export": export,
+	}, nil)
+}
+
+// ExportCaseCSV writes the transfers behind a case one per row, each with
+// the rules that flagged it.
+func (s *AMLService) ExportCaseCSV(id int) ([]byte, error) {
+	export, err := s.export(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, core.NotFound(err)
+		}
+		return nil, err
+	}
+
+	rules := map[string][]string{}
+	for _, a := range export.Alerts {
+		for _, ref := range splitRefs(a.TransactionRefs) {
+			rules[ref] = append(rules[ref], string(a.Rule))
+		}
+	}
+
+	closedAt := ""
+	if export.Subject.ClosedAt != nil {
+		closedAt = export.Subject.ClosedAt.UTC().Format(time.RFC3339)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"case_id", "subject_id", "subject_tag", "subject_name", "subject_closed_at", "ref", "created_at", "from", "to", "amount", "rules"})
+	for _, m := range export.Transactions {
+		w.Write([]string{
+			strconv.Itoa(export.Case.ID),
+			strconv.Itoa(export.Subject.ID),
+			export.Subject.Tag,
+			string(export.Subject.FullName),
+			closedAt,
+			m.Ref,
+			m.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.Itoa(m.From),
+			strconv.Itoa(m.To),
+			core.FormatAmount(m.Amount),
+			strings.Join(rules[m.Ref], ";"),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (s *AMLService) export(id int) (*CaseExport, error) {
+	c, err := s.repository.Cases.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := s.repository.Subjects.Find(c.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Closed accounts are still found; a case must never be exported
+		// about nobody.
+		return nil, fmt.Errorf("subject %d of case %d not found", c.UserID, c.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := s.repository.Alerts.ListByCase(c.ID)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := s.repository.Cases.Notes(c.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var refs []string
+	for _, a := range alerts {
+		for _, ref := range splitRefs(a.TransactionRefs) {
+			if !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	transactions, err := s.repository.Movements.ByRefs(refs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CaseExport{
+		Case:         *c,
+		Subject:      subject,
+		Alerts:       alerts,
+		Notes:        notes,
+		Transactions: transactions,
+		ExportedAt:   time.Now(),
+	}, nil
+}
+
+// logChange keeps an audit trail of case changes among its notes.
+func (s *AMLService) logChange(c *models.Case, actor, change string) {
+	note := models.CaseNote{CaseID: c.ID, Author: actor, Body: change}
+	if err := s.repository.Cases.AddNote(&note); err != nil {
+		core.Log.Error("failed to record case change")
+	}
+}
+
+func splitRefs(refs string) []string {
+	if refs == "" {
+		return nil
+	}
+	return strings.Split(refs, ",")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "nobody"
+	}
+	return s
+}
+
+// notFoundOr reports a missing record as NotFound with the given message and
+// anything else as an internal failure.
+func notFoundOr(err error, message string) core.Response {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.Error(core.NotFound(err), core.String(message))
+	}
+	return core.Error(err, nil)
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/core/currency"
+	"cashapp/internal/aml/models"
+	"cashapp/internal/aml/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	monitorName  = "ledger"
+	monitorBatch = 500
+	// monitorLag is how long after a settlement is recorded its transaction
+	// may still commit. Settlements this recent are read again.
+	monitorLag = 5 * time.Minute
+)
+
+type AMLService struct {
+	repository repository.Repo
+	rules      Rules
+}
+
+func New(r repository.Repo, rules Rules) *AMLService {
+	return &AMLService{
+		repository: r,
+		rules:      rules,
+	}
+}
+
+// detector checks the pattern around one settled transfer, returning an
+// alert for each user the rule fires for.
+type detector func(m repository.Movement) ([]*models.Alert, error)
+
+// RunMonitor periodically reads newly settled transfers and checks the
+// patterns around each of them. It blocks; run it in a goroutine.
+func (s *AMLService) RunMonitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.scan()
+		<-ticker.C
+	}
+}
+
+func (s *AMLService) scan() {
+	since := time.Now().Add(-monitorLag)
+	rescanned := false
+	for {
+		processed := 0
+		err := s.repository.State.Advance(monitorName, func(afterID int) (int, error) {
+			if !rescanned {
+				if err := s.rescan(since, afterID); err != nil {
+					return afterID, err
+				}
+				rescanned = true
+			}
+
+			movements, err := s.repository.Movements.After(afterID, monitorBatch)
+			if err != nil {
+				return afterID, err
+			}
+			for _, m := range movements {
+				if err := s.evaluate(m); err != nil {
+					return afterID, err
+				}
+				afterID = m.Seq
+			}
+			processed = len(movements)
+			return afterID, nil
+		})
+		if err != nil {
+			core.Log.Error("aml monitor failed", zap.Error(err))
+			return
+		}
+		if processed < monitorBatch {
+			return
+		}
+	}
+}
+
+// rescan evaluates again the settlements behind the cursor recorded since
+// since, catching any whose transaction committed after the cursor passed
+// them. Evaluating a transfer twice is harmless: alerts are deduplicated by
+// their window.
+func (s *AMLService) rescan(since time.Time, throughID int) error {
+	afterID := 0
+	for {
+		movements, err := s.repository.Movements.Recent(since, afterID, throughID, monitorBatch)
+		if err != nil {
+			return err
+		}
+		for _, m := range movements {
+			if err := s.evaluate(m); err != nil {
+				return err
+			}
+			afterID = m.Seq
+		}
+		if len(movements) < monitorBatch {
+			return nil
+		}
+	}
+}
+
+func (s *AMLService) evaluate(m repository.Movement) error {
+	for _, detect := range []detector{
+		s.detectStructuring,
+		s.detectRapidMovement,
+		s.detectFanIn,
+		s.detectFanOut,
+		s.detectDormant,
+	} {
+		alerts, err := detect(m)
+		if err != nil {
+			return err
+		}
+		for _, a := range alerts {
+			if err := s.raise(a); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// raise stores an alert and files it under the user's active case, opening
+// one if needed. An alert already raised for the same window is dropped.
+func (s *AMLService) raise(a *models.Alert) error {
+	created, err := s.repository.Alerts.Create(a)
+	if err != nil || !created {
+		return err
+	}
+
+	c, err := s.repository.Cases.FindActive(a.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c = &models.Case{UserID: a.UserID, Status: models.CaseOpen}
+		err = s.repository.Cases.Create(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.Cases.AttachAlert(c); err != nil {
+		return err
+	}
+	core.Log.Info("aml alert raised", zap.Int("user_id", a.UserID), zap.String("rule", string(a.Rule)), zap.Int("case_id", c.ID))
+	return s.repository.Alerts.SetCase(a, c.ID)
+}
+
+func (s *AMLService) detectStructuring(m repository.Movement) ([]*models.Alert, error) {
+	r := s.rules.Structuring
+	if r.Threshold == 0 || r.MinCount == 0 {
+		return nil, nil
+	}
+	threshold := currency.ConvertCedisToPessewas(r.Threshold)
+	floor := int64(float64(threshold) * r.Band)
+	if m.Amount < floor || m.Amount >= threshold {
+		return nil, nil
+	}
+
+	sent, err := s.repository.Movements.Sent(m.From, m.CreatedAt.Add(-hours(r.WindowHours)), m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	var near []repository.Movement
+	for _, x := range sent {
+		if x.Amount >= floor && x.Amount < threshold {
+			near = append(near, x)
+		}
+	}
+	if len(near) < r.MinCount {
+		return nil, nil
+	}
+
+	return []*models.Alert{newAlert(m.From, models.RuleStructuring, dayKey(m.CreatedAt), near,
+		fmt.Sprintf("%d sends of %s-%s within %d hours, just under the %s threshold",
+			len(near), core.FormatAmount(floor), core.FormatAmount(threshold-1), r.WindowHours, core.FormatAmount(threshold)),
+		map[string]interface{}{"count": len(near), "total": core.FormatAmount(total(near)), "threshold": core.FormatAmount(threshold)},
+	)}, nil
+}
+
+func (s *AMLService) detectRapidMovement(m repository.Movement) ([]*models.Alert, error) {
+	r := s.rules.RapidMovement
+	if r.MinInflow == 0 || r.WindowHours == 0 {
+		return nil, nil
+	}
+	since := m.CreatedAt.Add(-hours(r.WindowHours))
+
+	received, err := s.repository.Movements.Received(m.From, since, m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	inflow := total(received)
+	if inflow < currency.ConvertCedisToPessewas(r.MinInflow) {
+		return nil, nil
+	}
+
+	sent, err := s.repository.Movements.Sent(m.From, since, m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	outflow := total(sent)
+	if float64(outflow) < r.Ratio*float64(inflow) {
+		return nil, nil
+	}
+
+	return []*models.Alert{newAlert(m.From, models.RuleRapidMovement, dayKey(m.CreatedAt), append(received, sent...),
+		fmt.Sprintf("received %s and sent out %s within %d hours", core.FormatAmount(inflow), core.FormatAmount(outflow), r.WindowHours),
+		map[string]interface{}{"inflow": core.FormatAmount(inflow), "outflow": core.FormatAmount(outflow)},
+	)}, nil
+}
+
+func (s *AMLService) detectFanIn(m repository.Movement) ([]*models.Alert, error) {
+	r := s.rules.FanIn
+	if r.MinCounterparties == 0 {
+		return nil, nil
+	}
+
+	received, err := s.repository.Movements.Received(m.To, m.CreatedAt.Add(-hours(r.WindowHours)), m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	senders := distinct(received, func(x repository.Movement) int { return x.From })
+	if senders < r.MinCounterparties {
+		return nil, nil
+	}
+
+	return []*models.Alert{newAlert(m.To, models.RuleFanIn, dayKey(m.CreatedAt), received,
+		fmt.Sprintf("paid by %d different senders within %d hours", senders, r.WindowHours),
+		map[string]interface{}{"counterparties": senders, "total": core.FormatAmount(total(received))},
+	)}, nil
+}
+
+func (s *AMLService) detectFanOut(m repository.Movement) ([]*models.Alert, error) {
+	r := s.rules.FanOut
+	if r.MinCounterparties == 0 {
+		return nil, nil
+	}
+
+	sent, err := s.repository.Movements.Sent(m.From, m.CreatedAt.Add(-hours(r.WindowHours)), m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	recipients := distinct(sent, func(x repository.Movement) int { return x.To })
+	if recipients < r.MinCounterparties {
+		return nil, nil
+	}
+
+	return []*models.Alert{newAlert(m.From, models.RuleFanOut, dayKey(m.CreatedAt), sent,
+		fmt.Sprintf("paid %d different recipients within %d hours", recipients, r.WindowHours),
+		map[string]interface{}{"counterparties": recipients, "total": core.FormatAmount(total(sent))},
+	)}, nil
+}
+
+// detectDormant checks both parties: money moving into a long-idle account
+// is as telling as money leaving one.
+func (s *AMLService) detectDormant(m repository.Movement) ([]*models.Alert, error) {
+	r := s.rules.Dormant
+	if r.IdleDays == 0 || m.Amount < currency.ConvertCedisToPessewas(r.MinAmount) {
+		return nil, nil
+	}
+	idle := time.Duration(r.IdleDays) * 24 * time.Hour
+
+	var alerts []*models.Alert
+	for _, userID := range []int{m.From, m.To} {
+		last, err := s.repository.Movements.LastBefore(userID, m.CreatedAt)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// A first transfer is a new account, not a dormant one.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		gap := m.CreatedAt.Sub(last.CreatedAt)
+		if gap <= idle {
+			continue
+		}
+		days := int(gap.Hours() / 24)
+		alerts = append(alerts, newAlert(userID, models.RuleDormantReactivation, m.Ref, []repository.Movement{m},
+			fmt.Sprintf("%s moved after %d days without activity", core.FormatAmount(m.Amount), days),
+			map[string]interface{}{"idle_days": days, "amount": core.FormatAmount(m.Amount), "previous_ref": last.Ref},
+		))
+	}
+	return alerts, nil
+}
+
+func newAlert(userID int, rule models.Rule, windowKey string, movements []repository.Movement, summary string, details map[string]interface{}) *models.Alert {
+	refs := make([]string, 0, len(movements))
+	for _, m := range movements {
+		refs = append(refs, m.Ref)
+	}
+	raw, _ := json.Marshal(details)
+
+	return &models.Alert{
+		UserID:          userID,
+		Rule:            rule,
+		WindowKey:       windowKey,
+		Summary:         summary,
+		Details:         string(raw),
+		TransactionRefs: strings.Join(refs, ","),
+	}
+}
+
+// dayKey lets a rule alert at most once per user per day.
+func dayKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+func hours(n int) time.Duration {
+	return time.Duration(n) * time.Hour
+}
+
+func total(ms []repository.Movement) int64 {
+	var sum int64
+	for _, m := range ms {
+		sum += m.Amount
+	}
+	return sum
+}
+
+func distinct(ms []repository.Movement, key func(repository.Movement) int) int {
+	seen := make(map[int]bool, len(ms))
+	for _, m := range ms {
+		seen[key(m)] = true
+	}
+	return len(seen)
+}
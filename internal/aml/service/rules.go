@@ -0,0 +1,75 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// StructuringRule flags MinCount or more sends within WindowHours that each
+// fall between Band and 100% of Threshold (major units).
+type StructuringRule struct {
+	Threshold   int64   `json:"threshold"`
+	Band        float64 `json:"band"`
+	MinCount    int     `json:"min_count"`
+	WindowHours int     `json:"window_hours"`
+}
+
+// RapidMovementRule flags a user who received at least MinInflow (major
+// units) within WindowHours and sent at least Ratio of it back out in the
+// same window.
+type RapidMovementRule struct {
+	MinInflow   int64   `json:"min_inflow"`
+	Ratio       float64 `json:"ratio"`
+	WindowHours int     `json:"window_hours"`
+}
+
+// FanRule flags MinCounterparties or more distinct counterparties within
+// WindowHours.
+type FanRule struct {
+	MinCounterparties int `json:"min_counterparties"`
+	WindowHours       int `json:"window_hours"`
+}
+
+// DormantRule flags a send of at least MinAmount (major units) by a user
+// whose previous transfer was more than IdleDays ago.
+type DormantRule struct {
+	IdleDays  int   `json:"idle_days"`
+	MinAmount int64 `json:"min_amount"`
+}
+
+// Rules configure the monitor. A zero threshold turns its rule off.
+type Rules struct {
+	Structuring   StructuringRule   `json:"structuring"`
+	RapidMovement RapidMovementRule `json:"rapid_movement"`
+	FanIn         FanRule           `json:"fan_in"`
+	FanOut        FanRule           `json:"fan_out"`
+	Dormant       DormantRule       `json:"dormant"`
+}
+
+// DefaultRules apply when no AML_FILE is configured.
+var DefaultRules = Rules{
+	Structuring:   StructuringRule{Threshold: 1000, Band: 0.9, MinCount: 3, WindowHours: 7 * 24},
+	RapidMovement: RapidMovementRule{MinInflow: 1000, Ratio: 0.8, WindowHours: 24},
+	FanIn:         FanRule{MinCounterparties: 10, WindowHours: 24},
+	FanOut:        FanRule{MinCounterparties: 10, WindowHours: 24},
+	Dormant:       DormantRule{IdleDays: 180, MinAmount: 500},
+}
+
+// LoadRules reads rules from a JSON file shaped like Rules. An empty path
+// returns DefaultRules.
+func LoadRules(path string) (Rules, error) {
+	if path == "" {
+		return DefaultRules, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, err
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return Rules{}, err
+	}
+	return rules, nil
+}
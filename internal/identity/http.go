@@ -0,0 +1,44 @@
+package identity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// do sends a request and decodes a JSON response into out. Non-2xx
+// responses become errors carrying the provider's body.
+func do(req *http.Request, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+func splitName(full string) (string, string) {
+	fields := strings.Fields(full)
+	switch len(fields) {
+	case 0:
+		return "", ""
+	case 1:
+		return fields[0], fields[0]
+	default:
+		return strings.Join(fields[:len(fields)-1], " "), fields[len(fields)-1]
+	}
+}
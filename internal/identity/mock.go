@@ -0,0 +1,133 @@
+package identity
+
+import (
+	"bytes"
+	"cashapp/core/webhooks"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MockSignatureHeader carries the mock provider's webhook signature, in the
+// form webhooks.Sign produces.
+const MockSignatureHeader = "Mock-Signature"
+
+// MockSession is a session on the mock provider, as its API returns it.
+type MockSession struct {
+	ID           string            `json:"id"`
+	Reference    string            `json:"reference"`
+	UserID       int               `json:"user_id"`
+	FullName     string            `json:"full_name"`
+	DocumentType string            `json:"document_type"`
+	Status       string            `json:"status"` // pending, passed, failed
+	Reason       string            `json:"reason,omitempty"`
+	Checks       []MockCheck       `json:"checks,omitempty"`
+	URL          string            `json:"url"`
+	CreatedAt    time.Time         `json:"created_at"`
+	CompletedAt  *time.Time        `json:"completed_at,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// MockCheck is one of the checks behind a mock verdict.
+type MockCheck struct {
+	Name   string `json:"name"`   // document_authenticity, data_consistency, face_comparison
+	Result string `json:"result"` // clear, consider
+}
+
+// MockEvent is a webhook delivery from the mock provider.
+type MockEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"` // session.completed
+	Created int64  `json:"created"`
+	Data    struct {
+		Session MockSession `json:"session"`
+	} `json:"data"`
+}
+
+// Mock talks to the self-hosted mock provider in cmd/mockidp.
+type Mock struct {
+	baseURL       string
+	apiKey        string
+	webhookSecret string
+}
+
+func NewMock(baseURL, apiKey, webhookSecret string) *Mock {
+	if baseURL == "" {
+		baseURL = "http://localhost:8090"
+	}
+	return &Mock{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, webhookSecret: webhookSecret}
+}
+
+func (m *Mock) Name() string { return "mock" }
+
+func (m *Mock) CreateSession(req SessionRequest) (*Session, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"reference":     req.Reference,
+		"user_id":       req.UserID,
+		"full_name":     req.FullName,
+		"document_type": req.DocumentType,
+	})
+	httpReq, err := http.NewRequest(http.MethodPost, m.baseURL+"/v1/sessions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	m.authorize(httpReq)
+
+	var session MockSession
+	if err := do(httpReq, &session); err != nil {
+		return nil, err
+	}
+	return &Session{ProviderRef: session.ID, URL: session.URL}, nil
+}
+
+func (m *Mock) FetchResult(providerRef string) (*Result, error) {
+	httpReq, err := http.NewRequest(http.MethodGet, m.baseURL+"/v1/sessions/"+providerRef, nil)
+	if err != nil {
+		return nil, err
+	}
+	m.authorize(httpReq)
+
+	var session MockSession
+	if err := do(httpReq, &session); err != nil {
+		return nil, err
+	}
+	return mockResult(session), nil
+}
+
+func (m *Mock) Webhook() webhooks.Source {
+	return webhooks.Source{
+		Name:     "identity." + m.Name(),
+		Verifier: webhooks.TimestampedHMAC{Header: MockSignatureHeader, Secrets: []string{m.webhookSecret}},
+		EventID:  webhooks.JSONEventID("id"),
+	}
+}
+
+func (m *Mock) ParseWebhook(body []byte) (*Result, error) {
+	var event MockEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	if event.Type != "session.completed" {
+		return nil, nil
+	}
+	return mockResult(event.Data.Session), nil
+}
+
+func (m *Mock) authorize(req *http.Request) {
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+}
+
+func mockResult(session MockSession) *Result {
+	r := &Result{ProviderRef: session.ID, Outcome: OutcomePending, Reason: session.Reason}
+	switch session.Status {
+	case "passed":
+		r.Outcome = OutcomePassed
+	case "failed":
+		r.Outcome = OutcomeFailed
+	}
+	return r
+}
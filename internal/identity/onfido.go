@@ -0,0 +1,173 @@
+package identity
+
+import (
+	"bytes"
+	"cashapp/core/webhooks"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const onfidoSignatureHeader = "X-SHA2-Signature"
+
+// Onfido verifies documents through Onfido Studio workflow runs. The
+// workflow decides which captures and checks a run needs; we only start it
+// and read its status.
+type Onfido struct {
+	baseURL      string
+	apiToken     string
+	webhookToken string
+	workflowID   string
+	completedURL string
+}
+
+func NewOnfido(baseURL, apiToken, webhookToken, workflowID, completedURL string) *Onfido {
+	if baseURL == "" {
+		baseURL = "https://api.eu.onfido.com/v3.6"
+	}
+	return &Onfido{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		apiToken:     apiToken,
+		webhookToken: webhookToken,
+		workflowID:   workflowID,
+		completedURL: completedURL,
+	}
+}
+
+func (o *Onfido) Name() string { return "onfido" }
+
+type onfidoWorkflowRun struct {
+	ID      string   `json:"id"`
+	Status  string   `json:"status"` // awaiting_input, processing, approved, declined, review, abandoned, error
+	Reasons []string `json:"reasons"`
+	Link    struct {
+		URL string `json:"url"`
+	} `json:"link"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (o *Onfido) CreateSession(req SessionRequest) (*Session, error) {
+	first, last := splitName(req.FullName)
+	var applicant struct {
+		ID string `json:"id"`
+	}
+	if err := o.post("/applicants", map[string]interface{}{
+		"first_name": first,
+		"last_name":  last,
+	}, &applicant); err != nil {
+		return nil, err
+	}
+
+	link := map[string]interface{}{}
+	if o.completedURL != "" {
+		link["completed_redirect_url"] = o.completedURL
+	}
+	var run onfidoWorkflowRun
+	if err := o.post("/workflow_runs", map[string]interface{}{
+		"workflow_id":  o.workflowID,
+		"applicant_id": applicant.ID,
+		"link":         link,
+		"custom_data": map[string]interface{}{
+			"reference":     req.Reference,
+			"document_type": req.DocumentType,
+		},
+	}, &run); err != nil {
+		return nil, err
+	}
+
+	return &Session{ProviderRef: run.ID, URL: run.Link.URL}, nil
+}
+
+func (o *Onfido) FetchResult(providerRef string) (*Result, error) {
+	httpReq, err := http.NewRequest(http.MethodGet, o.baseURL+"/workflow_runs/"+providerRef, nil)
+	if err != nil {
+		return nil, err
+	}
+	o.authorize(httpReq)
+
+	var run onfidoWorkflowRun
+	if err := do(httpReq, &run); err != nil {
+		return nil, err
+	}
+	return onfidoResult(run), nil
+}
+
+type onfidoEvent struct {
+	Payload struct {
+		ResourceType string `json:"resource_type"`
+		Action       string `json:"action"`
+		Object       struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"object"`
+	} `json:"payload"`
+}
+
+// Webhook verifies X-SHA2-Signature, an HMAC of the raw body keyed with the
+// webhook's token. Onfido events carry no ID of their own, so an action on
+// an object counts as one event.
+func (o *Onfido) Webhook() webhooks.Source {
+	return webhooks.Source{
+		Name:     "identity." + o.Name(),
+		Verifier: webhooks.HMAC{Header: onfidoSignatureHeader, Secrets: []string{o.webhookToken}},
+		EventID: func(_ http.Header, body []byte) string {
+			var event onfidoEvent
+			if json.Unmarshal(body, &event) != nil || event.Payload.Object.ID == "" {
+				return ""
+			}
+			return event.Payload.Action + ":" + event.Payload.Object.ID
+		},
+	}
+}
+
+// ParseWebhook reads workflow_run.completed events.
+func (o *Onfido) ParseWebhook(body []byte) (*Result, error) {
+	var event onfidoEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	if event.Payload.Action != "workflow_run.completed" {
+		return nil, nil
+	}
+	if event.Payload.Object.Status == "" {
+		return o.FetchResult(event.Payload.Object.ID)
+	}
+	return onfidoResult(onfidoWorkflowRun{ID: event.Payload.Object.ID, Status: event.Payload.Object.Status}), nil
+}
+
+func (o *Onfido) post(path string, payload interface{}, out interface{}) error {
+	body, _ := json.Marshal(payload)
+	httpReq, err := http.NewRequest(http.MethodPost, o.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	o.authorize(httpReq)
+	return do(httpReq, out)
+}
+
+func (o *Onfido) authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Token token="+o.apiToken)
+}
+
+// onfidoResult maps a workflow run's status. Runs Onfido sends to its own
+// manual review count as failures here, so they land in our review queue.
+func onfidoResult(run onfidoWorkflowRun) *Result {
+	r := &Result{ProviderRef: run.ID, Outcome: OutcomePending}
+	switch run.Status {
+	case "approved":
+		r.Outcome = OutcomePassed
+	case "declined", "review", "abandoned", "error":
+		r.Outcome = OutcomeFailed
+		r.Reason = run.Status
+		if len(run.Reasons) > 0 {
+			r.Reason = strings.Join(run.Reasons, ",")
+		} else if run.Error != nil {
+			r.Reason = run.Error.Type
+		}
+	}
+	return r
+}
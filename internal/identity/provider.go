@@ -0,0 +1,88 @@
+package identity
+
+import (
+	"cashapp/core"
+	"cashapp/core/webhooks"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type Outcome string
+
+const (
+	OutcomePending Outcome = "pending" // the user hasn't finished, or the provider is still checking
+	OutcomePassed  Outcome = "passed"
+	OutcomeFailed  Outcome = "failed"
+)
+
+// SessionRequest describes the document a user is about to verify.
+// Reference is ours; providers echo it back where they can.
+type SessionRequest struct {
+	Reference    string
+	UserID       int
+	FullName     string
+	DocumentType string // see core.DocumentType*
+}
+
+// Session is a verification started with a provider. The user finishes it at
+// URL.
+type Session struct {
+	ProviderRef string
+	URL         string
+}
+
+// Result is a provider's verdict on a session. Reason is the provider's code
+// for a failure, such as "document_expired".
+type Result struct {
+	ProviderRef string
+	Outcome     Outcome
+	Reason      string
+}
+
+// IdentityProvider verifies identity documents with a third party. Results
+// arrive by webhook; FetchResult is for sessions whose webhook never came.
+type IdentityProvider interface {
+	Name() string
+	CreateSession(req SessionRequest) (*Session, error)
+	FetchResult(providerRef string) (*Result, error)
+	// Webhook describes how the provider signs and identifies its webhook
+	// deliveries.
+	Webhook() webhooks.Source
+	// ParseWebhook reads the result a verified delivery carries. Deliveries
+	// about anything but a session's result return nil.
+	ParseWebhook(body []byte) (*Result, error)
+}
+
+// New returns the provider selected by IDENTITY_PROVIDER: onfido, stripe
+// or, in development only, mock (see cmd/mockidp). There is no default, so
+// a deployment can't end up approving everyone by accident.
+func New(config *core.Config) (IdentityProvider, error) {
+	switch config.IDENTITY_PROVIDER {
+	case "":
+		return nil, errors.New("IDENTITY_PROVIDER is required")
+	case "mock":
+		if config.ENVIRONMENT != core.Development {
+			return nil, fmt.Errorf("the mock identity provider is for development only, not %q", config.ENVIRONMENT)
+		}
+		return NewMock(config.IDENTITY_API_URL, config.IDENTITY_API_KEY, config.IDENTITY_SECRET), nil
+	case "onfido":
+		return NewOnfido(config.IDENTITY_API_URL, config.IDENTITY_API_KEY, config.IDENTITY_SECRET, config.IDENTITY_WORKFLOW, config.IDENTITY_RETURN_URL), nil
+	case "stripe":
+		return NewStripe(config.IDENTITY_API_URL, config.IDENTITY_API_KEY, config.IDENTITY_SECRET, config.IDENTITY_RETURN_URL), nil
+	default:
+		return nil, fmt.Errorf("unknown identity provider %q", config.IDENTITY_PROVIDER)
+	}
+}
+
+// NewReference returns a random reference for a session.
+func NewReference() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "idv_" + hex.EncodeToString(b)
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
@@ -0,0 +1,148 @@
+package identity
+
+import (
+	"cashapp/core"
+	"cashapp/core/webhooks"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const stripeSignatureHeader = "Stripe-Signature"
+
+// stripeDocumentTypes maps our document types to the ones Stripe Identity
+// accepts.
+var stripeDocumentTypes = map[string]string{
+	core.DocumentTypePassport:       "passport",
+	core.DocumentTypeDriversLicense: "driving_license",
+	core.DocumentTypeNationalID:     "id_card",
+}
+
+// Stripe verifies documents with Stripe Identity verification sessions.
+type Stripe struct {
+	baseURL       string
+	secretKey     string
+	webhookSecret string
+	returnURL     string
+}
+
+func NewStripe(baseURL, secretKey, webhookSecret, returnURL string) *Stripe {
+	if baseURL == "" {
+		baseURL = "https://api.stripe.com"
+	}
+	return &Stripe{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		returnURL:     returnURL,
+	}
+}
+
+func (s *Stripe) Name() string { return "stripe" }
+
+type stripeVerificationSession struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	Status    string `json:"status"` // requires_input, processing, verified, canceled
+	LastError *struct {
+		Code   string `json:"code"`
+		Reason string `json:"reason"`
+	} `json:"last_error"`
+}
+
+// CreateSession starts a document check. A selfie is checked as part of a
+// document session, matched against the document's photo.
+func (s *Stripe) CreateSession(req SessionRequest) (*Session, error) {
+	form := url.Values{}
+	form.Set("type", "document")
+	form.Set("client_reference_id", req.Reference)
+	form.Set("metadata[reference]", req.Reference)
+	form.Set("metadata[user_id]", strconv.Itoa(req.UserID))
+	if t, ok := stripeDocumentTypes[req.DocumentType]; ok {
+		form.Add("options[document][allowed_types][]", t)
+	}
+	if req.DocumentType == core.DocumentTypeSelfie {
+		form.Set("options[document][require_matching_selfie]", "true")
+	}
+	if s.returnURL != "" {
+		form.Set("return_url", s.returnURL)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, s.baseURL+"/v1/identity/verification_sessions", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Idempotency-Key", req.Reference)
+	s.authorize(httpReq)
+
+	var session stripeVerificationSession
+	if err := do(httpReq, &session); err != nil {
+		return nil, err
+	}
+	return &Session{ProviderRef: session.ID, URL: session.URL}, nil
+}
+
+func (s *Stripe) FetchResult(providerRef string) (*Result, error) {
+	httpReq, err := http.NewRequest(http.MethodGet, s.baseURL+"/v1/identity/verification_sessions/"+providerRef, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.authorize(httpReq)
+
+	var session stripeVerificationSession
+	if err := do(httpReq, &session); err != nil {
+		return nil, err
+	}
+	return stripeResult(session), nil
+}
+
+// Webhook verifies the Stripe-Signature header; Stripe gives every event an
+// ID.
+func (s *Stripe) Webhook() webhooks.Source {
+	return webhooks.Source{
+		Name:     "identity." + s.Name(),
+		Verifier: webhooks.TimestampedHMAC{Header: stripeSignatureHeader, Secrets: []string{s.webhookSecret}},
+		EventID:  webhooks.JSONEventID("id"),
+	}
+}
+
+// ParseWebhook reads identity.verification_session.* events.
+func (s *Stripe) ParseWebhook(body []byte) (*Result, error) {
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			Object stripeVerificationSession `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(event.Type, "identity.verification_session.") {
+		return nil, nil
+	}
+	return stripeResult(event.Data.Object), nil
+}
+
+func (s *Stripe) authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+s.secretKey)
+}
+
+// stripeResult maps a session's status. A session back in requires_input
+// with an error is a failed check; without one the user hasn't finished.
+func stripeResult(session stripeVerificationSession) *Result {
+	r := &Result{ProviderRef: session.ID, Outcome: OutcomePending}
+	switch {
+	case session.Status == "verified":
+		r.Outcome = OutcomePassed
+	case session.Status == "canceled":
+		r.Outcome = OutcomeFailed
+		r.Reason = "canceled"
+	case session.Status == "requires_input" && session.LastError != nil:
+		r.Outcome = OutcomeFailed
+		r.Reason = session.LastError.Code
+	}
+	return r
+}
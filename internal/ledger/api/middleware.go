@@ -0,0 +1,54 @@
+package api
+
+import (
+	"cashapp/core"
+	"cashapp/internal/ledger/service"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const viewerKey = "viewer_id"
+
+// Authenticate resolves the calling user and stores their ID on the context.
+func Authenticate(s *service.PaymentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// In a real system, the user ID would come from the JWT auth middleware
+		// For this example, we'll look for a "X-User-Tag" header for simulation
+		id, err := s.Authenticate(c.GetHeader("X-User-Tag"))
+		if err != nil {
+			core.Abort(c, core.Error(err, nil))
+			return
+		}
+
+		c.Set(viewerKey, id)
+		c.Next()
+	}
+}
+
+// viewerID returns the user resolved by Authenticate.
+func viewerID(c *gin.Context) int {
+	return c.GetInt(viewerKey)
+}
+
+// ViewerID returns the user resolved by Authenticate, for routes mounted
+// from other packages.
+func ViewerID(c *gin.Context) int {
+	return viewerID(c)
+}
+
+// ownUserID returns the :id path parameter. Unless it is the viewer it
+// responds with an error and returns false.
+func ownUserID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		core.Respond(c, core.Error(core.Validation(err), core.String("invalid user id")))
+		return 0, false
+	}
+	if id != viewerID(c) {
+		core.Respond(c, core.Error(core.Forbidden(errors.New("user is not the viewer")), core.String("you can only view your own account")))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"cashapp/core"
+	"cashapp/internal/ledger/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRiskRoutes registers the staff routes for reviewing payments held
+// by risk checks.
+func RegisterRiskRoutes(e *gin.Engine, s *service.PaymentService, config *core.Config) {
+	staff := e.Group("/risk", core.RequireStaff(config))
+
+	// ListRiskReviews lists payments held for review
+	// @Router /risk/reviews [get]
+	staff.GET("/reviews", func(c *gin.Context) {
+		var q core.RiskReviewQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, s.ListRiskReviews(q))
+	})
+
+	// ResolveRiskReview approves or rejects a held payment
+	// @Router /risk/reviews/:ref [post]
+	staff.POST("/reviews/:ref", func(c *gin.Context) {
+		var req core.RiskReviewDecision
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.ResolveRiskReview(c.Param("ref"), req))
+	})
+}
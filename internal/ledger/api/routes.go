@@ -3,7 +3,6 @@ package api
 import (
 	"cashapp/core"
 	"cashapp/internal/ledger/service"
-	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -15,22 +14,103 @@ func RegisterPaymentRoutes(e *gin.Engine, s *service.PaymentService) {
 	// @Router /payments [post]
 	e.POST("/payments", func(c *gin.Context) {
 		var req core.CreatePaymentRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": err.Error(),
-			})
+		if !core.BindJSON(c, &req) {
 			return
 		}
 
-		response := s.SendMoney(req)
-		if response.Error {
-			c.JSON(response.Code, gin.H{
-				"message": response.Meta.Message,
-			})
+		core.Respond(c, s.SendMoney(req))
+	})
+
+	// SendToContact pays an email address or phone number, holding the
+	// money in escrow until the recipient signs up
+	// @Router /payments/contacts [post]
+	e.POST("/payments/contacts", Authenticate(s), func(c *gin.Context) {
+		var req core.SendToContactRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.SendToContact(viewerID(c), req))
+	})
+
+	// GetTransaction retrieves both legs of a transaction by reference
+	// @Router /transactions/:ref [get]
+	e.GET("/transactions/:ref", Authenticate(s), func(c *gin.Context) {
+		core.Respond(c, s.GetTransaction(viewerID(c), c.Param("ref")))
+	})
+
+	// UpdateTransactionPrivacy changes the caller's visibility choice for a transaction
+	// @Router /transactions/:ref/privacy [put]
+	e.PUT("/transactions/:ref/privacy", Authenticate(s), func(c *gin.Context) {
+		var req core.UpdatePrivacyRequest
+		if !core.BindJSON(c, &req) {
 			return
 		}
 
-		c.JSON(response.Code, response.Meta)
+		core.Respond(c, s.UpdateTransactionPrivacy(viewerID(c), c.Param("ref"), req))
+	})
+
+	// ConfirmTransfer sends a payment held for the sender's confirmation
+	// @Router /transactions/:ref/confirm [post]
+	e.POST("/transactions/:ref/confirm", Authenticate(s), func(c *gin.Context) {
+		var req core.ConfirmTransferRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.ConfirmTransfer(viewerID(c), c.Param("ref"), req))
+	})
+
+	// ResendStepUpCode sends a fresh code to confirm a held payment with
+	// @Router /transactions/:ref/confirm/code [post]
+	e.POST("/transactions/:ref/confirm/code", Authenticate(s), func(c *gin.Context) {
+		core.Respond(c, s.ResendStepUpCode(viewerID(c), c.Param("ref")))
+	})
+
+	// ListComments returns the comment thread on a transaction
+	// @Router /transactions/:ref/comments [get]
+	e.GET("/transactions/:ref/comments", Authenticate(s), func(c *gin.Context) {
+		core.Respond(c, s.ListComments(viewerID(c), c.Param("ref")))
+	})
+
+	// AddComment comments on, or replies within, a transaction's thread
+	// @Router /transactions/:ref/comments [post]
+	e.POST("/transactions/:ref/comments", Authenticate(s), func(c *gin.Context) {
+		var req core.CreateCommentRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.AddComment(viewerID(c), c.Param("ref"), req))
+	})
+
+	// DeleteComment removes a comment
+	// @Router /transactions/:ref/comments/:id [delete]
+	e.DELETE("/transactions/:ref/comments/:id", Authenticate(s), func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			core.Respond(c, core.Error(core.Validation(err), core.String("invalid comment id")))
+			return
+		}
+
+		core.Respond(c, s.DeleteComment(viewerID(c), c.Param("ref"), id))
+	})
+
+	// AddReaction reacts to a transaction
+	// @Router /transactions/:ref/reactions [post]
+	e.POST("/transactions/:ref/reactions", Authenticate(s), func(c *gin.Context) {
+		var req core.ReactionRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.AddReaction(viewerID(c), c.Param("ref"), req))
+	})
+
+	// RemoveReaction withdraws a reaction
+	// @Router /transactions/:ref/reactions/:emoji [delete]
+	e.DELETE("/transactions/:ref/reactions/:emoji", Authenticate(s), func(c *gin.Context) {
+		core.Respond(c, s.RemoveReaction(viewerID(c), c.Param("ref"), c.Param("emoji")))
 	})
 
 	// GetBalance retrieves wallet balance
@@ -39,36 +119,89 @@ func RegisterPaymentRoutes(e *gin.Engine, s *service.PaymentService) {
 		idStr := c.Param("id")
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": "invalid wallet id",
-			})
+			core.Respond(c, core.Error(core.Validation(err), core.String("invalid wallet id")))
+			return
+		}
+
+		core.Respond(c, s.GetBalance(id))
+	})
+
+	// GetWalletTransactions lists the transactions of one of the viewer's wallets
+	// @Router /wallets/:id/transactions [get]
+	e.GET("/wallets/:id/transactions", Authenticate(s), func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			core.Respond(c, core.Error(core.Validation(err), core.String("invalid wallet id")))
 			return
 		}
 
-		response := s.GetBalance(id)
-		if response.Error {
-			c.JSON(response.Code, gin.H{
-				"message": response.Meta.Message,
-			})
+		var q core.TransactionHistoryQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, s.GetWalletTransactions(viewerID(c), id, q))
+	})
+
+	// GetUserTransactions lists the viewer's transactions
+	// @Router /users/:id/transactions [get]
+	e.GET("/users/:id/transactions", Authenticate(s), func(c *gin.Context) {
+		id, ok := ownUserID(c)
+		if !ok {
 			return
 		}
-		c.JSON(response.Code, response.Meta)
+
+		var q core.TransactionHistoryQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, s.GetUserTransactions(id, q))
+	})
+
+	// GetLimits returns the viewer's limits and remaining headroom
+	// @Router /users/:id/limits [get]
+	e.GET("/users/:id/limits", Authenticate(s), func(c *gin.Context) {
+		id, ok := ownUserID(c)
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.GetLimits(id))
 	})
+
+	// ListClaims lists the viewer's payments to unregistered contacts
+	// @Router /users/:id/claims [get]
+	e.GET("/users/:id/claims", Authenticate(s), func(c *gin.Context) {
+		id, ok := ownUserID(c)
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.ListClaims(id))
+	})
+
 	// Create Payment Request
 	// @Router /payments/requests [post]
 	e.POST("/payments/requests", func(c *gin.Context) {
 		var req core.CreateRequestDTO
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		if !core.BindJSON(c, &req) {
 			return
 		}
 
-		response := s.CreateRequest(req)
-		if response.Error {
-			c.JSON(response.Code, gin.H{"message": response.Meta.Message})
+		core.Respond(c, s.CreateRequest(req))
+	})
+
+	// RemindRequest nudges the payer of a pending request
+	// @Router /payments/requests/:id/remind [post]
+	e.POST("/payments/requests/:id/remind", Authenticate(s), func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			core.Respond(c, core.Error(core.Validation(err), core.String("invalid request id")))
 			return
 		}
-		c.JSON(response.Code, response.Meta)
+
+		core.Respond(c, s.RemindRequest(viewerID(c), id))
 	})
 
 	// Pay a Payment Request
@@ -77,54 +210,45 @@ func RegisterPaymentRoutes(e *gin.Engine, s *service.PaymentService) {
 		idStr := c.Param("id")
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request id"})
+			core.Respond(c, core.Error(core.Validation(err), core.String("invalid request id")))
 			return
 		}
 
 		// In real world, we get payer info from context.
 		// For now, we assume the authorized user is the PayerID on the request.
-		response := s.PayRequest(id, "mock-auth-key")
-		if response.Error {
-			c.JSON(response.Code, gin.H{"message": response.Meta.Message})
-			return
-		}
-		c.JSON(response.Code, response.Meta)
+		core.Respond(c, s.PayRequest(id, "mock-auth-key"))
 	})
 
-	// Get Feed (Social Activity)
-	// @Router /feed [post]
-	e.POST("/feed", func(c *gin.Context) {
-		type FeedRequest struct {
-			FriendIDs []int `json:"friend_ids"`
-		}
-		var req FeedRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	// Get Feed (Social Activity) for the authenticated user
+	// @Router /feed [get]
+	e.GET("/feed", Authenticate(s), func(c *gin.Context) {
+		var q core.FeedQuery
+		if !core.BindQuery(c, &q) {
 			return
 		}
 
-		response := s.GetFeed(req.FriendIDs)
-		if response.Error {
-			c.JSON(response.Code, gin.H{"message": response.Meta.Message})
+		core.Respond(c, s.GetFeed(viewerID(c), q))
+	})
+
+	// Get Public Feed (Global Activity)
+	// @Router /feed/public [get]
+	e.GET("/feed/public", func(c *gin.Context) {
+		var q core.FeedQuery
+		if !core.BindQuery(c, &q) {
 			return
 		}
-		c.JSON(response.Code, response.Meta)
+
+		core.Respond(c, s.GetPublicFeed(q))
 	})
 
 	// Split Bill
 	// @Router /payments/split [post]
 	e.POST("/payments/split", func(c *gin.Context) {
 		var req core.SplitBillDTO
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		if !core.BindJSON(c, &req) {
 			return
 		}
 
-		response := s.SplitBill(req)
-		if response.Error {
-			c.JSON(response.Code, gin.H{"message": response.Meta.Message})
-			return
-		}
-		c.JSON(response.Code, response.Meta)
+		core.Respond(c, s.SplitBill(req))
 	})
 }
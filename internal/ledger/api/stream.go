@@ -0,0 +1,110 @@
+package api
+
+import (
+	"cashapp/core"
+	"cashapp/internal/ledger/realtime"
+	"cashapp/internal/ledger/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+// RegisterStreamRoutes mounts the Server-Sent Events endpoint.
+func RegisterStreamRoutes(e *gin.Engine, s *service.PaymentService, b *realtime.Broker) {
+	// Stream pushes balance changes, payments, payment request updates and
+	// feed items for the caller. Reconnecting clients send Last-Event-ID
+	// (or ?last_event_id=) to receive what they missed.
+	// @Router /stream [get]
+	e.GET("/stream", Authenticate(s), func(c *gin.Context) {
+		userID := viewerID(c)
+
+		lastID := c.GetHeader("Last-Event-ID")
+		if lastID == "" {
+			lastID = c.Query("last_event_id")
+		}
+		if lastID != "" && !realtime.ValidID(lastID) {
+			core.Respond(c, core.Error(core.Validation(errors.New("malformed event id")), core.String("invalid Last-Event-ID")))
+			return
+		}
+
+		flusher, ok := c.Writer.(http.Flusher)
+		if !ok {
+			core.Respond(c, core.Error(errors.New("streaming unsupported"), nil))
+			return
+		}
+
+		// Subscribe before replaying so nothing published in between is lost;
+		// live events already covered by the replay are skipped below.
+		events, unsubscribe := b.Subscribe(userID)
+		defer unsubscribe()
+
+		var missed []realtime.Event
+		if lastID != "" {
+			var err error
+			missed, err = b.Since(c.Request.Context(), userID, lastID)
+			if err != nil {
+				core.Respond(c, core.Error(core.Upstream(err), core.String("failed to load missed events")))
+				return
+			}
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		fmt.Fprint(c.Writer, "retry: 3000\n\n")
+
+		for _, event := range missed {
+			if !writeEvent(c, event) {
+				return
+			}
+			lastID = event.ID
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(c.Writer, ": ping\n\n")
+				flusher.Flush()
+			case event, ok := <-events:
+				if !ok {
+					// Dropped for falling behind; the client resumes on reconnect.
+					return
+				}
+				if lastID != "" && !realtime.After(event.ID, lastID) {
+					continue
+				}
+				if !writeEvent(c, event) {
+					return
+				}
+				lastID = event.ID
+				flusher.Flush()
+			}
+		}
+	})
+}
+
+func writeEvent(c *gin.Context, event realtime.Event) bool {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		core.Log.Error("failed to encode event", zap.String("id", event.ID), zap.Error(err))
+		return true
+	}
+
+	_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err == nil
+}
@@ -2,21 +2,34 @@ package models
 
 import (
 	"cashapp/core"
+	"cashapp/core/fieldcrypt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// EscrowWalletID is the ledger's own wallet for funds awaiting a claim. It
+// has no row in the wallets table; its balance is the sum of its events.
+const EscrowWalletID = -1
+
 type Transaction struct {
 	core.Model
 	FailureReason     string             `json:"failure_reason"`
-	Direction         core.Direction     `json:"direction"`
+	Direction         core.Direction     `json:"direction" gorm:"uniqueIndex:idx_transactions_ref_direction"`
 	Status            core.Status        `json:"status"`
 	Description       string             `json:"description"`
-	Ref               string             `json:"ref"`
+	Ref               string             `json:"ref" gorm:"uniqueIndex:idx_transactions_ref_direction"`
 	From              int                `json:"from"`
 	To                int                `json:"to"`
 	WalletID          int                `json:"wallet_id"`
 	Amount            int64              `json:"amount"`
 	Purpose           core.Purpose       `json:"purpose"`
-	Privacy           string             `json:"privacy" gorm:"default:'private'"` // public, friends, private
+	Privacy           string             `json:"privacy" gorm:"default:'private'"` // effective: the more restrictive of the two parties' choices
+	SenderPrivacy     string             `json:"sender_privacy,omitempty"`
+	RecipientPrivacy  string             `json:"recipient_privacy,omitempty"`
+	RiskOutcome       string             `json:"risk_outcome,omitempty"` // set on the outgoing leg when risk checks ran
+	RiskReasons       string             `json:"risk_reasons,omitempty"`
 	TransactionEvents []TransactionEvent `json:"transaction_events"`
 }
 
@@ -28,11 +41,146 @@ type TransactionEvent struct {
 	Amount        int64     `json:"amount"`
 }
 
+// TransactionStatusChange records every status a transaction leg moves through.
+type TransactionStatusChange struct {
+	core.Model
+	TransactionID int         `json:"transaction_id" gorm:"index"`
+	Status        core.Status `json:"status"`
+	Reason        string      `json:"reason,omitempty"`
+}
+
+type Comment struct {
+	core.Model
+	TransactionRef string    `json:"transaction_ref" gorm:"index"`
+	UserID         int       `json:"user_id"`
+	ParentID       *int      `json:"parent_id,omitempty" gorm:"index"`
+	Body           string    `json:"body"`
+	DeletedBy      int       `json:"deleted_by,omitempty"`
+	Replies        []Comment `json:"replies,omitempty" gorm:"-"`
+}
+
+type Reaction struct {
+	core.Model
+	TransactionRef string `json:"transaction_ref" gorm:"uniqueIndex:idx_reactions_ref_user_emoji"`
+	UserID         int    `json:"user_id" gorm:"uniqueIndex:idx_reactions_ref_user_emoji"`
+	Emoji          string `json:"emoji" gorm:"uniqueIndex:idx_reactions_ref_user_emoji"`
+}
+
 type PaymentRequest struct {
 	core.Model
-	RequesterID int    `json:"requester_id"`
-	PayerID     int    `json:"payer_id"`
-	Amount      int64  `json:"amount"`
-	Status      string `json:"status"` // pending, paid, declined
-	Description string `json:"description"`
+	RequesterID        int    `json:"requester_id"`
+	PayerID            int    `json:"payer_id"`
+	Amount             int64  `json:"amount"` // pesewas; see MigrateRequestAmounts
+	Status             string `json:"status"` // pending, paid, declined
+	Description        string `json:"description"`
+	TransactionRef     string `json:"transaction_ref,omitempty" gorm:"index"`      // set while paying, and kept once paid
+	SplitTransactionID int    `json:"split_transaction_id,omitempty" gorm:"index"` // set when created by SplitBill
+}
+
+// StepUpChallenge is a one-time code sent to the sender of a payment risk
+// checks stepped up. The payment is only sent once the code comes back.
+// Sending a new code supersedes older ones.
+type StepUpChallenge struct {
+	core.Model
+	TransactionRef string     `json:"transaction_ref" gorm:"index"`
+	UserID         int        `json:"user_id"`
+	Channel        string     `json:"channel"` // email, phone
+	Salt           string     `json:"-"`
+	CodeHash       string     `json:"-"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	Attempts       int        `json:"attempts"`
+	ConsumedAt     *time.Time `json:"consumed_at,omitempty"`
+}
+
+// Migration records a one-off data migration that has run.
+type Migration struct {
+	core.Model
+	Name string `json:"name" gorm:"uniqueIndex"`
+}
+
+// MigrateRequestAmounts converts payment requests created directly, which
+// held cedis, to pesewas like split requests. It runs once.
+func MigrateRequestAmounts(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Migration{Name: "payment_requests.amount_pesewas"})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Exec("UPDATE payment_requests SET amount = amount * 100 WHERE split_transaction_id = 0 OR split_transaction_id IS NULL").Error
+	})
+}
+
+type ClaimStatus string
+
+const (
+	ClaimFunding  ClaimStatus = "funding" // money not yet in escrow
+	ClaimFailed   ClaimStatus = "failed"  // funding never completed
+	ClaimPending  ClaimStatus = "pending"
+	ClaimSettling ClaimStatus = "settling" // a worker is paying it out
+	ClaimClaimed  ClaimStatus = "claimed"
+	ClaimRefunded ClaimStatus = "refunded"
+)
+
+// EncryptedTables lists the columns kept encrypted, for the rekey command.
+// The claim target index is scoped to the users table rather than its own,
+// so it is kept up by BeforeSave and BackfillClaimIndexes instead.
+var EncryptedTables = []fieldcrypt.Table{
+	{Name: "pending_claims", Columns: []fieldcrypt.Column{{Name: "target"}}},
+}
+
+// PendingClaim is a payment to an email address or phone number with no
+// verified account yet. The money sits in escrow until someone verifies the
+// contact, or goes back to the sender when ExpiresAt passes.
+type PendingClaim struct {
+	core.Model
+	SenderID       int               `json:"sender_id" gorm:"index"`
+	Channel        string            `json:"channel"` // email, phone
+	Target         fieldcrypt.String `json:"target"`
+	TargetIndex    *string           `json:"-" gorm:"index"` // see BeforeSave
+	Amount         int64             `json:"amount"`
+	Description    string            `json:"description"`
+	Status         ClaimStatus       `json:"status" gorm:"index"`
+	TransactionRef string            `json:"transaction_ref"`       // sender to escrow
+	ReleaseRef     string            `json:"release_ref,omitempty"` // escrow to claimant or sender
+	ClaimedBy      int               `json:"claimed_by,omitempty"`
+	ExpiresAt      time.Time         `json:"expires_at"`
+	ResolvedAt     *time.Time        `json:"resolved_at,omitempty"`
+}
+
+// BeforeSave indexes Target the way the users table indexes a verified
+// contact on the same channel, so claims can be joined to their claimant.
+func (c *PendingClaim) BeforeSave(tx *gorm.DB) error {
+	if c.Target == "" {
+		return nil
+	}
+	index, err := fieldcrypt.Index("users."+c.Channel, string(c.Target))
+	if err != nil {
+		return err
+	}
+	c.TargetIndex = &index
+	return nil
+}
+
+// BackfillClaimIndexes indexes the targets of open claims made before
+// targets were indexed, so the claim worker can match them.
+func BackfillClaimIndexes(db *gorm.DB) error {
+	for {
+		var claims []PendingClaim
+		err := db.Where("target_index IS NULL AND status IN ?", []ClaimStatus{ClaimFunding, ClaimPending, ClaimSettling}).
+			Order("id").Limit(500).Find(&claims).Error
+		if err != nil || len(claims) == 0 {
+			return err
+		}
+
+		for i := range claims {
+			claim := &claims[i]
+			index, err := fieldcrypt.Index("users."+claim.Channel, string(claim.Target))
+			if err != nil {
+				return err
+			}
+			if err := db.Model(claim).UpdateColumn("target_index", index).Error; err != nil {
+				return err
+			}
+		}
+	}
 }
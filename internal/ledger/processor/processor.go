@@ -22,13 +22,13 @@ func New(r repository.Repo) Processor {
 
 func (p *Processor) ProcessTransaction(fromTrans models.Transaction) error {
 	switch fromTrans.Purpose {
-	case core.PurposeTransfer:
+	case core.PurposeTransfer, core.PurposeEscrow, core.PurposeEscrowRelease:
 		f, t, err := p.MoveMoneyBetweenWallets(fromTrans)
 		if err != nil {
-			if err := p.FailureCallback(f, t, err); err != nil {
+			if err := p.FailureCallback(&fromTrans, t, err); err != nil {
 				return fmt.Errorf("failed to complete transaction. %v", err)
 			}
-			return fmt.Errorf("money transfer failed. %v", err)
+			return fmt.Errorf("money transfer failed. %w", err)
 		}
 		if err := p.SuccessCallback(f, t); err != nil {
 			return fmt.Errorf("failed to complete transaction. %v", err)
@@ -36,11 +36,11 @@ func (p *Processor) ProcessTransaction(fromTrans models.Transaction) error {
 
 	case core.PurposeWithdrawal: // Fixed duplicate case
 		if err := p.WithdrawMoneyFromWallet(fromTrans); err != nil {
-			return fmt.Errorf("money withdrawal failed. %v", err)
+			return fmt.Errorf("money withdrawal failed. %w", err)
 		}
 	case core.PurposeDeposit:
 		if err := p.DepositMoneyIntoWallet(fromTrans); err != nil {
-			return fmt.Errorf("money deposit failed. %v", err)
+			return fmt.Errorf("money deposit failed. %w", err)
 		}
 	default:
 		core.Log.Warn("no handler for purpose", zap.Any("purpose", fromTrans.Purpose))
@@ -57,13 +57,20 @@ func (p *Processor) SuccessCallback(fromTrans, toTrans *models.Transaction) erro
 	})
 }
 
+// FailureCallback marks the legs of a transfer as failed. toTrans is nil when
+// the transfer failed before the destination leg was created.
 func (p *Processor) FailureCallback(fromTrans, toTrans *models.Transaction, err error) error {
-	fromTrans.Status = core.StatusFailed
-	toTrans.Status = core.StatusFailed
-	fromTrans.FailureReason = err.Error()
-	toTrans.FailureReason = err.Error()
+	legs := []*models.Transaction{fromTrans}
+	if toTrans != nil {
+		legs = append(legs, toTrans)
+	}
+
+	for _, leg := range legs {
+		leg.Status = core.StatusFailed
+		leg.FailureReason = err.Error()
+	}
 
 	return p.Repo.Transactions.SQLTransaction(func(tx *gorm.DB) error {
-		return p.Repo.Transactions.Updates(tx, fromTrans, toTrans)
+		return p.Repo.Transactions.Updates(tx, legs...)
 	})
 }
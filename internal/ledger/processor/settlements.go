@@ -2,7 +2,6 @@ package processor
 
 import (
 	"cashapp/core"
-	"cashapp/core/currency"
 	"cashapp/internal/ledger/models"
 	"errors"
 	"fmt"
@@ -11,15 +10,9 @@ import (
 )
 
 func (p *Processor) MoveMoneyBetweenWallets(fromTrans models.Transaction) (*models.Transaction, *models.Transaction, error) {
-
-	originWalletID, err := p.Repo.WalletLookup.GetPrimaryWalletID(fromTrans.From)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to find primary wallet for origin. %v", err)
-	}
-
-	destinationWalletID, err := p.Repo.WalletLookup.GetPrimaryWalletID(fromTrans.To)
+	originWalletID, destinationWalletID, err := p.resolveWallets(fromTrans)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to find primary wallet for destination. %v", err)
+		return nil, nil, err
 	}
 
 	balance, err := p.Repo.TransactionEvents.GetWalletBalance(originWalletID)
@@ -28,18 +21,21 @@ func (p *Processor) MoveMoneyBetweenWallets(fromTrans models.Transaction) (*mode
 	}
 
 	if balance < fromTrans.Amount {
-		return nil, nil, errors.New("insufficient balance")
+		return nil, nil, core.InsufficientFunds(errors.New("insufficient balance"))
 	}
 
 	toTrans := models.Transaction{
-		From:        fromTrans.From,
-		To:          fromTrans.To,
-		Ref:         fromTrans.Ref,
-		Amount:      currency.ConvertCedisToPessewas(fromTrans.Amount),
-		Description: fromTrans.Description,
-		Direction:   core.DirectionIncoming,
-		Status:      core.StatusPending,
-		Purpose:     core.PurposeTransfer,
+		From:          fromTrans.From,
+		To:            fromTrans.To,
+		Ref:           fromTrans.Ref,
+		Amount:        fromTrans.Amount,
+		Description:   fromTrans.Description,
+		Direction:     core.DirectionIncoming,
+		Status:        core.StatusPending,
+		Purpose:       fromTrans.Purpose,
+		WalletID:      destinationWalletID,
+		Privacy:       fromTrans.Privacy,
+		SenderPrivacy: fromTrans.SenderPrivacy,
 	}
 
 	err = p.Repo.Transactions.SQLTransaction(func(tx *gorm.DB) error {
@@ -86,6 +82,31 @@ func (p *Processor) MoveMoneyBetweenWallets(fromTrans models.Transaction) (*mode
 	return &fromTrans, &toTrans, nil
 }
 
+// resolveWallets picks the wallets a transfer moves money between. Escrow
+// payments go from the sender to the escrow wallet; releases go from the
+// escrow wallet to the recipient.
+func (p *Processor) resolveWallets(fromTrans models.Transaction) (int, int, error) {
+	originWalletID := models.EscrowWalletID
+	if fromTrans.Purpose != core.PurposeEscrowRelease {
+		id, err := p.Repo.WalletLookup.GetPrimaryWalletID(fromTrans.From)
+		if err != nil {
+			return 0, 0, core.NotFound(fmt.Errorf("failed to find primary wallet for origin. %v", err))
+		}
+		originWalletID = id
+	}
+
+	destinationWalletID := models.EscrowWalletID
+	if fromTrans.Purpose != core.PurposeEscrow {
+		id, err := p.Repo.WalletLookup.GetPrimaryWalletID(fromTrans.To)
+		if err != nil {
+			return 0, 0, core.NotFound(fmt.Errorf("failed to find primary wallet for destination. %v", err))
+		}
+		destinationWalletID = id
+	}
+
+	return originWalletID, destinationWalletID, nil
+}
+
 func (p *Processor) DepositMoneyIntoWallet(fromTrans models.Transaction) error {
 	return nil
 }
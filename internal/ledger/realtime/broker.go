@@ -0,0 +1,229 @@
+package realtime
+
+import (
+	"cashapp/core"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	// Event types pushed to connected clients.
+	EventBalanceUpdated  = "balance.updated"
+	EventPaymentSent     = "payment.sent"
+	EventPaymentReceived = "payment.received"
+	EventRequestUpdated  = "request.updated"
+	EventFeedItem        = "feed.item"
+
+	pubsubChannel = "ledger:events"
+	// historyLength is roughly how many events per user are kept for
+	// clients resuming with Last-Event-ID.
+	historyLength = 500
+	// subscriberBuffer is how many events a slow client may fall behind
+	// before it is disconnected.
+	subscriberBuffer = 64
+)
+
+// publishScript appends an event to a user's stream and broadcasts it in
+// one step, so events are broadcast in the order of their IDs and a
+// subscriber never sees a later ID before an earlier one. The broadcast is
+// the ID, a space and the event without its ID, as encoded by the caller.
+var publishScript = redis.NewScript(`
+local id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[1], '*', 'type', ARGV[2], 'data', ARGV[3])
+redis.call('PUBLISH', ARGV[4], id .. ' ' .. ARGV[5])
+return id
+`)
+
+// Event is a message for one user. ID is the Redis stream entry ID, which
+// increases monotonically per user and doubles as the SSE event ID.
+type Event struct {
+	ID     string                 `json:"id"`
+	UserID int                    `json:"user_id"`
+	Type   string                 `json:"type"`
+	Data   map[string]interface{} `json:"data"`
+}
+
+// Broker fans events out to clients connected to any ledger instance. Each
+// event is appended to a per-user Redis stream, which backs resumption, and
+// published on a shared pub/sub channel that every instance listens to and
+// dispatches to its local subscribers.
+type Broker struct {
+	redis *redis.Client
+
+	mu   sync.RWMutex
+	subs map[int]map[chan Event]struct{}
+}
+
+func NewBroker(r *redis.Client) *Broker {
+	return &Broker{
+		redis: r,
+		subs:  make(map[int]map[chan Event]struct{}),
+	}
+}
+
+// Publish records and broadcasts an event for userID. Failures are logged;
+// real-time updates are best effort and clients can always re-fetch.
+func (b *Broker) Publish(userID int, eventType string, data map[string]interface{}) {
+	if err := b.publish(context.Background(), userID, eventType, data); err != nil {
+		core.Log.Error("failed to publish event", zap.Int("user_id", userID), zap.String("type", eventType), zap.Error(err))
+	}
+}
+
+func (b *Broker) publish(ctx context.Context, userID int, eventType string, data map[string]interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	msg, err := json.Marshal(Event{UserID: userID, Type: eventType, Data: data})
+	if err != nil {
+		return err
+	}
+
+	return publishScript.Run(ctx, b.redis, []string{streamKey(userID)},
+		historyLength, eventType, payload, pubsubChannel, msg).Err()
+}
+
+// Run relays events from the pub/sub channel to local subscribers until ctx
+// is cancelled. Start it once per instance.
+func (b *Broker) Run(ctx context.Context) {
+	sub := b.redis.Subscribe(ctx, pubsubChannel)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-sub.Channel():
+			if !ok {
+				return
+			}
+			event, err := decodeBroadcast(msg.Payload)
+			if err != nil {
+				core.Log.Error("dropping malformed event", zap.Error(err))
+				continue
+			}
+			b.dispatch(event)
+		}
+	}
+}
+
+// decodeBroadcast reads an event as publishScript broadcasts it.
+func decodeBroadcast(payload string) (Event, error) {
+	var event Event
+	parts := strings.SplitN(payload, " ", 2)
+	if len(parts) != 2 || !ValidID(parts[0]) {
+		return event, errors.New("event has no stream id")
+	}
+	if err := json.Unmarshal([]byte(parts[1]), &event); err != nil {
+		return event, err
+	}
+	event.ID = parts[0]
+	return event, nil
+}
+
+func (b *Broker) dispatch(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[event.UserID] {
+		select {
+		case ch <- event:
+		default:
+			// The client can't keep up. Closing the channel ends its stream;
+			// it reconnects and resumes from its last event.
+			delete(b.subs[event.UserID], ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe registers a local subscriber for userID's events. Call the
+// returned function to unsubscribe.
+func (b *Broker) Subscribe(userID int) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan Event]struct{})
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[userID][ch]; ok {
+			delete(b.subs[userID], ch)
+			close(ch)
+		}
+		if len(b.subs[userID]) == 0 {
+			delete(b.subs, userID)
+		}
+	}
+}
+
+// Since returns userID's events after lastID, oldest first. Events older
+// than the retained history are gone; clients should re-fetch state when
+// the first event returned isn't the one they expect.
+func (b *Broker) Since(ctx context.Context, userID int, lastID string) ([]Event, error) {
+	entries, err := b.redis.XRange(ctx, streamKey(userID), lastID, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(entries))
+	for _, entry := range entries {
+		if entry.ID == lastID {
+			continue
+		}
+		event := Event{ID: entry.ID, UserID: userID}
+		event.Type, _ = entry.Values["type"].(string)
+		if raw, ok := entry.Values["data"].(string); ok {
+			if err := json.Unmarshal([]byte(raw), &event.Data); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// ValidID reports whether id looks like a Redis stream ID ("<ms>-<seq>").
+func ValidID(id string) bool {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	_, err1 := strconv.ParseUint(parts[0], 10, 64)
+	_, err2 := strconv.ParseUint(parts[1], 10, 64)
+	return err1 == nil && err2 == nil
+}
+
+// After reports whether stream ID a comes after b.
+func After(a, b string) bool {
+	am, as := splitID(a)
+	bm, bs := splitID(b)
+	return am > bm || (am == bm && as > bs)
+}
+
+func splitID(id string) (uint64, uint64) {
+	parts := strings.SplitN(id, "-", 2)
+	ms, _ := strconv.ParseUint(parts[0], 10, 64)
+	var seq uint64
+	if len(parts) == 2 {
+		seq, _ = strconv.ParseUint(parts[1], 10, 64)
+	}
+	return ms, seq
+}
+
+func streamKey(userID int) string {
+	return fmt.Sprintf("ledger:events:user:%d", userID)
+}
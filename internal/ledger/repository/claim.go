@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"cashapp/internal/ledger/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type claimLayer struct {
+	db *gorm.DB
+}
+
+type ClaimRepo interface {
+	Create(claim *models.PendingClaim) error
+	Update(claim *models.PendingClaim) error
+	ListBySender(senderID int) ([]models.PendingClaim, error)
+	ListClaimable(limit int) ([]ClaimMatch, error)
+	ListExpired(now time.Time, limit int) ([]models.PendingClaim, error)
+	Transition(claim *models.PendingClaim, from, to models.ClaimStatus) (bool, error)
+}
+
+func newClaimLayer(db *gorm.DB) *claimLayer {
+	return &claimLayer{
+		db: db,
+	}
+}
+
+func (l *claimLayer) Create(claim *models.PendingClaim) error {
+	return l.db.Create(claim).Error
+}
+
+func (l *claimLayer) Update(claim *models.PendingClaim) error {
+	return l.db.Save(claim).Error
+}
+
+func (l *claimLayer) ListBySender(senderID int) ([]models.PendingClaim, error) {
+	var claims []models.PendingClaim
+	err := l.db.Where("sender_id = ?", senderID).Order("id DESC").Find(&claims).Error
+	return claims, err
+}
+
+// ClaimMatch is a pending claim and the user who has since verified its
+// contact.
+type ClaimMatch struct {
+	models.PendingClaim
+	ClaimantID int
+}
+
+// ListClaimable returns unexpired pending claims whose contact a user has
+// verified, matched on the contact's blind index.
+func (l *claimLayer) ListClaimable(limit int) ([]ClaimMatch, error) {
+	var matches []ClaimMatch
+	err := l.db.Table("pending_claims").
+		Select("pending_claims.*, users.id AS claimant_id").
+		Joins(`JOIN users ON users.deleted_at IS NULL AND (
+			(pending_claims.channel = 'email' AND users.email_index = pending_claims.target_index) OR
+			(pending_claims.channel = 'phone' AND users.phone_index = pending_claims.target_index))`).
+		Where("pending_claims.status = ? AND pending_claims.expires_at > ? AND pending_claims.deleted_at IS NULL", models.ClaimPending, time.Now()).
+		Order("pending_claims.id").Limit(limit).Scan(&matches).Error
+	return matches, err
+}
+
+func (l *claimLayer) ListExpired(now time.Time, limit int) ([]models.PendingClaim, error) {
+	var claims []models.PendingClaim
+	err := l.db.Where("status = ? AND expires_at <= ?", models.ClaimPending, now).
+		Order("id").Limit(limit).Find(&claims).Error
+	return claims, err
+}
+
+// Transition moves a claim between statuses only if it is still in from, so
+// concurrent workers can't settle the same claim twice.
+func (l *claimLayer) Transition(claim *models.PendingClaim, from, to models.ClaimStatus) (bool, error) {
+	result := l.db.Model(&models.PendingClaim{}).
+		Where("id = ? AND status = ?", claim.ID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		claim.Status = to
+	}
+	return result.RowsAffected == 1, nil
+}
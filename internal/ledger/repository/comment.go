@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"cashapp/internal/ledger/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type commentLayer struct {
+	db *gorm.DB
+}
+
+type CommentRepo interface {
+	Create(comment *models.Comment) error
+	FindByID(id int) (*models.Comment, error)
+	ListByRef(ref string) ([]models.Comment, error)
+	CountByRefs(refs []string) (map[string]int64, error)
+	Delete(comment *models.Comment, deletedBy int) error
+}
+
+func newCommentLayer(db *gorm.DB) *commentLayer {
+	return &commentLayer{
+		db: db,
+	}
+}
+
+func (l *commentLayer) Create(comment *models.Comment) error {
+	return l.db.Create(comment).Error
+}
+
+func (l *commentLayer) FindByID(id int) (*models.Comment, error) {
+	var comment models.Comment
+	if err := l.db.Where("deleted_at IS NULL").First(&comment, id).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// ListByRef returns every comment on a transaction, oldest first, including
+// deleted ones so replies keep their place in the thread.
+func (l *commentLayer) ListByRef(ref string) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := l.db.Where("transaction_ref = ?", ref).Order("id asc").Find(&comments).Error
+	return comments, err
+}
+
+func (l *commentLayer) CountByRefs(refs []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(refs))
+	if len(refs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		TransactionRef string
+		Count          int64
+	}
+	err := l.db.Model(&models.Comment{}).
+		Select("transaction_ref, COUNT(*) AS count").
+		Where("transaction_ref IN ? AND deleted_at IS NULL", refs).
+		Group("transaction_ref").
+		Scan(&rows).Error
+	for _, r := range rows {
+		counts[r.TransactionRef] = r.Count
+	}
+	return counts, err
+}
+
+func (l *commentLayer) Delete(comment *models.Comment, deletedBy int) error {
+	now := time.Now()
+	comment.DeletedAt = &now
+	comment.DeletedBy = deletedBy
+	return l.db.Model(comment).Updates(map[string]interface{}{
+		"deleted_at": now,
+		"deleted_by": deletedBy,
+	}).Error
+}
@@ -16,6 +16,7 @@ type eventLayer struct {
 type EventRepo interface {
 	GetWalletBalance(id int) (int64, error)
 	Save(tx *gorm.DB, data *models.TransactionEvent) error
+	RunningBalances(walletID int, transactionIDs []int) (map[int]int64, error)
 }
 
 func newEventLayer(db *gorm.DB) *eventLayer {
@@ -49,6 +50,40 @@ func (el *eventLayer) GetWalletBalance(id int) (int64, error) {
 	return balance, nil
 }
 
+// RunningBalances returns the wallet balance immediately after each of the
+// given transactions was applied, keyed by transaction ID. Transactions with
+// no event on the wallet are absent from the result.
+func (el *eventLayer) RunningBalances(walletID int, transactionIDs []int) (map[int]int64, error) {
+	balances := make(map[int]int64, len(transactionIDs))
+	if len(transactionIDs) == 0 {
+		return balances, nil
+	}
+
+	rows, err := el.db.Raw(`
+		SELECT transaction_id, running_balance FROM (
+			SELECT transaction_id,
+				SUM(CASE WHEN type = ? THEN -amount ELSE amount END) OVER (ORDER BY id) AS running_balance
+			FROM transaction_events
+			WHERE wallet_id = ? AND deleted_at IS NULL
+		) AS ledger
+		WHERE transaction_id IN ?`, core.TypeDebit, walletID, transactionIDs).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var balance int64
+		if err := rows.Scan(&id, &balance); err != nil {
+			return nil, fmt.Errorf("error reading running balance: %v", err)
+		}
+		balances[id] = balance
+	}
+
+	return balances, rows.Err()
+}
+
 func (el *eventLayer) Save(tx *gorm.DB, data *models.TransactionEvent) error {
 	if err := tx.Create(data).Error; err != nil {
 		return err
@@ -14,7 +14,11 @@ type PaymentRequestRepo interface {
 	Create(req *models.PaymentRequest) error
 	FindByID(id int) (*models.PaymentRequest, error)
 	ListByPayer(payerID int) ([]models.PaymentRequest, error)
+	FindByTransactionRef(ref string) (*models.PaymentRequest, error)
+	ListBySplitTransaction(transactionIDs []int) ([]models.PaymentRequest, error)
 	Update(req *models.PaymentRequest) error
+	Link(id int, ref string) (bool, error)
+	Unlink(ref string) error
 }
 
 func newPaymentRequestLayer(db *gorm.DB) *paymentRequestLayer {
@@ -41,6 +45,37 @@ func (l *paymentRequestLayer) ListByPayer(payerID int) ([]models.PaymentRequest,
 	return reqs, err
 }
 
+func (l *paymentRequestLayer) FindByTransactionRef(ref string) (*models.PaymentRequest, error) {
+	var req models.PaymentRequest
+	if err := l.db.Where("transaction_ref = ?", ref).First(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (l *paymentRequestLayer) ListBySplitTransaction(transactionIDs []int) ([]models.PaymentRequest, error) {
+	var reqs []models.PaymentRequest
+	err := l.db.Where("split_transaction_id IN ?", transactionIDs).Find(&reqs).Error
+	return reqs, err
+}
+
 func (l *paymentRequestLayer) Update(req *models.PaymentRequest) error {
 	return l.db.Save(req).Error
 }
+
+// Link ties the payment ref to a pending request that no other payment is
+// tied to, reporting whether it did. Only one payer gets to pay a request.
+func (l *paymentRequestLayer) Link(id int, ref string) (bool, error) {
+	res := l.db.Model(&models.PaymentRequest{}).
+		Where("id = ? AND status = ? AND (transaction_ref = '' OR transaction_ref IS NULL)", id, "pending").
+		Update("transaction_ref", ref)
+	return res.RowsAffected > 0, res.Error
+}
+
+// Unlink frees the pending request tied to ref, whose payment didn't go
+// through, to be paid again.
+func (l *paymentRequestLayer) Unlink(ref string) error {
+	return l.db.Model(&models.PaymentRequest{}).
+		Where("transaction_ref = ? AND status = ?", ref, "pending").
+		Update("transaction_ref", "").Error
+}
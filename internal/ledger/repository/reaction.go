@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"cashapp/internal/ledger/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type reactionLayer struct {
+	db *gorm.DB
+}
+
+type ReactionRepo interface {
+	Add(reaction *models.Reaction) error
+	Remove(ref string, userID int, emoji string) error
+	CountByRefs(refs []string) (map[string]map[string]int64, error)
+}
+
+func newReactionLayer(db *gorm.DB) *reactionLayer {
+	return &reactionLayer{
+		db: db,
+	}
+}
+
+// Add records a reaction. Reacting twice with the same emoji is a no-op.
+func (l *reactionLayer) Add(reaction *models.Reaction) error {
+	return l.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction).Error
+}
+
+func (l *reactionLayer) Remove(ref string, userID int, emoji string) error {
+	return l.db.Unscoped().
+		Where("transaction_ref = ? AND user_id = ? AND emoji = ?", ref, userID, emoji).
+		Delete(&models.Reaction{}).Error
+}
+
+// CountByRefs returns reaction counts per emoji for each transaction.
+func (l *reactionLayer) CountByRefs(refs []string) (map[string]map[string]int64, error) {
+	counts := make(map[string]map[string]int64, len(refs))
+	if len(refs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		TransactionRef string
+		Emoji          string
+		Count          int64
+	}
+	err := l.db.Model(&models.Reaction{}).
+		Select("transaction_ref, emoji, COUNT(*) AS count").
+		Where("transaction_ref IN ?", refs).
+		Group("transaction_ref, emoji").
+		Scan(&rows).Error
+	for _, r := range rows {
+		if counts[r.TransactionRef] == nil {
+			counts[r.TransactionRef] = map[string]int64{}
+		}
+		counts[r.TransactionRef][r.Emoji] = r.Count
+	}
+	return counts, err
+}
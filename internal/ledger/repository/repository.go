@@ -6,7 +6,12 @@ type Repo struct {
 	Transactions      TransactionRepo
 	TransactionEvents EventRepo
 	WalletLookup      WalletLookupRepo
+	UserLookup        UserLookupRepo
 	PaymentRequests   PaymentRequestRepo
+	Comments          CommentRepo
+	Reactions         ReactionRepo
+	Claims            ClaimRepo
+	StepUps           StepUpRepo
 }
 
 func New(db *gorm.DB) Repo {
@@ -14,6 +19,11 @@ func New(db *gorm.DB) Repo {
 		Transactions:      newTransactionLayer(db),
 		TransactionEvents: newEventLayer(db),
 		WalletLookup:      newWalletLookupLayer(db),
+		UserLookup:        newUserLookupLayer(db),
 		PaymentRequests:   newPaymentRequestLayer(db),
+		Comments:          newCommentLayer(db),
+		Reactions:         newReactionLayer(db),
+		Claims:            newClaimLayer(db),
+		StepUps:           newStepUpLayer(db),
 	}
 }
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"cashapp/internal/ledger/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type stepUpLayer struct {
+	db *gorm.DB
+}
+
+type StepUpRepo interface {
+	Create(challenge *models.StepUpChallenge) error
+	FindLatest(ref string) (*models.StepUpChallenge, error)
+	ClaimAttempt(id, maxAttempts int) (bool, error)
+	Consume(id int, at time.Time) (bool, error)
+}
+
+func newStepUpLayer(db *gorm.DB) *stepUpLayer {
+	return &stepUpLayer{
+		db: db,
+	}
+}
+
+func (l *stepUpLayer) Create(challenge *models.StepUpChallenge) error {
+	return l.db.Create(challenge).Error
+}
+
+// FindLatest returns the most recent unconsumed challenge for a payment.
+func (l *stepUpLayer) FindLatest(ref string) (*models.StepUpChallenge, error) {
+	var challenge models.StepUpChallenge
+	err := l.db.Where("transaction_ref = ? AND consumed_at IS NULL", ref).
+		Order("id DESC").
+		First(&challenge).Error
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// ClaimAttempt counts one guess against an unconsumed challenge, reporting
+// whether it had any left. Concurrent guesses can't share an attempt.
+func (l *stepUpLayer) ClaimAttempt(id, maxAttempts int) (bool, error) {
+	res := l.db.Model(&models.StepUpChallenge{}).
+		Where("id = ? AND attempts < ? AND consumed_at IS NULL", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	return res.RowsAffected > 0, res.Error
+}
+
+// Consume marks a challenge used, reporting whether it was still unused.
+func (l *stepUpLayer) Consume(id int, at time.Time) (bool, error) {
+	res := l.db.Model(&models.StepUpChallenge{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", at)
+	return res.RowsAffected > 0, res.Error
+}
@@ -1,11 +1,30 @@
 package repository
 
 import (
+	"cashapp/core"
 	"cashapp/internal/ledger/models"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// TransactionFilter narrows a transaction history query. Exactly one of
+// WalletID or UserID scopes the query; the rest are optional.
+type TransactionFilter struct {
+	WalletID       int
+	UserID         int
+	Since          time.Time
+	Until          time.Time
+	Direction      core.Direction
+	Purpose        core.Purpose
+	Status         core.Status
+	CounterpartyID int
+	MinAmount      int64
+	MaxAmount      int64
+	BeforeID       int
+	Limit          int
+}
+
 type transactionLayer struct {
 	db *gorm.DB
 }
@@ -14,8 +33,16 @@ type TransactionRepo interface {
 	SQLTransaction(f func(tx *gorm.DB) error) error
 	Create(tx *gorm.DB, data *models.Transaction) error
 	Updates(tx *gorm.DB, transactions ...*models.Transaction) error
-	GetFeed(friendIDs []int) ([]models.Transaction, error)
+	GetFeed(viewerID int, friendIDs, blockedIDs []int, beforeID, limit int) ([]models.Transaction, error)
+	GetPublicFeed(beforeID, limit int) ([]models.Transaction, error)
 	FindByID(id int) (*models.Transaction, error)
+	History(filter TransactionFilter) ([]models.Transaction, error)
+	FindByRef(ref string) ([]models.Transaction, error)
+	UpdatePrivacy(ref string, senderPrivacy, recipientPrivacy, privacy string) error
+	StatusHistory(transactionIDs []int) ([]models.TransactionStatusChange, error)
+	ListHeld(riskOutcome string, beforeID, limit int) ([]models.Transaction, error)
+	ListHeldBefore(riskOutcome string, createdBefore time.Time, limit int) ([]models.Transaction, error)
+	Transition(trans *models.Transaction, from, to core.Status, reason string) (bool, error)
 }
 
 func newTransactionLayer(db *gorm.DB) *transactionLayer {
@@ -30,14 +57,94 @@ func (tl *transactionLayer) FindByID(id int) (*models.Transaction, error) {
 	return &tx, err
 }
 
-func (tl *transactionLayer) GetFeed(friendIDs []int) ([]models.Transaction, error) {
+// GetFeed returns completed transfers involving the viewer or one of their
+// friends that the viewer may see: public ones, friends-only ones where the
+// viewer is a friend of a party, and anything the viewer took part in.
+// Activity of blockedIDs is left out entirely.
+func (tl *transactionLayer) GetFeed(viewerID int, friendIDs, blockedIDs []int, beforeID, limit int) ([]models.Transaction, error) {
 	var txs []models.Transaction
-	// Find transactions involving any friend where privacy is not 'private'
-	// Simplification: showing all public/friends transactions involving these users.
-	err := tl.db.Where("( \"from\" IN ? OR \"to\" IN ? ) AND privacy != 'private'", friendIDs, friendIDs).
-		Order("created_at desc").
-		Limit(20).
-		Find(&txs).Error
+	circle := append([]int{viewerID}, friendIDs...)
+
+	q := feedScope(tl.db).
+		Where("(\"from\" IN ? OR \"to\" IN ?)", circle, circle).
+		Where("(privacy IN ? OR \"from\" = ? OR \"to\" = ?)",
+			[]string{core.PrivacyPublic, core.PrivacyFriends}, viewerID, viewerID)
+	if len(blockedIDs) > 0 {
+		q = q.Where("\"from\" NOT IN ? AND \"to\" NOT IN ?", blockedIDs, blockedIDs)
+	}
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+
+	err := q.Order("id desc").Limit(limit).Find(&txs).Error
+	return txs, err
+}
+
+// GetPublicFeed returns completed public transfers from everyone.
+func (tl *transactionLayer) GetPublicFeed(beforeID, limit int) ([]models.Transaction, error) {
+	var txs []models.Transaction
+
+	q := feedScope(tl.db).Where("privacy = ?", core.PrivacyPublic)
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+
+	err := q.Order("id desc").Limit(limit).Find(&txs).Error
+	return txs, err
+}
+
+// feedScope restricts to one leg per successful transfer.
+func feedScope(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Transaction{}).
+		Where("direction = ? AND purpose = ? AND status = ?",
+			core.DirectionOutgoing, core.PurposeTransfer, core.StatusSuccess)
+}
+
+// History returns one leg per transaction matching the filter, newest first.
+// For a user that is the leg they own: outgoing when they sent, incoming
+// when they received.
+func (tl *transactionLayer) History(f TransactionFilter) ([]models.Transaction, error) {
+	var txs []models.Transaction
+	q := tl.db.Model(&models.Transaction{})
+
+	if f.WalletID != 0 {
+		q = q.Where("wallet_id = ?", f.WalletID)
+	}
+	if f.UserID != 0 {
+		q = q.Where("((direction = ? AND \"from\" = ?) OR (direction = ? AND \"to\" = ?))",
+			core.DirectionOutgoing, f.UserID, core.DirectionIncoming, f.UserID).
+			Where("wallet_id <> ?", models.EscrowWalletID) // escrow legs belong to no user
+	}
+	if f.CounterpartyID != 0 {
+		q = q.Where("((direction = ? AND \"to\" = ?) OR (direction = ? AND \"from\" = ?))",
+			core.DirectionOutgoing, f.CounterpartyID, core.DirectionIncoming, f.CounterpartyID)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until)
+	}
+	if f.Direction != "" {
+		q = q.Where("direction = ?", f.Direction)
+	}
+	if f.Purpose != "" {
+		q = q.Where("purpose = ?", f.Purpose)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.MinAmount > 0 {
+		q = q.Where("amount >= ?", f.MinAmount)
+	}
+	if f.MaxAmount > 0 {
+		q = q.Where("amount <= ?", f.MaxAmount)
+	}
+	if f.BeforeID > 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+
+	err := q.Order("id desc").Limit(f.Limit).Find(&txs).Error
 	return txs, err
 }
 
@@ -49,7 +156,7 @@ func (tl *transactionLayer) Create(tx *gorm.DB, data *models.Transaction) error
 	if err := tx.Create(data).Error; err != nil {
 		return err
 	}
-	return nil
+	return recordStatus(tx, data)
 }
 
 func (tl *transactionLayer) Updates(tx *gorm.DB, transactions ...*models.Transaction) error {
@@ -57,6 +164,89 @@ func (tl *transactionLayer) Updates(tx *gorm.DB, transactions ...*models.Transac
 		if err := tx.Updates(trans).Error; err != nil {
 			return err
 		}
+		if err := recordStatus(tx, trans); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// FindByRef returns every leg sharing ref, with their events, outgoing first.
+func (tl *transactionLayer) FindByRef(ref string) ([]models.Transaction, error) {
+	var txs []models.Transaction
+	err := tl.db.Preload("TransactionEvents").
+		Where("ref = ?", ref).
+		Order("direction desc").
+		Find(&txs).Error
+	if err == nil && len(txs) == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	return txs, err
+}
+
+// UpdatePrivacy sets the privacy choices on every leg sharing ref.
+func (tl *transactionLayer) UpdatePrivacy(ref string, senderPrivacy, recipientPrivacy, privacy string) error {
+	return tl.db.Model(&models.Transaction{}).Where("ref = ?", ref).Updates(map[string]interface{}{
+		"sender_privacy":    senderPrivacy,
+		"recipient_privacy": recipientPrivacy,
+		"privacy":           privacy,
+	}).Error
+}
+
+func (tl *transactionLayer) StatusHistory(transactionIDs []int) ([]models.TransactionStatusChange, error) {
+	var changes []models.TransactionStatusChange
+	err := tl.db.Where("transaction_id IN ?", transactionIDs).Order("id asc").Find(&changes).Error
+	return changes, err
+}
+
+// ListHeld returns outgoing legs held by risk checks with the given outcome,
+// newest first.
+func (tl *transactionLayer) ListHeld(riskOutcome string, beforeID, limit int) ([]models.Transaction, error) {
+	var txs []models.Transaction
+	q := tl.db.Where("status = ? AND direction = ? AND risk_outcome = ?", core.StatusHeld, core.DirectionOutgoing, riskOutcome)
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	err := q.Order("id desc").Limit(limit).Find(&txs).Error
+	return txs, err
+}
+
+// ListHeldBefore returns outgoing legs held by risk checks with the given
+// outcome that were created before createdBefore, oldest first.
+func (tl *transactionLayer) ListHeldBefore(riskOutcome string, createdBefore time.Time, limit int) ([]models.Transaction, error) {
+	var txs []models.Transaction
+	err := tl.db.Where("status = ? AND direction = ? AND risk_outcome = ? AND created_at < ?", core.StatusHeld, core.DirectionOutgoing, riskOutcome, createdBefore).
+		Order("id").Limit(limit).Find(&txs).Error
+	return txs, err
+}
+
+// Transition moves trans from one status to another only if it is still in
+// from, reporting whether it did. Callers racing on the same leg see exactly
+// one success.
+func (tl *transactionLayer) Transition(trans *models.Transaction, from, to core.Status, reason string) (bool, error) {
+	moved := false
+	err := tl.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Transaction{}).
+			Where("id = ? AND status = ?", trans.ID, from).
+			Updates(map[string]interface{}{"status": to, "failure_reason": reason})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		moved = true
+		trans.Status = to
+		trans.FailureReason = reason
+		return recordStatus(tx, trans)
+	})
+	return moved, err
+}
+
+func recordStatus(tx *gorm.DB, trans *models.Transaction) error {
+	if trans.Status == "" {
+		return nil
+	}
+	return tx.Create(&models.TransactionStatusChange{
+		TransactionID: trans.ID,
+		Status:        trans.Status,
+		Reason:        trans.FailureReason,
+	}).Error
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"cashapp/core"
+	"cashapp/core/fieldcrypt"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Minimal definition for lookup
+type userStub struct {
+	ID             int
+	Tag            string
+	DefaultPrivacy string
+}
+
+type UserLookupRepo interface {
+	GetTags(userIDs []int) (map[int]string, error)
+	FindIDByTag(tag string) (int, error)
+	FindIDByContact(channel, value string) (int, error)
+	VerifiedContact(userID int) (channel, value string, err error)
+	FriendIDs(userID int) ([]int, error)
+	GetDefaultPrivacy(userID int) (string, error)
+	BlockedIDs(userID int) ([]int, error)
+	HasBlocked(blockerID, blockedID int) (bool, error)
+}
+
+type userLookupLayer struct {
+	db *gorm.DB
+}
+
+func newUserLookupLayer(db *gorm.DB) *userLookupLayer {
+	return &userLookupLayer{db: db}
+}
+
+func (l *userLookupLayer) GetTags(userIDs []int) (map[int]string, error) {
+	tags := make(map[int]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return tags, nil
+	}
+
+	var users []userStub
+	if err := l.db.Table("users").Select("id, tag").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		tags[u.ID] = u.Tag
+	}
+	return tags, nil
+}
+
+// FindIDByTag resolves a cash tag the same way the user service does,
+// including tags still held after a change.
+func (l *userLookupLayer) FindIDByTag(tag string) (int, error) {
+	normalized := core.NormalizeTag(tag)
+
+	var u userStub
+	err := l.db.Table("users").Select("id").Where("normalized_tag = ? AND deleted_at IS NULL", normalized).First(&u).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return u.ID, err
+	}
+
+	var ids []int
+	err = l.db.Table("tag_changes").
+		Where("normalized_old_tag = ? AND held_until > ? AND deleted_at IS NULL", normalized, time.Now()).
+		Order("held_until DESC").
+		Limit(1).
+		Pluck("user_id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return ids[0], nil
+}
+
+// FindIDByContact finds the user who verified an email address or phone
+// number. channel is "email" or "phone".
+func (l *userLookupLayer) FindIDByContact(channel, value string) (int, error) {
+	column := "email"
+	if channel == "phone" {
+		column = "phone"
+	}
+
+	index, err := fieldcrypt.Index("users."+column, value)
+	if err != nil {
+		return 0, err
+	}
+
+	var u userStub
+	err = l.db.Table("users").Select("id").Where(column+"_index = ? AND deleted_at IS NULL", index).First(&u).Error
+	return u.ID, err
+}
+
+// VerifiedContact returns where to reach a user with a one-time code: their
+// verified phone number, or else their verified email address. channel is
+// empty when they have neither.
+func (l *userLookupLayer) VerifiedContact(userID int) (string, string, error) {
+	var u struct {
+		Phone *fieldcrypt.String
+		Email *fieldcrypt.String
+	}
+	err := l.db.Table("users").Select("phone, email").Where("id = ? AND deleted_at IS NULL", userID).Take(&u).Error
+	switch {
+	case err != nil:
+		return "", "", err
+	case u.Phone != nil && *u.Phone != "":
+		return "phone", string(*u.Phone), nil
+	case u.Email != nil && *u.Email != "":
+		return "email", string(*u.Email), nil
+	}
+	return "", "", nil
+}
+
+// FriendIDs returns the users with an accepted friendship with userID.
+func (l *userLookupLayer) FriendIDs(userID int) ([]int, error) {
+	var ids []int
+	err := l.db.Table("friendships").
+		Where("user_id = ? AND status = ? AND deleted_at IS NULL", userID, "accepted").
+		Pluck("friend_id", &ids).Error
+	return ids, err
+}
+
+func (l *userLookupLayer) GetDefaultPrivacy(userID int) (string, error) {
+	var u userStub
+	err := l.db.Table("users").Select("default_privacy").Where("id = ?", userID).First(&u).Error
+	return u.DefaultPrivacy, err
+}
+
+// BlockedIDs returns the users userID has blocked or been blocked by.
+func (l *userLookupLayer) BlockedIDs(userID int) ([]int, error) {
+	var ids []int
+	err := l.db.Raw(`
+		SELECT friend_id FROM friendships WHERE user_id = ? AND status = ? AND deleted_at IS NULL
+		UNION
+		SELECT user_id FROM friendships WHERE friend_id = ? AND status = ? AND deleted_at IS NULL`,
+		userID, "blocked", userID, "blocked").Scan(&ids).Error
+	return ids, err
+}
+
+func (l *userLookupLayer) HasBlocked(blockerID, blockedID int) (bool, error) {
+	var count int64
+	err := l.db.Table("friendships").
+		Where("user_id = ? AND friend_id = ? AND status = ? AND deleted_at IS NULL", blockerID, blockedID, "blocked").
+		Count(&count).Error
+	return count > 0, err
+}
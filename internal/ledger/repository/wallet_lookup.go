@@ -13,6 +13,7 @@ type walletStub struct {
 
 type WalletLookupRepo interface {
 	GetPrimaryWalletID(userID int) (int, error)
+	GetOwnerID(walletID int) (int, error)
 }
 
 type walletLookupLayer struct {
@@ -28,3 +29,9 @@ func (l *walletLookupLayer) GetPrimaryWalletID(userID int) (int, error) {
 	err := l.db.Table("wallets").Select("id").Where("user_id = ? AND is_primary = ?", userID, true).First(&w).Error
 	return w.ID, err
 }
+
+func (l *walletLookupLayer) GetOwnerID(walletID int) (int, error) {
+	var w walletStub
+	err := l.db.Table("wallets").Select("user_id").Where("id = ?", walletID).First(&w).Error
+	return w.UserID, err
+}
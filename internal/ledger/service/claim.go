@@ -0,0 +1,263 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/core/currency"
+	"cashapp/core/fieldcrypt"
+	"cashapp/internal/ledger/models"
+	"cashapp/internal/limits"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	claimBatchSize = 100
+)
+
+// SendToContact pays an email address or phone number. If a user has
+// verified it the money goes straight to them; otherwise it is held in
+// escrow and the contact is invited to sign up and claim it.
+func (p *PaymentService) SendToContact(senderID int, req core.SendToContactRequest) core.Response {
+	target, err := normalizeContact(req.Channel, req.Target)
+	if err != nil {
+		return core.Error(core.Validation(err), core.String(err.Error()))
+	}
+
+	recipientID, err := p.repository.UserLookup.FindIDByContact(req.Channel, target)
+	switch {
+	case err == nil:
+		if recipientID == senderID {
+			return core.Error(core.Validation(errors.New("payment to self")), core.String("you cannot send money to yourself"))
+		}
+		paid, err := p.sendMoney(core.CreatePaymentRequest{
+			From:        senderID,
+			To:          recipientID,
+			Amount:      req.Amount,
+			Description: req.Description,
+		})
+		if err != nil {
+			return core.Error(err, nil)
+		}
+		return core.Success(&map[string]interface{}{
+			"ref":          paid.Ref,
+			"recipient_id": recipientID,
+		}, nil)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return core.Error(err, nil)
+	}
+
+	// Until someone claims it, the contact itself counts as the counterparty.
+	contact, err := fieldcrypt.Index("users."+req.Channel, target)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	movement := limits.Movement{
+		UserID:  senderID,
+		Kind:    limits.KindSend,
+		Amount:  currency.ConvertCedisToPessewas(req.Amount),
+		Contact: contact,
+	}
+	if err := p.checkLimits(movement); err != nil {
+		return core.Error(err, nil)
+	}
+
+	escrow := models.Transaction{
+		From:          senderID,
+		Ref:           core.GenerateRef(),
+		Amount:        currency.ConvertCedisToPessewas(req.Amount),
+		Description:   req.Description,
+		Direction:     core.DirectionOutgoing,
+		Status:        core.StatusPending,
+		Purpose:       core.PurposeEscrow,
+		Privacy:       core.PrivacyPrivate,
+		SenderPrivacy: core.PrivacyPrivate,
+	}
+	if err := p.assessEscrow(&escrow); err != nil {
+		return core.Error(err, nil)
+	}
+
+	// The claim exists before the money moves so escrowed funds are never
+	// left without a claim pointing at them.
+	claim := models.PendingClaim{
+		SenderID:       senderID,
+		Channel:        req.Channel,
+		Target:         fieldcrypt.String(target),
+		Amount:         escrow.Amount,
+		Description:    req.Description,
+		Status:         models.ClaimFunding,
+		TransactionRef: escrow.Ref,
+		ExpiresAt:      time.Now().Add(p.config.CLAIM_EXPIRY),
+	}
+	if err := p.repository.Claims.Create(&claim); err != nil {
+		return core.Error(err, core.String("failed to create claim"))
+	}
+
+	_, err = p.settle(escrow, []limits.Movement{movement})
+	if err != nil {
+		if _, terr := p.repository.Claims.Transition(&claim, models.ClaimFunding, models.ClaimFailed); terr != nil {
+			core.Log.Error("failed to mark claim failed", zap.Int("claim_id", claim.ID), zap.Error(terr))
+		}
+		return core.Error(err, nil)
+	}
+
+	if _, err := p.repository.Claims.Transition(&claim, models.ClaimFunding, models.ClaimPending); err != nil {
+		// Funds are in escrow; the claim needs reconciling by hand.
+		core.Log.Error("failed to activate funded claim", zap.Int("claim_id", claim.ID), zap.String("ref", claim.TransactionRef), zap.Error(err))
+		return core.Error(err, nil)
+	}
+
+	p.sendInvite(claim)
+
+	return core.Success(&map[string]interface{}{
+		"ref":   claim.TransactionRef,
+		"claim": claim,
+	}, core.String("payment held until the recipient signs up"))
+}
+
+// ListClaims returns the payments a user has sent to unregistered contacts.
+func (p *PaymentService) ListClaims(senderID int) core.Response {
+	claims, err := p.repository.Claims.ListBySender(senderID)
+	if err != nil {
+		return core.Error(err, core.String("failed to load claims"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"claims": claims,
+	}, nil)
+}
+
+// RunClaimWorker periodically pays out claims whose contact has since been
+// verified and refunds claims that expired. It blocks; run it in a goroutine.
+func (p *PaymentService) RunClaimWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.settleClaims()
+		p.refundExpiredClaims()
+		<-ticker.C
+	}
+}
+
+func (p *PaymentService) settleClaims() {
+	matches, err := p.repository.Claims.ListClaimable(claimBatchSize)
+	if err != nil {
+		core.Log.Error("failed to load claimable claims", zap.Error(err))
+		return
+	}
+
+	for i := range matches {
+		claim, userID := &matches[i].PendingClaim, matches[i].ClaimantID
+		if err := p.releaseClaim(claim, userID, models.ClaimClaimed); err != nil {
+			core.Log.Error("failed to pay out claim", zap.Int("claim_id", claim.ID), zap.Error(err))
+			continue
+		}
+
+		p.notifier.Notify(claim.SenderID, core.EventClaimCompleted, map[string]interface{}{
+			"claim_id":   claim.ID,
+			"claimed_by": userID,
+			"amount":     core.FormatAmount(claim.Amount),
+		})
+	}
+}
+
+func (p *PaymentService) refundExpiredClaims() {
+	claims, err := p.repository.Claims.ListExpired(time.Now(), claimBatchSize)
+	if err != nil {
+		core.Log.Error("failed to load expired claims", zap.Error(err))
+		return
+	}
+
+	for i := range claims {
+		claim := &claims[i]
+		if err := p.releaseClaim(claim, claim.SenderID, models.ClaimRefunded); err != nil {
+			core.Log.Error("failed to refund claim", zap.Int("claim_id", claim.ID), zap.Error(err))
+			continue
+		}
+
+		p.notifier.Notify(claim.SenderID, core.EventClaimRefunded, map[string]interface{}{
+			"claim_id": claim.ID,
+			"amount":   core.FormatAmount(claim.Amount),
+		})
+	}
+}
+
+// releaseClaim moves a claim's money out of escrow to userID and records the
+// outcome. The claim is locked in the settling status while money moves; on
+// failure it goes back to pending to be retried.
+func (p *PaymentService) releaseClaim(claim *models.PendingClaim, userID int, outcome models.ClaimStatus) error {
+	// A refund isn't new income for the sender, so only a claim counts.
+	// Over the claimant's limits the money waits, and is refunded if it is
+	// still waiting when the claim expires.
+	var movements []limits.Movement
+	if outcome == models.ClaimClaimed {
+		movements = []limits.Movement{{UserID: userID, Kind: limits.KindReceive, Amount: claim.Amount, CounterpartyID: claim.SenderID}}
+		if err := p.checkLimits(movements...); err != nil {
+			return err
+		}
+	}
+
+	ok, err := p.repository.Claims.Transition(claim, models.ClaimPending, models.ClaimSettling)
+	if err != nil || !ok {
+		return err // another worker got there first when !ok
+	}
+
+	description := "Claimed: " + claim.Description
+	if outcome == models.ClaimRefunded {
+		description = fmt.Sprintf("Refund: unclaimed payment to %s", claim.Target)
+	}
+
+	released, err := p.settle(models.Transaction{
+		From:          claim.SenderID,
+		To:            userID,
+		Ref:           core.GenerateRef(),
+		Amount:        claim.Amount,
+		Description:   description,
+		Direction:     core.DirectionOutgoing,
+		Status:        core.StatusPending,
+		Purpose:       core.PurposeEscrowRelease,
+		WalletID:      models.EscrowWalletID,
+		Privacy:       core.PrivacyPrivate,
+		SenderPrivacy: core.PrivacyPrivate,
+	}, movements)
+	if err != nil {
+		if _, terr := p.repository.Claims.Transition(claim, models.ClaimSettling, models.ClaimPending); terr != nil {
+			core.Log.Error("failed to return claim to pending", zap.Int("claim_id", claim.ID), zap.Error(terr))
+		}
+		return err
+	}
+
+	now := time.Now()
+	claim.Status = outcome
+	claim.ReleaseRef = released.Ref
+	claim.ResolvedAt = &now
+	if outcome == models.ClaimClaimed {
+		claim.ClaimedBy = userID
+	}
+	return p.repository.Claims.Update(claim)
+}
+
+func (p *PaymentService) sendInvite(claim models.PendingClaim) {
+	sender := "Someone"
+	if tag := p.tagOf(claim.SenderID); tag != "" {
+		sender = "$" + tag
+	}
+
+	body := fmt.Sprintf("%s sent you %s on CashApp. Sign up and verify this %s by %s to claim it.",
+		sender, core.FormatAmount(claim.Amount), claim.Channel, claim.ExpiresAt.Format("2 Jan 2006"))
+	if err := p.sender.Send(claim.Channel, string(claim.Target), body); err != nil {
+		// The claim stands; the recipient can still sign up on their own.
+		core.Log.Error("failed to send claim invite", zap.Int("claim_id", claim.ID), zap.Error(err))
+	}
+}
+
+func normalizeContact(channel, value string) (string, error) {
+	if channel == "phone" {
+		return core.NormalizePhone(value)
+	}
+	return core.NormalizeEmail(value)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/core/currency"
+	"cashapp/internal/ledger/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Authenticate resolves the caller's cash tag to a user ID.
+func (p *PaymentService) Authenticate(tag string) (int, error) {
+	if tag == "" {
+		return 0, core.Unauthorized(errors.New("missing X-User-Tag header"))
+	}
+
+	id, err := p.repository.UserLookup.FindIDByTag(tag)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, core.Unauthorized(errors.New("unknown user tag"))
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetFeed returns the viewer's social feed. Friends are resolved from
+// accepted friendships rather than trusted from the client.
+func (p *PaymentService) GetFeed(viewerID int, q core.FeedQuery) core.Response {
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	friendIDs, err := p.repository.UserLookup.FriendIDs(viewerID)
+	if err != nil {
+		return core.Error(err, core.String("failed to load friends"))
+	}
+
+	blockedIDs, err := p.repository.UserLookup.BlockedIDs(viewerID)
+	if err != nil {
+		return core.Error(err, core.String("failed to load blocked users"))
+	}
+
+	limit := core.PageSize(q.Limit)
+	txs, err := p.repository.Transactions.GetFeed(viewerID, friendIDs, blockedIDs, cursor.BeforeID, limit+1)
+	if err != nil {
+		return core.Error(err, core.String("failed to fetch feed"))
+	}
+
+	return p.feedResponse(txs, limit)
+}
+
+// GetPublicFeed returns public activity from everyone.
+func (p *PaymentService) GetPublicFeed(q core.FeedQuery) core.Response {
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	limit := core.PageSize(q.Limit)
+	txs, err := p.repository.Transactions.GetPublicFeed(cursor.BeforeID, limit+1)
+	if err != nil {
+		return core.Error(err, core.String("failed to fetch feed"))
+	}
+
+	return p.feedResponse(txs, limit)
+}
+
+func (p *PaymentService) feedResponse(txs []models.Transaction, limit int) core.Response {
+	pagination := &core.Pagination{}
+	if len(txs) > limit {
+		txs = txs[:limit]
+		pagination.NextCursor = core.Cursor{BeforeID: txs[limit-1].ID}.Encode()
+	}
+	pagination.Count = int64(len(txs))
+
+	userIDs := make([]int, 0, len(txs)*2)
+	refs := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		userIDs = append(userIDs, tx.From, tx.To)
+		refs = append(refs, tx.Ref)
+	}
+	tags, err := p.repository.UserLookup.GetTags(userIDs)
+	if err != nil {
+		return core.Error(err, core.String("failed to resolve users"))
+	}
+
+	reactions, err := p.repository.Reactions.CountByRefs(refs)
+	if err != nil {
+		return core.Error(err, core.String("failed to count reactions"))
+	}
+	comments, err := p.repository.Comments.CountByRefs(refs)
+	if err != nil {
+		return core.Error(err, core.String("failed to count comments"))
+	}
+
+	// Transform to simplified feed items
+	feed := make([]map[string]interface{}, 0, len(txs))
+	for _, tx := range txs {
+		feed = append(feed, map[string]interface{}{
+			"id":            tx.ID,
+			"ref":           tx.Ref,
+			"from":          tx.From,
+			"from_tag":      tags[tx.From],
+			"to":            tx.To,
+			"to_tag":        tags[tx.To],
+			"amount":        currency.ConvertPessewasToCedis(tx.Amount),
+			"description":   tx.Description,
+			"timestamp":     tx.CreatedAt,
+			"privacy":       tx.Privacy,
+			"reactions":     reactions[tx.Ref],
+			"comment_count": comments[tx.Ref],
+		})
+	}
+
+	return core.Paginated(&map[string]interface{}{"feed": feed}, pagination, nil)
+}
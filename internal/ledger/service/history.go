@@ -0,0 +1,126 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/core/currency"
+	"cashapp/internal/ledger/models"
+	"cashapp/internal/ledger/repository"
+	"errors"
+	"time"
+)
+
+type HistoryItem struct {
+	ID              int            `json:"id"`
+	Ref             string         `json:"ref"`
+	Direction       core.Direction `json:"direction"`
+	Purpose         core.Purpose   `json:"purpose"`
+	Status          core.Status    `json:"status"`
+	Amount          int64          `json:"amount"`
+	Description     string         `json:"description"`
+	CounterpartyID  int            `json:"counterparty_id"`
+	CounterpartyTag string         `json:"counterparty_tag,omitempty"`
+	RunningBalance  *int64         `json:"running_balance,omitempty"`
+	FailureReason   string         `json:"failure_reason,omitempty"`
+	Timestamp       time.Time      `json:"timestamp"`
+}
+
+// GetWalletTransactions lists the transactions of one of the viewer's
+// wallets.
+func (p *PaymentService) GetWalletTransactions(viewerID, walletID int, q core.TransactionHistoryQuery) core.Response {
+	ownerID, err := p.repository.WalletLookup.GetOwnerID(walletID)
+	if err != nil {
+		return notFoundOr(err, "wallet not found")
+	}
+	if ownerID != viewerID {
+		return core.Error(core.Forbidden(errors.New("wallet belongs to another user")), core.String("you can only view your own wallets"))
+	}
+	return p.transactionHistory(repository.TransactionFilter{WalletID: walletID}, walletID, q)
+}
+
+func (p *PaymentService) GetUserTransactions(userID int, q core.TransactionHistoryQuery) core.Response {
+	walletID, err := p.repository.WalletLookup.GetPrimaryWalletID(userID)
+	if err != nil {
+		return notFoundOr(err, "wallet not found")
+	}
+	return p.transactionHistory(repository.TransactionFilter{UserID: userID}, walletID, q)
+}
+
+func (p *PaymentService) transactionHistory(filter repository.TransactionFilter, walletID int, q core.TransactionHistoryQuery) core.Response {
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	limit := core.PageSize(q.Limit)
+	filter.Since = q.Since
+	filter.Until = q.Until
+	filter.Direction = core.Direction(q.Direction)
+	filter.Purpose = core.Purpose(q.Purpose)
+	filter.Status = core.Status(q.Status)
+	filter.CounterpartyID = q.Counterparty
+	filter.MinAmount = currency.ConvertCedisToPessewas(q.MinAmount)
+	filter.MaxAmount = currency.ConvertCedisToPessewas(q.MaxAmount)
+	filter.BeforeID = cursor.BeforeID
+	filter.Limit = limit + 1 // one extra row tells us whether another page exists
+
+	txs, err := p.repository.Transactions.History(filter)
+	if err != nil {
+		return core.Error(err, core.String("failed to fetch transactions"))
+	}
+
+	pagination := &core.Pagination{}
+	if len(txs) > limit {
+		txs = txs[:limit]
+		pagination.NextCursor = core.Cursor{BeforeID: txs[limit-1].ID}.Encode()
+	}
+	pagination.Count = int64(len(txs))
+
+	ids := make([]int, 0, len(txs))
+	counterparties := make([]int, 0, len(txs))
+	for _, tx := range txs {
+		ids = append(ids, tx.ID)
+		counterparties = append(counterparties, counterpartyOf(tx))
+	}
+
+	balances, err := p.repository.TransactionEvents.RunningBalances(walletID, ids)
+	if err != nil {
+		return core.Error(err, core.String("failed to compute running balances"))
+	}
+
+	tags, err := p.repository.UserLookup.GetTags(counterparties)
+	if err != nil {
+		return core.Error(err, core.String("failed to resolve counterparties"))
+	}
+
+	items := make([]HistoryItem, 0, len(txs))
+	for _, tx := range txs {
+		item := HistoryItem{
+			ID:              tx.ID,
+			Ref:             tx.Ref,
+			Direction:       tx.Direction,
+			Purpose:         tx.Purpose,
+			Status:          tx.Status,
+			Amount:          currency.ConvertPessewasToCedis(tx.Amount),
+			Description:     tx.Description,
+			CounterpartyID:  counterpartyOf(tx),
+			CounterpartyTag: tags[counterpartyOf(tx)],
+			FailureReason:   tx.FailureReason,
+			Timestamp:       tx.CreatedAt,
+		}
+		if balance, ok := balances[tx.ID]; ok {
+			b := currency.ConvertPessewasToCedis(balance)
+			item.RunningBalance = &b
+		}
+		items = append(items, item)
+	}
+
+	return core.Paginated(&map[string]interface{}{"transactions": items}, pagination, nil)
+}
+
+// counterpartyOf returns the other party of a transaction leg.
+func counterpartyOf(tx models.Transaction) int {
+	if tx.Direction == core.DirectionIncoming {
+		return tx.From
+	}
+	return tx.To
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/ledger/models"
+	"cashapp/internal/limits"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+// GetLimits returns a user's KYC-tiered limits and the headroom left under
+// each.
+func (p *PaymentService) GetLimits(userID int) core.Response {
+	if p.limits == nil {
+		return core.Error(errors.New("limits engine not configured"), nil)
+	}
+
+	summary, err := p.limits.Summary(userID)
+	if err != nil {
+		return notFoundOr(err, "user not found")
+	}
+
+	return core.Success(&map[string]interface{}{
+		"limits": summary,
+	}, nil)
+}
+
+// checkLimits checks movements against their users' limits when a limits
+// engine is configured. It takes no locks, so reserveLimits must still
+// succeed before the money moves.
+func (p *PaymentService) checkLimits(movements ...limits.Movement) error {
+	if p.limits == nil {
+		return nil
+	}
+	for _, m := range movements {
+		if err := p.limits.Check(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reserveLimits counts a transfer's movements against limits before its
+// money moves, failing if any no longer fits.
+func (p *PaymentService) reserveLimits(ref string, movements ...limits.Movement) error {
+	if p.limits == nil || len(movements) == 0 {
+		return nil
+	}
+	return p.limits.Reserve(ref, movements...)
+}
+
+// releaseLimits stops counting a transfer whose money didn't move.
+func (p *PaymentService) releaseLimits(ref string) {
+	if p.limits == nil {
+		return
+	}
+	if err := p.limits.Release(ref); err != nil {
+		core.Log.Error("failed to release limit usage", zap.String("ref", ref), zap.Error(err))
+	}
+}
+
+// transferMovements returns what a transfer between users moves for each of
+// them, for checking against their limits.
+func transferMovements(tx *models.Transaction) []limits.Movement {
+	return []limits.Movement{
+		{UserID: tx.From, Kind: limits.KindSend, Amount: tx.Amount, CounterpartyID: tx.To},
+		{UserID: tx.To, Kind: limits.KindReceive, Amount: tx.Amount, CounterpartyID: tx.From},
+	}
+}
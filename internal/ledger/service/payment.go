@@ -6,6 +6,11 @@ import (
 	"cashapp/internal/ledger/models"
 	"cashapp/internal/ledger/processor"
 	"cashapp/internal/ledger/repository"
+	"cashapp/internal/limits"
+	"cashapp/internal/risk"
+	"errors"
+	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -15,6 +20,11 @@ type PaymentService struct {
 	repository repository.Repo
 	config     *core.Config
 	processor  processor.Processor
+	notifier   core.Notifier
+	sender     core.MessageSender
+	events     EventPublisher
+	limits     *limits.Engine
+	risk       *risk.Engine
 }
 
 func New(r repository.Repo, c *core.Config) *PaymentService {
@@ -22,20 +32,92 @@ func New(r repository.Repo, c *core.Config) *PaymentService {
 		repository: r,
 		config:     c,
 		processor:  processor.New(r),
+		notifier:   core.LogNotifier{},
+		sender:     core.NewMessageSender(c),
+		events:     nopPublisher{},
 	}
 }
 
+// SetLimits enables KYC-tiered limit checks on money movement.
+func (p *PaymentService) SetLimits(l *limits.Engine) {
+	p.limits = l
+}
+
+// SetNotifier replaces the notifier used for user-facing notifications.
+func (p *PaymentService) SetNotifier(n core.Notifier) {
+	p.notifier = n
+}
+
 func (p *PaymentService) SendMoney(req core.CreatePaymentRequest) core.Response {
+	fromTrans, err := p.sendMoney(req)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+
+	return core.Success(&map[string]interface{}{
+		"ref": fromTrans.Ref,
+	}, nil)
+}
+
+// sendMoney records the outgoing leg and settles the transfer, returning the
+// outgoing leg.
+func (p *PaymentService) sendMoney(req core.CreatePaymentRequest) (*models.Transaction, error) {
+	return p.sendAmount(req, core.GenerateRef(), currency.ConvertCedisToPessewas(req.Amount))
+}
+
+// sendAmount is sendMoney for an amount already in pesewas, under a ref the
+// caller chose; req.Amount is ignored.
+func (p *PaymentService) sendAmount(req core.CreatePaymentRequest, ref string, amount int64) (*models.Transaction, error) {
+	privacy := req.Privacy
+	if privacy == "" {
+		// Fall back to the sender's default
+		defaultPrivacy, err := p.repository.UserLookup.GetDefaultPrivacy(req.From)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		privacy = core.MostRestrictivePrivacy(defaultPrivacy)
+	}
+
 	fromTrans := models.Transaction{
-		From:        req.From,
-		To:          req.To,
-		Ref:         core.GenerateRef(),
-		Amount:      currency.ConvertCedisToPessewas(req.Amount),
-		Description: req.Description,
-		Direction:   core.DirectionOutgoing,
-		Status:      core.StatusPending,
-		Purpose:     core.PurposeTransfer,
-		Privacy:     req.Privacy,
+		From:          req.From,
+		To:            req.To,
+		Ref:           ref,
+		Amount:        amount,
+		Description:   req.Description,
+		Direction:     core.DirectionOutgoing,
+		Status:        core.StatusPending,
+		Purpose:       core.PurposeTransfer,
+		Privacy:       privacy,
+		SenderPrivacy: privacy,
+	}
+
+	movements := transferMovements(&fromTrans)
+	if err := p.checkLimits(movements...); err != nil {
+		return nil, err
+	}
+
+	decision, err := p.assess(risk.Input{
+		UserID:         req.From,
+		CounterpartyID: req.To,
+		Kind:           risk.KindSend,
+		Amount:         fromTrans.Amount,
+	}, &fromTrans)
+	if err != nil {
+		return nil, err
+	}
+	if decision.Outcome != risk.Allow {
+		return nil, p.hold(fromTrans, decision)
+	}
+
+	return p.settle(fromTrans, movements)
+}
+
+// settle reserves the transfer's movements against limits, records its
+// outgoing leg and processes it. The reservation is released if the money
+// doesn't move.
+func (p *PaymentService) settle(fromTrans models.Transaction, movements []limits.Movement) (*models.Transaction, error) {
+	if err := p.reserveLimits(fromTrans.Ref, movements...); err != nil {
+		return nil, err
 	}
 
 	err := p.repository.Transactions.SQLTransaction(func(tx *gorm.DB) error {
@@ -43,14 +125,33 @@ func (p *PaymentService) SendMoney(req core.CreatePaymentRequest) core.Response
 	})
 
 	if err != nil {
-		return core.Error(err, nil)
+		p.releaseLimits(fromTrans.Ref)
+		return nil, err
 	}
 
 	if err := p.processor.ProcessTransaction(fromTrans); err != nil {
-		return core.Error(err, nil)
+		p.releaseLimits(fromTrans.Ref)
+		return nil, err
 	}
 
-	return core.Success(nil, nil)
+	p.completeTransfer(&fromTrans)
+
+	return &fromTrans, nil
+}
+
+// completeTransfer runs the follow-up work for a processed outgoing leg.
+func (p *PaymentService) completeTransfer(fromTrans *models.Transaction) {
+	p.publishTransfer(fromTrans)
+
+	if fromTrans.Purpose == core.PurposeTransfer {
+		p.notifier.Notify(fromTrans.To, core.EventPaymentReceived, map[string]interface{}{
+			"ref":         fromTrans.Ref,
+			"from_id":     fromTrans.From,
+			"from_tag":    p.tagOf(fromTrans.From),
+			"amount":      core.FormatAmount(fromTrans.Amount),
+			"description": fromTrans.Description,
+		})
+	}
 }
 
 func (p *PaymentService) GetBalance(walletID int) core.Response {
@@ -66,10 +167,18 @@ func (p *PaymentService) GetBalance(walletID int) core.Response {
 
 func (p *PaymentService) CreateRequest(req core.CreateRequestDTO) core.Response {
 	// In real world, validate users exist via User Service
+	blocked, err := p.repository.UserLookup.HasBlocked(req.PayerID, req.RequesterID)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if blocked {
+		return core.Error(core.Forbidden(errors.New("requester blocked by payer")), core.String("you cannot request money from this user"))
+	}
+
 	pr := models.PaymentRequest{
 		RequesterID: req.RequesterID,
 		PayerID:     req.PayerID,
-		Amount:      req.Amount, // assume input in Pesewas/Cents
+		Amount:      currency.ConvertCedisToPessewas(req.Amount),
 		Description: req.Description,
 		Status:      "pending",
 	}
@@ -78,8 +187,8 @@ func (p *PaymentService) CreateRequest(req core.CreateRequestDTO) core.Response
 		return core.Error(err, core.String("failed to create payment request"))
 	}
 
-	// Mock Push Notification
-	core.Log.Info("Push Notification sent to Payer", zap.Int("payer_id", req.PayerID), zap.String("message", "You have a new payment request"))
+	p.notifyRequest(core.EventRequestCreated, &pr, "")
+	p.publishRequest(&pr)
 
 	return core.Success(&map[string]interface{}{
 		"request_id": pr.ID,
@@ -91,93 +200,86 @@ func (p *PaymentService) PayRequest(requestID int, payerKey string) core.Respons
 	// 1. Fetch Request
 	req, err := p.repository.PaymentRequests.FindByID(requestID)
 	if err != nil {
-		return core.Error(err, core.String("payment request not found"))
+		return notFoundOr(err, "payment request not found")
 	}
 
 	if req.Status != "pending" {
-		return core.Error(nil, core.String("request is already processed"))
+		return core.Error(core.Conflict(errors.New("payment request not pending")), core.String("request is already processed"))
 	}
 
-	// 2. Execute Payment (Reuse SendMoney logic)
-	// We need to map models.PaymentRequest to core.CreatePaymentRequest
-	// Note: SendMoney expects amount in Cedis (float logic in core struct implies int64 but conversion happens inside SendMoney??)
-	// Wait, core.CreatePaymentRequest has Amount int64.
-	// SendMoney implementations says: Amount: currency.ConvertCedisToPessewas(req.Amount)
-	// This implies CreatePaymentRequest.Amount is in MAJOR UNITS (Cedis/Dollars) but stored as int64?
-	// Or maybe it is just raw int64 and the conversion assumes it came as major units?
-	// If my PaymentRequest stores lowest denomination (based on my comment in CreateRequest), I should handle this.
-
-	// Check SendMoney implementation:
-	// Amount: currency.ConvertCedisToPessewas(req.Amount)
-	// This strongly suggests req.Amount is 100 for 100 GHS.
-
-	// If PaymentRequest.Amount is stored as Pesewas (10000 for 100 GHS), and SendMoney expects Major units...
-	// We should probably normalize everything to lowest denomination to avoid confusion, but sticking to existing pattern:
-
-	// Let's assume CreateRequestDTO takes Major Units for consistency with CreatePaymentRequest used in SendMoney.
-	// So req.Amount is 100.
-	// We store 100 in DB (as int64).
-	// When paying, we pass 100 to SendMoney, which converts to 10000.
+	// 2. Tie the payment to the request, so it can't be paid twice while
+	// this payment is in flight or held
+	ref := core.GenerateRef()
+	linked, err := p.repository.PaymentRequests.Link(req.ID, ref)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if !linked {
+		return core.Error(core.Conflict(errors.New("payment request already being paid")), core.String("a payment for this request is already in progress"))
+	}
 
+	// 3. Execute Payment (Reuse SendMoney logic)
 	payReq := core.CreatePaymentRequest{
 		From:        req.PayerID,
 		To:          req.RequesterID,
-		Amount:      req.Amount, // Major units
 		Description: req.Description,
 	}
 
-	// Ideally refactor SendMoney to not return HTTP Response but error/struct, but for now calling it is fine
-	// or we copy the logic. Calling it is better for DRY if we can extract the error.
-
-	// However, SendMoney currently returns core.Response.
-	resp := p.SendMoney(payReq)
-	if resp.Error {
-		return resp
+	paid, err := p.sendAmount(payReq, ref, req.Amount)
+	if err != nil {
+		// A held payment keeps the request until it is released or fails.
+		if code := core.CodeOf(err); code != core.ErrCodeStepUpRequired && code != core.ErrCodeHeldForReview {
+			p.unlinkRequest(ref)
+		}
+		return core.Error(err, nil)
 	}
 
-	// 3. Update Request Status
+	// 4. Update Request Status
+	req.TransactionRef = paid.Ref
+	p.markRequestPaid(req)
+
+	return core.Success(&map[string]interface{}{
+		"ref": paid.Ref,
+	}, core.String("request paid successfully"))
+}
+
+// markRequestPaid records that req's payment went through.
+func (p *PaymentService) markRequestPaid(req *models.PaymentRequest) {
 	req.Status = "paid"
 	if err := p.repository.PaymentRequests.Update(req); err != nil {
 		core.Log.Error("Failed to update payment request status", zap.Error(err))
 		// Payment succeeded but status update failed. In critical system, this needs reconciliation.
 	}
-
-	return core.Success(nil, core.String("request paid successfully"))
+	p.publishRequest(req)
 }
 
-func (p *PaymentService) GetFeed(friendIDs []int) core.Response {
-	txs, err := p.repository.Transactions.GetFeed(friendIDs)
-	if err != nil {
-		return core.Error(err, core.String("failed to fetch feed"))
-	}
-
-	// Transform to simplified feed items
-	var feed []map[string]interface{}
-	for _, tx := range txs {
-		feed = append(feed, map[string]interface{}{
-			"id":          tx.ID,
-			"from":        tx.From,
-			"to":          tx.To,
-			"amount":      currency.ConvertPessewasToCedis(tx.Amount),
-			"description": tx.Description,
-			"timestamp":   tx.CreatedAt,
-			"privacy":     tx.Privacy,
-		})
+// unlinkRequest frees the request a failed payment was paying, if any.
+func (p *PaymentService) unlinkRequest(ref string) {
+	if err := p.repository.PaymentRequests.Unlink(ref); err != nil {
+		core.Log.Error("failed to free payment request", zap.String("ref", ref), zap.Error(err))
 	}
-
-	return core.Success(&map[string]interface{}{"feed": feed}, nil)
 }
 
 func (p *PaymentService) SplitBill(req core.SplitBillDTO) core.Response {
 	// 1. Fetch Original Transaction
 	tx, err := p.repository.Transactions.FindByID(req.OriginalTransactionID)
 	if err != nil {
-		return core.Error(err, core.String("original transaction not found"))
+		return notFoundOr(err, "original transaction not found")
 	}
 
 	// 2. Validate Ownership (Assume requester must be the 'From' user, i.e., they paid initially)
 	if tx.From != req.RequesterID {
-		return core.Error(nil, core.String("only the payer can split the bill"))
+		return core.Error(core.Forbidden(errors.New("requester is not the payer")), core.String("only the payer can split the bill"))
+	}
+
+	for _, friendID := range req.FriendIDs {
+		blocked, err := p.repository.UserLookup.HasBlocked(friendID, req.RequesterID)
+		if err != nil {
+			return core.Error(err, nil)
+		}
+		if blocked {
+			return core.Error(core.Forbidden(errors.New("requester blocked by friend")), core.String("you cannot request money from one of these users"))
+		}
 	}
 
 	// 3. Calculate Split Amount
@@ -189,11 +291,12 @@ func (p *PaymentService) SplitBill(req core.SplitBillDTO) core.Response {
 	var requests []models.PaymentRequest
 	for _, friendID := range req.FriendIDs {
 		pr := models.PaymentRequest{
-			RequesterID: req.RequesterID,
-			PayerID:     friendID,
-			Amount:      splitAmount,
-			Description: "Split Bill: " + tx.Description,
-			Status:      "pending",
+			RequesterID:        req.RequesterID,
+			PayerID:            friendID,
+			Amount:             splitAmount,
+			Description:        "Split Bill: " + tx.Description,
+			Status:             "pending",
+			SplitTransactionID: tx.ID,
 		}
 		requests = append(requests, pr)
 	}
@@ -204,8 +307,8 @@ func (p *PaymentService) SplitBill(req core.SplitBillDTO) core.Response {
 			// In real world, use transaction to rollback all if one fails
 			core.Log.Error("Failed to create split request", zap.Error(err))
 		} else {
-			// Mock Push Notification
-			core.Log.Info("Split Bill Request sent", zap.Int("to_user", pr.PayerID), zap.Int64("amount", pr.Amount))
+			p.notifyRequest(core.EventRequestCreated, &pr, "")
+			p.publishRequest(&pr)
 		}
 	}
 
@@ -215,3 +318,58 @@ func (p *PaymentService) SplitBill(req core.SplitBillDTO) core.Response {
 		"requests_created": len(requests),
 	}, core.String("bill split successfully"))
 }
+
+// RemindRequest nudges the payer of a pending request. Reminders are
+// deduplicated per day.
+func (p *PaymentService) RemindRequest(viewerID int, requestID int) core.Response {
+	req, err := p.repository.PaymentRequests.FindByID(requestID)
+	if err != nil {
+		return notFoundOr(err, "payment request not found")
+	}
+
+	if req.RequesterID != viewerID {
+		return core.Error(core.Forbidden(errors.New("viewer is not the requester")), core.String("only the requester can send a reminder"))
+	}
+	if req.Status != "pending" {
+		return core.Error(core.Conflict(errors.New("payment request not pending")), core.String("request is already processed"))
+	}
+
+	p.notifyRequest(core.EventRequestReminder, req, time.Now().UTC().Format("2006-01-02"))
+
+	return core.Success(nil, core.String("reminder sent"))
+}
+
+// notifyRequest tells the payer about a payment request. A non-empty
+// dedupKey limits the notification to once per key.
+func (p *PaymentService) notifyRequest(event string, req *models.PaymentRequest, dedupKey string) {
+	data := map[string]interface{}{
+		"request_id":    req.ID,
+		"requester_id":  req.RequesterID,
+		"requester_tag": p.tagOf(req.RequesterID),
+		"amount":        core.FormatAmount(req.Amount),
+		"description":   req.Description,
+	}
+	if dedupKey != "" {
+		data["dedup_key"] = fmt.Sprintf("request-%d-%s", req.ID, dedupKey)
+	}
+	p.notifier.Notify(req.PayerID, event, data)
+}
+
+// tagOf returns a user's cash tag, or "" when it can't be resolved.
+func (p *PaymentService) tagOf(userID int) string {
+	tags, err := p.repository.UserLookup.GetTags([]int{userID})
+	if err != nil {
+		core.Log.Error("failed to resolve cash tag", zap.Int("user_id", userID), zap.Error(err))
+		return ""
+	}
+	return tags[userID]
+}
+
+// notFoundOr reports a missing record as NotFound with the given message and
+// anything else as an internal failure.
+func notFoundOr(err error, message string) core.Response {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.Error(core.NotFound(err), core.String(message))
+	}
+	return core.Error(err, nil)
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/ledger/models"
+	"cashapp/internal/ledger/realtime"
+
+	"go.uber.org/zap"
+)
+
+// EventPublisher pushes real-time events to a user's connected clients.
+// Amounts and balances in event data are in pesewas, so none are rounded.
+type EventPublisher interface {
+	Publish(userID int, eventType string, data map[string]interface{})
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(int, string, map[string]interface{}) {}
+
+// Publishers sends every event to each of its publishers, such as the
+// real-time broker and partner webhooks.
+type Publishers []EventPublisher
+
+func (ps Publishers) Publish(userID int, eventType string, data map[string]interface{}) {
+	for _, p := range ps {
+		p.Publish(userID, eventType, data)
+	}
+}
+
+// SetEventPublisher enables real-time events.
+func (p *PaymentService) SetEventPublisher(e EventPublisher) {
+	p.events = e
+}
+
+// publishTransfer pushes a settled transfer to everyone it concerns: new
+// balances and the payment itself to the parties, and a feed item to
+// whoever can see it in their feed.
+func (p *PaymentService) publishTransfer(tx *models.Transaction) {
+	// Escrow is not a user; a release's sender already saw the money leave.
+	sender := tx.From
+	if tx.Purpose == core.PurposeEscrowRelease {
+		sender = 0
+	}
+	recipient := tx.To
+
+	payment := map[string]interface{}{
+		"ref":         tx.Ref,
+		"from":        tx.From,
+		"to":          tx.To,
+		"amount":      tx.Amount,
+		"description": tx.Description,
+		"purpose":     tx.Purpose,
+	}
+	if sender != 0 {
+		p.events.Publish(sender, realtime.EventPaymentSent, payment)
+		p.publishBalance(sender)
+	}
+	if recipient != 0 {
+		p.events.Publish(recipient, realtime.EventPaymentReceived, payment)
+		if recipient != sender {
+			p.publishBalance(recipient)
+		}
+	}
+
+	if tx.Purpose == core.PurposeTransfer {
+		p.publishFeedItem(tx)
+	}
+}
+
+func (p *PaymentService) publishBalance(userID int) {
+	walletID, err := p.repository.WalletLookup.GetPrimaryWalletID(userID)
+	if err != nil {
+		core.Log.Error("failed to resolve wallet for balance event", zap.Int("user_id", userID), zap.Error(err))
+		return
+	}
+	balance, err := p.repository.TransactionEvents.GetWalletBalance(walletID)
+	if err != nil {
+		core.Log.Error("failed to load balance for balance event", zap.Int("wallet_id", walletID), zap.Error(err))
+		return
+	}
+
+	p.events.Publish(userID, realtime.EventBalanceUpdated, map[string]interface{}{
+		"wallet_id": walletID,
+		"balance":   balance,
+	})
+}
+
+// publishFeedItem sends a transfer to the feeds it appears in, using the
+// same rules as GetFeed: the parties always, their friends unless it's
+// private, and never anyone either party has blocked or been blocked by.
+func (p *PaymentService) publishFeedItem(tx *models.Transaction) {
+	audience := map[int]bool{tx.From: true, tx.To: true}
+
+	if tx.Privacy != core.PrivacyPrivate {
+		excluded := map[int]bool{}
+		for _, party := range []int{tx.From, tx.To} {
+			blocked, err := p.repository.UserLookup.BlockedIDs(party)
+			if err != nil {
+				core.Log.Error("failed to load blocks for feed event", zap.Int("user_id", party), zap.Error(err))
+				return
+			}
+			for _, id := range blocked {
+				excluded[id] = true
+			}
+		}
+
+		for _, party := range []int{tx.From, tx.To} {
+			friends, err := p.repository.UserLookup.FriendIDs(party)
+			if err != nil {
+				core.Log.Error("failed to load friends for feed event", zap.Int("user_id", party), zap.Error(err))
+				return
+			}
+			for _, id := range friends {
+				if !excluded[id] {
+					audience[id] = true
+				}
+			}
+		}
+	}
+
+	tags, err := p.repository.UserLookup.GetTags([]int{tx.From, tx.To})
+	if err != nil {
+		core.Log.Error("failed to resolve users for feed event", zap.String("ref", tx.Ref), zap.Error(err))
+	}
+
+	item := map[string]interface{}{
+		"id":          tx.ID,
+		"ref":         tx.Ref,
+		"from":        tx.From,
+		"from_tag":    tags[tx.From],
+		"to":          tx.To,
+		"to_tag":      tags[tx.To],
+		"amount":      tx.Amount,
+		"description": tx.Description,
+		"timestamp":   tx.CreatedAt,
+		"privacy":     tx.Privacy,
+	}
+	for userID := range audience {
+		p.events.Publish(userID, realtime.EventFeedItem, item)
+	}
+}
+
+// publishRequest tells both sides of a payment request about its status.
+func (p *PaymentService) publishRequest(req *models.PaymentRequest) {
+	data := map[string]interface{}{
+		"request_id":      req.ID,
+		"requester_id":    req.RequesterID,
+		"payer_id":        req.PayerID,
+		"amount":          req.Amount,
+		"description":     req.Description,
+		"status":          req.Status,
+		"transaction_ref": req.TransactionRef,
+	}
+	p.events.Publish(req.RequesterID, realtime.EventRequestUpdated, data)
+	p.events.Publish(req.PayerID, realtime.EventRequestUpdated, data)
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/ledger/models"
+	"cashapp/internal/risk"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// stepUpTTL is how long a sender has to confirm a payment risk checks
+	// stepped up.
+	stepUpTTL        = 15 * time.Minute
+	stepUpSweepBatch = 100
+	stepUpExpired    = "confirmation window expired"
+
+	stepUpCodeLength     = 6
+	stepUpMaxAttempts    = 5
+	stepUpResendInterval = time.Minute
+)
+
+// SetRisk enables risk checks on outgoing payments.
+func (p *PaymentService) SetRisk(r *risk.Engine) {
+	p.risk = r
+}
+
+// assess runs risk checks for an outgoing leg and stamps the decision on it.
+// Every movement is allowed when no risk engine is configured.
+func (p *PaymentService) assess(in risk.Input, trans *models.Transaction) (risk.Decision, error) {
+	if p.risk == nil {
+		return risk.Decision{Outcome: risk.Allow}, nil
+	}
+
+	decision, err := p.risk.Evaluate(in)
+	if err != nil {
+		return risk.Decision{}, err
+	}
+	trans.RiskOutcome = string(decision.Outcome)
+	trans.RiskReasons = decision.Summary()
+
+	if err := p.risk.Record(in, trans.Ref, decision); err != nil {
+		core.Log.Error("failed to record risk decision", zap.String("ref", trans.Ref), zap.Error(err))
+	}
+	return decision, nil
+}
+
+// hold records an outgoing leg risk checks didn't allow and returns the
+// error telling the sender what happens next. Blocked legs fail straight
+// away; the rest wait, unprocessed, for the sender or a reviewer.
+func (p *PaymentService) hold(fromTrans models.Transaction, decision risk.Decision) error {
+	// A stepped-up payment is confirmed with a code sent to the sender, so
+	// one with nowhere to send it can't be confirmed at all.
+	var channel, target string
+	if decision.Outcome == risk.StepUp {
+		var err error
+		if channel, target, err = p.repository.UserLookup.VerifiedContact(fromTrans.From); err != nil {
+			return err
+		}
+	}
+
+	fromTrans.Status = core.StatusHeld
+	switch {
+	case decision.Outcome == risk.Block:
+		fromTrans.Status = core.StatusFailed
+		fromTrans.FailureReason = "blocked by risk checks"
+	case decision.Outcome == risk.StepUp && channel == "":
+		fromTrans.Status = core.StatusFailed
+		fromTrans.FailureReason = "no verified contact to confirm with"
+	}
+
+	err := p.repository.Transactions.SQLTransaction(func(tx *gorm.DB) error {
+		return p.repository.Transactions.Create(tx, &fromTrans)
+	})
+	if err != nil {
+		return err
+	}
+
+	details := map[string]interface{}{"ref": fromTrans.Ref}
+	switch decision.Outcome {
+	case risk.Block:
+		return core.RiskDeclined(core.ErrCodeRiskBlocked, errors.New("this payment can't be sent"), details)
+	case risk.StepUp:
+		if channel == "" {
+			return core.RiskDeclined(core.ErrCodeRiskBlocked, errors.New("verify a phone number or email address, then send this payment again"), details)
+		}
+		if err := p.issueStepUp(&fromTrans, channel, target); err != nil {
+			// The payment stays held; the sender can ask for another code.
+			core.Log.Error("failed to send step-up code", zap.String("ref", fromTrans.Ref), zap.Error(err))
+		}
+		details["reasons"] = decision.Reasons
+		details["channel"] = channel
+		details["expires_at"] = fromTrans.CreatedAt.Add(stepUpTTL)
+		return core.RiskDeclined(core.ErrCodeStepUpRequired, errors.New("enter the code we sent you to send this payment"), details)
+	default:
+		return core.RiskDeclined(core.ErrCodeHeldForReview, errors.New("this payment is being reviewed and will be sent once approved"), details)
+	}
+}
+
+// assessEscrow runs risk checks for a payment to an unregistered contact.
+// Escrow can't sit waiting on the sender or a reviewer, so anything short of
+// allow turns the payment down.
+func (p *PaymentService) assessEscrow(trans *models.Transaction) error {
+	decision, err := p.assess(risk.Input{
+		UserID: trans.From,
+		Kind:   risk.KindSend,
+		Amount: trans.Amount,
+	}, trans)
+	if err != nil {
+		return err
+	}
+	if decision.Outcome != risk.Allow {
+		return core.RiskDeclined(core.ErrCodeRiskBlocked, errors.New("this payment can't be sent to an unregistered contact; ask them to sign up first"), nil)
+	}
+	return nil
+}
+
+// ConfirmTransfer sends a payment that risk checks held for the sender to
+// confirm, once they enter the code sent to their verified contact.
+func (p *PaymentService) ConfirmTransfer(viewerID int, ref string, req core.ConfirmTransferRequest) core.Response {
+	leg, resp := p.awaitingStepUp(viewerID, ref)
+	if leg == nil {
+		return resp
+	}
+
+	challenge, err := p.repository.StepUps.FindLatest(ref)
+	if err != nil {
+		return notFoundOr(err, "no confirmation code pending; request a new one")
+	}
+	claimed, err := p.repository.StepUps.ClaimAttempt(challenge.ID, stepUpMaxAttempts)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if !claimed {
+		return core.Error(core.RateLimited(errors.New("too many attempts")), core.String("too many incorrect attempts, request a new code"))
+	}
+	challenge.Attempts++
+
+	if !core.CheckOTP(challenge.Salt, challenge.CodeHash, req.Code) {
+		return core.Error(core.Validation(errors.New("code mismatch")), core.String(fmt.Sprintf("incorrect code, %d attempt(s) left", max(0, stepUpMaxAttempts-challenge.Attempts))))
+	}
+	consumed, err := p.repository.StepUps.Consume(challenge.ID, time.Now())
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if !consumed {
+		return core.Error(core.Conflict(errors.New("code already used")), core.String("this code was already used"))
+	}
+
+	return p.release(leg, core.String("payment confirmed"))
+}
+
+// ResendStepUpCode sends the sender a fresh code for a payment awaiting
+// their confirmation. Earlier codes stop working.
+func (p *PaymentService) ResendStepUpCode(viewerID int, ref string) core.Response {
+	leg, resp := p.awaitingStepUp(viewerID, ref)
+	if leg == nil {
+		return resp
+	}
+
+	if last, err := p.repository.StepUps.FindLatest(ref); err == nil {
+		if wait := time.Until(last.CreatedAt.Add(stepUpResendInterval)); wait > 0 {
+			return core.Error(core.RateLimited(errors.New("code resend too soon")), core.String(fmt.Sprintf("wait %d seconds before requesting another code", int(wait.Seconds())+1)))
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.Error(err, nil)
+	}
+
+	channel, target, err := p.repository.UserLookup.VerifiedContact(viewerID)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if channel == "" {
+		return core.Error(core.Conflict(errors.New("no verified contact")), core.String("verify a phone number or email address to confirm this payment"))
+	}
+	if err := p.issueStepUp(leg, channel, target); err != nil {
+		return core.Error(core.Upstream(err), core.String("failed to send confirmation code"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"ref":        leg.Ref,
+		"channel":    channel,
+		"expires_at": leg.CreatedAt.Add(stepUpTTL),
+	}, core.String("confirmation code sent"))
+}
+
+// awaitingStepUp loads the viewer's payment held for their confirmation,
+// failing it if the window has passed. leg is nil when the response is an
+// error.
+func (p *PaymentService) awaitingStepUp(viewerID int, ref string) (*models.Transaction, core.Response) {
+	legs, err := p.repository.Transactions.FindByRef(ref)
+	if err != nil {
+		return nil, notFoundOr(err, "transaction not found")
+	}
+
+	leg := legs[0]
+	if leg.From != viewerID {
+		return nil, core.Error(core.Forbidden(errors.New("viewer is not the sender")), core.String("only the sender can confirm a payment"))
+	}
+	if leg.Status != core.StatusHeld || leg.RiskOutcome != string(risk.StepUp) {
+		return nil, core.Error(core.Conflict(errors.New("transaction not awaiting confirmation")), core.String("this payment is not awaiting confirmation"))
+	}
+	if time.Since(leg.CreatedAt) > stepUpTTL {
+		if _, err := p.failHold(&leg, stepUpExpired); err != nil {
+			return nil, core.Error(err, nil)
+		}
+		return nil, core.Error(core.Conflict(errors.New("confirmation window expired")), core.String("this payment expired; send it again"))
+	}
+	return &leg, core.Response{}
+}
+
+// issueStepUp records a new confirmation code for a held payment and sends
+// it to the sender. The code lasts as long as the confirmation window.
+func (p *PaymentService) issueStepUp(leg *models.Transaction, channel, target string) error {
+	code, salt, hash, err := core.NewOTP(stepUpCodeLength)
+	if err != nil {
+		return err
+	}
+
+	challenge := models.StepUpChallenge{
+		TransactionRef: leg.Ref,
+		UserID:         leg.From,
+		Channel:        channel,
+		Salt:           salt,
+		CodeHash:       hash,
+		ExpiresAt:      leg.CreatedAt.Add(stepUpTTL),
+	}
+	if err := p.repository.StepUps.Create(&challenge); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Your CashApp code to confirm sending %s is %s. Don't share it with anyone.", core.FormatAmount(leg.Amount), code)
+	return p.sender.Send(channel, target, body)
+}
+
+// ListRiskReviews returns payments held for review, newest first.
+func (p *PaymentService) ListRiskReviews(q core.RiskReviewQuery) core.Response {
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	limit := core.PageSize(q.Limit)
+	txs, err := p.repository.Transactions.ListHeld(string(risk.Review), cursor.BeforeID, limit+1)
+	if err != nil {
+		return core.Error(err, core.String("failed to load reviews"))
+	}
+
+	pagination := &core.Pagination{}
+	if len(txs) > limit {
+		txs = txs[:limit]
+		pagination.NextCursor = core.Cursor{BeforeID: txs[limit-1].ID}.Encode()
+	}
+	pagination.Count = int64(len(txs))
+
+	return core.Paginated(&map[string]interface{}{
+		"reviews": txs,
+	}, pagination, nil)
+}
+
+// ResolveRiskReview approves or rejects a payment held for review and feeds
+// the result back into the sender's risk score.
+func (p *PaymentService) ResolveRiskReview(ref string, req core.RiskReviewDecision) core.Response {
+	legs, err := p.repository.Transactions.FindByRef(ref)
+	if err != nil {
+		return notFoundOr(err, "transaction not found")
+	}
+
+	leg := legs[0]
+	if leg.Status != core.StatusHeld || leg.RiskOutcome != string(risk.Review) {
+		return core.Error(core.Conflict(errors.New("transaction not held for review")), core.String("this payment is not awaiting review"))
+	}
+
+	if *req.Approve {
+		resp := p.release(&leg, core.String("payment approved"))
+		if !resp.Error {
+			p.recordReview(&leg, true)
+		}
+		return resp
+	}
+
+	reason := "rejected in review"
+	if req.Note != "" {
+		reason += ": " + req.Note
+	}
+	moved, err := p.failHold(&leg, reason)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if !moved {
+		return core.Error(core.Conflict(errors.New("transaction already resolved")), core.String("this payment was already processed"))
+	}
+	p.recordReview(&leg, false)
+
+	return core.Success(&map[string]interface{}{
+		"ref":    leg.Ref,
+		"status": leg.Status,
+	}, core.String("payment rejected"))
+}
+
+func (p *PaymentService) recordReview(leg *models.Transaction, approved bool) {
+	if p.risk == nil {
+		return
+	}
+	if err := p.risk.RecordReview(leg.From, approved); err != nil {
+		core.Log.Error("failed to record risk review", zap.String("ref", leg.Ref), zap.Error(err))
+	}
+}
+
+// release processes a held outgoing leg. The status transition makes sure
+// only one caller gets to move the money.
+func (p *PaymentService) release(leg *models.Transaction, message *string) core.Response {
+	// Limits are checked again: other payments may have used the headroom
+	// while this one was held.
+	if err := p.reserveLimits(leg.Ref, transferMovements(leg)...); err != nil {
+		return core.Error(err, nil)
+	}
+
+	moved, err := p.repository.Transactions.Transition(leg, core.StatusHeld, core.StatusPending, "")
+	if err != nil {
+		p.releaseLimits(leg.Ref)
+		return core.Error(err, nil)
+	}
+	if !moved {
+		p.releaseLimits(leg.Ref)
+		return core.Error(core.Conflict(errors.New("transaction already resolved")), core.String("this payment was already processed"))
+	}
+
+	if err := p.processor.ProcessTransaction(*leg); err != nil {
+		p.releaseLimits(leg.Ref)
+		p.unlinkRequest(leg.Ref)
+		return core.Error(err, nil)
+	}
+	p.completeTransfer(leg)
+	p.requestReleased(leg.Ref)
+
+	return core.Success(&map[string]interface{}{
+		"ref": leg.Ref,
+	}, message)
+}
+
+// requestReleased marks paid the request a released leg was paying, if any.
+func (p *PaymentService) requestReleased(ref string) {
+	req, err := p.repository.PaymentRequests.FindByTransactionRef(ref)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	if err != nil {
+		core.Log.Error("failed to load payment request for released payment", zap.String("ref", ref), zap.Error(err))
+		return
+	}
+	if req.Status == "pending" {
+		p.markRequestPaid(req)
+	}
+}
+
+// failHold fails a held leg, freeing any request it was paying. Like
+// Transition, it reports whether this caller moved the leg.
+func (p *PaymentService) failHold(leg *models.Transaction, reason string) (bool, error) {
+	moved, err := p.repository.Transactions.Transition(leg, core.StatusHeld, core.StatusFailed, reason)
+	if err != nil || !moved {
+		return moved, err
+	}
+	p.unlinkRequest(leg.Ref)
+	return true, nil
+}
+
+// RunStepUpSweeper periodically fails payments their senders didn't confirm
+// in time, rather than waiting for a confirmation attempt to notice. It
+// blocks; run it in a goroutine.
+func (p *PaymentService) RunStepUpSweeper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.expireStepUps()
+		<-ticker.C
+	}
+}
+
+func (p *PaymentService) expireStepUps() {
+	legs, err := p.repository.Transactions.ListHeldBefore(string(risk.StepUp), time.Now().Add(-stepUpTTL), stepUpSweepBatch)
+	if err != nil {
+		core.Log.Error("failed to load expired step-ups", zap.Error(err))
+		return
+	}
+
+	for i := range legs {
+		if _, err := p.failHold(&legs[i], stepUpExpired); err != nil {
+			core.Log.Error("failed to expire step-up", zap.String("ref", legs[i].Ref), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/ledger/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// viewableTransaction loads the outgoing leg of ref if the viewer may see it.
+// Transactions the viewer may not see are reported as missing.
+func (p *PaymentService) viewableTransaction(viewerID int, ref string) (*models.Transaction, error) {
+	legs, err := p.repository.Transactions.FindByRef(ref)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, core.NotFound(err)
+		}
+		return nil, err
+	}
+
+	tx := legs[0]
+	visible, err := p.canView(viewerID, tx)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, core.NotFound(errors.New("transaction not visible to viewer"))
+	}
+	return &tx, nil
+}
+
+func (p *PaymentService) canView(viewerID int, tx models.Transaction) (bool, error) {
+	if viewerID == tx.From || viewerID == tx.To {
+		return true, nil
+	}
+
+	blockedIDs, err := p.repository.UserLookup.BlockedIDs(viewerID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range blockedIDs {
+		if id == tx.From || id == tx.To {
+			return false, nil
+		}
+	}
+
+	switch tx.Privacy {
+	case core.PrivacyPublic:
+		return true, nil
+	case core.PrivacyFriends:
+		friendIDs, err := p.repository.UserLookup.FriendIDs(viewerID)
+		if err != nil {
+			return false, err
+		}
+		for _, id := range friendIDs {
+			if id == tx.From || id == tx.To {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (p *PaymentService) ListComments(viewerID int, ref string) core.Response {
+	if _, err := p.viewableTransaction(viewerID, ref); err != nil {
+		return core.Error(err, core.String("transaction not found"))
+	}
+
+	comments, err := p.repository.Comments.ListByRef(ref)
+	if err != nil {
+		return core.Error(err, core.String("failed to load comments"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"comments": threadComments(comments),
+	}, nil)
+}
+
+func (p *PaymentService) AddComment(viewerID int, ref string, req core.CreateCommentRequest) core.Response {
+	tx, err := p.viewableTransaction(viewerID, ref)
+	if err != nil {
+		return core.Error(err, core.String("transaction not found"))
+	}
+
+	comment := models.Comment{
+		TransactionRef: ref,
+		UserID:         viewerID,
+		Body:           req.Body,
+	}
+
+	recipients := map[int]bool{tx.From: true, tx.To: true}
+	if req.ParentID != 0 {
+		parent, err := p.repository.Comments.FindByID(req.ParentID)
+		if err != nil || parent.TransactionRef != ref {
+			return core.Error(core.NotFound(errors.New("parent comment not on transaction")), core.String("parent comment not found"))
+		}
+		comment.ParentID = &parent.ID
+		recipients[parent.UserID] = true
+	}
+
+	if err := p.repository.Comments.Create(&comment); err != nil {
+		return core.Error(err, core.String("failed to add comment"))
+	}
+
+	delete(recipients, viewerID)
+	for userID := range recipients {
+		p.notifier.Notify(userID, core.EventCommentCreated, map[string]interface{}{
+			"transaction_ref": ref,
+			"comment_id":      comment.ID,
+			"author_id":       viewerID,
+			"author_tag":      p.tagOf(viewerID),
+			"body":            comment.Body,
+		})
+	}
+
+	return core.Success(&map[string]interface{}{
+		"comment": comment,
+	}, core.String("comment added"))
+}
+
+// DeleteComment removes a comment. Authors may delete their own comments and
+// the parties to a transaction may moderate any comment on it.
+func (p *PaymentService) DeleteComment(viewerID int, ref string, commentID int) core.Response {
+	tx, err := p.viewableTransaction(viewerID, ref)
+	if err != nil {
+		return core.Error(err, core.String("transaction not found"))
+	}
+
+	comment, err := p.repository.Comments.FindByID(commentID)
+	if err != nil || comment.TransactionRef != ref {
+		return core.Error(core.NotFound(errors.New("comment not on transaction")), core.String("comment not found"))
+	}
+
+	if comment.UserID != viewerID && viewerID != tx.From && viewerID != tx.To {
+		return core.Error(core.Forbidden(errors.New("viewer cannot moderate comment")), core.String("you cannot delete this comment"))
+	}
+
+	if err := p.repository.Comments.Delete(comment, viewerID); err != nil {
+		return core.Error(err, core.String("failed to delete comment"))
+	}
+
+	return core.Success(nil, core.String("comment deleted"))
+}
+
+func (p *PaymentService) AddReaction(viewerID int, ref string, req core.ReactionRequest) core.Response {
+	if _, err := p.viewableTransaction(viewerID, ref); err != nil {
+		return core.Error(err, core.String("transaction not found"))
+	}
+
+	reaction := models.Reaction{
+		TransactionRef: ref,
+		UserID:         viewerID,
+		Emoji:          req.Emoji,
+	}
+	if err := p.repository.Reactions.Add(&reaction); err != nil {
+		return core.Error(err, core.String("failed to add reaction"))
+	}
+
+	return p.reactionSummary(ref)
+}
+
+func (p *PaymentService) RemoveReaction(viewerID int, ref string, emoji string) core.Response {
+	if _, err := p.viewableTransaction(viewerID, ref); err != nil {
+		return core.Error(err, core.String("transaction not found"))
+	}
+
+	if err := p.repository.Reactions.Remove(ref, viewerID, emoji); err != nil {
+		return core.Error(err, core.String("failed to remove reaction"))
+	}
+
+	return p.reactionSummary(ref)
+}
+
+func (p *PaymentService) reactionSummary(ref string) core.Response {
+	counts, err := p.repository.Reactions.CountByRefs([]string{ref})
+	if err != nil {
+		return core.Error(err, core.String("failed to count reactions"))
+	}
+
+	reactions := counts[ref]
+	if reactions == nil {
+		reactions = map[string]int64{}
+	}
+	return core.Success(&map[string]interface{}{
+		"reactions": reactions,
+	}, nil)
+}
+
+// threadComments nests replies under their parents. Deleted comments keep
+// their place so replies stay attached, but lose their body.
+func threadComments(comments []models.Comment) []*models.Comment {
+	byID := make(map[int]*models.Comment, len(comments))
+	for i := range comments {
+		if comments[i].DeletedAt != nil {
+			comments[i].Body = ""
+		}
+		byID[comments[i].ID] = &comments[i]
+	}
+
+	var roots []*models.Comment
+	children := make(map[int][]int)
+	for i := range comments {
+		c := &comments[i]
+		if c.ParentID != nil && byID[*c.ParentID] != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+			continue
+		}
+		roots = append(roots, c)
+	}
+
+	var attach func(c *models.Comment)
+	attach = func(c *models.Comment) {
+		for _, id := range children[c.ID] {
+			child := byID[id]
+			attach(child)
+			c.Replies = append(c.Replies, *child)
+		}
+	}
+	for _, root := range roots {
+		attach(root)
+	}
+	return roots
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/core/currency"
+	"cashapp/internal/ledger/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// GetTransaction returns both legs of a transfer with their events, status
+// history and whatever payment request or split the transfer is linked to.
+// Someone who isn't a party only gets what the feed shows. Transactions the
+// viewer may not see are reported as missing, as in the feed.
+func (p *PaymentService) GetTransaction(viewerID int, ref string) core.Response {
+	legs, err := p.repository.Transactions.FindByRef(ref)
+	if err != nil {
+		return notFoundOr(err, "transaction not found")
+	}
+
+	visible, err := p.canView(viewerID, legs[0])
+	if err != nil {
+		return core.Error(err, core.String("failed to check visibility"))
+	}
+	if !visible {
+		return core.Error(core.NotFound(errors.New("transaction not visible to viewer")), core.String("transaction not found"))
+	}
+	if viewerID != legs[0].From && viewerID != legs[0].To {
+		return p.publicTransaction(legs[0])
+	}
+
+	ids := make([]int, 0, len(legs))
+	for _, leg := range legs {
+		ids = append(ids, leg.ID)
+	}
+
+	history, err := p.repository.Transactions.StatusHistory(ids)
+	if err != nil {
+		return core.Error(err, core.String("failed to load status history"))
+	}
+
+	data := map[string]interface{}{
+		"ref":            ref,
+		"status":         legs[0].Status,
+		"failure_reason": legs[0].FailureReason,
+		"legs":           legs,
+		"status_history": history,
+	}
+
+	request, err := p.repository.PaymentRequests.FindByTransactionRef(ref)
+	switch {
+	case err == nil:
+		data["payment_request"] = request
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return core.Error(err, core.String("failed to load payment request"))
+	}
+
+	splits, err := p.repository.PaymentRequests.ListBySplitTransaction(ids)
+	if err != nil {
+		return core.Error(err, core.String("failed to load split requests"))
+	}
+	if len(splits) > 0 {
+		data["split_requests"] = splits
+	}
+
+	return core.Success(&data, nil)
+}
+
+// publicTransaction is a transaction as the feed shows it, for viewers who
+// aren't a party to it.
+func (p *PaymentService) publicTransaction(tx models.Transaction) core.Response {
+	tags, err := p.repository.UserLookup.GetTags([]int{tx.From, tx.To})
+	if err != nil {
+		return core.Error(err, core.String("failed to resolve users"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"ref":         tx.Ref,
+		"from":        tx.From,
+		"from_tag":    tags[tx.From],
+		"to":          tx.To,
+		"to_tag":      tags[tx.To],
+		"amount":      currency.ConvertPessewasToCedis(tx.Amount),
+		"description": tx.Description,
+		"timestamp":   tx.CreatedAt,
+	}, nil)
+}
+
+// UpdateTransactionPrivacy records one party's visibility choice for a
+// transaction. The effective privacy of every leg becomes the more
+// restrictive of the sender's and recipient's choices.
+func (p *PaymentService) UpdateTransactionPrivacy(viewerID int, ref string, req core.UpdatePrivacyRequest) core.Response {
+	legs, err := p.repository.Transactions.FindByRef(ref)
+	if err != nil {
+		return notFoundOr(err, "transaction not found")
+	}
+
+	leg := legs[0]
+	senderPrivacy, recipientPrivacy := leg.SenderPrivacy, leg.RecipientPrivacy
+	if senderPrivacy == "" {
+		// Predates per-party choices; the stored privacy was the sender's.
+		senderPrivacy = leg.Privacy
+	}
+	switch viewerID {
+	case leg.From:
+		senderPrivacy = req.Privacy
+	case leg.To:
+		recipientPrivacy = req.Privacy
+	default:
+		return core.Error(core.Forbidden(errors.New("viewer is not a party")), core.String("only the sender or recipient can change visibility"))
+	}
+
+	privacy := core.MostRestrictivePrivacy(senderPrivacy, recipientPrivacy)
+	if err := p.repository.Transactions.UpdatePrivacy(ref, senderPrivacy, recipientPrivacy, privacy); err != nil {
+		return core.Error(err, core.String("failed to update visibility"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"ref":               ref,
+		"privacy":           privacy,
+		"sender_privacy":    senderPrivacy,
+		"recipient_privacy": recipientPrivacy,
+	}, core.String("visibility updated"))
+}
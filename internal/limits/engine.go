@@ -0,0 +1,272 @@
+package limits
+
+import (
+	"cashapp/core"
+	"cashapp/core/currency"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reserveLockKey namespaces the advisory locks that serialise reservations
+// for one user.
+const reserveLockKey = 410041
+
+// Usage is one completed movement of money counted against limits.
+type Usage struct {
+	core.Model
+	UserID         int    `json:"user_id" gorm:"index:idx_limit_usages_user_kind"`
+	Kind           Kind   `json:"kind" gorm:"index:idx_limit_usages_user_kind"`
+	Amount         int64  `json:"amount"` // minor units
+	CounterpartyID int    `json:"counterparty_id,omitempty"`
+	Contact        string `json:"-"` // blind index of an unregistered counterparty
+	Ref            string `json:"ref,omitempty" gorm:"index"`
+}
+
+// Movement is money moving for one user.
+type Movement struct {
+	UserID int
+	Kind   Kind
+	Amount int64 // minor units
+	// CounterpartyID is the user on the other side, or 0 when there is
+	// none, as for deposits.
+	CounterpartyID int
+	// Contact stands in for the counterparty when it is an email address
+	// or phone number with no account yet: its blind index.
+	Contact string
+}
+
+// Exceeded describes the limit a movement would break. Amounts are in major
+// units.
+type Exceeded struct {
+	Limit     string `json:"limit"` // e.g. "send.daily", "per_transaction"
+	Max       int64  `json:"max"`
+	Used      int64  `json:"used"`
+	Requested int64  `json:"requested"`
+	Remaining int64  `json:"remaining"`
+}
+
+// Engine checks money movements against the limits for the user's KYC level.
+// Check gives a quick answer; Reserve must succeed before money moves, and
+// Release undoes it if the money doesn't move after all.
+type Engine struct {
+	db    *gorm.DB
+	rules Rules
+}
+
+func New(db *gorm.DB, rules Rules) *Engine {
+	return &Engine{
+		db:    db,
+		rules: rules,
+	}
+}
+
+type windowTotals struct {
+	Daily   int64
+	Weekly  int64
+	Monthly int64
+}
+
+type counterpartyTotals struct {
+	Daily        int
+	Weekly       int
+	Monthly      int
+	KnownDaily   bool
+	KnownWeekly  bool
+	KnownMonthly bool
+}
+
+// Check returns a core.LimitExceeded error if m would break one of its
+// user's limits. It takes no locks; a concurrent movement can still use the
+// same headroom until one of them is reserved.
+func (e *Engine) Check(m Movement) error {
+	return e.check(e.db, m)
+}
+
+// Reserve checks each movement against its user's limits and, if they all
+// fit, counts them under ref in the same transaction. The users' usage is
+// locked while this happens, so two movements can't both fit into the same
+// headroom.
+func (e *Engine) Reserve(ref string, movements ...Movement) error {
+	users := make([]int, 0, len(movements))
+	for _, m := range movements {
+		users = append(users, m.UserID)
+	}
+	// A fixed order keeps two reservations from deadlocking.
+	sort.Ints(users)
+
+	return e.db.Transaction(func(tx *gorm.DB) error {
+		for _, userID := range users {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", reserveLockKey, userID).Error; err != nil {
+				return err
+			}
+		}
+
+		usages := make([]Usage, 0, len(movements))
+		for _, m := range movements {
+			if err := e.check(tx, m); err != nil {
+				return err
+			}
+			usages = append(usages, Usage{
+				UserID:         m.UserID,
+				Kind:           m.Kind,
+				Amount:         m.Amount,
+				CounterpartyID: m.CounterpartyID,
+				Contact:        m.Contact,
+				Ref:            ref,
+			})
+		}
+		if len(usages) == 0 {
+			return nil
+		}
+		return tx.Create(&usages).Error
+	})
+}
+
+// Release stops counting what was reserved under ref.
+func (e *Engine) Release(ref string) error {
+	return e.db.Where("ref = ?", ref).Delete(&Usage{}).Error
+}
+
+func (e *Engine) check(db *gorm.DB, m Movement) error {
+	level, err := kycLevel(db, m.UserID)
+	if err != nil {
+		return err
+	}
+	rule := e.rules.For(level)
+
+	if m.Kind == KindSend && rule.PerTransaction > 0 {
+		max := currency.ConvertCedisToPessewas(rule.PerTransaction)
+		if m.Amount > max {
+			return exceeded("per_transaction", "per transaction", max, 0, m.Amount)
+		}
+	}
+
+	window := rule.window(m.Kind)
+	totals, err := volume(db, m.UserID, m.Kind, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, w := range []struct {
+		name  string
+		limit int64
+		used  int64
+	}{
+		{"daily", window.Daily, totals.Daily},
+		{"weekly", window.Weekly, totals.Weekly},
+		{"monthly", window.Monthly, totals.Monthly},
+	} {
+		if w.limit == 0 {
+			continue
+		}
+		max := currency.ConvertCedisToPessewas(w.limit)
+		if w.used+m.Amount > max {
+			return exceeded(string(m.Kind)+"."+w.name, w.name+" "+string(m.Kind), max, w.used, m.Amount)
+		}
+	}
+
+	if key := counterpartyKey(m.CounterpartyID, m.Contact); m.Kind == KindSend && key != "" {
+		return e.checkCounterparties(db, m.UserID, key, rule.Counterparties)
+	}
+	return nil
+}
+
+func (e *Engine) checkCounterparties(db *gorm.DB, userID int, counterparty string, limit CountWindow) error {
+	counts, err := counterparties(db, userID, counterparty, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, w := range []struct {
+		name  string
+		limit int
+		used  int
+		known bool
+	}{
+		{"daily", limit.Daily, counts.Daily, counts.KnownDaily},
+		{"weekly", limit.Weekly, counts.Weekly, counts.KnownWeekly},
+		{"monthly", limit.Monthly, counts.Monthly, counts.KnownMonthly},
+	} {
+		if w.limit == 0 || w.known || w.used < w.limit {
+			continue
+		}
+		return core.LimitExceeded(
+			fmt.Errorf("you can send money to at most %d different people per %s period", w.limit, w.name),
+			Exceeded{Limit: "counterparties." + w.name, Max: int64(w.limit), Used: int64(w.used), Requested: 1},
+		)
+	}
+	return nil
+}
+
+func kycLevel(db *gorm.DB, userID int) (int, error) {
+	var user struct{ KYCLevel int }
+	err := db.Table("users").Select("kyc_level").Where("id = ? AND deleted_at IS NULL", userID).Take(&user).Error
+	return user.KYCLevel, err
+}
+
+func volume(db *gorm.DB, userID int, kind Kind, now time.Time) (windowTotals, error) {
+	var t windowTotals
+	err := db.Model(&Usage{}).
+		Select(`COALESCE(SUM(amount) FILTER (WHERE created_at > ?), 0) AS daily,
+			COALESCE(SUM(amount) FILTER (WHERE created_at > ?), 0) AS weekly,
+			COALESCE(SUM(amount), 0) AS monthly`, now.Add(-Day), now.Add(-Week)).
+		Where("user_id = ? AND kind = ? AND created_at > ?", userID, kind, now.Add(-Month)).
+		Scan(&t).Error
+	return t, err
+}
+
+// counterparties counts the distinct people userID sent money to, users and
+// unregistered contacts alike, and whether counterparty (a counterpartyKey)
+// is one of them.
+func counterparties(db *gorm.DB, userID int, counterparty string, now time.Time) (counterpartyTotals, error) {
+	var t counterpartyTotals
+	err := db.Raw(`
+		SELECT COUNT(DISTINCT cp) FILTER (WHERE created_at > ?) AS daily,
+			COUNT(DISTINCT cp) FILTER (WHERE created_at > ?) AS weekly,
+			COUNT(DISTINCT cp) AS monthly,
+			COALESCE(BOOL_OR(cp = ?) FILTER (WHERE created_at > ?), false) AS known_daily,
+			COALESCE(BOOL_OR(cp = ?) FILTER (WHERE created_at > ?), false) AS known_weekly,
+			COALESCE(BOOL_OR(cp = ?), false) AS known_monthly
+		FROM (
+			SELECT CASE WHEN counterparty_id <> 0 THEN 'user:' || counterparty_id ELSE 'contact:' || contact END AS cp, created_at
+			FROM usages
+			WHERE user_id = ? AND kind = ? AND (counterparty_id <> 0 OR contact <> '') AND created_at > ?
+		) u`,
+		now.Add(-Day), now.Add(-Week),
+		counterparty, now.Add(-Day), counterparty, now.Add(-Week), counterparty,
+		userID, KindSend, now.Add(-Month)).
+		Scan(&t).Error
+	return t, err
+}
+
+// counterpartyKey identifies a counterparty the way counterparties does, or
+// is "" when there is none.
+func counterpartyKey(counterpartyID int, contact string) string {
+	switch {
+	case counterpartyID != 0:
+		return "user:" + strconv.Itoa(counterpartyID)
+	case contact != "":
+		return "contact:" + contact
+	}
+	return ""
+}
+
+func exceeded(limit, label string, max, used, requested int64) error {
+	remaining := max - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return core.LimitExceeded(
+		fmt.Errorf("this exceeds your %s limit; %s remaining", label, core.FormatAmount(remaining)),
+		Exceeded{
+			Limit:     limit,
+			Max:       currency.ConvertPessewasToCedis(max),
+			Used:      currency.ConvertPessewasToCedis(used),
+			Requested: currency.ConvertPessewasToCedis(requested),
+			Remaining: currency.ConvertPessewasToCedis(remaining),
+		},
+	)
+}
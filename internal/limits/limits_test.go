@@ -0,0 +1,225 @@
+package limits
+
+import (
+	"cashapp/core"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRulesFor(t *testing.T) {
+	gapped := Rules{
+		0: {PerTransaction: 100},
+		2: {PerTransaction: 10000},
+	}
+
+	tests := []struct {
+		name  string
+		rules Rules
+		level int
+		want  int64
+	}{
+		{"exact level", DefaultRules, 1, 1000},
+		{"above the highest level", DefaultRules, 5, 10000},
+		{"gap uses the level below", gapped, 1, 100},
+		{"level after the gap", gapped, 2, 10000},
+		{"no rules", Rules{}, 3, 0},
+		{"negative level", DefaultRules, -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.For(tt.level).PerTransaction; got != tt.want {
+				t.Fatalf("For(%d).PerTransaction = %d, want %d", tt.level, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	valid := write("limits.json", `{"0": {"per_transaction": 50, "send": {"daily": 75}}, "3": {"per_transaction": 90000}}`)
+	invalid := write("broken.json", `{"0": {"per_transaction": "lots"}}`)
+
+	tests := []struct {
+		name    string
+		path    string
+		level   int
+		want    int64
+		wantErr bool
+	}{
+		{"no file uses defaults", "", 0, DefaultRules[0].PerTransaction, false},
+		{"file", valid, 0, 50, false},
+		{"file, higher level", valid, 3, 90000, false},
+		{"file, level between", valid, 2, 50, false},
+		{"missing file", filepath.Join(dir, "missing.json"), 0, 0, true},
+		{"bad JSON", invalid, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := LoadRules(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadRules error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := rules.For(tt.level).PerTransaction; got != tt.want {
+				t.Fatalf("For(%d).PerTransaction = %d, want %d", tt.level, got, tt.want)
+			}
+		})
+	}
+
+	rules, err := LoadRules(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules.For(0).Send.Daily; got != 75 {
+		t.Fatalf("send.daily = %d, want 75", got)
+	}
+}
+
+func TestRuleWindow(t *testing.T) {
+	rule := Rule{
+		Send:       Window{Daily: 1},
+		Receive:    Window{Daily: 2},
+		Deposit:    Window{Daily: 3},
+		Withdrawal: Window{Daily: 4},
+	}
+
+	tests := []struct {
+		kind Kind
+		want int64
+	}{
+		{KindSend, 1},
+		{KindReceive, 2},
+		{KindDeposit, 3},
+		{KindWithdrawal, 4},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			if got := rule.window(tt.kind).Daily; got != tt.want {
+				t.Fatalf("window(%s).Daily = %d, want %d", tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadroom(t *testing.T) {
+	tests := []struct {
+		name          string
+		max, used     int64
+		wantMax       *int64
+		wantRemaining *int64
+	}{
+		{"uncapped", 0, 500, nil, nil},
+		{"unused", 200, 0, ptr(200), ptr(200)},
+		{"partly used", 200, 150, ptr(200), ptr(50)},
+		{"used up", 200, 200, ptr(200), ptr(0)},
+		{"over the cap", 200, 260, ptr(200), ptr(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := headroom(tt.max, tt.used)
+			if h.Used != tt.used {
+				t.Fatalf("Used = %d, want %d", h.Used, tt.used)
+			}
+			if !sameInt(h.Max, tt.wantMax) {
+				t.Fatalf("Max = %v, want %v", deref(h.Max), deref(tt.wantMax))
+			}
+			if !sameInt(h.Remaining, tt.wantRemaining) {
+				t.Fatalf("Remaining = %v, want %v", deref(h.Remaining), deref(tt.wantRemaining))
+			}
+		})
+	}
+
+	if capOf(0) != nil {
+		t.Fatal("capOf(0) is a cap")
+	}
+	if got := capOf(100); got == nil || *got != 100 {
+		t.Fatalf("capOf(100) = %v", deref(got))
+	}
+}
+
+func TestCounterpartyKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int
+		contact string
+		want    string
+	}{
+		{"user", 42, "", "user:42"},
+		{"user wins over contact", 42, "abc", "user:42"},
+		{"contact", 0, "abc", "contact:abc"},
+		{"none", 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := counterpartyKey(tt.id, tt.contact); got != tt.want {
+				t.Fatalf("counterpartyKey(%d, %q) = %q, want %q", tt.id, tt.contact, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExceeded(t *testing.T) {
+	tests := []struct {
+		name                 string
+		max, used, requested int64
+		wantUsed, wantRemain int64
+	}{
+		{"some left", 20000, 15000, 10000, 150, 50},
+		{"none left", 20000, 20000, 100, 200, 0},
+		{"already over", 20000, 25000, 100, 250, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := exceeded("send.daily", "daily send", tt.max, tt.used, tt.requested)
+			if code := core.CodeOf(err); code != core.ErrCodeLimitExceeded {
+				t.Fatalf("code = %s, want %s", code, core.ErrCodeLimitExceeded)
+			}
+
+			var appErr *core.AppError
+			if !errors.As(err, &appErr) {
+				t.Fatalf("error %T is not an AppError", err)
+			}
+			details, ok := appErr.Details.(Exceeded)
+			if !ok {
+				t.Fatalf("details = %T, want Exceeded", appErr.Details)
+			}
+			want := Exceeded{
+				Limit:     "send.daily",
+				Max:       tt.max / 100,
+				Used:      tt.wantUsed,
+				Requested: tt.requested / 100,
+				Remaining: tt.wantRemain,
+			}
+			if details != want {
+				t.Fatalf("details = %+v, want %+v", details, want)
+			}
+		})
+	}
+}
+
+func ptr(n int64) *int64 { return &n }
+
+func deref(p *int64) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func sameInt(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
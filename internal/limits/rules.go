@@ -0,0 +1,124 @@
+package limits
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Kind is a direction of money movement that limits apply to.
+type Kind string
+
+const (
+	KindSend       Kind = "send"
+	KindReceive    Kind = "receive"
+	KindDeposit    Kind = "deposit"
+	KindWithdrawal Kind = "withdrawal"
+)
+
+// Rolling windows, measured back from now.
+const (
+	Day   = 24 * time.Hour
+	Week  = 7 * Day
+	Month = 30 * Day
+)
+
+// Window caps volume over rolling periods, in major currency units. Zero
+// means no cap.
+type Window struct {
+	Daily   int64 `json:"daily"`
+	Weekly  int64 `json:"weekly"`
+	Monthly int64 `json:"monthly"`
+}
+
+// CountWindow caps how many distinct people a user sends money to over
+// rolling periods. Zero means no cap.
+type CountWindow struct {
+	Daily   int `json:"daily"`
+	Weekly  int `json:"weekly"`
+	Monthly int `json:"monthly"`
+}
+
+// Rule is the set of limits for one KYC level.
+type Rule struct {
+	PerTransaction int64       `json:"per_transaction"` // largest single send, major units
+	Send           Window      `json:"send"`
+	Receive        Window      `json:"receive"`
+	Deposit        Window      `json:"deposit"`
+	Withdrawal     Window      `json:"withdrawal"`
+	Counterparties CountWindow `json:"counterparties"`
+}
+
+func (r Rule) window(kind Kind) Window {
+	switch kind {
+	case KindSend:
+		return r.Send
+	case KindReceive:
+		return r.Receive
+	case KindDeposit:
+		return r.Deposit
+	default:
+		return r.Withdrawal
+	}
+}
+
+// Rules maps a KYC level to its limits. Levels without a rule use the
+// highest level below them.
+type Rules map[int]Rule
+
+// DefaultRules apply when no LIMITS_FILE is configured.
+var DefaultRules = Rules{
+	0: {
+		PerTransaction: 100,
+		Send:           Window{Daily: 200, Weekly: 500, Monthly: 1000},
+		Receive:        Window{Daily: 500, Weekly: 1000, Monthly: 2000},
+		Deposit:        Window{Daily: 200, Weekly: 500, Monthly: 1000},
+		Withdrawal:     Window{Daily: 100, Weekly: 200, Monthly: 500},
+		Counterparties: CountWindow{Daily: 5, Weekly: 10, Monthly: 20},
+	},
+	1: {
+		PerTransaction: 1000,
+		Send:           Window{Daily: 2500, Weekly: 7500, Monthly: 15000},
+		Receive:        Window{Daily: 5000, Weekly: 15000, Monthly: 30000},
+		Deposit:        Window{Daily: 2500, Weekly: 10000, Monthly: 20000},
+		Withdrawal:     Window{Daily: 2500, Weekly: 7500, Monthly: 15000},
+		Counterparties: CountWindow{Daily: 20, Weekly: 50, Monthly: 100},
+	},
+	2: {
+		PerTransaction: 10000,
+		Send:           Window{Daily: 25000, Weekly: 100000, Monthly: 250000},
+		Deposit:        Window{Daily: 25000, Weekly: 100000, Monthly: 250000},
+		Withdrawal:     Window{Daily: 25000, Weekly: 100000, Monthly: 250000},
+		Counterparties: CountWindow{Daily: 100, Weekly: 300, Monthly: 1000},
+	},
+}
+
+// LoadRules reads rules from a JSON file keyed by KYC level, e.g.
+// {"0": {"per_transaction": 100, "send": {"daily": 200}}}. An empty path
+// returns DefaultRules.
+func LoadRules(path string) (Rules, error) {
+	if path == "" {
+		return DefaultRules, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// For returns the rule for a KYC level.
+func (rs Rules) For(level int) Rule {
+	for l := level; l >= 0; l-- {
+		if rule, ok := rs[l]; ok {
+			return rule
+		}
+	}
+	return Rule{}
+}
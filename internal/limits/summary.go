@@ -0,0 +1,103 @@
+package limits
+
+import (
+	"cashapp/core/currency"
+	"time"
+)
+
+// Headroom is one limit with how much of it is used. Amounts are in major
+// units; Max and Remaining are nil when the limit is uncapped.
+type Headroom struct {
+	Max       *int64 `json:"max"`
+	Used      int64  `json:"used"`
+	Remaining *int64 `json:"remaining"`
+}
+
+type WindowHeadroom struct {
+	Daily   Headroom `json:"daily"`
+	Weekly  Headroom `json:"weekly"`
+	Monthly Headroom `json:"monthly"`
+}
+
+// Summary is a user's limits and current usage.
+type Summary struct {
+	UserID         int            `json:"user_id"`
+	KYCLevel       int            `json:"kyc_level"`
+	PerTransaction *int64         `json:"per_transaction"`
+	Send           WindowHeadroom `json:"send"`
+	Receive        WindowHeadroom `json:"receive"`
+	Deposit        WindowHeadroom `json:"deposit"`
+	Withdrawal     WindowHeadroom `json:"withdrawal"`
+	Counterparties WindowHeadroom `json:"counterparties"`
+	// NextLevel shows what verifying further would unlock.
+	NextLevel *Rule `json:"next_level,omitempty"`
+}
+
+// Summary reports userID's limits and how much of each is left.
+func (e *Engine) Summary(userID int) (*Summary, error) {
+	level, err := kycLevel(e.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	rule := e.rules.For(level)
+	now := time.Now()
+
+	s := &Summary{
+		UserID:         userID,
+		KYCLevel:       level,
+		PerTransaction: capOf(rule.PerTransaction),
+	}
+
+	for kind, dst := range map[Kind]*WindowHeadroom{
+		KindSend:       &s.Send,
+		KindReceive:    &s.Receive,
+		KindDeposit:    &s.Deposit,
+		KindWithdrawal: &s.Withdrawal,
+	} {
+		totals, err := volume(e.db, userID, kind, now)
+		if err != nil {
+			return nil, err
+		}
+		w := rule.window(kind)
+		*dst = WindowHeadroom{
+			Daily:   headroom(w.Daily, currency.ConvertPessewasToCedis(totals.Daily)),
+			Weekly:  headroom(w.Weekly, currency.ConvertPessewasToCedis(totals.Weekly)),
+			Monthly: headroom(w.Monthly, currency.ConvertPessewasToCedis(totals.Monthly)),
+		}
+	}
+
+	counts, err := counterparties(e.db, userID, "", now)
+	if err != nil {
+		return nil, err
+	}
+	s.Counterparties = WindowHeadroom{
+		Daily:   headroom(int64(rule.Counterparties.Daily), int64(counts.Daily)),
+		Weekly:  headroom(int64(rule.Counterparties.Weekly), int64(counts.Weekly)),
+		Monthly: headroom(int64(rule.Counterparties.Monthly), int64(counts.Monthly)),
+	}
+
+	if next, ok := e.rules[level+1]; ok {
+		s.NextLevel = &next
+	}
+	return s, nil
+}
+
+func headroom(max, used int64) Headroom {
+	h := Headroom{Used: used}
+	if max == 0 {
+		return h
+	}
+	remaining := max - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	h.Max, h.Remaining = &max, &remaining
+	return h
+}
+
+func capOf(max int64) *int64 {
+	if max == 0 {
+		return nil
+	}
+	return &max
+}
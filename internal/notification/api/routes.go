@@ -0,0 +1,60 @@
+package api
+
+import (
+	"cashapp/core"
+	"cashapp/internal/notification/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterNotificationRoutes mounts the inbox and preference endpoints. The
+// hosting service supplies its auth middleware and a way to read the
+// authenticated user's ID.
+func RegisterNotificationRoutes(e *gin.Engine, s *service.NotificationService, auth gin.HandlerFunc, userID func(*gin.Context) int) {
+	// ListInbox lists the caller's in-app notifications
+	// @Router /notifications [get]
+	e.GET("/notifications", auth, func(c *gin.Context) {
+		var q core.InboxQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, s.ListInbox(userID(c), q))
+	})
+
+	// MarkRead marks one notification read
+	// @Router /notifications/:id/read [post]
+	e.POST("/notifications/:id/read", auth, func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			core.Respond(c, core.Error(core.Validation(err), core.String("invalid notification id")))
+			return
+		}
+
+		core.Respond(c, s.MarkRead(userID(c), id))
+	})
+
+	// MarkAllRead marks every notification read
+	// @Router /notifications/read-all [post]
+	e.POST("/notifications/read-all", auth, func(c *gin.Context) {
+		core.Respond(c, s.MarkAllRead(userID(c)))
+	})
+
+	// GetPreferences returns channel preferences and quiet hours
+	// @Router /notifications/preferences [get]
+	e.GET("/notifications/preferences", auth, func(c *gin.Context) {
+		core.Respond(c, s.GetPreferences(userID(c)))
+	})
+
+	// UpdatePreferences changes channel preferences and quiet hours
+	// @Router /notifications/preferences [put]
+	e.PUT("/notifications/preferences", auth, func(c *gin.Context) {
+		var req core.NotificationPreferencesRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.UpdatePreferences(userID(c), req))
+	})
+}
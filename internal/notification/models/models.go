@@ -0,0 +1,64 @@
+package models
+
+import (
+	"cashapp/core"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending    Status = "pending" // waiting for delivery or a retry
+	StatusSent       Status = "sent"
+	StatusFailed     Status = "failed"     // gave up after the last retry
+	StatusSuppressed Status = "suppressed" // nowhere to deliver it
+)
+
+const (
+	ChannelInbox = "inbox"
+	ChannelPush  = "push"
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// AllEvents matches every event in a ChannelPreference.
+const AllEvents = "*"
+
+// Notification is one message to one user on one channel. Rows on the inbox
+// channel make up the user's in-app inbox.
+type Notification struct {
+	core.Model
+	UserID        int        `json:"user_id" gorm:"index"`
+	Event         string     `json:"event"`
+	Channel       string     `json:"channel" gorm:"uniqueIndex:idx_notifications_dedup"`
+	DedupKey      string     `json:"-" gorm:"uniqueIndex:idx_notifications_dedup"`
+	Title         string     `json:"title"`
+	Body          string     `json:"body"`
+	Data          string     `json:"data,omitempty"` // JSON
+	Status        Status     `json:"status" gorm:"index"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"-" gorm:"index"`
+	LastError     string     `json:"-"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	ReadAt        *time.Time `json:"read_at,omitempty"`
+}
+
+// ChannelPreference turns a channel on or off for one event, or for all
+// events when Event is AllEvents. An event-specific row wins.
+type ChannelPreference struct {
+	core.Model
+	UserID  int    `json:"user_id" gorm:"uniqueIndex:idx_channel_preferences_user_event_channel"`
+	Event   string `json:"event" gorm:"uniqueIndex:idx_channel_preferences_user_event_channel"`
+	Channel string `json:"channel" gorm:"uniqueIndex:idx_channel_preferences_user_event_channel"`
+	Enabled bool   `json:"enabled"`
+}
+
+// QuietHours holds push and SMS delivery between StartTime and EndTime
+// ("HH:MM") in the user's time zone. The window may cross midnight.
+type QuietHours struct {
+	core.Model
+	UserID    int    `json:"user_id" gorm:"uniqueIndex"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Timezone  string `json:"timezone"`
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"cashapp/core/fieldcrypt"
+
+	"gorm.io/gorm"
+)
+
+// Minimal definition for lookup
+type contactStub struct {
+	ID    int
+	Email *fieldcrypt.String
+	Phone *fieldcrypt.String
+}
+
+type claimStub struct {
+	Target fieldcrypt.String
+}
+
+type contactLayer struct {
+	db *gorm.DB
+}
+
+// ContactRepo reads the verified addresses the user service keeps on users.
+type ContactRepo interface {
+	Find(userID int) (email string, phone string, err error)
+	// FindClaimTarget returns the email address or phone number a payment
+	// held in escrow by the ledger was sent to.
+	FindClaimTarget(claimID int) (string, error)
+}
+
+func newContactLayer(db *gorm.DB) *contactLayer {
+	return &contactLayer{
+		db: db,
+	}
+}
+
+func (l *contactLayer) Find(userID int) (string, string, error) {
+	var c contactStub
+	err := l.db.Table("users").Select("id, email, phone").Where("id = ? AND deleted_at IS NULL", userID).First(&c).Error
+	if err != nil {
+		return "", "", err
+	}
+
+	email, phone := "", ""
+	if c.Email != nil {
+		email = string(*c.Email)
+	}
+	if c.Phone != nil {
+		phone = string(*c.Phone)
+	}
+	return email, phone, nil
+}
+
+func (l *contactLayer) FindClaimTarget(claimID int) (string, error) {
+	var c claimStub
+	err := l.db.Table("pending_claims").Select("target").Where("id = ?", claimID).First(&c).Error
+	return string(c.Target), err
+}
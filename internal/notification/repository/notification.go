@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"cashapp/internal/notification/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type notificationLayer struct {
+	db *gorm.DB
+}
+
+type NotificationRepo interface {
+	Create(n *models.Notification) (bool, error)
+	Update(n *models.Notification) error
+	ClaimDue(now time.Time, limit int, lease time.Duration) ([]models.Notification, error)
+	ListWithDataKey(events []string, key string, limit int) ([]models.Notification, error)
+	ListInbox(userID int, unreadOnly bool, beforeID int, limit int) ([]models.Notification, error)
+	CountUnread(userID int) (int64, error)
+	MarkRead(userID int, id int) (bool, error)
+	MarkAllRead(userID int) (int64, error)
+}
+
+func newNotificationLayer(db *gorm.DB) *notificationLayer {
+	return &notificationLayer{
+		db: db,
+	}
+}
+
+// Create stores a notification unless one with the same dedup key already
+// exists on the channel. It reports whether a row was written.
+func (l *notificationLayer) Create(n *models.Notification) (bool, error) {
+	result := l.db.Clauses(clause.OnConflict{DoNothing: true}).Create(n)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (l *notificationLayer) Update(n *models.Notification) error {
+	return l.db.Save(n).Error
+}
+
+// ClaimDue takes up to limit pending notifications whose next attempt is
+// due and pushes their next attempt back by lease, so no other worker picks
+// them up while the caller delivers them. Delivery happens after the claim
+// commits; the caller saves each outcome with Update. Rows locked by
+// another worker are skipped, so several instances can run side by side.
+func (l *notificationLayer) ClaimDue(now time.Time, limit int, lease time.Duration) ([]models.Notification, error) {
+	var due []models.Notification
+	err := l.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.StatusPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		ids := make([]int, len(due))
+		leased := now.Add(lease)
+		for i := range due {
+			ids[i] = due[i].ID
+			due[i].NextAttemptAt = leased
+		}
+		return tx.Model(&models.Notification{}).Where("id IN ?", ids).UpdateColumn("next_attempt_at", leased).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// ListWithDataKey returns up to limit notifications for events whose data
+// still has key.
+func (l *notificationLayer) ListWithDataKey(events []string, key string, limit int) ([]models.Notification, error) {
+	var items []models.Notification
+	err := l.db.Where("event IN ? AND data LIKE ?", events, `%"`+key+`":%`).
+		Order("id").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+func (l *notificationLayer) ListInbox(userID int, unreadOnly bool, beforeID int, limit int) ([]models.Notification, error) {
+	var items []models.Notification
+	q := l.db.Where("user_id = ? AND channel = ?", userID, models.ChannelInbox)
+	if unreadOnly {
+		q = q.Where("read_at IS NULL")
+	}
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	err := q.Order("id desc").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+func (l *notificationLayer) CountUnread(userID int) (int64, error) {
+	var count int64
+	err := l.db.Model(&models.Notification{}).
+		Where("user_id = ? AND channel = ? AND read_at IS NULL", userID, models.ChannelInbox).
+		Count(&count).Error
+	return count, err
+}
+
+func (l *notificationLayer) MarkRead(userID int, id int) (bool, error) {
+	result := l.db.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND channel = ?", id, userID, models.ChannelInbox).
+		Where("read_at IS NULL").
+		Update("read_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (l *notificationLayer) MarkAllRead(userID int) (int64, error) {
+	result := l.db.Model(&models.Notification{}).
+		Where("user_id = ? AND channel = ? AND read_at IS NULL", userID, models.ChannelInbox).
+		Update("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
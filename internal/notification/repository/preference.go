@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"cashapp/internal/notification/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type preferenceLayer struct {
+	db *gorm.DB
+}
+
+type PreferenceRepo interface {
+	ListChannels(userID int) ([]models.ChannelPreference, error)
+	SetChannel(pref *models.ChannelPreference) error
+	FindQuietHours(userID int) (*models.QuietHours, error)
+	SetQuietHours(q *models.QuietHours) error
+	ClearQuietHours(userID int) error
+}
+
+func newPreferenceLayer(db *gorm.DB) *preferenceLayer {
+	return &preferenceLayer{
+		db: db,
+	}
+}
+
+func (l *preferenceLayer) ListChannels(userID int) ([]models.ChannelPreference, error) {
+	var prefs []models.ChannelPreference
+	err := l.db.Where("user_id = ?", userID).Order("event, channel").Find(&prefs).Error
+	return prefs, err
+}
+
+func (l *preferenceLayer) SetChannel(pref *models.ChannelPreference) error {
+	return l.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "event"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(pref).Error
+}
+
+func (l *preferenceLayer) FindQuietHours(userID int) (*models.QuietHours, error) {
+	var q models.QuietHours
+	if err := l.db.Where("user_id = ?", userID).First(&q).Error; err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (l *preferenceLayer) SetQuietHours(q *models.QuietHours) error {
+	return l.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"start_time", "end_time", "timezone", "updated_at"}),
+	}).Create(q).Error
+}
+
+func (l *preferenceLayer) ClearQuietHours(userID int) error {
+	return l.db.Where("user_id = ?", userID).Delete(&models.QuietHours{}).Error
+}
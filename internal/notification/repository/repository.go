@@ -0,0 +1,17 @@
+package repository
+
+import "gorm.io/gorm"
+
+type Repo struct {
+	Notifications NotificationRepo
+	Preferences   PreferenceRepo
+	Contacts      ContactRepo
+}
+
+func New(db *gorm.DB) Repo {
+	return Repo{
+		Notifications: newNotificationLayer(db),
+		Preferences:   newPreferenceLayer(db),
+		Contacts:      newContactLayer(db),
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/notification/models"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Channel delivers a rendered notification to an address: a device owner's
+// user ID for push, an email address or a phone number.
+type Channel interface {
+	Deliver(n *models.Notification, address string) error
+}
+
+// stdoutChannel logs each delivery.
+type stdoutChannel struct {
+	name string
+}
+
+func (c stdoutChannel) Deliver(n *models.Notification, address string) error {
+	core.Log.Info("notification delivered",
+		zap.String("channel", c.name),
+		zap.String("to", address),
+		zap.String("event", n.Event),
+		zap.String("title", n.Title),
+		zap.String("body", n.Body))
+	return nil
+}
+
+// fileChannel appends each delivery as a line to a file shared by all
+// channels.
+type fileChannel struct {
+	name string
+	path string
+	mu   *sync.Mutex
+}
+
+func (c fileChannel) Deliver(n *models.Notification, address string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file, err := os.OpenFile(c.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		time.Now().Format(time.RFC3339), c.name, address, n.Event, n.Title, n.Body)
+	return err
+}
+
+// devChannels builds the push, email and SMS channels for the configured
+// local sink. Production providers replace them through SetChannel.
+func devChannels(config *core.Config) map[string]Channel {
+	channels := make(map[string]Channel)
+	mu := &sync.Mutex{}
+	for _, name := range []string{models.ChannelPush, models.ChannelEmail, models.ChannelSMS} {
+		if config.NOTIFY_SINK == "file" {
+			channels[name] = fileChannel{name: name, path: config.NOTIFY_FILE, mu: mu}
+		} else {
+			channels[name] = stdoutChannel{name: name}
+		}
+	}
+	return channels
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/notification/models"
+	"encoding/json"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// claimEvents name the contact a payment held in escrow was sent to. Their
+// data carries only the claim ID; the contact is looked up from the claim,
+// masked in what is stored and shown in full only in what is delivered.
+var claimEvents = []string{core.EventClaimCompleted, core.EventClaimRefunded}
+
+// withClaimTarget returns data with target set to the contact of the claim
+// in data["claim_id"], masked unless reveal is set. Data without a claim is
+// returned as is.
+func (s *NotificationService) withClaimTarget(data map[string]interface{}, reveal bool) (map[string]interface{}, error) {
+	claimID := claimIDOf(data)
+	if claimID == 0 {
+		return data, nil
+	}
+
+	target, err := s.repository.Contacts.FindClaimTarget(claimID)
+	if err != nil {
+		return nil, err
+	}
+	if !reveal {
+		target = maskContact(target)
+	}
+
+	out := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		out[k] = v
+	}
+	out["target"] = target
+	return out, nil
+}
+
+// revealed returns n as it should be delivered: re-rendered with the full
+// contact when it names a claim's, otherwise n itself.
+func (s *NotificationService) revealed(n *models.Notification) (*models.Notification, error) {
+	var data map[string]interface{}
+	if n.Data == "" || json.Unmarshal([]byte(n.Data), &data) != nil || claimIDOf(data) == 0 {
+		return n, nil
+	}
+
+	data, err := s.withClaimTarget(data, true)
+	if err != nil {
+		return nil, err
+	}
+	msg := *n
+	msg.Title, msg.Body = render(n.Event, data)
+	return &msg, nil
+}
+
+// ScrubClaimTargets removes the contacts that claim notifications stored in
+// the clear before they stored only the claim ID, masking them in the
+// stored title and body.
+func (s *NotificationService) ScrubClaimTargets() error {
+	for {
+		items, err := s.repository.Notifications.ListWithDataKey(claimEvents, "target", retryBatch)
+		if err != nil || len(items) == 0 {
+			return err
+		}
+
+		for i := range items {
+			n := &items[i]
+			var data map[string]interface{}
+			if err := json.Unmarshal([]byte(n.Data), &data); err != nil {
+				core.Log.Error("failed to read notification data", zap.Int("notification_id", n.ID), zap.Error(err))
+				data = map[string]interface{}{}
+			}
+
+			target, _ := data["target"].(string)
+			delete(data, "target")
+			payload, err := json.Marshal(data)
+			if err != nil {
+				return err
+			}
+
+			masked := make(map[string]interface{}, len(data)+1)
+			for k, v := range data {
+				masked[k] = v
+			}
+			masked["target"] = maskContact(target)
+
+			n.Data = string(payload)
+			n.Title, n.Body = render(n.Event, masked)
+			if err := s.repository.Notifications.Update(n); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// claimIDOf reads data["claim_id"], which is an int when data comes from
+// the caller and a float64 when it has been through JSON.
+func claimIDOf(data map[string]interface{}) int {
+	switch id := data["claim_id"].(type) {
+	case int:
+		return id
+	case float64:
+		return int(id)
+	}
+	return 0
+}
+
+// maskContact keeps enough of an email address or phone number for its
+// owner to recognise it: the first letter and domain of an email, the last
+// four digits of a phone number.
+func maskContact(contact string) string {
+	if at := strings.LastIndex(contact, "@"); at > 0 {
+		return contact[:1] + "***" + contact[at:]
+	}
+	if len(contact) > 4 {
+		return "***" + contact[len(contact)-4:]
+	}
+	return "***"
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/notification/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ListInbox returns the user's in-app notifications, newest first.
+func (s *NotificationService) ListInbox(userID int, q core.InboxQuery) core.Response {
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	limit := core.PageSize(q.Limit)
+	items, err := s.repository.Notifications.ListInbox(userID, q.Unread, cursor.BeforeID, limit+1)
+	if err != nil {
+		return core.Error(err, core.String("failed to load notifications"))
+	}
+
+	pagination := &core.Pagination{}
+	if len(items) > limit {
+		items = items[:limit]
+		pagination.NextCursor = core.Cursor{BeforeID: items[limit-1].ID}.Encode()
+	}
+	pagination.Count = int64(len(items))
+
+	unread, err := s.repository.Notifications.CountUnread(userID)
+	if err != nil {
+		return core.Error(err, core.String("failed to count unread notifications"))
+	}
+
+	return core.Paginated(&map[string]interface{}{
+		"notifications": items,
+		"unread":        unread,
+	}, pagination, nil)
+}
+
+func (s *NotificationService) MarkRead(userID int, id int) core.Response {
+	ok, err := s.repository.Notifications.MarkRead(userID, id)
+	if err != nil {
+		return core.Error(err, core.String("failed to mark notification read"))
+	}
+	if !ok {
+		return core.Error(core.NotFound(errors.New("unread notification not found")), core.String("notification not found or already read"))
+	}
+	return core.Success(nil, core.String("notification marked read"))
+}
+
+func (s *NotificationService) MarkAllRead(userID int) core.Response {
+	n, err := s.repository.Notifications.MarkAllRead(userID)
+	if err != nil {
+		return core.Error(err, core.String("failed to mark notifications read"))
+	}
+	return core.Success(&map[string]interface{}{
+		"marked": n,
+	}, core.String("notifications marked read"))
+}
+
+func (s *NotificationService) GetPreferences(userID int) core.Response {
+	prefs, err := s.repository.Preferences.ListChannels(userID)
+	if err != nil {
+		return core.Error(err, core.String("failed to load preferences"))
+	}
+
+	data := map[string]interface{}{
+		"defaults": defaultChannels,
+		"channels": prefs,
+	}
+
+	quiet, err := s.repository.Preferences.FindQuietHours(userID)
+	switch {
+	case err == nil:
+		data["quiet_hours"] = quiet
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return core.Error(err, core.String("failed to load quiet hours"))
+	}
+
+	return core.Success(&data, nil)
+}
+
+func (s *NotificationService) UpdatePreferences(userID int, req core.NotificationPreferencesRequest) core.Response {
+	if req.QuietHours != nil {
+		if _, err := time.LoadLocation(req.QuietHours.Timezone); err != nil {
+			return core.Error(core.Validation(err), core.String("unknown timezone"))
+		}
+	}
+
+	for _, p := range req.Channels {
+		if p.Event != models.AllEvents {
+			if _, ok := templates[p.Event]; !ok {
+				return core.Error(core.Validation(errors.New("unknown event")), core.String("unknown notification event "+p.Event))
+			}
+		}
+		err := s.repository.Preferences.SetChannel(&models.ChannelPreference{
+			UserID:  userID,
+			Event:   p.Event,
+			Channel: p.Channel,
+			Enabled: *p.Enabled,
+		})
+		if err != nil {
+			return core.Error(err, core.String("failed to save preferences"))
+		}
+	}
+
+	switch {
+	case req.QuietHours != nil:
+		err := s.repository.Preferences.SetQuietHours(&models.QuietHours{
+			UserID:    userID,
+			StartTime: req.QuietHours.StartTime,
+			EndTime:   req.QuietHours.EndTime,
+			Timezone:  req.QuietHours.Timezone,
+		})
+		if err != nil {
+			return core.Error(err, core.String("failed to save quiet hours"))
+		}
+	case req.ClearQuietHours:
+		if err := s.repository.Preferences.ClearQuietHours(userID); err != nil {
+			return core.Error(err, core.String("failed to clear quiet hours"))
+		}
+	}
+
+	return s.GetPreferences(userID)
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/notification/models"
+	"cashapp/internal/notification/repository"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	maxAttempts = 5
+	retryBase   = 30 * time.Second
+	retryBatch  = 100
+	// deliveryLease keeps other workers away from a notification while one
+	// delivers it.
+	deliveryLease = time.Minute
+)
+
+// defaultChannels applies when a user has no preference for a channel.
+var defaultChannels = map[string]bool{
+	models.ChannelPush:  true,
+	models.ChannelEmail: false,
+	models.ChannelSMS:   false,
+}
+
+// quietChannels are held back during quiet hours.
+var quietChannels = map[string]bool{
+	models.ChannelPush: true,
+	models.ChannelSMS:  true,
+}
+
+type NotificationService struct {
+	repository repository.Repo
+	channels   map[string]Channel
+}
+
+func New(r repository.Repo, c *core.Config) *NotificationService {
+	return &NotificationService{
+		repository: r,
+		channels:   devChannels(c),
+	}
+}
+
+// SetChannel replaces the delivery adapter for a channel.
+func (s *NotificationService) SetChannel(name string, ch Channel) {
+	s.channels[name] = ch
+}
+
+// Notify renders event for userID and queues it on every channel the user
+// has enabled. The in-app inbox always gets a copy. Set data["dedup_key"] to
+// control deduplication; by default identical events with identical data
+// are only sent once. Errors are logged rather than returned so callers can
+// notify without failing the operation that triggered it.
+func (s *NotificationService) Notify(userID int, event string, data map[string]interface{}) {
+	if err := s.notify(userID, event, data); err != nil {
+		core.Log.Error("failed to queue notification", zap.Int("user_id", userID), zap.String("event", event), zap.Error(err))
+	}
+}
+
+func (s *NotificationService) notify(userID int, event string, data map[string]interface{}) error {
+	dedupKey, data := dedupKeyOf(userID, event, data)
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	stored, err := s.withClaimTarget(data, false)
+	if err != nil {
+		return err
+	}
+	title, body := render(event, stored)
+
+	channels, err := s.enabledChannels(userID, event)
+	if err != nil {
+		return err
+	}
+	quietUntil, err := s.quietUntil(userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, channel := range channels {
+		n := models.Notification{
+			UserID:        userID,
+			Event:         event,
+			Channel:       channel,
+			DedupKey:      dedupKey,
+			Title:         title,
+			Body:          body,
+			Data:          string(payload),
+			Status:        models.StatusPending,
+			NextAttemptAt: now.Add(deliveryLease),
+		}
+		held := quietChannels[channel] && quietUntil.After(now)
+		switch {
+		case channel == models.ChannelInbox:
+			n.Status = models.StatusSent
+			n.SentAt = &now
+		case held:
+			n.NextAttemptAt = quietUntil
+		}
+
+		created, err := s.repository.Notifications.Create(&n)
+		if err != nil {
+			return err
+		}
+		if !created || n.Status != models.StatusPending || held {
+			continue
+		}
+
+		if err := s.attempt(&n); err != nil {
+			return err
+		}
+		if err := s.repository.Notifications.Update(&n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunRetries delivers notifications held for quiet hours and retries failed
+// deliveries with exponential backoff. It blocks; run it in a goroutine.
+func (s *NotificationService) RunRetries(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			due, err := s.repository.Notifications.ClaimDue(time.Now(), retryBatch, deliveryLease)
+			if err != nil {
+				core.Log.Error("failed to claim queued notifications", zap.Error(err))
+				break
+			}
+			for i := range due {
+				s.deliverQueued(&due[i])
+			}
+			if len(due) < retryBatch {
+				break
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// deliverQueued makes one attempt at a claimed notification and saves the
+// outcome. If the attempt can't be made it is left to be claimed again once
+// its lease runs out.
+func (s *NotificationService) deliverQueued(n *models.Notification) {
+	if err := s.attempt(n); err != nil {
+		core.Log.Error("failed to deliver notification", zap.Int("notification_id", n.ID), zap.Error(err))
+		return
+	}
+	if err := s.repository.Notifications.Update(n); err != nil {
+		core.Log.Error("failed to save notification delivery", zap.Int("notification_id", n.ID), zap.Error(err))
+	}
+}
+
+// attempt makes one delivery attempt and records the outcome on n. It only
+// returns an error when the recipient or the message can't be looked up.
+func (s *NotificationService) attempt(n *models.Notification) error {
+	channel, ok := s.channels[n.Channel]
+	if !ok {
+		n.Status = models.StatusSuppressed
+		n.LastError = "no adapter for channel"
+		return nil
+	}
+
+	address, err := s.addressFor(n.UserID, n.Channel)
+	if err != nil {
+		return err
+	}
+	if address == "" {
+		n.Status = models.StatusSuppressed
+		n.LastError = fmt.Sprintf("no verified %s", n.Channel)
+		return nil
+	}
+
+	msg, err := s.revealed(n)
+	if err != nil {
+		return err
+	}
+
+	n.Attempts++
+	if err := channel.Deliver(msg, address); err != nil {
+		n.LastError = err.Error()
+		if n.Attempts >= maxAttempts {
+			n.Status = models.StatusFailed
+			core.Log.Error("notification delivery failed", zap.Int("notification_id", n.ID), zap.String("channel", n.Channel), zap.Error(err))
+			return nil
+		}
+		n.NextAttemptAt = time.Now().Add(retryBase << (n.Attempts - 1))
+		return nil
+	}
+
+	now := time.Now()
+	n.Status = models.StatusSent
+	n.SentAt = &now
+	n.LastError = ""
+	return nil
+}
+
+func (s *NotificationService) addressFor(userID int, channel string) (string, error) {
+	if channel == models.ChannelPush {
+		return strconv.Itoa(userID), nil
+	}
+
+	email, phone, err := s.repository.Contacts.Find(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	if channel == models.ChannelSMS {
+		return phone, nil
+	}
+	return email, nil
+}
+
+// enabledChannels resolves the user's preferences for event: an
+// event-specific preference beats one for all events, which beats the
+// default. The inbox can't be turned off.
+func (s *NotificationService) enabledChannels(userID int, event string) ([]string, error) {
+	prefs, err := s.repository.Preferences.ListChannels(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool, len(defaultChannels))
+	for channel, on := range defaultChannels {
+		enabled[channel] = on
+	}
+	for _, p := range prefs {
+		if p.Event == models.AllEvents {
+			enabled[p.Channel] = p.Enabled
+		}
+	}
+	for _, p := range prefs {
+		if p.Event == event {
+			enabled[p.Channel] = p.Enabled
+		}
+	}
+
+	channels := []string{models.ChannelInbox}
+	for _, channel := range []string{models.ChannelPush, models.ChannelEmail, models.ChannelSMS} {
+		if enabled[channel] {
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
+
+// quietUntil returns when the user's quiet hours end if now falls inside
+// them, or the zero time otherwise.
+func (s *NotificationService) quietUntil(userID int, now time.Time) (time.Time, error) {
+	q, err := s.repository.Preferences.FindQuietHours(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return quietWindowEnd(q, now), nil
+}
+
+func quietWindowEnd(q *models.QuietHours, now time.Time) time.Time {
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, err1 := time.Parse("15:04", q.StartTime)
+	end, err2 := time.Parse("15:04", q.EndTime)
+	if err1 != nil || err2 != nil {
+		return time.Time{}
+	}
+
+	local := now.In(loc)
+	at := func(day time.Time, clock time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	}
+
+	startToday, endToday := at(local, start), at(local, end)
+	if !startToday.After(endToday) {
+		// Same-day window, e.g. 13:00-15:00.
+		if !local.Before(startToday) && local.Before(endToday) {
+			return endToday
+		}
+		return time.Time{}
+	}
+
+	// Window crosses midnight, e.g. 22:00-07:00.
+	if !local.Before(startToday) {
+		return endToday.AddDate(0, 0, 1)
+	}
+	if local.Before(endToday) {
+		return endToday
+	}
+	return time.Time{}
+}
+
+// dedupKeyOf takes the caller's dedup_key out of data, or derives one from
+// the event and its data.
+func dedupKeyOf(userID int, event string, data map[string]interface{}) (string, map[string]interface{}) {
+	if key, ok := data["dedup_key"].(string); ok && key != "" {
+		rest := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			if k != "dedup_key" {
+				rest[k] = v
+			}
+		}
+		return fmt.Sprintf("%d:%s:%s", userID, event, key), rest
+	}
+
+	payload, _ := json.Marshal(data)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s:%s", userID, event, payload)))
+	return hex.EncodeToString(sum[:]), data
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"cashapp/core"
+	"strings"
+	"text/template"
+)
+
+type messageTemplate struct {
+	title *template.Template
+	body  *template.Template
+}
+
+func newTemplate(title, body string) messageTemplate {
+	return messageTemplate{
+		title: template.Must(template.New("title").Option("missingkey=error").Parse(title)),
+		body:  template.Must(template.New("body").Option("missingkey=error").Parse(body)),
+	}
+}
+
+var templates = map[string]messageTemplate{
+	core.EventPaymentReceived: newTemplate(
+		"You received {{.amount}}",
+		`${{.from_tag}} sent you {{.amount}}{{with .description}} for "{{.}}"{{end}}.`,
+	),
+	core.EventRequestCreated: newTemplate(
+		"New payment request",
+		`${{.requester_tag}} requested {{.amount}} from you{{with .description}} for "{{.}}"{{end}}.`,
+	),
+	core.EventRequestReminder: newTemplate(
+		"Payment request reminder",
+		`${{.requester_tag}} is reminding you about their request for {{.amount}}{{with .description}} for "{{.}}"{{end}}.`,
+	),
+	core.EventKYCResult: newTemplate(
+		`Identity verification {{if eq .status "in_review"}}in review{{else}}{{.status}}{{end}}`,
+		`{{if eq .status "verified"}}You're verified. Higher limits are now available.{{else if eq .status "in_review"}}We're taking a closer look at your details and will let you know once we're done.{{else}}We couldn't verify your identity. You can try again with a different document.{{end}}`,
+	),
+	core.EventKYCDocumentRejected: newTemplate(
+		"Document not accepted",
+		"We couldn't accept your {{.document}}: {{.reason}}. You can upload a new one.",
+	),
+	core.EventDepositSettled: newTemplate(
+		"Deposit complete",
+		"{{.amount}} from your {{.source}} is now in your wallet.",
+	),
+	core.EventCommentCreated: newTemplate(
+		"New comment",
+		"${{.author_tag}}: {{.body}}",
+	),
+	core.EventClaimCompleted: newTemplate(
+		"Payment claimed",
+		"The {{.amount}} you sent to {{.target}} was claimed.",
+	),
+	core.EventClaimRefunded: newTemplate(
+		"Payment returned",
+		"Nobody claimed the {{.amount}} you sent to {{.target}}, so it's back in your wallet.",
+	),
+	core.EventWebhookDisabled: newTemplate(
+		"Webhook endpoint turned off",
+		"Deliveries to {{.url}} kept failing, so we stopped sending them. Turn the endpoint back on once it's fixed.",
+	),
+}
+
+// render fills in an event's title and body. Events without a template, or
+// whose data is missing fields, fall back to the event name.
+func render(event string, data map[string]interface{}) (string, string) {
+	t, ok := templates[event]
+	if !ok {
+		return event, ""
+	}
+
+	var title, body strings.Builder
+	if err := t.title.Execute(&title, data); err != nil {
+		return event, ""
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return title.String(), ""
+	}
+	return title.String(), body.String()
+}
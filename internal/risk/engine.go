@@ -0,0 +1,278 @@
+package risk
+
+import (
+	"cashapp/core"
+	"cashapp/core/currency"
+	"cashapp/internal/limits"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Input is a movement of money to assess. CounterpartyID is 0 when there is
+// none, as for deposits and payments to unregistered contacts.
+type Input struct {
+	UserID         int
+	CounterpartyID int
+	Kind           Kind
+	Amount         int64 // minor units
+}
+
+// Reason is one rule that fired.
+type Reason struct {
+	Rule    string  `json:"rule"`
+	Outcome Outcome `json:"outcome"`
+	Detail  string  `json:"detail"`
+}
+
+// Decision is the outcome of assessing a movement: the most severe outcome
+// of the rules that fired.
+type Decision struct {
+	Outcome Outcome  `json:"outcome"`
+	Reasons []Reason `json:"reasons,omitempty"`
+}
+
+// Summary joins the reasons into one line for storing alongside a
+// transaction.
+func (d Decision) Summary() string {
+	parts := make([]string, 0, len(d.Reasons))
+	for _, r := range d.Reasons {
+		parts = append(parts, r.Rule+": "+r.Detail)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (d *Decision) add(rule string, outcome Outcome, format string, args ...interface{}) {
+	if outcome == "" || outcome == Allow {
+		return
+	}
+	d.Reasons = append(d.Reasons, Reason{Rule: rule, Outcome: outcome, Detail: fmt.Sprintf(format, args...)})
+	d.Outcome = worse(d.Outcome, outcome)
+}
+
+// Assessment records every decision other than allow, so the reasons are
+// kept even for movements that never became a transaction.
+type Assessment struct {
+	core.Model
+	UserID         int     `json:"user_id" gorm:"index"`
+	CounterpartyID int     `json:"counterparty_id,omitempty"`
+	Kind           Kind    `json:"kind"`
+	Amount         int64   `json:"amount"` // minor units
+	Ref            string  `json:"ref,omitempty" gorm:"index"`
+	Outcome        Outcome `json:"outcome"`
+	Reasons        string  `json:"reasons"`
+}
+
+// Engine assesses money movements before they happen. Volume and
+// counterparty history come from the usage the limits engine records.
+type Engine struct {
+	db    *gorm.DB
+	rules Rules
+}
+
+func New(db *gorm.DB, rules Rules) *Engine {
+	return &Engine{
+		db:    db,
+		rules: rules,
+	}
+}
+
+// Evaluate runs every rule against in and returns the combined decision.
+func (e *Engine) Evaluate(in Input) (Decision, error) {
+	d := Decision{Outcome: Allow}
+	now := time.Now()
+
+	for _, check := range []func(Input, time.Time, *Decision) error{
+		e.checkSenderScore,
+		e.checkRecipientScore,
+		e.checkVelocity,
+		e.checkNewRecipient,
+		e.checkFundingCashOut,
+		e.checkRoundAmounts,
+	} {
+		if err := check(in, now, &d); err != nil {
+			return Decision{}, err
+		}
+	}
+	return d, nil
+}
+
+// Record stores a decision against ref and moves the user's risk score by the
+// configured amount for its outcome.
+func (e *Engine) Record(in Input, ref string, d Decision) error {
+	if d.Outcome != Allow {
+		err := e.db.Create(&Assessment{
+			UserID:         in.UserID,
+			CounterpartyID: in.CounterpartyID,
+			Kind:           in.Kind,
+			Amount:         in.Amount,
+			Ref:            ref,
+			Outcome:        d.Outcome,
+			Reasons:        d.Summary(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	s := e.rules.Scoring
+	delta := map[Outcome]int{Allow: s.Allow, StepUp: s.StepUp, Review: s.Review, Block: s.Block}[d.Outcome]
+	return e.adjustScore(in.UserID, delta)
+}
+
+// RecordReview moves a user's risk score after a reviewer approved or
+// rejected one of their held movements.
+func (e *Engine) RecordReview(userID int, approved bool) error {
+	if approved {
+		return e.adjustScore(userID, e.rules.Scoring.Approved)
+	}
+	return e.adjustScore(userID, e.rules.Scoring.Rejected)
+}
+
+func (e *Engine) adjustScore(userID, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return e.db.Table("users").
+		Where("id = ? AND deleted_at IS NULL", userID).
+		Update("risk_score", gorm.Expr("LEAST(100, GREATEST(0, risk_score + ?))", delta)).Error
+}
+
+func (e *Engine) checkSenderScore(in Input, _ time.Time, d *Decision) error {
+	return e.checkScore("sender_score", in.UserID, e.rules.SenderScore, d)
+}
+
+func (e *Engine) checkRecipientScore(in Input, _ time.Time, d *Decision) error {
+	if in.CounterpartyID == 0 {
+		return nil
+	}
+	return e.checkScore("recipient_score", in.CounterpartyID, e.rules.RecipientScore, d)
+}
+
+func (e *Engine) checkScore(rule string, userID int, r ScoreRule, d *Decision) error {
+	if r.ReviewAt == 0 && r.BlockAt == 0 {
+		return nil
+	}
+
+	score, err := e.riskScore(userID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case r.BlockAt > 0 && score >= r.BlockAt:
+		d.add(rule, Block, "risk score %d", score)
+	case r.ReviewAt > 0 && score >= r.ReviewAt:
+		d.add(rule, Review, "risk score %d", score)
+	}
+	return nil
+}
+
+func (e *Engine) checkVelocity(in Input, now time.Time, d *Decision) error {
+	r := e.rules.Velocity
+	if r.MaxCount == 0 || r.WindowMinutes == 0 {
+		return nil
+	}
+
+	var count int64
+	err := e.usage(in.UserID, limitsKind(in.Kind), now.Add(-time.Duration(r.WindowMinutes)*time.Minute)).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count >= int64(r.MaxCount) {
+		d.add("velocity", r.Outcome, "%d %ss in the last %d minutes", count+1, in.Kind, r.WindowMinutes)
+	}
+	return nil
+}
+
+func (e *Engine) checkNewRecipient(in Input, _ time.Time, d *Decision) error {
+	r := e.rules.NewRecipient
+	if in.Kind != KindSend || in.CounterpartyID == 0 || r.MinAmount == 0 || in.Amount < currency.ConvertCedisToPessewas(r.MinAmount) {
+		return nil
+	}
+
+	var count int64
+	err := e.db.Model(&limits.Usage{}).
+		Where("user_id = ? AND kind = ? AND counterparty_id = ?", in.UserID, limits.KindSend, in.CounterpartyID).
+		Limit(1).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		d.add("new_recipient", r.Outcome, "%s to a first-time recipient", core.FormatAmount(in.Amount))
+	}
+	return nil
+}
+
+func (e *Engine) checkFundingCashOut(in Input, now time.Time, d *Decision) error {
+	r := e.rules.FundingCashOut
+	if in.Kind == KindDeposit || r.WindowHours == 0 {
+		return nil
+	}
+	since := now.Add(-time.Duration(r.WindowHours) * time.Hour)
+
+	var linked int64
+	err := e.db.Table("funding_sources").
+		Where("user_id = ? AND created_at > ? AND deleted_at IS NULL", in.UserID, since).
+		Count(&linked).Error
+	if err != nil || linked == 0 {
+		return err
+	}
+
+	var deposited int64
+	err = e.usage(in.UserID, limits.KindDeposit, since).Select("COALESCE(SUM(amount), 0)").Scan(&deposited).Error
+	if err != nil || deposited == 0 {
+		return err
+	}
+	if float64(in.Amount) >= r.MinRatio*float64(deposited) {
+		d.add("funding_cash_out", r.Outcome, "%s out within %d hours of linking a new funding source and depositing %s",
+			core.FormatAmount(in.Amount), r.WindowHours, core.FormatAmount(deposited))
+	}
+	return nil
+}
+
+func (e *Engine) checkRoundAmounts(in Input, now time.Time, d *Decision) error {
+	r := e.rules.RoundAmounts
+	if in.Kind != KindSend || r.Multiple == 0 || r.MaxCount == 0 {
+		return nil
+	}
+	multiple := currency.ConvertCedisToPessewas(r.Multiple)
+	if in.Amount%multiple != 0 {
+		return nil
+	}
+
+	var count int64
+	err := e.usage(in.UserID, limits.KindSend, now.Add(-time.Duration(r.WindowHours)*time.Hour)).
+		Where("amount % ? = 0", multiple).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count >= int64(r.MaxCount) {
+		d.add("round_amounts", r.Outcome, "%d sends in multiples of %d within %d hours", count+1, r.Multiple, r.WindowHours)
+	}
+	return nil
+}
+
+func (e *Engine) usage(userID int, kind limits.Kind, since time.Time) *gorm.DB {
+	return e.db.Model(&limits.Usage{}).Where("user_id = ? AND kind = ? AND created_at > ?", userID, kind, since)
+}
+
+func (e *Engine) riskScore(userID int) (int, error) {
+	var user struct{ RiskScore int }
+	err := e.db.Table("users").Select("risk_score").Where("id = ? AND deleted_at IS NULL", userID).Take(&user).Error
+	return user.RiskScore, err
+}
+
+func limitsKind(k Kind) limits.Kind {
+	switch k {
+	case KindDeposit:
+		return limits.KindDeposit
+	case KindWithdrawal:
+		return limits.KindWithdrawal
+	default:
+		return limits.KindSend
+	}
+}
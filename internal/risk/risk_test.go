@@ -0,0 +1,149 @@
+package risk
+
+import (
+	"cashapp/internal/limits"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorse(t *testing.T) {
+	tests := []struct {
+		a, b Outcome
+		want Outcome
+	}{
+		{Allow, Allow, Allow},
+		{Allow, StepUp, StepUp},
+		{StepUp, Allow, StepUp},
+		{StepUp, Review, Review},
+		{Block, Review, Block},
+		{Review, Block, Block},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.a)+"/"+string(tt.b), func(t *testing.T) {
+			if got := worse(tt.a, tt.b); got != tt.want {
+				t.Fatalf("worse(%s, %s) = %s, want %s", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecisionAdd(t *testing.T) {
+	type fired struct {
+		rule    string
+		outcome Outcome
+	}
+	tests := []struct {
+		name        string
+		fired       []fired
+		wantOutcome Outcome
+		wantSummary string
+	}{
+		{"nothing fired", nil, Allow, ""},
+		{"allow isn't a reason", []fired{{"velocity", Allow}}, Allow, ""},
+		{"unset outcome isn't a reason", []fired{{"velocity", ""}}, Allow, ""},
+		{"one rule", []fired{{"new_recipient", StepUp}}, StepUp, "new_recipient: detail"},
+		{"most severe wins", []fired{{"round_amounts", StepUp}, {"sender_score", Block}, {"velocity", Review}}, Block,
+			"round_amounts: detail; sender_score: detail; velocity: detail"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Decision{Outcome: Allow}
+			for _, f := range tt.fired {
+				d.add(f.rule, f.outcome, "detail")
+			}
+			if d.Outcome != tt.wantOutcome {
+				t.Fatalf("Outcome = %s, want %s", d.Outcome, tt.wantOutcome)
+			}
+			if got := d.Summary(); got != tt.wantSummary {
+				t.Fatalf("Summary = %q, want %q", got, tt.wantSummary)
+			}
+		})
+	}
+}
+
+// TestEvaluateSkipsRulesThatDontApply runs Evaluate without a database: a
+// rule that reaches for one here would panic.
+func TestEvaluateSkipsRulesThatDontApply(t *testing.T) {
+	rules := Rules{
+		NewRecipient: NewRecipientRule{MinAmount: 500, Outcome: StepUp},
+		RoundAmounts: RoundAmountRule{Multiple: 100, WindowHours: 24, MaxCount: 3, Outcome: StepUp},
+	}
+
+	tests := []struct {
+		name  string
+		rules Rules
+		in    Input
+	}{
+		{"every rule off", Rules{}, Input{UserID: 1, CounterpartyID: 2, Kind: KindSend, Amount: 100000}},
+		{"deposit", rules, Input{UserID: 1, Kind: KindDeposit, Amount: 100000}},
+		{"withdrawal", rules, Input{UserID: 1, Kind: KindWithdrawal, Amount: 100000}},
+		{"small send, not round", rules, Input{UserID: 1, CounterpartyID: 2, Kind: KindSend, Amount: 12345}},
+		{"send to a contact, not round", rules, Input{UserID: 1, Kind: KindSend, Amount: 123456}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := New(nil, tt.rules).Evaluate(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Outcome != Allow || len(d.Reasons) != 0 {
+				t.Fatalf("Evaluate = %+v, want allow", d)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	valid := write("risk.json", `{"velocity": {"window_minutes": 5, "max_count": 2, "outcome": "block"}}`)
+	invalid := write("broken.json", `{"velocity": []}`)
+
+	tests := []struct {
+		name    string
+		path    string
+		want    VelocityRule
+		wantErr bool
+	}{
+		{"no file uses defaults", "", DefaultRules.Velocity, false},
+		{"file", valid, VelocityRule{WindowMinutes: 5, MaxCount: 2, Outcome: Block}, false},
+		{"missing file", filepath.Join(dir, "missing.json"), VelocityRule{}, true},
+		{"bad JSON", invalid, VelocityRule{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := LoadRules(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadRules error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if rules.Velocity != tt.want {
+				t.Fatalf("Velocity = %+v, want %+v", rules.Velocity, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitsKind(t *testing.T) {
+	tests := []struct {
+		kind Kind
+		want limits.Kind
+	}{
+		{KindSend, limits.KindSend},
+		{KindDeposit, limits.KindDeposit},
+		{KindWithdrawal, limits.KindWithdrawal},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			if got := limitsKind(tt.kind); got != tt.want {
+				t.Fatalf("limitsKind(%s) = %s, want %s", tt.kind, got, tt.want)
+			}
+		})
+	}
+}
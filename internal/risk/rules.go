@@ -0,0 +1,129 @@
+package risk
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Kind is the movement of money being assessed.
+type Kind string
+
+const (
+	KindSend       Kind = "send"
+	KindDeposit    Kind = "deposit"
+	KindWithdrawal Kind = "withdrawal"
+)
+
+// Outcome is what risk checks decide for a movement, from least to most
+// severe.
+type Outcome string
+
+const (
+	Allow  Outcome = "allow"
+	StepUp Outcome = "step_up" // the sender must confirm before it goes through
+	Review Outcome = "review"  // held until a reviewer approves it
+	Block  Outcome = "block"
+)
+
+var severity = map[Outcome]int{Allow: 0, StepUp: 1, Review: 2, Block: 3}
+
+// worse returns the more severe of two outcomes.
+func worse(a, b Outcome) Outcome {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
+
+// VelocityRule flags more than MaxCount movements of the same kind within
+// WindowMinutes.
+type VelocityRule struct {
+	WindowMinutes int     `json:"window_minutes"`
+	MaxCount      int     `json:"max_count"`
+	Outcome       Outcome `json:"outcome"`
+}
+
+// NewRecipientRule flags sends of at least MinAmount (major units) to someone
+// the sender has never paid before.
+type NewRecipientRule struct {
+	MinAmount int64   `json:"min_amount"`
+	Outcome   Outcome `json:"outcome"`
+}
+
+// FundingCashOutRule flags money leaving within WindowHours of a new funding
+// source being linked when it takes at least MinRatio of what was deposited
+// over the same window.
+type FundingCashOutRule struct {
+	WindowHours int     `json:"window_hours"`
+	MinRatio    float64 `json:"min_ratio"`
+	Outcome     Outcome `json:"outcome"`
+}
+
+// RoundAmountRule flags a send that is a whole multiple of Multiple (major
+// units) when the sender already made MaxCount such sends within
+// WindowHours.
+type RoundAmountRule struct {
+	Multiple    int64   `json:"multiple"`
+	WindowHours int     `json:"window_hours"`
+	MaxCount    int     `json:"max_count"`
+	Outcome     Outcome `json:"outcome"`
+}
+
+// ScoreRule holds or blocks movements involving a user whose risk score has
+// reached ReviewAt or BlockAt.
+type ScoreRule struct {
+	ReviewAt int `json:"review_at"`
+	BlockAt  int `json:"block_at"`
+}
+
+// Scoring is how much each outcome moves the sender's risk score, which is
+// kept between 0 and 100.
+type Scoring struct {
+	Allow    int `json:"allow"`
+	StepUp   int `json:"step_up"`
+	Review   int `json:"review"`
+	Block    int `json:"block"`
+	Approved int `json:"approved"` // a held movement a reviewer let through
+	Rejected int `json:"rejected"` // a held movement a reviewer turned down
+}
+
+// Rules configure the risk checks. A zero threshold turns its rule off.
+type Rules struct {
+	Velocity       VelocityRule       `json:"velocity"`
+	NewRecipient   NewRecipientRule   `json:"new_recipient"`
+	FundingCashOut FundingCashOutRule `json:"funding_cash_out"`
+	RoundAmounts   RoundAmountRule    `json:"round_amounts"`
+	RecipientScore ScoreRule          `json:"recipient_score"`
+	SenderScore    ScoreRule          `json:"sender_score"`
+	Scoring        Scoring            `json:"scoring"`
+}
+
+// DefaultRules apply when no RISK_FILE is configured.
+var DefaultRules = Rules{
+	Velocity:       VelocityRule{WindowMinutes: 10, MaxCount: 5, Outcome: Review},
+	NewRecipient:   NewRecipientRule{MinAmount: 500, Outcome: StepUp},
+	FundingCashOut: FundingCashOutRule{WindowHours: 24, MinRatio: 0.8, Outcome: Review},
+	RoundAmounts:   RoundAmountRule{Multiple: 100, WindowHours: 24, MaxCount: 3, Outcome: StepUp},
+	RecipientScore: ScoreRule{ReviewAt: 80, BlockAt: 100},
+	SenderScore:    ScoreRule{ReviewAt: 90, BlockAt: 100},
+	Scoring:        Scoring{Allow: -1, StepUp: 2, Review: 5, Block: 15, Approved: -10, Rejected: 25},
+}
+
+// LoadRules reads rules from a JSON file shaped like Rules. An empty path
+// returns DefaultRules.
+func LoadRules(path string) (Rules, error) {
+	if path == "" {
+		return DefaultRules, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, err
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return Rules{}, err
+	}
+	return rules, nil
+}
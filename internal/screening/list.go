@@ -0,0 +1,280 @@
+package screening
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Entry is one listed party. Names are kept as published; matching works on
+// their normalized tokens.
+type Entry struct {
+	List         string   `json:"list"`
+	UID          string   `json:"uid"`
+	Name         string   `json:"name"`
+	Aliases      []string `json:"aliases,omitempty"`
+	DatesOfBirth []string `json:"dates_of_birth,omitempty"` // 2006-01-02, 2006-01 or 2006
+	Program      string   `json:"program,omitempty"`
+
+	tokens [][]string // Name then Aliases, normalized
+}
+
+// Watchlist is every entry loaded from the configured files. Version changes
+// whenever the content of any file does.
+type Watchlist struct {
+	Version string
+	Entries []Entry
+}
+
+// Load reads watchlists from CSV and XML files. Each file becomes a list
+// named after the file.
+func Load(paths []string) (*Watchlist, error) {
+	hash := sha256.New()
+	wl := &Watchlist{}
+
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write([]byte(path))
+		hash.Write(raw)
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		var entries []Entry
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			entries, err = parseCSV(name, strings.NewReader(string(raw)))
+		case ".xml":
+			entries, err = parseXML(name, raw)
+		default:
+			err = fmt.Errorf("unsupported watchlist format %q", filepath.Ext(path))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", path, err)
+		}
+		wl.Entries = append(wl.Entries, entries...)
+	}
+
+	for i := range wl.Entries {
+		e := &wl.Entries[i]
+		for _, n := range append([]string{e.Name}, e.Aliases...) {
+			if tokens := NormalizeName(n); len(tokens) > 0 {
+				e.tokens = append(e.tokens, tokens)
+			}
+		}
+	}
+
+	if len(paths) > 0 {
+		wl.Version = hex.EncodeToString(hash.Sum(nil))[:16]
+	}
+	return wl, nil
+}
+
+// csvColumns maps the header names we accept to entry fields.
+var csvColumns = map[string]string{
+	"uid":            "uid",
+	"ent_num":        "uid",
+	"name":           "name",
+	"sdn_name":       "name",
+	"aliases":        "aliases",
+	"aka":            "aliases",
+	"dob":            "dob",
+	"date_of_birth":  "dob",
+	"dates_of_birth": "dob",
+	"program":        "program",
+}
+
+// parseCSV reads either a list with a header row naming its columns (uid,
+// name, aliases, dob, program; multiple aliases or dates separated by ";")
+// or a headerless OFAC sdn.csv, whose aliases and dates of birth live in the
+// remarks column.
+func parseCSV(list string, r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, h := range rows[0] {
+		if field, ok := csvColumns[strings.ToLower(strings.TrimSpace(h))]; ok {
+			columns[field] = i
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		return parseSDNCSV(list, rows), nil
+	}
+
+	get := func(row []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var entries []Entry
+	for n, row := range rows[1:] {
+		name := get(row, "name")
+		if name == "" {
+			continue
+		}
+		uid := get(row, "uid")
+		if uid == "" {
+			uid = fmt.Sprintf("row-%d", n+2)
+		}
+		entries = append(entries, Entry{
+			List:         list,
+			UID:          uid,
+			Name:         name,
+			Aliases:      splitList(get(row, "aliases")),
+			DatesOfBirth: parseDates(splitList(get(row, "dob"))),
+			Program:      get(row, "program"),
+		})
+	}
+	return entries, nil
+}
+
+var (
+	sdnAlias = regexp.MustCompile(`(?i)a\.k\.a\. '([^']+)'`)
+	sdnDOB   = regexp.MustCompile(`(?i)DOB ([0-9A-Za-z ]+?)(?:;|\.|$| to )`)
+)
+
+// parseSDNCSV reads OFAC's sdn.csv layout: ent_num, SDN_Name, SDN_Type,
+// Program, Title, Call_Sign, Vess_type, Tonnage, GRT, Vess_flag, Vess_owner,
+// Remarks. Missing values are "-0-".
+func parseSDNCSV(list string, rows [][]string) []Entry {
+	var entries []Entry
+	for _, row := range rows {
+		if len(row) < 2 || sdnValue(row[1]) == "" {
+			continue
+		}
+		e := Entry{List: list, UID: sdnValue(row[0]), Name: sdnValue(row[1])}
+		if len(row) > 3 {
+			e.Program = sdnValue(row[3])
+		}
+		if len(row) > 11 {
+			remarks := sdnValue(row[11])
+			for _, m := range sdnAlias.FindAllStringSubmatch(remarks, -1) {
+				e.Aliases = append(e.Aliases, m[1])
+			}
+			var dobs []string
+			for _, m := range sdnDOB.FindAllStringSubmatch(remarks, -1) {
+				dobs = append(dobs, m[1])
+			}
+			e.DatesOfBirth = parseDates(dobs)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func sdnValue(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "-0-" {
+		return ""
+	}
+	return v
+}
+
+// sdnList mirrors the parts of OFAC's sdn.xml we use. Namespaces are ignored
+// so internal lists may use the same shape without one.
+type sdnList struct {
+	Entries []struct {
+		UID       string   `xml:"uid"`
+		FirstName string   `xml:"firstName"`
+		LastName  string   `xml:"lastName"`
+		Programs  []string `xml:"programList>program"`
+		Akas      []struct {
+			FirstName string `xml:"firstName"`
+			LastName  string `xml:"lastName"`
+		} `xml:"akaList>aka"`
+		DatesOfBirth []string `xml:"dateOfBirthList>dateOfBirthItem>dateOfBirth"`
+	} `xml:"sdnEntry"`
+}
+
+func parseXML(list string, raw []byte) ([]Entry, error) {
+	var doc sdnList
+	if err := xml.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(doc.Entries))
+	for _, x := range doc.Entries {
+		name := joinName(x.FirstName, x.LastName)
+		if name == "" {
+			continue
+		}
+		e := Entry{
+			List:         list,
+			UID:          strings.TrimSpace(x.UID),
+			Name:         name,
+			DatesOfBirth: parseDates(x.DatesOfBirth),
+			Program:      strings.Join(x.Programs, ","),
+		}
+		for _, aka := range x.Akas {
+			if alias := joinName(aka.FirstName, aka.LastName); alias != "" {
+				e.Aliases = append(e.Aliases, alias)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func joinName(first, last string) string {
+	return strings.TrimSpace(strings.TrimSpace(first) + " " + strings.TrimSpace(last))
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// dateLayouts are the date of birth formats found in lists, most precise
+// first, paired with the precision we keep.
+var dateLayouts = []struct {
+	layout string
+	keep   string
+}{
+	{"2006-01-02", "2006-01-02"},
+	{"02 Jan 2006", "2006-01-02"},
+	{"2 Jan 2006", "2006-01-02"},
+	{"2006-01", "2006-01"},
+	{"Jan 2006", "2006-01"},
+	{"2006", "2006"},
+}
+
+// parseDates normalizes dates of birth to 2006-01-02, 2006-01 or 2006,
+// dropping any it can't read.
+func parseDates(values []string) []string {
+	var out []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		for _, d := range dateLayouts {
+			if t, err := time.Parse(d.layout, v); err == nil {
+				out = append(out, t.Format(d.keep))
+				break
+			}
+		}
+	}
+	return out
+}
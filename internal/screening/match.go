@@ -0,0 +1,157 @@
+package screening
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Date of birth adjustments to a name score. A listed date that agrees with
+// the user's makes a match likelier; one that contradicts it, much less so.
+const (
+	dobMatchBonus   = 0.05
+	dobMismatchCost = 0.15
+)
+
+// minTokenAgreement is the similarity below which two name tokens count as
+// unrelated rather than partly alike.
+const minTokenAgreement = 0.5
+
+// accents folds the accented Latin letters common in names to plain ASCII.
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "ā", "a",
+	"ç", "c", "č", "c", "ć", "c",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "ē", "e", "ė", "e", "ę", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i", "ī", "i",
+	"ñ", "n", "ń", "n",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o", "ō", "o",
+	"ś", "s", "š", "s", "ş", "s", "ß", "ss",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ū", "u",
+	"ý", "y", "ÿ", "y",
+	"ž", "z", "ź", "z", "ż", "z",
+	"đ", "d", "ł", "l",
+)
+
+// NormalizeName lowercases a name, folds accents and splits it into tokens
+// on anything that isn't a letter or digit, so "SMITH, John-Paul" becomes
+// [smith john paul].
+func NormalizeName(name string) []string {
+	name = accents.Replace(strings.ToLower(name))
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// nameScore compares two tokenized names from 0 to 1. Word order doesn't
+// matter, and every token on both sides has to find a close counterpart for
+// a high score, so a lone first name doesn't match a full listed name.
+func nameScore(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	whole := jaroWinkler(strings.Join(sortedA, " "), strings.Join(sortedB, " "))
+
+	tokens := (bestTokenMatches(a, b) + bestTokenMatches(b, a)) / 2
+	if tokens > whole {
+		return tokens
+	}
+	return whole
+}
+
+// bestTokenMatches averages, over the tokens of a, the closest match each
+// finds in b.
+func bestTokenMatches(a, b []string) float64 {
+	total := 0.0
+	for _, x := range a {
+		best := 0.0
+		for _, y := range b {
+			if s := jaroWinkler(x, y); s > best {
+				best = s
+			}
+		}
+		if best < minTokenAgreement {
+			best = 0
+		}
+		total += best
+	}
+	return total / float64(len(a))
+}
+
+// dobAdjustment scores a user's date of birth against an entry's. Listed
+// dates may only give a year or month, so they match by prefix. It returns 0
+// when either side has no date.
+func dobAdjustment(dob string, listed []string) (float64, bool) {
+	if dob == "" || len(listed) == 0 {
+		return 0, false
+	}
+	for _, d := range listed {
+		if strings.HasPrefix(dob, d) {
+			return dobMatchBonus, true
+		}
+	}
+	return -dobMismatchCost, false
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of two strings, from 0 to
+// 1.
+func jaroWinkler(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	window := max(len(ra), len(rb))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		lo, hi := max(0, i-window), min(len(rb), i+window+1)
+		for j := lo; j < hi; j++ {
+			if matchedB[j] || ra[i] != rb[j] {
+				continue
+			}
+			matchedA[i], matchedB[j] = true, true
+			matches++
+			break
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
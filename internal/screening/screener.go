@@ -0,0 +1,273 @@
+package screening
+
+import (
+	"cashapp/core"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type HitStatus string
+
+const (
+	HitPending   HitStatus = "pending"
+	HitConfirmed HitStatus = "confirmed" // a reviewer found it is the listed party
+	HitDismissed HitStatus = "dismissed" // a reviewer found it is someone else
+)
+
+// Triggers record why a user was screened.
+const (
+	TriggerOnboarding = "onboarding"
+	TriggerProfile    = "profile"
+	TriggerKYC        = "kyc"
+	TriggerRescreen   = "rescreen"
+)
+
+// Hit is a possible match between a user and a list entry, waiting for or
+// carrying a reviewer's decision. A user is only ever queued once per entry.
+type Hit struct {
+	core.Model
+	UserID      int        `json:"user_id" gorm:"uniqueIndex:idx_screening_hits_user_entry"`
+	List        string     `json:"list" gorm:"uniqueIndex:idx_screening_hits_user_entry"`
+	EntryUID    string     `json:"entry_uid" gorm:"uniqueIndex:idx_screening_hits_user_entry"`
+	EntryName   string     `json:"entry_name"`
+	MatchedName string     `json:"matched_name"` // the user's name that matched
+	ListedName  string     `json:"listed_name"`  // the entry name or alias it matched
+	Score       float64    `json:"score"`
+	DOBMatched  bool       `json:"dob_matched"`
+	Program     string     `json:"program,omitempty"`
+	Trigger     string     `json:"trigger"`
+	ListVersion string     `json:"list_version"`
+	Status      HitStatus  `json:"status" gorm:"index"`
+	ReviewNote  string     `json:"review_note,omitempty"`
+	ReviewedBy  string     `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// ListVersion records that every user was screened against a version of the
+// watchlists.
+type ListVersion struct {
+	core.Model
+	Version    string    `json:"version" gorm:"uniqueIndex"`
+	Entries    int       `json:"entries"`
+	ScreenedAt time.Time `json:"screened_at"`
+}
+
+// Subject is a user to screen: every name they go by and, if known, their
+// date of birth as 2006-01-02.
+type Subject struct {
+	UserID      int
+	Names       []string
+	DateOfBirth string
+}
+
+// Screener matches users against watchlists loaded from local files. Hits
+// are queued for review rather than acted on.
+type Screener struct {
+	db        *gorm.DB
+	paths     []string
+	threshold float64
+
+	mu   sync.RWMutex
+	list *Watchlist
+}
+
+func New(db *gorm.DB, paths []string, threshold float64) (*Screener, error) {
+	s := &Screener{
+		db:        db,
+		paths:     paths,
+		threshold: threshold,
+		list:      &Watchlist{},
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload rereads the watchlist files and reports whether their content
+// changed. The previous lists stay in use if reading fails.
+func (s *Screener) Reload() (bool, error) {
+	list, err := Load(s.paths)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := list.Version != s.list.Version
+	s.list = list
+	return changed, nil
+}
+
+func (s *Screener) current() *Watchlist {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list
+}
+
+// Match returns the best match per entry scoring at or above the threshold,
+// strongest first.
+func (s *Screener) Match(subject Subject) []Hit {
+	list := s.current()
+
+	var names [][]string
+	var raw []string
+	for _, n := range subject.Names {
+		if tokens := NormalizeName(n); len(tokens) > 0 {
+			names = append(names, tokens)
+			raw = append(raw, n)
+		}
+	}
+
+	var hits []Hit
+	for _, e := range list.Entries {
+		best := Hit{}
+		for i, name := range names {
+			for j, listed := range e.tokens {
+				score := nameScore(name, listed)
+				if score <= best.Score {
+					continue
+				}
+				best.Score = score
+				best.MatchedName = raw[i]
+				best.ListedName = e.Name
+				if j > 0 {
+					best.ListedName = e.Aliases[j-1]
+				}
+			}
+		}
+		if best.Score == 0 {
+			continue
+		}
+
+		adjust, dobMatched := dobAdjustment(subject.DateOfBirth, e.DatesOfBirth)
+		best.Score = min(1, best.Score+adjust)
+		if best.Score < s.threshold {
+			continue
+		}
+
+		best.UserID = subject.UserID
+		best.List = e.List
+		best.EntryUID = e.UID
+		best.EntryName = e.Name
+		best.DOBMatched = dobMatched
+		best.Program = e.Program
+		best.ListVersion = list.Version
+		best.Status = HitPending
+		hits = append(hits, best)
+	}
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	return hits
+}
+
+// Screen matches a user and queues any hit not already queued for them.
+// It returns the matches found this time.
+func (s *Screener) Screen(subject Subject, trigger string) ([]Hit, error) {
+	hits := s.Match(subject)
+	for i := range hits {
+		hits[i].Trigger = trigger
+		err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&hits[i]).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hits, nil
+}
+
+// PendingHits counts a user's hits still waiting for review.
+func (s *Screener) PendingHits(userID int) (int64, error) {
+	return s.countHits(userID, HitPending)
+}
+
+// ConfirmedHits counts a user's hits a reviewer found to be the listed
+// party.
+func (s *Screener) ConfirmedHits(userID int) (int64, error) {
+	return s.countHits(userID, HitConfirmed)
+}
+
+func (s *Screener) countHits(userID int, status HitStatus) (int64, error) {
+	var count int64
+	err := s.db.Model(&Hit{}).Where("user_id = ? AND status = ?", userID, status).Count(&count).Error
+	return count, err
+}
+
+// ListHits returns hits newest first, optionally only those in status.
+func (s *Screener) ListHits(status HitStatus, beforeID, limit int) ([]Hit, error) {
+	var hits []Hit
+	q := s.db.Model(&Hit{})
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	err := q.Order("id desc").Limit(limit).Find(&hits).Error
+	return hits, err
+}
+
+func (s *Screener) FindHit(id int) (*Hit, error) {
+	var hit Hit
+	err := s.db.First(&hit, id).Error
+	return &hit, err
+}
+
+// Resolve records a reviewer's decision on a pending hit, reporting whether
+// it was still pending.
+func (s *Screener) Resolve(hit *Hit, status HitStatus, note, reviewer string) (bool, error) {
+	now := time.Now()
+	res := s.db.Model(&Hit{}).
+		Where("id = ? AND status = ?", hit.ID, HitPending).
+		Updates(map[string]interface{}{"status": status, "review_note": note, "reviewed_by": reviewer, "reviewed_at": now})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	hit.Status = status
+	hit.ReviewNote = note
+	hit.ReviewedBy = reviewer
+	hit.ReviewedAt = &now
+	return true, nil
+}
+
+// NeedsRescreen reports whether the loaded lists hold entries every user has
+// not yet been screened against.
+func (s *Screener) NeedsRescreen() (bool, error) {
+	list := s.current()
+	if len(list.Entries) == 0 {
+		return false, nil
+	}
+
+	var count int64
+	err := s.db.Model(&ListVersion{}).Where("version = ?", list.Version).Count(&count).Error
+	return count == 0, err
+}
+
+// MarkScreened records that every user was screened against the loaded
+// lists.
+func (s *Screener) MarkScreened() error {
+	list := s.current()
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "version"}},
+		DoUpdates: clause.AssignmentColumns([]string{"screened_at", "updated_at"}),
+	}).Create(&ListVersion{
+		Version:    list.Version,
+		Entries:    len(list.Entries),
+		ScreenedAt: time.Now(),
+	}).Error
+}
+
+// SplitPaths reads a comma-separated SCREENING_LISTS value.
+func SplitPaths(v string) []string {
+	var paths []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,282 @@
+package screening
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"SMITH, John-Paul", []string{"smith", "john", "paul"}},
+		{"  Kwame   Nkrumah ", []string{"kwame", "nkrumah"}},
+		{"José Muñoz", []string{"jose", "munoz"}},
+		{"Łukasz Wałęsa", []string{"lukasz", "walesa"}},
+		{"Strauß", []string{"strauss"}},
+		{"Al-Qaida 2", []string{"al", "qaida", "2"}},
+		{"--", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeName(tt.name); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"martha", "martha", 1},
+		{"martha", "marhta", 0.961},
+		{"dwayne", "duane", 0.84},
+		{"dixon", "dicksonx", 0.813},
+		{"abc", "xyz", 0},
+		{"", "abc", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := jaroWinkler(tt.a, tt.b); math.Abs(got-tt.want) > 0.001 {
+				t.Fatalf("jaroWinkler(%q, %q) = %.3f, want %.3f", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		atLeast float64
+		below   float64
+	}{
+		{"same name", "John Smith", "John Smith", 1, 1.01},
+		{"word order", "Smith John", "John Smith", 1, 1.01},
+		{"misspelt", "Jon Smyth", "John Smith", 0.9, 1},
+		{"first name only", "John", "John Smith", 0, 0.9},
+		{"unrelated", "Ama Mensah", "John Smith", 0, 0.6},
+		{"nothing to compare", "", "John Smith", 0, 0.01},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nameScore(NormalizeName(tt.a), NormalizeName(tt.b))
+			if got < tt.atLeast || got >= tt.below {
+				t.Fatalf("nameScore(%q, %q) = %.3f, want in [%.2f, %.2f)", tt.a, tt.b, got, tt.atLeast, tt.below)
+			}
+		})
+	}
+}
+
+func TestDOBAdjustment(t *testing.T) {
+	tests := []struct {
+		name        string
+		dob         string
+		listed      []string
+		want        float64
+		wantMatched bool
+	}{
+		{"exact", "1970-05-01", []string{"1970-05-01"}, dobMatchBonus, true},
+		{"year only", "1970-05-01", []string{"1970"}, dobMatchBonus, true},
+		{"one of several", "1970-05-01", []string{"1968", "1970-05"}, dobMatchBonus, true},
+		{"contradicts", "1970-05-01", []string{"1985-02-03"}, -dobMismatchCost, false},
+		{"user has no date", "", []string{"1970"}, 0, false},
+		{"entry has no date", "1970-05-01", nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, matched := dobAdjustment(tt.dob, tt.listed)
+			if got != tt.want || matched != tt.wantMatched {
+				t.Fatalf("dobAdjustment = %v, %v, want %v, %v", got, matched, tt.want, tt.wantMatched)
+			}
+		})
+	}
+}
+
+func TestParseDates(t *testing.T) {
+	got := parseDates([]string{"1970-05-01", "01 May 1970", "1 May 1970", "1970-05", "May 1970", "1970", "circa 1970", ""})
+	want := []string{"1970-05-01", "1970-05-01", "1970-05-01", "1970-05", "1970-05", "1970"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseDates = %q, want %q", got, want)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want []Entry
+	}{
+		{
+			"with a header",
+			"uid,name,aliases,dob,program\n" +
+				"7,John Smith,Johnny Smith; J. Smith,1970-05-01;1971,SDGT\n" +
+				",Ama Mensah,,,\n" +
+				"9,,,,\n",
+			[]Entry{
+				{List: "local", UID: "7", Name: "John Smith", Aliases: []string{"Johnny Smith", "J. Smith"}, DatesOfBirth: []string{"1970-05-01", "1971"}, Program: "SDGT"},
+				{List: "local", UID: "row-3", Name: "Ama Mensah"},
+			},
+		},
+		{
+			"OFAC sdn.csv",
+			`36,"AEROCARIBBEAN AIRLINES",-0-,"CUBA",-0-,-0-,-0-,-0-,-0-,-0-,-0-,"a.k.a. 'AERO-CARIBBEAN'."` + "\n" +
+				`173,"ALI, Hassan",individual,"SDGT",-0-,-0-,-0-,-0-,-0-,-0-,-0-,"DOB 01 May 1970; a.k.a. 'ALI, Hasan'; a.k.a. 'HASSAN ALI'."` + "\n" +
+				`174,-0-,individual` + "\n",
+			[]Entry{
+				{List: "local", UID: "36", Name: "AEROCARIBBEAN AIRLINES", Aliases: []string{"AERO-CARIBBEAN"}, Program: "CUBA"},
+				{List: "local", UID: "173", Name: "ALI, Hassan", Aliases: []string{"ALI, Hasan", "HASSAN ALI"}, DatesOfBirth: []string{"1970-05-01"}, Program: "SDGT"},
+			},
+		},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCSV("local", strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseCSV =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseXML(t *testing.T) {
+	raw := []byte(`<sdnList xmlns="http://tempuri.org/sdnList.xsd">
+  <sdnEntry>
+    <uid>173</uid>
+    <firstName>Hassan</firstName>
+    <lastName>ALI</lastName>
+    <programList><program>SDGT</program><program>IRAN</program></programList>
+    <akaList><aka><firstName>Hasan</firstName><lastName>ALI</lastName></aka></akaList>
+    <dateOfBirthList><dateOfBirthItem><dateOfBirth>01 May 1970</dateOfBirth></dateOfBirthItem></dateOfBirthList>
+  </sdnEntry>
+  <sdnEntry><uid>174</uid></sdnEntry>
+</sdnList>`)
+
+	got, err := parseXML("sdn", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{{
+		List:         "sdn",
+		UID:          "173",
+		Name:         "Hassan ALI",
+		Aliases:      []string{"Hasan ALI"},
+		DatesOfBirth: []string{"1970-05-01"},
+		Program:      "SDGT,IRAN",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseXML =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if _, err := parseXML("sdn", []byte("<sdnList>")); err == nil {
+		t.Fatal("parseXML accepted a truncated document")
+	}
+}
+
+func writeList(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	list := writeList(t, dir, "local.csv", "uid,name\n1,John Smith\n")
+
+	wl, err := Load([]string{list})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wl.Entries) != 1 || wl.Entries[0].List != "local" || wl.Version == "" {
+		t.Fatalf("Load = %+v", wl)
+	}
+
+	writeList(t, dir, "local.csv", "uid,name\n1,John Smith\n2,Ama Mensah\n")
+	changed, err := Load([]string{list})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Version == wl.Version {
+		t.Fatal("Version didn't change with the file's content")
+	}
+
+	if empty, err := Load(nil); err != nil || empty.Version != "" {
+		t.Fatalf("Load(nil) = %+v, %v", empty, err)
+	}
+	if _, err := Load([]string{writeList(t, dir, "list.txt", "John Smith")}); err == nil {
+		t.Fatal("Load accepted a .txt list")
+	}
+	if _, err := Load([]string{filepath.Join(dir, "missing.csv")}); err == nil {
+		t.Fatal("Load accepted a missing file")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	dir := t.TempDir()
+	list := writeList(t, dir, "local.csv",
+		"uid,name,aliases,dob\n"+
+			"1,John Smith,Johnny Smith,1970-05-01\n"+
+			"2,Kwame Mensah,,\n")
+	s, err := New(nil, []string{list}, 0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		subject     Subject
+		wantEntries []string
+		wantListed  string
+		wantDOB     bool
+	}{
+		{"exact name", Subject{UserID: 1, Names: []string{"John Smith"}}, []string{"1"}, "John Smith", false},
+		{"alias", Subject{UserID: 1, Names: []string{"Johnny Smith"}}, []string{"1"}, "Johnny Smith", false},
+		{"matching date of birth", Subject{UserID: 1, Names: []string{"John Smith"}, DateOfBirth: "1970-05-01"}, []string{"1"}, "John Smith", true},
+		{"contradicting date of birth", Subject{UserID: 1, Names: []string{"Jon Smyth"}, DateOfBirth: "1990-01-01"}, nil, "", false},
+		{"second name matches", Subject{UserID: 1, Names: []string{"Ama Owusu", "Mensah Kwame"}}, []string{"2"}, "Kwame Mensah", false},
+		{"first name only", Subject{UserID: 1, Names: []string{"John"}}, nil, "", false},
+		{"no names", Subject{UserID: 1}, nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := s.Match(tt.subject)
+			var entries []string
+			for _, h := range hits {
+				entries = append(entries, h.EntryUID)
+			}
+			if !reflect.DeepEqual(entries, tt.wantEntries) {
+				t.Fatalf("Match entries = %q, want %q", entries, tt.wantEntries)
+			}
+			if len(hits) == 0 {
+				return
+			}
+			h := hits[0]
+			if h.ListedName != tt.wantListed || h.DOBMatched != tt.wantDOB || h.Status != HitPending || h.UserID != tt.subject.UserID {
+				t.Fatalf("hit = %+v", h)
+			}
+		})
+	}
+}
+
+func TestSplitPaths(t *testing.T) {
+	got := SplitPaths(" lists/sdn.xml, ,lists/local.csv ")
+	want := []string{"lists/sdn.xml", "lists/local.csv"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SplitPaths = %q, want %q", got, want)
+	}
+}
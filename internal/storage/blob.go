@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"cashapp/core"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files. Keys are slash-separated paths such as
+// "documents/7/0c5f...". Files are small enough to hold in memory.
+type BlobStore interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) ([]byte, error)
+	// Delete removes a blob. Deleting one that doesn't exist is not an
+	// error.
+	Delete(key string) error
+}
+
+// New returns the store selected by STORAGE_BACKEND, local (the default) or
+// s3, with everything in it encrypted under STORAGE_KEY.
+func New(config *core.Config) (BlobStore, error) {
+	key, err := masterKey(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var backend BlobStore
+	switch config.STORAGE_BACKEND {
+	case "", "local":
+		backend, err = NewLocal(config.STORAGE_DIR)
+	case "s3":
+		backend, err = NewS3(config.S3_ENDPOINT, config.S3_REGION, config.S3_BUCKET, config.S3_ACCESS_KEY, config.S3_SECRET_KEY, config.S3_PATH_STYLE)
+	default:
+		err = fmt.Errorf("unknown storage backend %q", config.STORAGE_BACKEND)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewEncrypted(backend, key)
+}
+
+// NewKey returns a fresh key under prefix.
+func NewKey(prefix string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return strings.TrimSuffix(prefix, "/") + "/" + hex.EncodeToString(b)
+}
+
+// devKey stands in for STORAGE_KEY in development so a fresh checkout
+// works. Anything stored with it is as good as plaintext.
+var devKey = sha256.Sum256([]byte("cashapp development storage key"))
+
+// masterKey decodes STORAGE_KEY, 32 bytes in base64.
+func masterKey(config *core.Config) ([]byte, error) {
+	if config.STORAGE_KEY == "" {
+		if config.ENVIRONMENT != core.Development {
+			return nil, errors.New("STORAGE_KEY is required outside development")
+		}
+		core.Log.Warn("STORAGE_KEY not set, using the development key")
+		return devKey[:], nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(config.STORAGE_KEY)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("STORAGE_KEY must be 32 bytes, base64 encoded")
+	}
+	return key, nil
+}
+
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+var errInvalidKey = errors.New("invalid blob key")
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps blobs as files under a directory, for development and
+// single-instance deployments.
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) (*Local, error) {
+	if dir == "" {
+		dir = "uploads"
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir}, nil
+}
+
+// Put writes to a temporary file and renames it into place, so readers
+// never see half a blob.
+func (l *Local) Put(key string, data []byte, _ string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Get(key string) ([]byte, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (l *Local) Delete(key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", errInvalidKey
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
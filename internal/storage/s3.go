@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"cashapp/core/awsv4"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3 keeps blobs in an S3-compatible bucket: AWS S3, or MinIO in
+// development. Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	endpoint *url.URL
+	region   string
+	bucket   string
+	creds    awsv4.Credentials
+	// pathStyle addresses the bucket as endpoint/bucket/key rather than
+	// bucket.endpoint/key. MinIO needs it.
+	pathStyle bool
+}
+
+var s3Client = &http.Client{Timeout: 30 * time.Second}
+
+func NewS3(endpoint, region, bucket, accessKey, secretKey string, pathStyle bool) (*S3, error) {
+	if bucket == "" {
+		return nil, errors.New("S3_BUCKET is required for the s3 storage backend")
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", endpoint)
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3{endpoint: u, region: region, bucket: bucket, creds: awsv4.Credentials{AccessKey: accessKey, SecretKey: secretKey}, pathStyle: pathStyle}, nil
+}
+
+func (s *S3) Put(key string, data []byte, contentType string) error {
+	resp, err := s.do(http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp)
+}
+
+func (s *S3) Get(key string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if err := s3Error(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *S3) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return s3Error(resp)
+}
+
+func (s *S3) do(method, key string, body []byte, contentType string) (*http.Response, error) {
+	if !validKey(key) {
+		return nil, errInvalidKey
+	}
+
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path = "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = escapePath(u.Path)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	awsv4.Sign(req, body, s.creds, s.region, "s3", time.Now())
+	return s3Client.Do(req)
+}
+
+func s3Error(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return fmt.Errorf("s3 %s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// escapePath percent-encodes everything but unreserved characters and
+// slashes, as Signature Version 4 expects.
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
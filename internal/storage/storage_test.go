@@ -0,0 +1,360 @@
+package storage
+
+import (
+	"bytes"
+	"cashapp/core"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+func newTestEncrypted(t *testing.T, key []byte) (*Encrypted, *Local) {
+	t.Helper()
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEncrypted(local, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, local
+}
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"documents/7/0c5f", true},
+		{"file", true},
+		{"", false},
+		{"/documents/7", false},
+		{"documents//7", false},
+		{"documents/7/", false},
+		{"documents/../secrets", false},
+		{"./documents", false},
+		{`documents\7`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := validKey(tt.key); got != tt.want {
+				t.Fatalf("validKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"documents/7", "documents/7/"},
+		{"documents/7/", "documents/7/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			key := NewKey(tt.prefix)
+			if !strings.HasPrefix(key, tt.want) || len(key) != len(tt.want)+32 {
+				t.Fatalf("NewKey(%q) = %q", tt.prefix, key)
+			}
+			if !validKey(key) {
+				t.Fatalf("NewKey(%q) = %q, which isn't a valid key", tt.prefix, key)
+			}
+		})
+	}
+	if NewKey("documents") == NewKey("documents") {
+		t.Fatal("NewKey returned the same key twice")
+	}
+}
+
+func TestMasterKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  core.Config
+		wantErr bool
+	}{
+		{"32 bytes", core.Config{ENVIRONMENT: core.Production, STORAGE_KEY: base64.StdEncoding.EncodeToString(testKey)}, false},
+		{"missing outside development", core.Config{ENVIRONMENT: core.Production}, true},
+		{"too short", core.Config{ENVIRONMENT: core.Production, STORAGE_KEY: base64.StdEncoding.EncodeToString(testKey[:16])}, true},
+		{"not base64", core.Config{ENVIRONMENT: core.Production, STORAGE_KEY: "not base64!"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := masterKey(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("masterKey error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(key, testKey) {
+				t.Fatalf("masterKey = %x, want %x", key, testKey)
+			}
+		})
+	}
+}
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	local, err := NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := local.Put("documents/7/a", []byte("hello"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := local.Get("documents/7/a"); err != nil || string(got) != "hello" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if err := local.Put("documents/7/a", []byte("replaced"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := local.Get("documents/7/a"); err != nil || string(got) != "replaced" {
+		t.Fatalf("Get after overwrite = %q, %v", got, err)
+	}
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(filepath.Join(dir, "documents", "7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("directory holds %d entries, want 1", len(entries))
+	}
+
+	if err := local.Delete("documents/7/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := local.Get("documents/7/a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := local.Delete("documents/7/a"); err != nil {
+		t.Fatalf("Delete of a missing blob = %v", err)
+	}
+
+	if err := local.Put("../escape", []byte("x"), ""); !errors.Is(err, errInvalidKey) {
+		t.Fatalf("Put outside the directory = %v, want errInvalidKey", err)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	e, local := newTestEncrypted(t, testKey)
+	other, _ := newTestEncrypted(t, bytes.Repeat([]byte{8}, 32))
+	other.store = local // same blobs, different key
+
+	if err := e.Put("documents/7/a", []byte("passport scan"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := local.Get("documents/7/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("passport scan")) {
+		t.Fatal("the store holds the plaintext")
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		store   *Encrypted
+		key     string
+		setup   func() error
+		want    string
+		wantErr bool
+	}{
+		{"round trip", e, "documents/7/a", nil, "passport scan", false},
+		{"wrong key", other, "documents/7/a", nil, "", true},
+		{"copied under another key", e, "documents/7/b", func() error { return local.Put("documents/7/b", sealed, "") }, "", true},
+		{"tampered", e, "documents/7/c", func() error { return local.Put("documents/7/c", tampered, "") }, "", true},
+		{"not encrypted", e, "documents/7/d", func() error { return local.Put("documents/7/d", []byte("plain file"), "") }, "", true},
+		{"too short", e, "documents/7/e", func() error { return local.Put("documents/7/e", sealedMagic, "") }, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				if err := tt.setup(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := tt.store.Get(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Get = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := e.Get("documents/7/missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of a missing blob = %v, want ErrNotFound", err)
+	}
+	if _, err := NewEncrypted(local, testKey[:10]); err == nil {
+		t.Fatal("NewEncrypted accepted a 10 byte key")
+	}
+}
+
+func TestLinkSigner(t *testing.T) {
+	config := &core.Config{ENVIRONMENT: core.Production, STORAGE_KEY: base64.StdEncoding.EncodeToString(testKey)}
+	signer, err := NewLinkSigner(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner := &LinkSigner{key: []byte("other")}
+
+	path := "/documents/7/download"
+	later := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	earlier := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		path      string
+		expires   string
+		signature string
+		want      error
+		wantErr   bool
+	}{
+		{"valid", path, later, signer.signature(path, later), nil, false},
+		{"expired", path, earlier, signer.signature(path, earlier), ErrLinkExpired, true},
+		{"other document", "/documents/8/download", later, signer.signature(path, later), nil, true},
+		{"expiry pushed back", path, later, signer.signature(path, earlier), nil, true},
+		{"other key", path, later, otherSigner.signature(path, later), nil, true},
+		{"not hex", path, later, "zz", nil, true},
+		{"missing", path, "", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := signer.Verify(tt.path, tt.expires, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	link := signer.Sign(path, time.Now().Add(time.Hour))
+	if !strings.HasPrefix(link, path+"?") || !strings.Contains(link, "expires=") || !strings.Contains(link, "signature=") {
+		t.Fatalf("Sign = %q", link)
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/bucket/documents/7/0c5f", "/bucket/documents/7/0c5f"},
+		{"/bucket/a b", "/bucket/a%20b"},
+		{"/bucket/a+b=c", "/bucket/a%2Bb%3Dc"},
+		{"/bucket/~user_1.-", "/bucket/~user_1.-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := escapePath(tt.path); got != tt.want {
+				t.Fatalf("escapePath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeS3 serves path-style object requests from memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		http.Error(w, "unsigned", http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s3, err := NewS3(server.URL, "", "uploads", "access", "secret", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s3.Put("documents/7/a", []byte("hello"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects["/uploads/documents/7/a"]; !ok {
+		t.Fatalf("objects = %v, want the blob under the bucket", fake.objects)
+	}
+	if got, err := s3.Get("documents/7/a"); err != nil || string(got) != "hello" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if err := s3.Delete("documents/7/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s3.Get("documents/7/a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if _, err := s3.Get("../a"); !errors.Is(err, errInvalidKey) {
+		t.Fatalf("Get of an invalid key = %v, want errInvalidKey", err)
+	}
+}
+
+func TestNewS3(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		region   string
+		bucket   string
+		wantHost string
+		wantErr  bool
+	}{
+		{"aws", "", "eu-west-1", "uploads", "s3.eu-west-1.amazonaws.com", false},
+		{"custom endpoint", "http://minio:9000/", "", "uploads", "minio:9000", false},
+		{"no bucket", "http://minio:9000", "", "", "", true},
+		{"bad endpoint", "minio", "", "uploads", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3, err := NewS3(tt.endpoint, tt.region, tt.bucket, "access", "secret", false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewS3 error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && s3.endpoint.Host != tt.wantHost {
+				t.Fatalf("host = %q, want %q", s3.endpoint.Host, tt.wantHost)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"cashapp/core"
+	"cashapp/internal/user/service"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is room in the request body for the multipart framing
+// around an upload of the largest allowed size.
+const multipartOverhead = 64 << 10
+
+// RegisterDocumentRoutes registers document uploads and the signed links
+// reviewers download them through.
+func RegisterDocumentRoutes(e *gin.Engine, s *service.UserService) {
+	// UploadDocument stores a JPEG, PNG or PDF in the "file" form field for
+	// the caller to submit to /verification/session
+	// @Router /documents [post]
+	e.POST("/documents", Authenticate(s), func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.MaxUploadBytes()+multipartOverhead)
+
+		header, err := c.FormFile("file")
+		if err != nil {
+			core.Respond(c, core.Error(core.Validation(err), core.String("file is required and must be at most "+strconv.FormatInt(s.MaxUploadBytes()>>20, 10)+" MB")))
+			return
+		}
+		f, err := header.Open()
+		if err != nil {
+			core.Respond(c, core.Error(core.Validation(err), core.String("failed to read upload")))
+			return
+		}
+		defer f.Close()
+
+		core.Respond(c, s.UploadDocument(currentUser(c), header.Filename, f))
+	})
+
+	// DownloadDocument serves a document to whoever holds a link signed by
+	// POST /kyc/documents/:id/link; the signature is the authorization
+	// @Router /documents/files/:id [get]
+	e.GET("/documents/files/:id", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+		if c.Query("signature") == "" {
+			core.Respond(c, core.Error(core.Unauthorized(errors.New("missing signature")), core.String("this link is not signed")))
+			return
+		}
+
+		upload, data, resp := s.OpenUpload(id, c.Query("expires"), c.Query("signature"))
+		if resp != nil {
+			core.Respond(c, *resp)
+			return
+		}
+
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": upload.Filename}))
+		c.Header("Cache-Control", "no-store")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Data(http.StatusOK, upload.ContentType, data)
+	})
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"cashapp/core"
+	"cashapp/internal/user/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterKYCRoutes registers the staff routes for the KYC document review
+// queue.
+func RegisterKYCRoutes(e *gin.Engine, s *service.UserService, config *core.Config) {
+	staff := e.Group("/kyc", core.RequireStaff(config))
+
+	// ListKYCReviews lists documents waiting for a reviewer
+	// @Router /kyc/reviews [get]
+	staff.GET("/reviews", func(c *gin.Context) {
+		var q core.KYCReviewQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, s.ListKYCReviews(q))
+	})
+
+	// ReviewDocument approves or rejects a document
+	// @Router /kyc/documents/:id/review [post]
+	staff.POST("/documents/:id/review", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		reviewer, ok := staffMember(c)
+		if !ok {
+			return
+		}
+
+		var req core.KYCDocumentDecision
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.ReviewDocument(id, reviewer, req))
+	})
+
+	// DocumentLink returns a short-lived link to download a document's file
+	// @Router /kyc/documents/:id/link [post]
+	staff.POST("/documents/:id/link", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.DocumentLink(id))
+	})
+}
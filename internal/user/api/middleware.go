@@ -1,13 +1,43 @@
 package api
 
 import (
+	"cashapp/core"
 	"cashapp/internal/user/models"
 	"cashapp/internal/user/service"
-	"net/http"
+	"errors"
 
 	"github.com/gin-gonic/gin"
 )
 
+const currentUserKey = "current_user"
+
+// Authenticate resolves the calling user and stores them on the context.
+func Authenticate(s *service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// In a real system, the user ID would come from the JWT auth middleware
+		// For this example, we'll look for a "X-User-Tag" header for simulation
+		user, err := s.Authenticate(c.GetHeader("X-User-Tag"))
+		if err != nil {
+			core.Abort(c, core.Error(err, nil))
+			return
+		}
+
+		c.Set(currentUserKey, user)
+		c.Next()
+	}
+}
+
+// currentUser returns the user resolved by Authenticate.
+func currentUser(c *gin.Context) *models.User {
+	return c.MustGet(currentUserKey).(*models.User)
+}
+
+// CurrentUserID returns the ID of the user resolved by Authenticate, for
+// routes mounted from other packages.
+func CurrentUserID(c *gin.Context) int {
+	return currentUser(c).ID
+}
+
 // RequireKYC ensures the user has a sufficient KYC level
 func RequireKYC(minLevel int, s *service.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -15,13 +45,13 @@ func RequireKYC(minLevel int, s *service.UserService) gin.HandlerFunc {
 		// For this example, we'll look for a "X-User-Tag" header for simulation
 		userTag := c.GetHeader("X-User-Tag")
 		if userTag == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing X-User-Tag header"})
+			core.Abort(c, core.Error(core.Unauthorized(errors.New("missing X-User-Tag header")), core.String("Unauthorized: missing X-User-Tag header")))
 			return
 		}
 
 		response := s.GetUser(userTag)
 		if response.Error || response.Meta.Data == nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: invalid user"})
+			core.Abort(c, core.Error(core.Unauthorized(errors.New("unknown user tag")), core.String("Unauthorized: invalid user")))
 			return
 		}
 
@@ -30,12 +60,16 @@ func RequireKYC(minLevel int, s *service.UserService) gin.HandlerFunc {
 		userObj := (*data)["user"].(*models.User)
 
 		if userObj.KYCLevel < minLevel {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-				"error":      "insufficient KYC level",
-				"required":   minLevel,
-				"current":    userObj.KYCLevel,
-				"kyc_status": userObj.KYCStatus,
-			})
+			err := &core.AppError{
+				Code: core.ErrCodeForbidden,
+				Err:  errors.New("insufficient KYC level"),
+				Details: gin.H{
+					"required":   minLevel,
+					"current":    userObj.KYCLevel,
+					"kyc_status": userObj.KYCStatus,
+				},
+			}
+			core.Abort(c, core.Error(err, core.String("insufficient KYC level")))
 			return
 		}
 
@@ -3,89 +3,158 @@ package api
 import (
 	"cashapp/core"
 	"cashapp/internal/user/service"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterUserRoutes(e *gin.Engine, s *service.UserService) {
+func RegisterUserRoutes(e *gin.Engine, s *service.UserService, limiter *core.RateLimiter) {
 	// CreateUser creates a new user account
 	// @Router /users [post]
 	e.POST("/users", func(c *gin.Context) {
 		var req core.CreateUserRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": err.Error(),
-			})
+		if !core.BindJSON(c, &req) {
 			return
 		}
 
-		response := s.CreateUser(req)
-		if response.Error {
-			c.JSON(response.Code, gin.H{
-				"message": response.Meta.Message,
-			})
+		core.Respond(c, s.CreateUser(req))
+	})
+
+	// SearchUsers looks people up by cash tag or display name. Rate limited
+	// per caller so the directory can't be enumerated.
+	// @Router /users/search [get]
+	e.GET("/users/search", Authenticate(s), limiter.Limit("user-search", 30, time.Minute, func(c *gin.Context) string {
+		return strconv.Itoa(currentUser(c).ID)
+	}), func(c *gin.Context) {
+		var q core.UserSearchQuery
+		if !core.BindQuery(c, &q) {
 			return
 		}
-		c.JSON(response.Code, response.Meta)
+
+		core.Respond(c, s.SearchUsers(currentUser(c), q))
 	})
 
-	// GetUser retrieves a user by tag
-	// @Router /users/:tag [get]
-	e.GET("/users/:tag", func(c *gin.Context) {
-		tag := c.Param("tag")
-		response := s.GetUser(tag)
-		if response.Error {
-			c.JSON(response.Code, gin.H{
-				"message": response.Meta.Message,
-			})
+	// GetProfile returns the caller's profile and verified contacts
+	// @Router /users/me [get]
+	e.GET("/users/me", Authenticate(s), func(c *gin.Context) {
+		core.Respond(c, s.GetProfile(currentUser(c)))
+	})
+
+	// UpdateProfile changes the caller's name and avatar
+	// @Router /users/me/profile [put]
+	e.PUT("/users/me/profile", Authenticate(s), func(c *gin.Context) {
+		var req core.UpdateProfileRequest
+		if !core.BindJSON(c, &req) {
 			return
 		}
-		c.JSON(response.Code, response.Meta)
+
+		core.Respond(c, s.UpdateProfile(currentUser(c), req))
 	})
 
-	// InitVerification starts the identity verification process
-	// @Router /verification/session [post]
-	e.POST("/verification/session", func(c *gin.Context) {
-		var req core.VerifyIdentityRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": err.Error(),
-			})
+	// StartContactVerification sends a one-time code to an email or phone
+	// @Router /users/me/verifications [post]
+	e.POST("/users/me/verifications", Authenticate(s), limiter.Limit("contact-verification", 5, time.Hour, func(c *gin.Context) string {
+		return strconv.Itoa(currentUser(c).ID)
+	}), func(c *gin.Context) {
+		var req core.StartVerificationRequest
+		if !core.BindJSON(c, &req) {
 			return
 		}
 
-		response := s.InitVerification(req)
-		if response.Error {
-			c.JSON(response.Code, gin.H{
-				"message": response.Meta.Message,
-			})
+		core.Respond(c, s.StartContactVerification(currentUser(c), req))
+	})
+
+	// ConfirmContactVerification checks a one-time code
+	// @Router /users/me/verifications/confirm [post]
+	e.POST("/users/me/verifications/confirm", Authenticate(s), func(c *gin.Context) {
+		var req core.ConfirmVerificationRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.ConfirmContactVerification(currentUser(c), req))
+	})
+
+	// LookupContacts finds users by verified email or phone number
+	// @Router /users/lookup [post]
+	e.POST("/users/lookup", Authenticate(s), limiter.Limit("contact-lookup", 10, time.Minute, func(c *gin.Context) string {
+		return strconv.Itoa(currentUser(c).ID)
+	}), func(c *gin.Context) {
+		var req core.ContactLookupRequest
+		if !core.BindJSON(c, &req) {
 			return
 		}
-		c.JSON(response.Code, response.Meta)
+
+		core.Respond(c, s.LookupContacts(currentUser(c), req))
 	})
 
-	// Webhook for identity provider
-	// @Router /webhooks/identity [post]
-	e.POST("/webhooks/identity", func(c *gin.Context) {
-		var req core.IdentityWebhookRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": err.Error(),
-			})
+	// ChangeTag moves the caller to a new cash tag
+	// @Router /users/me/tag [put]
+	e.PUT("/users/me/tag", Authenticate(s), func(c *gin.Context) {
+		var req core.ChangeTagRequest
+		if !core.BindJSON(c, &req) {
 			return
 		}
 
-		// In real world, verify signature here
+		core.Respond(c, s.ChangeTag(currentUser(c), req))
+	})
+
+	// ListTagHistory lists the caller's previous cash tags
+	// @Router /users/me/tag-history [get]
+	e.GET("/users/me/tag-history", Authenticate(s), func(c *gin.Context) {
+		core.Respond(c, s.ListTagHistory(currentUser(c)))
+	})
 
-		response := s.HandleIdentityWebhook(req)
-		if response.Error {
-			c.JSON(response.Code, gin.H{
-				"message": response.Meta.Message,
-			})
+	// UpdateDirectorySettings changes the caller's display name and whether
+	// they appear in search
+	// @Router /users/me/directory [put]
+	e.PUT("/users/me/directory", Authenticate(s), func(c *gin.Context) {
+		var req core.DirectorySettingsRequest
+		if !core.BindJSON(c, &req) {
 			return
 		}
-		c.JSON(response.Code, response.Meta)
+
+		core.Respond(c, s.UpdateDirectorySettings(currentUser(c), req))
+	})
+
+	// GetUser retrieves a user by tag
+	// @Router /users/:tag [get]
+	e.GET("/users/:tag", func(c *gin.Context) {
+		tag := c.Param("tag")
+		core.Respond(c, s.GetUser(tag))
+	})
+
+	// UpdateDefaultPrivacy sets the caller's default payment visibility
+	// @Router /users/me/default-privacy [put]
+	e.PUT("/users/me/default-privacy", Authenticate(s), func(c *gin.Context) {
+		var req core.UpdatePrivacyRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.UpdateDefaultPrivacy(currentUser(c), req))
+	})
+
+	// GetKYCStatus shows the caller's KYC requirements and what's missing
+	// for the next level
+	// @Router /users/me/kyc [get]
+	e.GET("/users/me/kyc", Authenticate(s), func(c *gin.Context) {
+		core.Respond(c, s.GetKYCStatus(currentUser(c)))
+	})
+
+	// InitVerification submits one of the caller's uploads as an identity
+	// document
+	// @Router /verification/session [post]
+	e.POST("/verification/session", Authenticate(s), func(c *gin.Context) {
+		var req core.VerifyIdentityRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.InitVerification(currentUser(c), req))
 	})
 
 	// Example protected route: Request High Limits
@@ -101,50 +170,159 @@ func RegisterUserRoutes(e *gin.Engine, s *service.UserService) {
 	// @Router /wallets/funding-sources [post]
 	e.POST("/wallets/funding-sources", func(c *gin.Context) {
 		var req core.LinkFundingSourceRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		if !core.BindJSON(c, &req) {
 			return
 		}
 
-		response := s.LinkFundingSource(req)
-		if response.Error {
-			c.JSON(response.Code, gin.H{"message": response.Meta.Message})
-			return
-		}
-		c.JSON(response.Code, response.Meta)
+		core.Respond(c, s.LinkFundingSource(req))
 	})
 
 	// Deposit Funds
 	// @Router /wallets/deposit [post]
 	e.POST("/wallets/deposit", func(c *gin.Context) {
 		var req core.DepositRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		if !core.BindJSON(c, &req) {
 			return
 		}
 
-		response := s.Deposit(req)
-		if response.Error {
-			c.JSON(response.Code, gin.H{"message": response.Meta.Message})
+		core.Respond(c, s.Deposit(req))
+	})
+
+	// SendFriendRequest asks another user to be friends
+	// @Router /users/me/friend-requests [post]
+	e.POST("/users/me/friend-requests", Authenticate(s), func(c *gin.Context) {
+		var req core.FriendRequestDTO
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.SendFriendRequest(currentUser(c), req))
+	})
+
+	// ListFriendRequests lists pending incoming and outgoing requests
+	// @Router /users/me/friend-requests [get]
+	e.GET("/users/me/friend-requests", Authenticate(s), func(c *gin.Context) {
+		var q core.FriendRequestQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, s.ListFriendRequests(currentUser(c), q))
+	})
+
+	// AcceptFriendRequest accepts an incoming request
+	// @Router /users/me/friend-requests/:id/accept [post]
+	e.POST("/users/me/friend-requests/:id/accept", Authenticate(s), func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.AcceptFriendRequest(currentUser(c), id))
+	})
+
+	// DeclineFriendRequest declines an incoming request
+	// @Router /users/me/friend-requests/:id/decline [post]
+	e.POST("/users/me/friend-requests/:id/decline", Authenticate(s), func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.DeclineFriendRequest(currentUser(c), id))
+	})
+
+	// CancelFriendRequest withdraws an outgoing request
+	// @Router /users/me/friend-requests/:id [delete]
+	e.DELETE("/users/me/friend-requests/:id", Authenticate(s), func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.CancelFriendRequest(currentUser(c), id))
+	})
+
+	// ListFriends lists accepted friends
+	// @Router /users/me/friends [get]
+	e.GET("/users/me/friends", Authenticate(s), func(c *gin.Context) {
+		core.Respond(c, s.ListFriends(currentUser(c)))
+	})
+
+	// Unfriend removes a friend
+	// @Router /users/me/friends/:id [delete]
+	e.DELETE("/users/me/friends/:id", Authenticate(s), func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
 			return
 		}
-		c.JSON(response.Code, response.Meta)
+
+		core.Respond(c, s.Unfriend(currentUser(c), id))
 	})
 
-	// Add Friend
-	// @Router /users/friends [post]
-	e.POST("/users/friends", func(c *gin.Context) {
-		var req core.CreateFriendshipRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	// ListFriendSuggestions ranks people the caller may know
+	// @Router /users/me/friend-suggestions [get]
+	e.GET("/users/me/friend-suggestions", Authenticate(s), func(c *gin.Context) {
+		var q core.SuggestionQuery
+		if !core.BindQuery(c, &q) {
 			return
 		}
 
-		response := s.AddFriend(req)
-		if response.Error {
-			c.JSON(response.Code, gin.H{"message": response.Meta.Message})
+		core.Respond(c, s.ListFriendSuggestions(currentUser(c), q))
+	})
+
+	// DismissFriendSuggestion hides a suggested user
+	// @Router /users/me/friend-suggestions/:id/dismiss [post]
+	e.POST("/users/me/friend-suggestions/:id/dismiss", Authenticate(s), func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
 			return
 		}
-		c.JSON(response.Code, response.Meta)
+
+		core.Respond(c, s.DismissFriendSuggestion(currentUser(c), id))
 	})
+
+	// BlockUser blocks another user
+	// @Router /users/me/blocks [post]
+	e.POST("/users/me/blocks", Authenticate(s), func(c *gin.Context) {
+		var req core.BlockUserRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.BlockUser(currentUser(c), req))
+	})
+
+	// UnblockUser lifts a block
+	// @Router /users/me/blocks/:id [delete]
+	e.DELETE("/users/me/blocks/:id", Authenticate(s), func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.UnblockUser(currentUser(c), id))
+	})
+}
+
+// intParam parses a numeric path parameter, responding with a validation
+// error when it isn't one.
+// staffMember returns the reviewer behind the staff key. Reviews are
+// attributed to them, so the shared key can't make one.
+func staffMember(c *gin.Context) (string, bool) {
+	name, ok := core.StaffMember(c)
+	if !ok {
+		core.Respond(c, core.Error(core.Forbidden(errors.New("shared staff key used for a review")), core.String("reviews need your own staff key")))
+		return "", false
+	}
+	return name, true
+}
+
+func intParam(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		core.Respond(c, core.Error(core.Validation(err), core.String("invalid "+name)))
+		return 0, false
+	}
+	return id, true
 }
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"cashapp/core"
+	"cashapp/internal/user/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterScreeningRoutes registers the staff routes for the sanctions
+// screening review queue.
+func RegisterScreeningRoutes(e *gin.Engine, s *service.UserService, config *core.Config) {
+	staff := e.Group("/screening", core.RequireStaff(config))
+
+	// ListScreeningHits lists possible watchlist matches
+	// @Router /screening/hits [get]
+	staff.GET("/hits", func(c *gin.Context) {
+		var q core.ScreeningHitQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, s.ListScreeningHits(q))
+	})
+
+	// ResolveScreeningHit confirms or dismisses a possible match
+	// @Router /screening/hits/:id [post]
+	staff.POST("/hits/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			core.Respond(c, core.Error(core.Validation(err), core.String("invalid hit id")))
+			return
+		}
+
+		reviewer, ok := staffMember(c)
+		if !ok {
+			return
+		}
+
+		var req core.ScreeningDecisionRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.ResolveScreeningHit(id, reviewer, req))
+	})
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"cashapp/core/webhooks"
+	"cashapp/internal/user/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWebhookRoutes registers the webhooks third parties call. The
+// receiver verifies, stores and deduplicates each delivery before the
+// service sees it.
+func RegisterWebhookRoutes(e *gin.Engine, s *service.UserService, receiver *webhooks.Receiver) {
+	// Webhook for identity provider
+	// @Router /webhooks/identity [post]
+	e.POST("/webhooks/identity", receiver.Register(s.IdentityWebhookSource(), s.HandleIdentityWebhook))
+}
@@ -2,7 +2,9 @@ package models
 
 import (
 	"cashapp/core"
+	"cashapp/core/fieldcrypt"
 	"errors"
+	"time"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -14,23 +16,158 @@ const (
 	KYCStatusPending  KYCStatus = "pending"
 	KYCStatusVerified KYCStatus = "verified"
 	KYCStatusRejected KYCStatus = "rejected"
+	// KYCStatusReview is a user whose identity check passed but who has
+	// screening hits waiting for review.
+	KYCStatusReview KYCStatus = "in_review"
 )
 
 type User struct {
 	core.Model
-	Tag            string    `json:"tag"`
-	Wallets        []Wallet  `json:"wallets"`
-	KYCLevel       int       `json:"kyc_level"` // 0: Unverified, 1: Basic, 2: Full
-	KYCStatus      KYCStatus `json:"kyc_status" gorm:"default:'pending'"`
-	RiskScore      int       `json:"risk_score"`
-	DefaultPrivacy string    `json:"default_privacy" gorm:"default:'public'"` // public, friends, private
+	Tag             string             `json:"tag"`
+	NormalizedTag   *string            `json:"-" gorm:"uniqueIndex"` // see core.NormalizeTag
+	TagChangedAt    *time.Time         `json:"tag_changed_at,omitempty"`
+	TagRenameNeeded bool               `json:"tag_rename_needed"` // see BackfillNormalizedTags
+	DisplayName     string             `json:"display_name"`
+	FullName        fieldcrypt.String  `json:"-"` // legal name; only the user sees it, see GetProfile
+	DateOfBirth     fieldcrypt.String  `json:"-"` // 2006-01-02; only the user sees it
+	AvatarURL       string             `json:"avatar_url"`
+	Email           *fieldcrypt.String `json:"-"` // verified only; see VerificationCode
+	EmailIndex      *string            `json:"-" gorm:"uniqueIndex"`
+	EmailVerifiedAt *time.Time         `json:"-"`
+	Phone           *fieldcrypt.String `json:"-"` // verified only, E.164
+	PhoneIndex      *string            `json:"-" gorm:"uniqueIndex"`
+	PhoneVerifiedAt *time.Time         `json:"-"`
+	Discoverable    bool               `json:"discoverable" gorm:"default:true"` // listed in directory search
+	Wallets         []Wallet           `json:"wallets"`
+	KYCLevel        int                `json:"kyc_level"` // 0: Unverified, 1: Basic, 2: Full
+	KYCStatus       KYCStatus          `json:"kyc_status" gorm:"default:'pending'"`
+	RiskScore       int                `json:"risk_score"`
+	DefaultPrivacy  string             `json:"default_privacy" gorm:"default:'public'"` // public, friends, private
 }
 
+// BeforeSave keeps the blind indexes on email and phone in step with them.
+func (u *User) BeforeSave(tx *gorm.DB) (err error) {
+	if u.EmailIndex, err = fieldcrypt.IndexOf("users.email", u.Email); err != nil {
+		return err
+	}
+	u.PhoneIndex, err = fieldcrypt.IndexOf("users.phone", u.Phone)
+	return err
+}
+
+type FriendshipStatus string
+
+const (
+	FriendshipPending  FriendshipStatus = "pending"
+	FriendshipAccepted FriendshipStatus = "accepted"
+	FriendshipBlocked  FriendshipStatus = "blocked"
+)
+
+// Friendship is a directed edge. A pending request is a single row from the
+// requester; an accepted friendship is a row in each direction; a block is a
+// single row from the blocker.
 type Friendship struct {
 	core.Model
-	UserID   int    `json:"user_id"`
-	FriendID int    `json:"friend_id"`
-	Status   string `json:"status"` // pending, accepted
+	UserID   int              `json:"user_id" gorm:"uniqueIndex:idx_friendships_pair"`
+	FriendID int              `json:"friend_id" gorm:"uniqueIndex:idx_friendships_pair"`
+	Status   FriendshipStatus `json:"status"` // pending, accepted, blocked
+}
+
+// FriendSuggestion holds the signals for suggesting CandidateID to UserID.
+// Counts are maintained incrementally as friendships and payments happen.
+type FriendSuggestion struct {
+	core.Model
+	UserID        int  `json:"user_id" gorm:"uniqueIndex:idx_friend_suggestions_pair"`
+	CandidateID   int  `json:"candidate_id" gorm:"uniqueIndex:idx_friend_suggestions_pair"`
+	MutualFriends int  `json:"mutual_friends"`
+	Transactions  int  `json:"transactions"`
+	Dismissed     bool `json:"dismissed"`
+}
+
+type ContactChannel string
+
+const (
+	ContactEmail ContactChannel = "email"
+	ContactPhone ContactChannel = "phone"
+)
+
+// VerificationCode is a one-time code sent to an email address or phone
+// number the user wants to add. Only a salted hash of the code is stored.
+type VerificationCode struct {
+	core.Model
+	UserID     int               `json:"user_id" gorm:"index"`
+	Channel    ContactChannel    `json:"channel"`
+	Target     fieldcrypt.String `json:"target"`
+	Salt       string            `json:"-"`
+	CodeHash   string            `json:"-"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	Attempts   int               `json:"attempts"`
+	ConsumedAt *time.Time        `json:"consumed_at,omitempty"`
+}
+
+// TagChange records a cash tag change. The old tag keeps resolving to the
+// user, and can't be claimed by anyone else, until HeldUntil.
+type TagChange struct {
+	core.Model
+	UserID           int       `json:"user_id" gorm:"index"`
+	OldTag           string    `json:"old_tag"`
+	NewTag           string    `json:"new_tag"`
+	NormalizedOldTag string    `json:"-" gorm:"index"`
+	HeldUntil        time.Time `json:"held_until"`
+}
+
+// BackfillNormalizedTags fills NormalizedTag for users created before tags
+// were normalized. When legacy tags collide after normalization the oldest
+// account keeps the tag. The rest get the tag with "-<id>" appended, which
+// no new tag can take since tags may not contain "-", and are flagged to
+// pick a new one.
+func BackfillNormalizedTags(db *gorm.DB) error {
+	err := db.Exec(`
+		UPDATE users u SET normalized_tag = lower(ltrim(u.tag, '$'))
+		WHERE u.normalized_tag IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM users o
+				WHERE o.id <> u.id
+					AND lower(ltrim(o.tag, '$')) = lower(ltrim(u.tag, '$'))
+					AND (o.normalized_tag IS NOT NULL OR o.id < u.id)
+			)`).Error
+	if err != nil {
+		return err
+	}
+
+	var conflicting []string
+	if err := db.Model(&User{}).Where("normalized_tag IS NULL").Pluck("tag", &conflicting).Error; err != nil {
+		return err
+	}
+	if len(conflicting) == 0 {
+		return nil
+	}
+
+	err = db.Exec(`
+		UPDATE users SET
+			tag = ltrim(tag, '$') || '-' || id,
+			normalized_tag = lower(ltrim(tag, '$')) || '-' || id,
+			tag_rename_needed = true
+		WHERE normalized_tag IS NULL`).Error
+	if err != nil {
+		return err
+	}
+	core.Log.Warn("cash tags collide after normalization; renamed and flagged for a new tag", zap.Strings("tags", conflicting))
+	return nil
+}
+
+// SyncState records how far a background job has read another table.
+type SyncState struct {
+	core.Model
+	Name   string `json:"name" gorm:"uniqueIndex"`
+	LastID int    `json:"last_id"`
+}
+
+// UnsettledTransfer is a ledger transfer a sync read past while it was still
+// pending or held. It is checked again until it settles.
+type UnsettledTransfer struct {
+	core.Model
+	TransactionID int       `json:"transaction_id" gorm:"uniqueIndex"`
+	CheckedAt     time.Time `json:"checked_at" gorm:"index"`
 }
 
 type Wallet struct {
@@ -44,18 +181,85 @@ type Wallet struct {
 
 type FundingSource struct {
 	core.Model
-	UserID     int    `json:"user_id"`
-	Type       string `json:"type"`        // card, bank_account
-	ProviderID string `json:"provider_id"` // stripe_pm_123
-	Last4      string `json:"last4"`
-	Brand      string `json:"brand"` // visa, mastercard
+	UserID     int               `json:"user_id"`
+	Type       string            `json:"type"`        // card, bank_account
+	ProviderID fieldcrypt.String `json:"provider_id"` // stripe_pm_123
+	Last4      string            `json:"last4"`
+	Brand      string            `json:"brand"` // visa, mastercard
+}
+
+// EncryptedTables lists the columns kept encrypted, for the rekey command.
+var EncryptedTables = []fieldcrypt.Table{
+	{Name: "users", Columns: []fieldcrypt.Column{
+		{Name: "full_name"},
+		{Name: "date_of_birth"},
+		{Name: "email", Index: "email_index"},
+		{Name: "phone", Index: "phone_index"},
+	}},
+	{Name: "verification_codes", Columns: []fieldcrypt.Column{{Name: "target"}}},
+	{Name: "funding_sources", Columns: []fieldcrypt.Column{{Name: "provider_id"}}},
+	{Name: "identity_documents", Columns: []fieldcrypt.Column{{Name: "provider_ref", Index: "provider_ref_index"}, {Name: "legacy_url"}}},
+}
+
+// FillIndexes fills in the blind indexes missing from rows written before
+// their columns were encrypted, so lookups by contact find them.
+func FillIndexes(db *gorm.DB) error {
+	for _, t := range EncryptedTables {
+		updated, err := fieldcrypt.FillIndexes(db, fieldcrypt.Default(), t, 500)
+		if err != nil {
+			return err
+		}
+		if updated > 0 {
+			core.Log.Info("filled in blind indexes", zap.String("table", t.Name), zap.Int("updated", updated))
+		}
+	}
+	return nil
+}
+
+// MigrateLegacyDocumentURLs moves the URLs documents were submitted with
+// before uploads, held in plaintext, into LegacyURL, encrypted, and drops
+// the old column.
+func MigrateLegacyDocumentURLs(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&IdentityDocument{}, "url") {
+		return nil
+	}
+
+	for lastID := 0; ; {
+		var rows []struct {
+			ID  int
+			URL string
+		}
+		err := db.Table("identity_documents").Select("id, url").
+			Where("id > ? AND url IS NOT NULL AND url <> ''", lastID).
+			Order("id").Limit(500).
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			err := db.Table("identity_documents").Where("id = ?", row.ID).
+				Updates(map[string]interface{}{"legacy_url": fieldcrypt.String(row.URL), "url": nil}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// Another instance may be migrating too.
+	return db.Exec("ALTER TABLE identity_documents DROP COLUMN IF EXISTS url").Error
 }
 
 func RunSeeds(db *gorm.DB) {
+	normalized := core.NormalizeTag("yaw")
 	user := User{
-		Tag:       "yaw",
-		KYCLevel:  1,
-		KYCStatus: KYCStatusVerified,
+		Tag:           "yaw",
+		NormalizedTag: &normalized,
+		KYCLevel:      1,
+		KYCStatus:     KYCStatusVerified,
 	}
 
 	if err := db.Model(&User{}).Where("tag=?", user.Tag).First(&user).Error; err != nil {
@@ -83,11 +287,112 @@ func RunSeeds(db *gorm.DB) {
 
 }
 
+type DocumentStatus string
+
+const (
+	DocumentPending  DocumentStatus = "pending"   // waiting on the identity provider
+	DocumentInReview DocumentStatus = "in_review" // waiting on a reviewer
+	DocumentVerified DocumentStatus = "verified"
+	DocumentRejected DocumentStatus = "rejected"
+)
+
 type IdentityDocument struct {
 	core.Model
-	UserID int    `json:"user_id"`
-	User   *User  `json:"user,omitempty"`
-	Type   string `json:"type"`   // passport, drivers_license
-	URL    string `json:"url"`    // S3 URL
-	Status string `json:"status"` // pending, verified, rejected
+	UserID           int               `json:"user_id" gorm:"index"`
+	User             *User             `json:"user,omitempty"`
+	Type             string            `json:"type"`   // see core.DocumentType*
+	Status           DocumentStatus    `json:"status"` // pending, in_review, verified, rejected
+	Provider         string            `json:"provider,omitempty"`
+	ProviderRef      fieldcrypt.String `json:"provider_ref,omitempty"` // the provider's session
+	ProviderRefIndex *string           `json:"-" gorm:"index"`
+	ProviderResult   string            `json:"provider_result,omitempty"` // passed, failed
+	ProviderReason   string            `json:"provider_reason,omitempty"` // the provider's code for a failure
+	RejectionReason  string            `json:"rejection_reason,omitempty"`
+	ReviewedBy       string            `json:"reviewed_by,omitempty"`
+	ReviewedAt       *time.Time        `json:"reviewed_at,omitempty"`
+	UploadID         *int              `json:"upload_id,omitempty" gorm:"uniqueIndex"` // the file the user uploaded
+	LegacyURL        fieldcrypt.String `json:"-"`                                      // where documents submitted before uploads were kept
+}
+
+// BeforeSave keeps the blind index on ProviderRef in step with it.
+func (d *IdentityDocument) BeforeSave(tx *gorm.DB) error {
+	d.ProviderRefIndex = nil
+	if d.ProviderRef == "" {
+		return nil
+	}
+	index, err := fieldcrypt.Index("identity_documents.provider_ref", string(d.ProviderRef))
+	if err != nil {
+		return err
+	}
+	d.ProviderRefIndex = &index
+	return nil
+}
+
+// Open reports whether a document still waits on the provider or a reviewer.
+func (d IdentityDocument) Open() bool {
+	return d.Status == DocumentPending || d.Status == DocumentInReview
+}
+
+// Upload is a file a user uploaded, kept encrypted in the blob store until
+// PurgeAfter. Until it is attached to a document it only lives a day.
+type Upload struct {
+	core.Model
+	UserID      int        `json:"-" gorm:"index"`
+	Key         string     `json:"-"` // in the blob store
+	Filename    string     `json:"filename"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	SHA256      string     `json:"sha256"`
+	DocumentID  *int       `json:"document_id,omitempty" gorm:"index"`
+	PurgeAfter  *time.Time `json:"purge_after,omitempty" gorm:"index"`
+	PurgedAt    *time.Time `json:"purged_at,omitempty"`
+}
+
+type RequirementStatus string
+
+const (
+	RequirementMissing   RequirementStatus = "missing"
+	RequirementPending   RequirementStatus = "pending" // submitted, not yet checked
+	RequirementSatisfied RequirementStatus = "satisfied"
+	RequirementRejected  RequirementStatus = "rejected" // the latest submission was rejected
+)
+
+// Requirements a user meets to reach a KYC level.
+const (
+	RequirementPhone          = "verified_phone"
+	RequirementFullName       = "full_name"
+	RequirementDateOfBirth    = "date_of_birth"
+	RequirementGovernmentID   = "government_id"
+	RequirementSelfie         = "selfie"
+	RequirementProofOfAddress = "proof_of_address"
+)
+
+// KYCTiers lists what each KYC level requires on top of the levels below it.
+var KYCTiers = []struct {
+	Level        int
+	Requirements []string
+}{
+	{1, []string{RequirementPhone, RequirementFullName, RequirementDateOfBirth}},
+	{2, []string{RequirementGovernmentID, RequirementSelfie, RequirementProofOfAddress}},
+}
+
+// RequirementDocuments maps the requirements met by documents to the
+// document types that satisfy them.
+var RequirementDocuments = map[string][]string{
+	RequirementGovernmentID:   {core.DocumentTypePassport, core.DocumentTypeDriversLicense, core.DocumentTypeNationalID},
+	RequirementSelfie:         {core.DocumentTypeSelfie},
+	RequirementProofOfAddress: {core.DocumentTypeProofOfAddress},
+}
+
+// KYCRequirement is where a user stands on one requirement, as of the last
+// time their KYC level was worked out.
+type KYCRequirement struct {
+	core.Model
+	UserID      int               `json:"-" gorm:"uniqueIndex:idx_kyc_requirements_user_name"`
+	Name        string            `json:"name" gorm:"uniqueIndex:idx_kyc_requirements_user_name"`
+	Level       int               `json:"level"`
+	Status      RequirementStatus `json:"status"`
+	DocumentID  *int              `json:"document_id,omitempty"` // the document behind Status, if any
+	Reason      string            `json:"reason,omitempty"`      // why the document was rejected
+	SatisfiedAt *time.Time        `json:"satisfied_at,omitempty"`
 }
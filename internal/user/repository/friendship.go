@@ -11,10 +11,17 @@ type friendshipLayer struct {
 }
 
 type FriendshipRepo interface {
+	SQLTransaction(f func(repo FriendshipRepo) error) error
 	Create(f *models.Friendship) error
 	FindByUser(userID int) ([]models.Friendship, error)
 	Find(userID, friendID int) (*models.Friendship, error)
+	FindByID(id int) (*models.Friendship, error)
+	ListIncoming(userID int, status models.FriendshipStatus) ([]models.Friendship, error)
+	ListOutgoing(userID int, status models.FriendshipStatus) ([]models.Friendship, error)
+	IsBlocked(userID, otherID int) (bool, error)
 	Update(f *models.Friendship) error
+	Delete(f *models.Friendship) error
+	DeleteBetween(userID, otherID int) error
 }
 
 func newFriendshipLayer(db *gorm.DB) *friendshipLayer {
@@ -23,14 +30,19 @@ func newFriendshipLayer(db *gorm.DB) *friendshipLayer {
 	}
 }
 
+// SQLTransaction runs f against a FriendshipRepo bound to a database transaction.
+func (l *friendshipLayer) SQLTransaction(f func(repo FriendshipRepo) error) error {
+	return l.db.Transaction(func(tx *gorm.DB) error {
+		return f(newFriendshipLayer(tx))
+	})
+}
+
 func (l *friendshipLayer) Create(f *models.Friendship) error {
 	return l.db.Create(f).Error
 }
 
 func (l *friendshipLayer) FindByUser(userID int) ([]models.Friendship, error) {
-	var friends []models.Friendship
-	err := l.db.Where("user_id = ?", userID).Find(&friends).Error
-	return friends, err
+	return l.ListOutgoing(userID, models.FriendshipAccepted)
 }
 
 func (l *friendshipLayer) Find(userID, friendID int) (*models.Friendship, error) {
@@ -39,6 +51,48 @@ func (l *friendshipLayer) Find(userID, friendID int) (*models.Friendship, error)
 	return &f, err
 }
 
+func (l *friendshipLayer) FindByID(id int) (*models.Friendship, error) {
+	var f models.Friendship
+	if err := l.db.First(&f, id).Error; err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (l *friendshipLayer) ListIncoming(userID int, status models.FriendshipStatus) ([]models.Friendship, error) {
+	var friends []models.Friendship
+	err := l.db.Where("friend_id = ? AND status = ?", userID, status).Order("id desc").Find(&friends).Error
+	return friends, err
+}
+
+func (l *friendshipLayer) ListOutgoing(userID int, status models.FriendshipStatus) ([]models.Friendship, error) {
+	var friends []models.Friendship
+	err := l.db.Where("user_id = ? AND status = ?", userID, status).Order("id desc").Find(&friends).Error
+	return friends, err
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (l *friendshipLayer) IsBlocked(userID, otherID int) (bool, error) {
+	var count int64
+	err := l.db.Model(&models.Friendship{}).
+		Where("((user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)) AND status = ?",
+			userID, otherID, otherID, userID, models.FriendshipBlocked).
+		Count(&count).Error
+	return count > 0, err
+}
+
 func (l *friendshipLayer) Update(f *models.Friendship) error {
 	return l.db.Save(f).Error
 }
+
+func (l *friendshipLayer) Delete(f *models.Friendship) error {
+	return l.db.Delete(f).Error
+}
+
+// DeleteBetween removes friendship and request edges between two users, in
+// both directions. Blocks are left in place.
+func (l *friendshipLayer) DeleteBetween(userID, otherID int) error {
+	return l.db.Where("((user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)) AND status <> ?",
+		userID, otherID, otherID, userID, models.FriendshipBlocked).
+		Delete(&models.Friendship{}).Error
+}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"cashapp/core/fieldcrypt"
 	"cashapp/internal/user/models"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -15,6 +17,12 @@ type IdentityDocumentRepo interface {
 	Update(doc *models.IdentityDocument) error
 	FindByID(id int) (*models.IdentityDocument, error)
 	FindByUserID(userID int) ([]models.IdentityDocument, error)
+	FindOpen(userID int, types []string) (*models.IdentityDocument, error)
+	FindByProviderRef(ref string) (*models.IdentityDocument, error)
+	ListAwaitingProvider(createdAfter, checkedBefore time.Time, limit int) ([]models.IdentityDocument, error)
+	Touch(doc *models.IdentityDocument) error
+	ListByStatus(status models.DocumentStatus, beforeID, limit int) ([]models.IdentityDocument, error)
+	Transition(doc *models.IdentityDocument, from []models.DocumentStatus) (bool, error)
 }
 
 func newIdentityDocumentLayer(db *gorm.DB) *identityDocumentLayer {
@@ -41,6 +49,77 @@ func (l *identityDocumentLayer) FindByID(id int) (*models.IdentityDocument, erro
 
 func (l *identityDocumentLayer) FindByUserID(userID int) ([]models.IdentityDocument, error) {
 	var docs []models.IdentityDocument
-	err := l.db.Where("user_id = ?", userID).Find(&docs).Error
+	err := l.db.Where("user_id = ?", userID).Order("id").Find(&docs).Error
 	return docs, err
 }
+
+// FindOpen returns a user's most recent document of one of the types that is
+// still waiting on the provider or a reviewer.
+func (l *identityDocumentLayer) FindOpen(userID int, types []string) (*models.IdentityDocument, error) {
+	var doc models.IdentityDocument
+	err := l.db.Where("user_id = ? AND type IN ? AND status IN ?", userID, types, []models.DocumentStatus{models.DocumentPending, models.DocumentInReview}).
+		Order("id DESC").
+		First(&doc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (l *identityDocumentLayer) FindByProviderRef(ref string) (*models.IdentityDocument, error) {
+	index, err := fieldcrypt.Index("identity_documents.provider_ref", ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc models.IdentityDocument
+	if err := l.db.Where("provider_ref_index = ?", index).First(&doc).Error; err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// ListAwaitingProvider returns documents submitted after createdAfter that
+// still wait on the provider and haven't changed since checkedBefore, least
+// recently checked first.
+func (l *identityDocumentLayer) ListAwaitingProvider(createdAfter, checkedBefore time.Time, limit int) ([]models.IdentityDocument, error) {
+	var docs []models.IdentityDocument
+	err := l.db.Where("status = ? AND provider_ref <> ''", models.DocumentPending).
+		Where("created_at > ? AND updated_at < ?", createdAfter, checkedBefore).
+		Order("updated_at").
+		Limit(limit).
+		Find(&docs).Error
+	return docs, err
+}
+
+// Touch marks a document as just checked.
+func (l *identityDocumentLayer) Touch(doc *models.IdentityDocument) error {
+	return l.db.Model(doc).Update("updated_at", time.Now()).Error
+}
+
+// ListByStatus returns documents in a status, newest first.
+func (l *identityDocumentLayer) ListByStatus(status models.DocumentStatus, beforeID, limit int) ([]models.IdentityDocument, error) {
+	var docs []models.IdentityDocument
+	q := l.db.Where("status = ?", status)
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	err := q.Order("id desc").Limit(limit).Find(&docs).Error
+	return docs, err
+}
+
+// Transition saves a decision on a document only if it is still in one of
+// the from statuses, reporting whether it was.
+func (l *identityDocumentLayer) Transition(doc *models.IdentityDocument, from []models.DocumentStatus) (bool, error) {
+	res := l.db.Model(&models.IdentityDocument{}).
+		Where("id = ? AND status IN ?", doc.ID, from).
+		Updates(map[string]interface{}{
+			"status":           doc.Status,
+			"provider_result":  doc.ProviderResult,
+			"provider_reason":  doc.ProviderReason,
+			"rejection_reason": doc.RejectionReason,
+			"reviewed_by":      doc.ReviewedBy,
+			"reviewed_at":      doc.ReviewedAt,
+		})
+	return res.RowsAffected > 0, res.Error
+}
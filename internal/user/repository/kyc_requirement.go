@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"cashapp/internal/user/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type kycRequirementLayer struct {
+	db *gorm.DB
+}
+
+type KYCRequirementRepo interface {
+	Save(reqs []models.KYCRequirement) error
+	FindByUserID(userID int) ([]models.KYCRequirement, error)
+}
+
+func newKYCRequirementLayer(db *gorm.DB) *kycRequirementLayer {
+	return &kycRequirementLayer{
+		db: db,
+	}
+}
+
+// Save records where a user stands on each requirement, replacing what was
+// recorded before.
+func (l *kycRequirementLayer) Save(reqs []models.KYCRequirement) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	return l.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"level", "status", "document_id", "reason", "satisfied_at", "updated_at"}),
+	}).Create(&reqs).Error
+}
+
+func (l *kycRequirementLayer) FindByUserID(userID int) ([]models.KYCRequirement, error) {
+	var reqs []models.KYCRequirement
+	err := l.db.Where("user_id = ?", userID).Order("level, id").Find(&reqs).Error
+	return reqs, err
+}
@@ -8,6 +8,10 @@ type Repo struct {
 	IdentityDocuments IdentityDocumentRepo
 	FundingSources    FundingSourceRepo
 	Friendships       FriendshipRepo
+	Suggestions       SuggestionRepo
+	Verifications     VerificationRepo
+	KYCRequirements   KYCRequirementRepo
+	Uploads           UploadRepo
 }
 
 func New(db *gorm.DB) Repo {
@@ -17,5 +21,9 @@ func New(db *gorm.DB) Repo {
 		IdentityDocuments: newIdentityDocumentLayer(db),
 		FundingSources:    newFundingSourceLayer(db),
 		Friendships:       newFriendshipLayer(db),
+		Suggestions:       newSuggestionLayer(db),
+		Verifications:     newVerificationLayer(db),
+		KYCRequirements:   newKYCRequirementLayer(db),
+		Uploads:           newUploadLayer(db),
 	}
 }
@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"cashapp/internal/user/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	suggestionTransactionsSync = "suggestions.transactions"
+	suggestionMutualBackfill   = "suggestions.mutual_backfill"
+
+	// Arbitrary key serialising suggestion syncs across user service instances.
+	suggestionSyncLockKey = 340034
+)
+
+// Suggestion is a ranked friend suggestion with the candidate's tag.
+type Suggestion struct {
+	CandidateID   int    `json:"candidate_id"`
+	Tag           string `json:"tag"`
+	MutualFriends int    `json:"mutual_friends"`
+	Transactions  int    `json:"transactions"`
+	Score         int    `json:"score"`
+}
+
+type suggestionLayer struct {
+	db *gorm.DB
+}
+
+type SuggestionRepo interface {
+	AddMutual(userID int, candidateIDs []int, delta int) error
+	Dismiss(userID, candidateID int) error
+	List(userID, limit int) ([]Suggestion, error)
+	BackfillMutuals() error
+	SyncTransactions(batchSize int) (int, error)
+}
+
+func newSuggestionLayer(db *gorm.DB) *suggestionLayer {
+	return &suggestionLayer{
+		db: db,
+	}
+}
+
+// AddMutual adjusts the mutual friend count between userID and each
+// candidate, in both directions.
+func (l *suggestionLayer) AddMutual(userID int, candidateIDs []int, delta int) error {
+	if len(candidateIDs) == 0 || delta == 0 {
+		return nil
+	}
+
+	if delta < 0 {
+		return l.db.Model(&models.FriendSuggestion{}).
+			Where("(user_id = ? AND candidate_id IN ?) OR (user_id IN ? AND candidate_id = ?)",
+				userID, candidateIDs, candidateIDs, userID).
+			Update("mutual_friends", gorm.Expr("GREATEST(mutual_friends + ?, 0)", delta)).Error
+	}
+
+	rows := make([]models.FriendSuggestion, 0, len(candidateIDs)*2)
+	for _, id := range candidateIDs {
+		rows = append(rows,
+			models.FriendSuggestion{UserID: userID, CandidateID: id, MutualFriends: delta},
+			models.FriendSuggestion{UserID: id, CandidateID: userID, MutualFriends: delta},
+		)
+	}
+	return l.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "candidate_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"mutual_friends": gorm.Expr("friend_suggestions.mutual_friends + EXCLUDED.mutual_friends"),
+		}),
+	}).Create(&rows).Error
+}
+
+func (l *suggestionLayer) Dismiss(userID, candidateID int) error {
+	return l.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "candidate_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"dismissed": true}),
+	}).Create(&models.FriendSuggestion{UserID: userID, CandidateID: candidateID, Dismissed: true}).Error
+}
+
+// List ranks candidates for userID, leaving out dismissed candidates and
+// anyone the user is already connected to: friends, pending requests and
+// blocks in either direction.
+func (l *suggestionLayer) List(userID, limit int) ([]Suggestion, error) {
+	var suggestions []Suggestion
+	err := l.db.Raw(`
+		SELECT s.candidate_id, u.tag, s.mutual_friends, s.transactions,
+			(s.mutual_friends * 3 + s.transactions * 2) AS score
+		FROM friend_suggestions s
+		JOIN users u ON u.id = s.candidate_id AND u.deleted_at IS NULL
+		WHERE s.user_id = ?
+			AND s.candidate_id <> s.user_id
+			AND s.dismissed = false
+			AND (s.mutual_friends > 0 OR s.transactions > 0)
+			AND NOT EXISTS (
+				SELECT 1 FROM friendships f
+				WHERE (f.user_id = s.user_id AND f.friend_id = s.candidate_id)
+					OR (f.user_id = s.candidate_id AND f.friend_id = s.user_id)
+			)
+		ORDER BY score DESC, s.candidate_id ASC
+		LIMIT ?`, userID, limit).Scan(&suggestions).Error
+	return suggestions, err
+}
+
+// BackfillMutuals computes mutual friend counts from existing friendships
+// once, so suggestions cover friendships made before they were tracked.
+func (l *suggestionLayer) BackfillMutuals() error {
+	return l.db.Transaction(func(tx *gorm.DB) error {
+		if !tryLock(tx) {
+			return nil
+		}
+
+		var state models.SyncState
+		err := tx.Where("name = ?", suggestionMutualBackfill).First(&state).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		err = tx.Exec(`
+			INSERT INTO friend_suggestions (user_id, candidate_id, mutual_friends, transactions, dismissed, created_at, updated_at)
+			SELECT a.user_id, b.friend_id, COUNT(*), 0, false, NOW(), NOW()
+			FROM friendships a
+			JOIN friendships b ON b.user_id = a.friend_id
+			WHERE a.status = ? AND b.status = ? AND a.user_id <> b.friend_id
+			GROUP BY a.user_id, b.friend_id
+			ON CONFLICT (user_id, candidate_id) DO UPDATE SET mutual_friends = EXCLUDED.mutual_friends`,
+			models.FriendshipAccepted, models.FriendshipAccepted).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&models.SyncState{Name: suggestionMutualBackfill}).Error
+	})
+}
+
+// syncTransfer is a ledger transfer as the suggestion sync reads it.
+type syncTransfer struct {
+	ID     int
+	From   int
+	To     int
+	Status string
+}
+
+// SyncTransactions folds up to batchSize transfers recorded by the ledger
+// since the last sync into the payment counts, and returns how many it
+// consumed. Transfers still pending or held are remembered and counted on a
+// later sync, once they settle, so they never hold the cursor back.
+func (l *suggestionLayer) SyncTransactions(batchSize int) (int, error) {
+	consumed := 0
+	err := l.db.Transaction(func(tx *gorm.DB) error {
+		if !tryLock(tx) {
+			return nil
+		}
+
+		state := models.SyncState{Name: suggestionTransactionsSync}
+		if err := tx.Where("name = ?", state.Name).FirstOrCreate(&state).Error; err != nil {
+			return err
+		}
+
+		counts := make(map[[2]int]int)
+		count := func(t syncTransfer) {
+			if t.Status != "success" || t.From == t.To {
+				return
+			}
+			counts[[2]int{t.From, t.To}]++
+			counts[[2]int{t.To, t.From}]++
+		}
+
+		if err := revisitUnsettled(tx, batchSize, count); err != nil {
+			return err
+		}
+
+		var transfers []syncTransfer
+		err := tx.Table("transactions").
+			Select(`id, "from", "to", status`).
+			Where("id > ? AND direction = ? AND purpose = ?", state.LastID, "outgoing", "transfer").
+			Order("id asc").
+			Limit(batchSize).
+			Scan(&transfers).Error
+		if err != nil {
+			return err
+		}
+
+		var unsettled []models.UnsettledTransfer
+		for _, t := range transfers {
+			state.LastID = t.ID
+			consumed++
+			if !settled(t.Status) {
+				unsettled = append(unsettled, models.UnsettledTransfer{TransactionID: t.ID, CheckedAt: time.Now()})
+				continue
+			}
+			count(t)
+		}
+
+		if len(unsettled) > 0 {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&unsettled).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(counts) > 0 {
+			rows := make([]models.FriendSuggestion, 0, len(counts))
+			for pair, n := range counts {
+				rows = append(rows, models.FriendSuggestion{UserID: pair[0], CandidateID: pair[1], Transactions: n})
+			}
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "user_id"}, {Name: "candidate_id"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"transactions": gorm.Expr("friend_suggestions.transactions + EXCLUDED.transactions"),
+				}),
+			}).Create(&rows).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Save(&state).Error
+	})
+	return consumed, err
+}
+
+// revisitUnsettled checks up to limit remembered transfers, least recently
+// checked first, passing each one that has since settled to count and
+// forgetting it.
+func revisitUnsettled(tx *gorm.DB, limit int, count func(syncTransfer)) error {
+	var ids []int
+	err := tx.Model(&models.UnsettledTransfer{}).
+		Order("checked_at asc").
+		Limit(limit).
+		Pluck("transaction_id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	var transfers []syncTransfer
+	err = tx.Table("transactions").
+		Select(`id, "from", "to", status`).
+		Where("id IN ?", ids).
+		Scan(&transfers).Error
+	if err != nil {
+		return err
+	}
+
+	done := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		// A transfer that has gone missing is forgotten too.
+		done[id] = true
+	}
+	var waiting []int
+	for _, t := range transfers {
+		if !settled(t.Status) {
+			done[t.ID] = false
+			waiting = append(waiting, t.ID)
+			continue
+		}
+		count(t)
+	}
+
+	var forget []int
+	for id, ok := range done {
+		if ok {
+			forget = append(forget, id)
+		}
+	}
+	if len(forget) > 0 {
+		if err := tx.Where("transaction_id IN ?", forget).Delete(&models.UnsettledTransfer{}).Error; err != nil {
+			return err
+		}
+	}
+	if len(waiting) > 0 {
+		return tx.Model(&models.UnsettledTransfer{}).Where("transaction_id IN ?", waiting).Update("checked_at", time.Now()).Error
+	}
+	return nil
+}
+
+// settled reports whether a transfer's status is final.
+func settled(status string) bool {
+	return status == "success" || status == "failed"
+}
+
+// tryLock takes a transaction-scoped advisory lock, reporting false when
+// another instance holds it.
+func tryLock(tx *gorm.DB) bool {
+	var locked bool
+	if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", suggestionSyncLockKey).Scan(&locked).Error; err != nil {
+		return false
+	}
+	return locked
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"cashapp/internal/user/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type uploadLayer struct {
+	db *gorm.DB
+}
+
+type UploadRepo interface {
+	Create(u *models.Upload) error
+	FindByID(id int) (*models.Upload, error)
+	FindForUser(userID int, id int) (*models.Upload, error)
+	Attach(u *models.Upload, documentID int) (bool, error)
+	SetPurgeAfter(documentID int, at time.Time) error
+	ListDue(now time.Time, limit int) ([]models.Upload, error)
+	MarkPurged(u *models.Upload) error
+}
+
+func newUploadLayer(db *gorm.DB) *uploadLayer {
+	return &uploadLayer{
+		db: db,
+	}
+}
+
+func (l *uploadLayer) Create(u *models.Upload) error {
+	return l.db.Create(u).Error
+}
+
+func (l *uploadLayer) FindByID(id int) (*models.Upload, error) {
+	var u models.Upload
+	if err := l.db.First(&u, id).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (l *uploadLayer) FindForUser(userID int, id int) (*models.Upload, error) {
+	var u models.Upload
+	if err := l.db.Where("id = ? AND user_id = ?", id, userID).First(&u).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Attach ties an upload to a document and holds it until the document is
+// decided. It reports false when the upload is already attached or purged.
+func (l *uploadLayer) Attach(u *models.Upload, documentID int) (bool, error) {
+	result := l.db.Model(&models.Upload{}).
+		Where("id = ? AND document_id IS NULL AND purged_at IS NULL", u.ID).
+		Updates(map[string]interface{}{"document_id": documentID, "purge_after": nil})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	u.DocumentID = &documentID
+	u.PurgeAfter = nil
+	return true, nil
+}
+
+// SetPurgeAfter schedules a document's upload for deletion.
+func (l *uploadLayer) SetPurgeAfter(documentID int, at time.Time) error {
+	return l.db.Model(&models.Upload{}).
+		Where("document_id = ? AND purged_at IS NULL", documentID).
+		Update("purge_after", at).Error
+}
+
+// ListDue returns uploads past their retention, oldest first.
+func (l *uploadLayer) ListDue(now time.Time, limit int) ([]models.Upload, error) {
+	var uploads []models.Upload
+	err := l.db.Where("purge_after <= ? AND purged_at IS NULL", now).
+		Order("purge_after").
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, err
+}
+
+func (l *uploadLayer) MarkPurged(u *models.Upload) error {
+	now := time.Now()
+	u.PurgedAt = &now
+	return l.db.Model(u).Update("purged_at", now).Error
+}
@@ -1,7 +1,12 @@
 package repository
 
 import (
+	"cashapp/core"
+	"cashapp/core/fieldcrypt"
 	"cashapp/internal/user/models"
+	"errors"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -13,8 +18,25 @@ type userLayer struct {
 type UserRepo interface {
 	Create(user *models.User) error
 	Update(user *models.User) error
+	RaiseRiskScore(userID, score int) error
 	FindByTag(tag string) (*models.User, error)
+	ChangeTag(user *models.User, change *models.TagChange) error
+	TagHistory(userID int) ([]models.TagChange, error)
+	FindByContact(channel models.ContactChannel, value string) (*models.User, error)
+	FindByContacts(emails, phones []string) ([]models.User, error)
 	FindByID(id int) (*models.User, error)
+	ListAfter(afterID, limit int) ([]models.User, error)
+	Search(viewerID int, query string, limit int) ([]SearchResult, error)
+}
+
+// SearchResult is a directory match with the signals used to rank it.
+type SearchResult struct {
+	ID                 int     `json:"id"`
+	Tag                string  `json:"tag"`
+	DisplayName        string  `json:"display_name"`
+	IsFriend           bool    `json:"is_friend"`
+	RecentCounterparty bool    `json:"recent_counterparty"`
+	Score              float64 `json:"score"`
 }
 
 func newUserLayer(db *gorm.DB) *userLayer {
@@ -31,18 +53,128 @@ func (ul *userLayer) Create(user *models.User) error {
 
 }
 
+// Update saves a user. The risk score is left out: the risk engine moves it
+// in place, and a copy read before that mustn't undo it.
 func (ul *userLayer) Update(user *models.User) error {
-	return ul.db.Save(user).Error
+	return ul.db.Omit("risk_score").Save(user).Error
+}
+
+// RaiseRiskScore lifts a user's risk score to at least score.
+func (ul *userLayer) RaiseRiskScore(userID, score int) error {
+	return ul.db.Table("users").
+		Where("id = ?", userID).
+		Update("risk_score", gorm.Expr("GREATEST(risk_score, ?)", score)).Error
+}
+
+// ListAfter pages through every user in ID order.
+func (ul *userLayer) ListAfter(afterID, limit int) ([]models.User, error) {
+	var users []models.User
+	err := ul.db.Where("id > ? AND deleted_at IS NULL", afterID).Order("id asc").Limit(limit).Find(&users).Error
+	return users, err
 }
 
+// FindByTag resolves a cash tag in any casing, with or without "$". Tags a
+// user changed away from still resolve to them while they're held.
 func (ul *userLayer) FindByTag(tag string) (*models.User, error) {
-	user := models.User{Tag: tag}
-	if err := ul.db.Where("tag = ?", tag).First(&user).Error; err != nil {
+	normalized := core.NormalizeTag(tag)
+
+	var user models.User
+	err := ul.db.Where("normalized_tag = ?", normalized).First(&user).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &user, err
+	}
+
+	var change models.TagChange
+	err = ul.db.Where("normalized_old_tag = ? AND held_until > ?", normalized, time.Now()).
+		Order("held_until DESC").
+		First(&change).Error
+	if err != nil {
 		return &user, err
 	}
+
+	err = ul.db.First(&user, change.UserID).Error
+	return &user, err
+}
+
+// ChangeTag saves the user's new tag and records the change. A held tag the
+// user is reclaiming is released so it no longer shadows the new one.
+func (ul *userLayer) ChangeTag(user *models.User, change *models.TagChange) error {
+	return ul.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.TagChange{}).
+			Where("user_id = ? AND normalized_old_tag = ? AND held_until > ?", user.ID, *user.NormalizedTag, time.Now()).
+			Update("held_until", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(change).Error; err != nil {
+			return err
+		}
+		return tx.Omit("risk_score").Save(user).Error
+	})
+}
+
+// FindByContact finds the user who verified an email address or phone number.
+func (ul *userLayer) FindByContact(channel models.ContactChannel, value string) (*models.User, error) {
+	index, err := fieldcrypt.Index("users."+string(channel), value)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = ul.db.Where(string(channel)+"_index = ?", index).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
+// FindByContacts returns users whose verified email or phone is in the lists.
+func (ul *userLayer) FindByContacts(emails, phones []string) ([]models.User, error) {
+	var users []models.User
+	if len(emails) == 0 && len(phones) == 0 {
+		return users, nil
+	}
+
+	emailIndexes, err := contactIndexes("users.email", emails)
+	if err != nil {
+		return nil, err
+	}
+	phoneIndexes, err := contactIndexes("users.phone", phones)
+	if err != nil {
+		return nil, err
+	}
+
+	query := ul.db.Where("1 = 0")
+	if len(emailIndexes) > 0 {
+		query = query.Or("email_index IN ?", emailIndexes)
+	}
+	if len(phoneIndexes) > 0 {
+		query = query.Or("phone_index IN ?", phoneIndexes)
+	}
+	err = query.Find(&users).Error
+	return users, err
+}
+
+// contactIndexes returns the blind indexes of contacts, which is how
+// they're looked up now they're stored encrypted.
+func contactIndexes(scope string, contacts []string) ([]string, error) {
+	indexes := make([]string, 0, len(contacts))
+	for _, c := range contacts {
+		index, err := fieldcrypt.Index(scope, c)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+func (ul *userLayer) TagHistory(userID int) ([]models.TagChange, error) {
+	var changes []models.TagChange
+	err := ul.db.Where("user_id = ?", userID).Order("id DESC").Find(&changes).Error
+	return changes, err
+}
+
 func (ul *userLayer) FindByID(id int) (*models.User, error) {
 	var user models.User
 	if err := ul.db.First(&user, id).Error; err != nil {
@@ -50,3 +182,59 @@ func (ul *userLayer) FindByID(id int) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+// recentCounterpartyWindow is how far back a payment counts towards ranking.
+const recentCounterpartyWindow = 90 * 24 * time.Hour
+
+// Search matches query against cash tags and display names by prefix or
+// trigram similarity. Friends and recent payment counterparties rank higher.
+// Users who opted out of the directory are only found by their friends, and
+// blocked users are never returned.
+func (ul *userLayer) Search(viewerID int, query string, limit int) ([]SearchResult, error) {
+	q := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "$"))
+	prefix := likeEscaper.Replace(q) + "%"
+
+	var results []SearchResult
+	err := ul.db.Raw(`
+		SELECT id, tag, display_name, is_friend, recent_counterparty,
+			(CASE WHEN prefix_match THEN 2 ELSE 0 END) + similarity
+				+ (CASE WHEN is_friend THEN 1.5 ELSE 0 END)
+				+ (CASE WHEN recent_counterparty THEN 1 ELSE 0 END) AS score
+		FROM (
+			SELECT u.id, u.tag, u.display_name, u.discoverable,
+				(lower(u.tag) LIKE @prefix OR lower(u.display_name) LIKE @prefix) AS prefix_match,
+				GREATEST(similarity(lower(u.tag), @q), similarity(lower(u.display_name), @q)) AS similarity,
+				EXISTS (
+					SELECT 1 FROM friendships f
+					WHERE f.user_id = @viewer AND f.friend_id = u.id AND f.status = 'accepted'
+				) AS is_friend,
+				EXISTS (
+					SELECT 1 FROM transactions t
+					WHERE t.direction = 'outgoing' AND t.status = 'success' AND t.created_at > @since
+						AND ((t."from" = @viewer AND t."to" = u.id) OR (t."from" = u.id AND t."to" = @viewer))
+				) AS recent_counterparty
+			FROM users u
+			WHERE u.deleted_at IS NULL
+				AND u.id <> @viewer
+				AND (lower(u.tag) LIKE @prefix OR lower(u.display_name) LIKE @prefix
+					OR lower(u.tag) % @q OR lower(u.display_name) % @q)
+				AND NOT EXISTS (
+					SELECT 1 FROM friendships b
+					WHERE b.status = 'blocked'
+						AND ((b.user_id = @viewer AND b.friend_id = u.id) OR (b.user_id = u.id AND b.friend_id = @viewer))
+				)
+		) AS matches
+		WHERE discoverable OR is_friend
+		ORDER BY score DESC, id ASC
+		LIMIT @limit`,
+		map[string]interface{}{
+			"q":      q,
+			"prefix": prefix,
+			"viewer": viewerID,
+			"since":  time.Now().Add(-recentCounterpartyWindow),
+			"limit":  limit,
+		}).Scan(&results).Error
+	return results, err
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"cashapp/internal/user/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type verificationLayer struct {
+	db *gorm.DB
+}
+
+type VerificationRepo interface {
+	Create(code *models.VerificationCode) error
+	ClaimAttempt(id, maxAttempts int) (bool, error)
+	Consume(id int, at time.Time) (bool, error)
+	FindLatest(userID int, channel models.ContactChannel) (*models.VerificationCode, error)
+}
+
+func newVerificationLayer(db *gorm.DB) *verificationLayer {
+	return &verificationLayer{
+		db: db,
+	}
+}
+
+func (vl *verificationLayer) Create(code *models.VerificationCode) error {
+	return vl.db.Create(code).Error
+}
+
+// ClaimAttempt counts one guess against an unconsumed code, reporting
+// whether it had any left. Concurrent guesses can't share an attempt.
+func (vl *verificationLayer) ClaimAttempt(id, maxAttempts int) (bool, error) {
+	res := vl.db.Model(&models.VerificationCode{}).
+		Where("id = ? AND attempts < ? AND consumed_at IS NULL", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	return res.RowsAffected > 0, res.Error
+}
+
+// Consume marks a code used, reporting whether it was still unused.
+func (vl *verificationLayer) Consume(id int, at time.Time) (bool, error) {
+	res := vl.db.Model(&models.VerificationCode{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", at)
+	return res.RowsAffected > 0, res.Error
+}
+
+// FindLatest returns the most recent unconsumed code for a channel. Issuing
+// a new code supersedes older ones.
+func (vl *verificationLayer) FindLatest(userID int, channel models.ContactChannel) (*models.VerificationCode, error) {
+	var code models.VerificationCode
+	err := vl.db.Where("user_id = ? AND channel = ? AND consumed_at IS NULL", userID, channel).
+		Order("id DESC").
+		First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
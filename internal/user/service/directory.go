@@ -0,0 +1,64 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/screening"
+	"cashapp/internal/user/models"
+	"errors"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const defaultSearchLimit = 10
+
+// SearchUsers finds users by cash tag or display name for the directory.
+func (s *UserService) SearchUsers(user *models.User, q core.UserSearchQuery) core.Response {
+	query := strings.TrimPrefix(strings.TrimSpace(q.Q), "$")
+	if len(query) < 2 {
+		return core.Error(core.Validation(errors.New("query too short")), core.String("search query must be at least 2 characters"))
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	results, err := s.repository.Users.Search(user.ID, query, limit)
+	if err != nil {
+		return core.Error(err, core.String("failed to search users"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"results": results,
+	}, nil)
+}
+
+// UpdateDirectorySettings changes how the user appears in directory search.
+// A new display name is screened, like any other name the user goes by.
+func (s *UserService) UpdateDirectorySettings(user *models.User, req core.DirectorySettingsRequest) core.Response {
+	displayName := user.DisplayName
+	if req.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.Discoverable != nil {
+		user.Discoverable = *req.Discoverable
+	}
+
+	if err := s.repository.Users.Update(user); err != nil {
+		return core.Error(err, core.String("failed to update directory settings"))
+	}
+
+	if user.DisplayName != displayName {
+		s.screenAndLog(user, screening.TriggerProfile)
+		if err := s.refreshKYC(user, "profile-"+strconv.FormatInt(user.UpdatedAt.UnixNano(), 10)); err != nil {
+			core.Log.Error("failed to refresh kyc level", zap.Int("user_id", user.ID), zap.Error(err))
+		}
+	}
+
+	return core.Success(&map[string]interface{}{
+		"display_name": user.DisplayName,
+		"discoverable": user.Discoverable,
+	}, core.String("directory settings updated"))
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/storage"
+	"cashapp/internal/user/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// unattachedUploadTTL is how long an upload waits to be submitted for
+	// verification before it is purged.
+	unattachedUploadTTL = 24 * time.Hour
+	// documentLinkTTL is how long a reviewer's download link works.
+	documentLinkTTL      = 15 * time.Minute
+	documentPurgeBatch   = 100
+	maxUploadFilenameLen = 255
+)
+
+// documentContentTypes are the files we accept, as sniffed from their
+// contents rather than what the client claims.
+var documentContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// SetDocumentStore sets where uploaded documents are kept and how reviewer
+// download links are signed.
+func (s *UserService) SetDocumentStore(blobs storage.BlobStore, links *storage.LinkSigner) {
+	s.blobs = blobs
+	s.links = links
+}
+
+// MaxUploadBytes is the largest document the caller may upload.
+func (s *UserService) MaxUploadBytes() int64 {
+	return s.config.UPLOAD_MAX_BYTES
+}
+
+// UploadDocument stores a document for the user to submit for
+// verification. It is purged if it isn't submitted within a day.
+func (s *UserService) UploadDocument(user *models.User, filename string, r io.Reader) core.Response {
+	if s.blobs == nil {
+		return core.Error(errors.New("document storage not configured"), nil)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, s.config.UPLOAD_MAX_BYTES+1))
+	if err != nil {
+		return core.Error(core.Validation(err), core.String("failed to read upload"))
+	}
+	if int64(len(data)) > s.config.UPLOAD_MAX_BYTES {
+		return core.Error(core.Validation(errors.New("upload too large")), core.String("documents must be at most "+strconv.FormatInt(s.config.UPLOAD_MAX_BYTES>>20, 10)+" MB"))
+	}
+	if len(data) == 0 {
+		return core.Error(core.Validation(errors.New("empty upload")), core.String("the file is empty"))
+	}
+
+	contentType := http.DetectContentType(data)
+	if !documentContentTypes[contentType] {
+		return core.Error(core.Validation(errors.New("unsupported content type "+contentType)), core.String("documents must be a JPEG, PNG or PDF"))
+	}
+
+	sum := sha256.Sum256(data)
+	expires := time.Now().Add(unattachedUploadTTL)
+	upload := &models.Upload{
+		UserID:      user.ID,
+		Key:         storage.NewKey("documents/" + strconv.Itoa(user.ID)),
+		Filename:    cleanFilename(filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		PurgeAfter:  &expires,
+	}
+
+	if err := s.blobs.Put(upload.Key, data, contentType); err != nil {
+		core.Log.Error("failed to store upload", zap.Int("user_id", user.ID), zap.Error(err))
+		return core.Error(err, core.String("failed to store document"))
+	}
+	if err := s.repository.Uploads.Create(upload); err != nil {
+		if err := s.blobs.Delete(upload.Key); err != nil {
+			core.Log.Error("failed to delete orphaned upload", zap.String("key", upload.Key), zap.Error(err))
+		}
+		return core.Error(err, core.String("failed to record upload"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"upload": upload,
+	}, nil)
+}
+
+// DocumentLink gives a reviewer a short-lived link to download the file
+// behind a document.
+func (s *UserService) DocumentLink(documentID int) core.Response {
+	if s.links == nil {
+		return core.Error(errors.New("document storage not configured"), nil)
+	}
+
+	doc, err := s.repository.IdentityDocuments.FindByID(documentID)
+	if err != nil {
+		return notFoundOr(err, "document not found")
+	}
+	if doc.UploadID == nil {
+		if doc.LegacyURL != "" {
+			// Submitted as a link before uploads; it can't be signed.
+			return core.Success(&map[string]interface{}{
+				"url": doc.LegacyURL,
+			}, nil)
+		}
+		return core.Error(core.NotFound(errors.New("document has no upload")), core.String("this document has no file"))
+	}
+
+	upload, err := s.repository.Uploads.FindByID(*doc.UploadID)
+	if err != nil {
+		return notFoundOr(err, "upload not found")
+	}
+	if upload.PurgedAt != nil {
+		return core.Error(core.NotFound(errors.New("upload purged")), core.String("this document's file has been deleted"))
+	}
+
+	expires := time.Now().Add(documentLinkTTL)
+	return core.Success(&map[string]interface{}{
+		"url":        s.links.Sign(uploadPath(upload.ID), expires),
+		"expires_at": expires,
+	}, nil)
+}
+
+// OpenUpload checks a download link and returns the file it points to.
+func (s *UserService) OpenUpload(id int, expires, signature string) (*models.Upload, []byte, *core.Response) {
+	if s.blobs == nil || s.links == nil {
+		resp := core.Error(errors.New("document storage not configured"), nil)
+		return nil, nil, &resp
+	}
+
+	if err := s.links.Verify(uploadPath(id), expires, signature); err != nil {
+		resp := core.Error(core.Forbidden(err), core.String(err.Error()))
+		return nil, nil, &resp
+	}
+
+	upload, err := s.repository.Uploads.FindByID(id)
+	if err != nil {
+		resp := notFoundOr(err, "upload not found")
+		return nil, nil, &resp
+	}
+	if upload.PurgedAt != nil {
+		resp := core.Error(core.NotFound(errors.New("upload purged")), core.String("this document's file has been deleted"))
+		return nil, nil, &resp
+	}
+
+	data, err := s.blobs.Get(upload.Key)
+	if err != nil {
+		core.Log.Error("failed to read upload", zap.Int("upload_id", upload.ID), zap.Error(err))
+		resp := core.Error(err, core.String("failed to read document"))
+		return nil, nil, &resp
+	}
+	return upload, data, nil
+}
+
+// RunDocumentPurge periodically deletes uploads past their retention. It
+// blocks; run it in a goroutine.
+func (s *UserService) RunDocumentPurge(interval time.Duration) {
+	if s.blobs == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.purgeDocuments()
+	}
+}
+
+func (s *UserService) purgeDocuments() {
+	uploads, err := s.repository.Uploads.ListDue(time.Now(), documentPurgeBatch)
+	if err != nil {
+		core.Log.Error("failed to load uploads due for purge", zap.Error(err))
+		return
+	}
+
+	for i := range uploads {
+		upload := &uploads[i]
+		if err := s.blobs.Delete(upload.Key); err != nil {
+			core.Log.Error("failed to delete upload", zap.Int("upload_id", upload.ID), zap.Error(err))
+			continue
+		}
+		if err := s.repository.Uploads.MarkPurged(upload); err != nil {
+			core.Log.Error("failed to mark upload purged", zap.Int("upload_id", upload.ID), zap.Error(err))
+		}
+	}
+}
+
+// findSubmittableUpload checks that the user's upload can back a new
+// document.
+func (s *UserService) findSubmittableUpload(userID int, uploadID int) (*models.Upload, *core.Response) {
+	if s.blobs == nil {
+		resp := core.Error(errors.New("document storage not configured"), nil)
+		return nil, &resp
+	}
+
+	upload, err := s.repository.Uploads.FindForUser(userID, uploadID)
+	if err != nil {
+		resp := notFoundOr(err, "upload not found")
+		return nil, &resp
+	}
+	if upload.DocumentID != nil {
+		resp := core.Error(core.Conflict(errors.New("upload already submitted")), core.String("this upload was already submitted"))
+		return nil, &resp
+	}
+	if upload.PurgedAt != nil || (upload.PurgeAfter != nil && time.Now().After(*upload.PurgeAfter)) {
+		resp := core.Error(core.Conflict(errors.New("upload expired")), core.String("this upload has expired, upload the document again"))
+		return nil, &resp
+	}
+	return upload, nil
+}
+
+// schedulePurge starts a decided document's retention period.
+func (s *UserService) schedulePurge(doc *models.IdentityDocument) {
+	if doc.UploadID == nil || doc.Open() {
+		return
+	}
+	if err := s.repository.Uploads.SetPurgeAfter(doc.ID, time.Now().Add(s.config.DOCUMENT_RETENTION)); err != nil {
+		core.Log.Error("failed to schedule document purge", zap.Int("document_id", doc.ID), zap.Error(err))
+	}
+}
+
+func uploadPath(id int) string {
+	return "/documents/files/" + strconv.Itoa(id)
+}
+
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		name = ""
+	}
+	if len(name) > maxUploadFilenameLen {
+		name = name[:maxUploadFilenameLen]
+	}
+	return name
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/user/models"
+	"cashapp/internal/user/repository"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// SendFriendRequest records a single pending edge from the user to friendID.
+func (s *UserService) SendFriendRequest(user *models.User, req core.FriendRequestDTO) core.Response {
+	if req.FriendID == user.ID {
+		return core.Error(core.Validation(errors.New("self friend request")), core.String("you cannot befriend yourself"))
+	}
+
+	if _, err := s.repository.Users.FindByID(req.FriendID); err != nil {
+		return notFoundOr(err, "user not found")
+	}
+
+	blocked, err := s.repository.Friendships.IsBlocked(user.ID, req.FriendID)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if blocked {
+		return core.Error(core.Forbidden(errors.New("users blocked")), core.String("you cannot send a friend request to this user"))
+	}
+
+	if existing, err := s.repository.Friendships.Find(user.ID, req.FriendID); err == nil {
+		if existing.Status == models.FriendshipAccepted {
+			return core.Error(core.Conflict(errors.New("friendship exists")), core.String("already friends"))
+		}
+		return core.Error(core.Conflict(errors.New("request exists")), core.String("friend request already sent"))
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.Error(err, nil)
+	}
+
+	if _, err := s.repository.Friendships.Find(req.FriendID, user.ID); err == nil {
+		return core.Error(core.Conflict(errors.New("reverse request exists")), core.String("this user has already sent you a friend request"))
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.Error(err, nil)
+	}
+
+	request := models.Friendship{
+		UserID:   user.ID,
+		FriendID: req.FriendID,
+		Status:   models.FriendshipPending,
+	}
+	if err := s.repository.Friendships.Create(&request); err != nil {
+		return core.Error(err, core.String("failed to send friend request"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"request": request,
+	}, core.String("friend request sent"))
+}
+
+// ListFriendRequests lists pending requests to (incoming) or from (outgoing)
+// the user. Both are returned when no direction is given.
+func (s *UserService) ListFriendRequests(user *models.User, q core.FriendRequestQuery) core.Response {
+	data := map[string]interface{}{}
+
+	if q.Direction != "outgoing" {
+		incoming, err := s.repository.Friendships.ListIncoming(user.ID, models.FriendshipPending)
+		if err != nil {
+			return core.Error(err, core.String("failed to load friend requests"))
+		}
+		data["incoming"] = incoming
+	}
+
+	if q.Direction != "incoming" {
+		outgoing, err := s.repository.Friendships.ListOutgoing(user.ID, models.FriendshipPending)
+		if err != nil {
+			return core.Error(err, core.String("failed to load friend requests"))
+		}
+		data["outgoing"] = outgoing
+	}
+
+	return core.Success(&data, nil)
+}
+
+// AcceptFriendRequest accepts a pending request addressed to the user and
+// adds the reciprocal edge.
+func (s *UserService) AcceptFriendRequest(user *models.User, requestID int) core.Response {
+	request, resp := s.pendingRequest(requestID, func(f *models.Friendship) bool { return f.FriendID == user.ID })
+	if resp != nil {
+		return *resp
+	}
+
+	err := s.repository.Friendships.SQLTransaction(func(repo repository.FriendshipRepo) error {
+		request.Status = models.FriendshipAccepted
+		if err := repo.Update(request); err != nil {
+			return err
+		}
+		return repo.Create(&models.Friendship{
+			UserID:   user.ID,
+			FriendID: request.UserID,
+			Status:   models.FriendshipAccepted,
+		})
+	})
+	if err != nil {
+		return core.Error(err, core.String("failed to accept friend request"))
+	}
+
+	s.friendshipChanged(request.UserID, request.FriendID, 1)
+
+	return core.Success(nil, core.String("friend request accepted"))
+}
+
+// DeclineFriendRequest rejects a pending request addressed to the user.
+func (s *UserService) DeclineFriendRequest(user *models.User, requestID int) core.Response {
+	request, resp := s.pendingRequest(requestID, func(f *models.Friendship) bool { return f.FriendID == user.ID })
+	if resp != nil {
+		return *resp
+	}
+
+	if err := s.repository.Friendships.Delete(request); err != nil {
+		return core.Error(err, core.String("failed to decline friend request"))
+	}
+	return core.Success(nil, core.String("friend request declined"))
+}
+
+// CancelFriendRequest withdraws a pending request the user sent.
+func (s *UserService) CancelFriendRequest(user *models.User, requestID int) core.Response {
+	request, resp := s.pendingRequest(requestID, func(f *models.Friendship) bool { return f.UserID == user.ID })
+	if resp != nil {
+		return *resp
+	}
+
+	if err := s.repository.Friendships.Delete(request); err != nil {
+		return core.Error(err, core.String("failed to cancel friend request"))
+	}
+	return core.Success(nil, core.String("friend request cancelled"))
+}
+
+func (s *UserService) ListFriends(user *models.User) core.Response {
+	friends, err := s.repository.Friendships.ListOutgoing(user.ID, models.FriendshipAccepted)
+	if err != nil {
+		return core.Error(err, core.String("failed to load friends"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"friends": friends,
+	}, nil)
+}
+
+// Unfriend removes both edges of an accepted friendship.
+func (s *UserService) Unfriend(user *models.User, friendID int) core.Response {
+	f, err := s.repository.Friendships.Find(user.ID, friendID)
+	if err != nil || f.Status != models.FriendshipAccepted {
+		return core.Error(core.NotFound(errors.New("friendship not found")), core.String("not friends with this user"))
+	}
+
+	if err := s.repository.Friendships.DeleteBetween(user.ID, friendID); err != nil {
+		return core.Error(err, core.String("failed to remove friend"))
+	}
+
+	s.friendshipChanged(user.ID, friendID, -1)
+
+	return core.Success(nil, core.String("friend removed"))
+}
+
+// BlockUser drops any friendship or pending request between the two users
+// and records a block from the user. Blocked users cannot send requests,
+// payment requests, or see the blocker's activity.
+func (s *UserService) BlockUser(user *models.User, req core.BlockUserRequest) core.Response {
+	if req.UserID == user.ID {
+		return core.Error(core.Validation(errors.New("self block")), core.String("you cannot block yourself"))
+	}
+
+	if _, err := s.repository.Users.FindByID(req.UserID); err != nil {
+		return notFoundOr(err, "user not found")
+	}
+
+	wasFriend := false
+	if f, err := s.repository.Friendships.Find(user.ID, req.UserID); err == nil {
+		if f.Status == models.FriendshipBlocked {
+			return core.Success(nil, core.String("user blocked"))
+		}
+		wasFriend = f.Status == models.FriendshipAccepted
+	}
+
+	err := s.repository.Friendships.SQLTransaction(func(repo repository.FriendshipRepo) error {
+		if err := repo.DeleteBetween(user.ID, req.UserID); err != nil {
+			return err
+		}
+		return repo.Create(&models.Friendship{
+			UserID:   user.ID,
+			FriendID: req.UserID,
+			Status:   models.FriendshipBlocked,
+		})
+	})
+	if err != nil {
+		return core.Error(err, core.String("failed to block user"))
+	}
+
+	if wasFriend {
+		s.friendshipChanged(user.ID, req.UserID, -1)
+	}
+
+	return core.Success(nil, core.String("user blocked"))
+}
+
+func (s *UserService) UnblockUser(user *models.User, blockedID int) core.Response {
+	f, err := s.repository.Friendships.Find(user.ID, blockedID)
+	if err != nil || f.Status != models.FriendshipBlocked {
+		return core.Error(core.NotFound(errors.New("block not found")), core.String("user is not blocked"))
+	}
+
+	if err := s.repository.Friendships.Delete(f); err != nil {
+		return core.Error(err, core.String("failed to unblock user"))
+	}
+	return core.Success(nil, core.String("user unblocked"))
+}
+
+// pendingRequest loads a pending request the caller is allowed to act on.
+func (s *UserService) pendingRequest(id int, allowed func(*models.Friendship) bool) (*models.Friendship, *core.Response) {
+	request, err := s.repository.Friendships.FindByID(id)
+	if err != nil {
+		resp := notFoundOr(err, "friend request not found")
+		return nil, &resp
+	}
+
+	if request.Status != models.FriendshipPending || !allowed(request) {
+		resp := core.Error(core.NotFound(errors.New("no actionable pending request")), core.String("friend request not found"))
+		return nil, &resp
+	}
+	return request, nil
+}
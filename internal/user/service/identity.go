@@ -0,0 +1,211 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/core/fieldcrypt"
+	"cashapp/core/webhooks"
+	"cashapp/internal/identity"
+	"cashapp/internal/user/models"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// identitySyncAge is how long a document waits on the provider's webhook
+	// before we ask the provider for its result instead, and how often we ask
+	// again after that.
+	identitySyncAge = 15 * time.Minute
+	// identitySyncWindow is how long we keep asking; sessions left unfinished
+	// that long are abandoned.
+	identitySyncWindow    = 7 * 24 * time.Hour
+	identitySyncBatchSize = 100
+)
+
+// manualDocuments are the document types the identity provider doesn't
+// check; they go straight to the review queue.
+var manualDocuments = map[string]bool{
+	core.DocumentTypeProofOfAddress: true,
+}
+
+// SetIdentityProvider sets the provider documents are verified with.
+func (s *UserService) SetIdentityProvider(p identity.IdentityProvider) {
+	s.identity = p
+}
+
+// InitVerification records a document and, unless it only needs a
+// reviewer, starts a session with the identity provider for the user to
+// complete.
+func (s *UserService) InitVerification(user *models.User, req core.VerifyIdentityRequest) core.Response {
+	upload, resp := s.findSubmittableUpload(user.ID, req.UploadID)
+	if resp != nil {
+		return *resp
+	}
+
+	doc := &models.IdentityDocument{
+		UserID:   user.ID,
+		Type:     req.DocumentType,
+		Status:   models.DocumentInReview,
+		UploadID: &upload.ID,
+	}
+
+	var session *identity.Session
+	if !manualDocuments[doc.Type] {
+		if s.identity == nil {
+			return core.Error(errors.New("identity provider not configured"), nil)
+		}
+		var err error
+		session, err = s.identity.CreateSession(identity.SessionRequest{
+			Reference:    identity.NewReference(),
+			UserID:       user.ID,
+			FullName:     string(user.FullName),
+			DocumentType: doc.Type,
+		})
+		if err != nil {
+			core.Log.Error("failed to start identity session", zap.Int("user_id", user.ID), zap.String("provider", s.identity.Name()), zap.Error(err))
+			return core.Error(core.Upstream(err), core.String("identity verification is unavailable, try again later"))
+		}
+		doc.Status = models.DocumentPending
+		doc.Provider = s.identity.Name()
+		doc.ProviderRef = fieldcrypt.String(session.ProviderRef)
+	}
+
+	if err := s.repository.IdentityDocuments.Create(doc); err != nil {
+		return core.Error(err, core.String("failed to create document record"))
+	}
+	if attached, err := s.repository.Uploads.Attach(upload, doc.ID); err != nil || !attached {
+		core.Log.Error("failed to attach upload", zap.Int("upload_id", upload.ID), zap.Int("document_id", doc.ID), zap.Error(err))
+	}
+	if err := s.refreshKYC(user, "document-"+strconv.Itoa(doc.ID)+"-submitted"); err != nil {
+		core.Log.Error("failed to refresh kyc level", zap.Int("user_id", user.ID), zap.Error(err))
+	}
+
+	if session == nil {
+		return core.Success(&map[string]interface{}{
+			"status":      "pending_review",
+			"document_id": doc.ID,
+		}, nil)
+	}
+
+	return core.Success(&map[string]interface{}{
+		"session_url": session.URL,
+		"status":      "pending_verification",
+		"document_id": doc.ID,
+	}, nil)
+}
+
+// IdentityWebhookSource is how deliveries to the identity webhook are
+// verified. Without a provider every delivery is refused.
+func (s *UserService) IdentityWebhookSource() webhooks.Source {
+	if s.identity == nil {
+		return webhooks.Source{Name: "identity", Verifier: webhooks.Deny{}}
+	}
+	return s.identity.Webhook()
+}
+
+// HandleIdentityWebhook applies the result carried by a verified delivery
+// from the identity provider.
+func (s *UserService) HandleIdentityWebhook(event *webhooks.InboundEvent) core.Response {
+	if s.identity == nil {
+		return core.Error(errors.New("identity provider not configured"), nil)
+	}
+
+	result, err := s.identity.ParseWebhook([]byte(event.Payload))
+	if err != nil {
+		return core.Error(core.Validation(err), core.String("invalid webhook payload"))
+	}
+	if result == nil || result.Outcome == identity.OutcomePending {
+		return core.Success(nil, core.String("ignored"))
+	}
+
+	doc, err := s.repository.IdentityDocuments.FindByProviderRef(result.ProviderRef)
+	if err != nil {
+		return notFoundOr(err, "no document for this session")
+	}
+	return s.applyIdentityResult(doc, result)
+}
+
+// applyIdentityResult records the provider's verdict on a document. A pass
+// verifies it; a failure sends it to the review queue, since most are
+// blurry photos or glare a reviewer can judge better than the provider.
+func (s *UserService) applyIdentityResult(doc *models.IdentityDocument, result *identity.Result) core.Response {
+	user, err := s.repository.Users.FindByID(doc.UserID)
+	if err != nil {
+		return notFoundOr(err, "user not found")
+	}
+
+	doc.ProviderResult = string(result.Outcome)
+	doc.ProviderReason = result.Reason
+	doc.Status = models.DocumentInReview
+	if result.Outcome == identity.OutcomePassed {
+		doc.Status = models.DocumentVerified
+	}
+
+	moved, err := s.repository.IdentityDocuments.Transition(doc, []models.DocumentStatus{models.DocumentPending})
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if !moved {
+		// A redelivery, or a reviewer got there first.
+		return core.Success(&map[string]interface{}{
+			"user_id":    user.ID,
+			"kyc_status": user.KYCStatus,
+		}, core.String("document already processed"))
+	}
+
+	if resp := s.afterDocumentDecision(user, doc); resp != nil {
+		return *resp
+	}
+
+	return core.Success(&map[string]interface{}{
+		"user_id":    user.ID,
+		"kyc_status": user.KYCStatus,
+		"kyc_level":  user.KYCLevel,
+	}, nil)
+}
+
+// RunIdentitySync periodically asks the provider for the result of
+// documents whose webhook hasn't arrived. It blocks; run it in a goroutine.
+func (s *UserService) RunIdentitySync(interval time.Duration) {
+	if s.identity == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.syncIdentityResults()
+	}
+}
+
+func (s *UserService) syncIdentityResults() {
+	now := time.Now()
+	docs, err := s.repository.IdentityDocuments.ListAwaitingProvider(now.Add(-identitySyncWindow), now.Add(-identitySyncAge), identitySyncBatchSize)
+	if err != nil {
+		core.Log.Error("failed to load documents awaiting the identity provider", zap.Error(err))
+		return
+	}
+
+	for i := range docs {
+		doc := &docs[i]
+		if doc.Provider != s.identity.Name() {
+			continue
+		}
+		result, err := s.identity.FetchResult(string(doc.ProviderRef))
+		if err != nil {
+			core.Log.Warn("failed to fetch identity result", zap.Int("document_id", doc.ID), zap.Error(err))
+		}
+		if err != nil || result.Outcome == identity.OutcomePending {
+			if err := s.repository.IdentityDocuments.Touch(doc); err != nil {
+				core.Log.Error("failed to mark document checked", zap.Int("document_id", doc.ID), zap.Error(err))
+			}
+			continue
+		}
+		if resp := s.applyIdentityResult(doc, result); resp.Error {
+			core.Log.Error("failed to apply identity result", zap.Int("document_id", doc.ID), zap.Any("response", resp))
+		}
+	}
+}
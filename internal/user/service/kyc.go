@@ -0,0 +1,353 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/screening"
+	"cashapp/internal/user/models"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kycRiskScores is the least risk score a user has on entering a KYC status.
+// A higher score the risk engine has built up is kept.
+var kycRiskScores = map[models.KYCStatus]int{
+	models.KYCStatusVerified: 10, // Low risk
+	models.KYCStatusReview:   50, // Pending review
+	models.KYCStatusRejected: 90, // High risk
+}
+
+// ListKYCReviews returns the document review queue.
+func (s *UserService) ListKYCReviews(q core.KYCReviewQuery) core.Response {
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	status := models.DocumentInReview
+	if q.Status != "" {
+		status = models.DocumentStatus(q.Status)
+	}
+
+	limit := core.PageSize(q.Limit)
+	docs, err := s.repository.IdentityDocuments.ListByStatus(status, cursor.BeforeID, limit+1)
+	if err != nil {
+		return core.Error(err, core.String("failed to load documents"))
+	}
+
+	pagination := &core.Pagination{}
+	if len(docs) > limit {
+		docs = docs[:limit]
+		pagination.NextCursor = core.Cursor{BeforeID: docs[limit-1].ID}.Encode()
+	}
+	pagination.Count = int64(len(docs))
+
+	return core.Paginated(&map[string]interface{}{
+		"documents": docs,
+	}, pagination, nil)
+}
+
+// ReviewDocument records a reviewer's decision on a document still waiting
+// on the provider or a reviewer, and works out the user's level again.
+func (s *UserService) ReviewDocument(docID int, reviewer string, req core.KYCDocumentDecision) core.Response {
+	doc, err := s.repository.IdentityDocuments.FindByID(docID)
+	if err != nil {
+		return notFoundOr(err, "document not found")
+	}
+	if !doc.Open() {
+		return core.Error(core.Conflict(errors.New("document already reviewed")), core.String("this document was already "+string(doc.Status)))
+	}
+
+	now := time.Now()
+	doc.ReviewedBy = reviewer
+	doc.ReviewedAt = &now
+	if req.Decision == "approve" {
+		doc.Status = models.DocumentVerified
+	} else {
+		doc.Status = models.DocumentRejected
+		doc.RejectionReason = req.Reason
+	}
+
+	moved, err := s.repository.IdentityDocuments.Transition(doc, []models.DocumentStatus{models.DocumentPending, models.DocumentInReview})
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if !moved {
+		return core.Error(core.Conflict(errors.New("document already reviewed")), core.String("this document was already reviewed"))
+	}
+
+	user, err := s.repository.Users.FindByID(doc.UserID)
+	if err != nil {
+		return notFoundOr(err, "user not found")
+	}
+	if resp := s.afterDocumentDecision(user, doc); resp != nil {
+		return *resp
+	}
+
+	return core.Success(&map[string]interface{}{
+		"document":   doc,
+		"kyc_status": user.KYCStatus,
+		"kyc_level":  user.KYCLevel,
+	}, nil)
+}
+
+// afterDocumentDecision tells the user about a rejected document, screens
+// them once their government ID is verified, starts the clock on deleting
+// the file, and works out their level again.
+func (s *UserService) afterDocumentDecision(user *models.User, doc *models.IdentityDocument) *core.Response {
+	cause := "document-" + strconv.Itoa(doc.ID) + "-" + string(doc.Status)
+
+	switch doc.Status {
+	case models.DocumentRejected:
+		s.notifier.Notify(user.ID, core.EventKYCDocumentRejected, map[string]interface{}{
+			"document":  strings.ReplaceAll(doc.Type, "_", " "),
+			"reason":    doc.RejectionReason,
+			"dedup_key": cause,
+		})
+	case models.DocumentVerified:
+		if satisfies(models.RequirementGovernmentID, doc.Type) {
+			if _, err := s.screenUser(user, screening.TriggerKYC); err != nil {
+				resp := core.Error(err, core.String("failed to screen user"))
+				return &resp
+			}
+		}
+	}
+	s.schedulePurge(doc)
+
+	if err := s.refreshKYC(user, cause); err != nil {
+		resp := core.Error(err, core.String("failed to update kyc level"))
+		return &resp
+	}
+	return nil
+}
+
+// GetKYCStatus shows the caller where they stand on each level's
+// requirements and what they still need for the next one.
+func (s *UserService) GetKYCStatus(user *models.User) core.Response {
+	reqs, err := s.kycRequirements(user)
+	if err != nil {
+		return core.Error(err, core.String("failed to load kyc requirements"))
+	}
+
+	type tier struct {
+		Level        int                     `json:"level"`
+		Met          bool                    `json:"met"`
+		Requirements []models.KYCRequirement `json:"requirements"`
+	}
+	tiers := make([]tier, 0, len(models.KYCTiers))
+	for _, t := range models.KYCTiers {
+		current := tier{Level: t.Level, Met: true}
+		for _, r := range reqs {
+			if r.Level != t.Level {
+				continue
+			}
+			current.Requirements = append(current.Requirements, r)
+			if r.Status != models.RequirementSatisfied {
+				current.Met = false
+			}
+		}
+		tiers = append(tiers, current)
+	}
+
+	data := map[string]interface{}{
+		"kyc_level":  user.KYCLevel,
+		"kyc_status": user.KYCStatus,
+		"tiers":      tiers,
+	}
+	for _, t := range tiers {
+		if t.Level <= user.KYCLevel {
+			continue
+		}
+		missing := []models.KYCRequirement{}
+		for _, r := range t.Requirements {
+			if r.Status != models.RequirementSatisfied {
+				missing = append(missing, r)
+			}
+		}
+		data["next_level"] = t.Level
+		data["missing"] = missing
+		break
+	}
+
+	return core.Success(&data, nil)
+}
+
+// refreshKYC works out a user's requirements and KYC level from their
+// profile, documents and screening hits, saving and announcing any change.
+// Pending screening hits hold a user in review at level 1 at most; a
+// confirmed one rejects them outright.
+func (s *UserService) refreshKYC(user *models.User, cause string) error {
+	reqs, err := s.kycRequirements(user)
+	if err != nil {
+		return err
+	}
+	if err := s.repository.KYCRequirements.Save(reqs); err != nil {
+		return err
+	}
+
+	level := kycLevel(reqs)
+	status := models.KYCStatusPending
+	switch {
+	case level > 0:
+		status = models.KYCStatusVerified
+	case anyRequirement(reqs, models.RequirementRejected) && !anyRequirement(reqs, models.RequirementPending):
+		status = models.KYCStatusRejected
+	}
+
+	riskScore := kycRiskScores[status]
+	if s.screener != nil {
+		confirmed, err := s.screener.ConfirmedHits(user.ID)
+		if err != nil {
+			return err
+		}
+		pending, err := s.screener.PendingHits(user.ID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case confirmed > 0:
+			level, status, riskScore = 0, models.KYCStatusRejected, 100 // Critical risk
+		case pending > 0:
+			level, status, riskScore = min(level, 1), models.KYCStatusReview, kycRiskScores[models.KYCStatusReview]
+		}
+	}
+
+	if level == user.KYCLevel && status == user.KYCStatus {
+		return nil
+	}
+	if status != user.KYCStatus && status != models.KYCStatusPending {
+		if err := s.repository.Users.RaiseRiskScore(user.ID, riskScore); err != nil {
+			return err
+		}
+		user.RiskScore = max(user.RiskScore, riskScore)
+	}
+	user.KYCLevel = level
+	user.KYCStatus = status
+	if err := s.repository.Users.Update(user); err != nil {
+		return err
+	}
+
+	s.notifier.Notify(user.ID, core.EventKYCResult, map[string]interface{}{
+		"status":    user.KYCStatus,
+		"kyc_level": user.KYCLevel,
+		"dedup_key": "kyc-" + cause,
+	})
+	return nil
+}
+
+// kycRequirements works out where a user stands on every requirement, in
+// tier order.
+func (s *UserService) kycRequirements(user *models.User) ([]models.KYCRequirement, error) {
+	docs, err := s.repository.IdentityDocuments.FindByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.repository.KYCRequirements.FindByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	satisfiedAt := map[string]*time.Time{}
+	for _, r := range existing {
+		satisfiedAt[r.Name] = r.SatisfiedAt
+	}
+
+	now := time.Now()
+	var reqs []models.KYCRequirement
+	for _, t := range models.KYCTiers {
+		for _, name := range t.Requirements {
+			r := models.KYCRequirement{UserID: user.ID, Name: name, Level: t.Level, Status: models.RequirementMissing}
+			switch name {
+			case models.RequirementPhone:
+				if user.PhoneVerifiedAt != nil {
+					r.Status = models.RequirementSatisfied
+				}
+			case models.RequirementFullName:
+				if user.FullName != "" {
+					r.Status = models.RequirementSatisfied
+				}
+			case models.RequirementDateOfBirth:
+				if user.DateOfBirth != "" {
+					r.Status = models.RequirementSatisfied
+				}
+			default:
+				documentRequirement(&r, docs)
+			}
+
+			if r.Status == models.RequirementSatisfied {
+				r.SatisfiedAt = satisfiedAt[name]
+				if r.SatisfiedAt == nil {
+					r.SatisfiedAt = &now
+				}
+			}
+			reqs = append(reqs, r)
+		}
+	}
+	return reqs, nil
+}
+
+// documentRequirement settles a requirement met by documents: any verified
+// document satisfies it; otherwise the latest open or rejected one decides.
+func documentRequirement(r *models.KYCRequirement, docs []models.IdentityDocument) {
+	var open, rejected *models.IdentityDocument
+	for i := range docs {
+		d := &docs[i]
+		if !satisfies(r.Name, d.Type) {
+			continue
+		}
+		switch {
+		case d.Status == models.DocumentVerified:
+			r.Status = models.RequirementSatisfied
+			r.DocumentID = &d.ID
+			return
+		case d.Open():
+			open = d
+		case d.Status == models.DocumentRejected:
+			rejected = d
+		}
+	}
+
+	switch {
+	case open != nil:
+		r.Status = models.RequirementPending
+		r.DocumentID = &open.ID
+	case rejected != nil:
+		r.Status = models.RequirementRejected
+		r.DocumentID = &rejected.ID
+		r.Reason = rejected.RejectionReason
+	}
+}
+
+// satisfies reports whether a document type meets a requirement.
+func satisfies(requirement, docType string) bool {
+	for _, t := range models.RequirementDocuments[requirement] {
+		if t == docType {
+			return true
+		}
+	}
+	return false
+}
+
+// kycLevel is the highest level whose requirements, and those of every level
+// below it, are all satisfied.
+func kycLevel(reqs []models.KYCRequirement) int {
+	level := 0
+	for _, t := range models.KYCTiers {
+		for _, r := range reqs {
+			if r.Level == t.Level && r.Status != models.RequirementSatisfied {
+				return level
+			}
+		}
+		level = t.Level
+	}
+	return level
+}
+
+func anyRequirement(reqs []models.KYCRequirement, status models.RequirementStatus) bool {
+	for _, r := range reqs {
+		if r.Status == status {
+			return true
+		}
+	}
+	return false
+}
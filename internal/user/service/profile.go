@@ -0,0 +1,265 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/core/fieldcrypt"
+	"cashapp/internal/screening"
+	"cashapp/internal/user/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	codeLength         = 6
+	codeTTL            = 10 * time.Minute
+	codeMaxAttempts    = 5
+	codeResendInterval = time.Minute
+)
+
+// GetProfile returns the caller's own profile, including the legal name,
+// date of birth and verified contacts which are never shown to other users.
+func (s *UserService) GetProfile(user *models.User) core.Response {
+	return core.Success(&map[string]interface{}{
+		"user":          user,
+		"full_name":     user.FullName,
+		"date_of_birth": user.DateOfBirth,
+		"contacts":      contactsOf(user),
+	}, nil)
+}
+
+func (s *UserService) UpdateProfile(user *models.User, req core.UpdateProfileRequest) core.Response {
+	name, dob := user.FullName, user.DateOfBirth
+	if req.FullName != nil {
+		user.FullName = fieldcrypt.String(strings.TrimSpace(*req.FullName))
+	}
+	if req.AvatarURL != nil {
+		user.AvatarURL = strings.TrimSpace(*req.AvatarURL)
+	}
+	if req.DateOfBirth != nil {
+		user.DateOfBirth = fieldcrypt.String(*req.DateOfBirth)
+	}
+
+	if err := s.repository.Users.Update(user); err != nil {
+		return core.Error(err, core.String("failed to update profile"))
+	}
+
+	if user.FullName != name || user.DateOfBirth != dob {
+		s.screenAndLog(user, screening.TriggerProfile)
+		if err := s.refreshKYC(user, "profile-"+strconv.FormatInt(user.UpdatedAt.UnixNano(), 10)); err != nil {
+			core.Log.Error("failed to refresh kyc level", zap.Int("user_id", user.ID), zap.Error(err))
+		}
+	}
+
+	return core.Success(&map[string]interface{}{
+		"user":          user,
+		"full_name":     user.FullName,
+		"date_of_birth": user.DateOfBirth,
+	}, core.String("profile updated"))
+}
+
+// StartContactVerification sends a one-time code to an email address or
+// phone number. The contact is only attached to the user once confirmed.
+func (s *UserService) StartContactVerification(user *models.User, req core.StartVerificationRequest) core.Response {
+	channel := models.ContactChannel(req.Channel)
+	target, err := normalizeContact(channel, req.Target)
+	if err != nil {
+		return core.Error(core.Validation(err), core.String(err.Error()))
+	}
+
+	if owner, err := s.repository.Users.FindByContact(channel, target); err == nil {
+		if owner.ID == user.ID {
+			return core.Error(core.Conflict(errors.New("already verified")), core.String(fmt.Sprintf("this %s is already verified", channel)))
+		}
+		return core.Error(core.Conflict(errors.New("contact in use")), core.String(fmt.Sprintf("this %s belongs to another account", channel)))
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.Error(err, nil)
+	}
+
+	if last, err := s.repository.Verifications.FindLatest(user.ID, channel); err == nil {
+		if wait := time.Until(last.CreatedAt.Add(codeResendInterval)); wait > 0 {
+			return core.Error(core.RateLimited(errors.New("code resend too soon")), core.String(fmt.Sprintf("wait %d seconds before requesting another code", int(wait.Seconds())+1)))
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.Error(err, nil)
+	}
+
+	code, salt, hash, err := core.NewOTP(codeLength)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+
+	verification := models.VerificationCode{
+		UserID:    user.ID,
+		Channel:   channel,
+		Target:    fieldcrypt.String(target),
+		Salt:      salt,
+		CodeHash:  hash,
+		ExpiresAt: time.Now().Add(codeTTL),
+	}
+	if err := s.repository.Verifications.Create(&verification); err != nil {
+		return core.Error(err, core.String("failed to start verification"))
+	}
+
+	body := fmt.Sprintf("Your CashApp verification code is %s. It expires in %d minutes.", code, int(codeTTL.Minutes()))
+	if err := s.sender.Send(string(channel), target, body); err != nil {
+		core.Log.Error("failed to send verification code", zap.Int("user_id", user.ID), zap.String("channel", string(channel)), zap.Error(err))
+		return core.Error(core.Upstream(err), core.String("failed to send verification code"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"channel":    channel,
+		"target":     target,
+		"expires_at": verification.ExpiresAt,
+	}, core.String("verification code sent"))
+}
+
+// ConfirmContactVerification checks a code and, if it matches, attaches the
+// contact to the user as verified.
+func (s *UserService) ConfirmContactVerification(user *models.User, req core.ConfirmVerificationRequest) core.Response {
+	channel := models.ContactChannel(req.Channel)
+	verification, err := s.repository.Verifications.FindLatest(user.ID, channel)
+	if err != nil {
+		return notFoundOr(err, "no pending verification")
+	}
+
+	if time.Now().After(verification.ExpiresAt) {
+		return core.Error(core.Validation(errors.New("code expired")), core.String("verification code has expired, request a new one"))
+	}
+	claimed, err := s.repository.Verifications.ClaimAttempt(verification.ID, codeMaxAttempts)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if !claimed {
+		return core.Error(core.RateLimited(errors.New("too many attempts")), core.String("too many incorrect attempts, request a new code"))
+	}
+	verification.Attempts++
+
+	if !core.CheckOTP(verification.Salt, verification.CodeHash, req.Code) {
+		return core.Error(core.Validation(errors.New("code mismatch")), core.String(fmt.Sprintf("incorrect code, %d attempt(s) left", max(0, codeMaxAttempts-verification.Attempts))))
+	}
+
+	if owner, err := s.repository.Users.FindByContact(channel, string(verification.Target)); err == nil && owner.ID != user.ID {
+		return core.Error(core.Conflict(errors.New("contact in use")), core.String(fmt.Sprintf("this %s belongs to another account", channel)))
+	}
+
+	now := time.Now()
+	consumed, err := s.repository.Verifications.Consume(verification.ID, now)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if !consumed {
+		return core.Error(core.Conflict(errors.New("code already used")), core.String("this code has already been used"))
+	}
+
+	target := verification.Target
+	switch channel {
+	case models.ContactEmail:
+		user.Email, user.EmailVerifiedAt = &target, &now
+	case models.ContactPhone:
+		user.Phone, user.PhoneVerifiedAt = &target, &now
+	}
+	if err := s.repository.Users.Update(user); err != nil {
+		return core.Error(err, core.String(fmt.Sprintf("failed to save verified %s", channel)))
+	}
+	if channel == models.ContactPhone {
+		if err := s.refreshKYC(user, "verification-"+strconv.Itoa(verification.ID)); err != nil {
+			core.Log.Error("failed to refresh kyc level", zap.Int("user_id", user.ID), zap.Error(err))
+		}
+	}
+
+	return core.Success(&map[string]interface{}{
+		"contacts": contactsOf(user),
+	}, core.String(fmt.Sprintf("%s verified", channel)))
+}
+
+// LookupContacts matches address book entries against verified emails and
+// phone numbers. Users hidden from the directory are only matched by their
+// friends, and blocked users never are.
+func (s *UserService) LookupContacts(user *models.User, req core.ContactLookupRequest) core.Response {
+	emails := make([]string, 0, len(req.Emails))
+	for _, e := range req.Emails {
+		if email, err := core.NormalizeEmail(e); err == nil {
+			emails = append(emails, email)
+		}
+	}
+	phones := make([]string, 0, len(req.Phones))
+	for _, p := range req.Phones {
+		if phone, err := core.NormalizePhone(p); err == nil {
+			phones = append(phones, phone)
+		}
+	}
+
+	users, err := s.repository.Users.FindByContacts(emails, phones)
+	if err != nil {
+		return core.Error(err, core.String("failed to look up contacts"))
+	}
+
+	matches := make([]map[string]interface{}, 0, len(users))
+	for _, u := range users {
+		if u.ID == user.ID {
+			continue
+		}
+		blocked, err := s.repository.Friendships.IsBlocked(user.ID, u.ID)
+		if err != nil {
+			return core.Error(err, nil)
+		}
+		if blocked {
+			continue
+		}
+		f, err := s.repository.Friendships.Find(user.ID, u.ID)
+		isFriend := err == nil && f.Status == models.FriendshipAccepted
+		if !u.Discoverable && !isFriend {
+			continue
+		}
+
+		match := map[string]interface{}{
+			"id":           u.ID,
+			"tag":          u.Tag,
+			"display_name": u.DisplayName,
+			"avatar_url":   u.AvatarURL,
+			"is_friend":    isFriend,
+		}
+		if u.Email != nil && contains(emails, string(*u.Email)) {
+			match["email"] = *u.Email
+		}
+		if u.Phone != nil && contains(phones, string(*u.Phone)) {
+			match["phone"] = *u.Phone
+		}
+		matches = append(matches, match)
+	}
+
+	return core.Success(&map[string]interface{}{
+		"matches": matches,
+	}, nil)
+}
+
+func contactsOf(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"email":             user.Email,
+		"email_verified_at": user.EmailVerifiedAt,
+		"phone":             user.Phone,
+		"phone_verified_at": user.PhoneVerifiedAt,
+	}
+}
+
+func normalizeContact(channel models.ContactChannel, value string) (string, error) {
+	if channel == models.ContactPhone {
+		return core.NormalizePhone(value)
+	}
+	return core.NormalizeEmail(value)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/screening"
+	"cashapp/internal/user/models"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const rescreenBatchSize = 500
+
+// SetScreener enables sanctions screening of users.
+func (s *UserService) SetScreener(sc *screening.Screener) {
+	s.screener = sc
+}
+
+// screenUser screens a user's names and date of birth and reports whether
+// they have hits waiting for review. Hits are never acted on automatically.
+func (s *UserService) screenUser(user *models.User, trigger string) (bool, error) {
+	if s.screener == nil {
+		return false, nil
+	}
+
+	subject := screening.Subject{UserID: user.ID, DateOfBirth: string(user.DateOfBirth)}
+	for _, name := range []string{string(user.FullName), user.DisplayName} {
+		if name != "" {
+			subject.Names = append(subject.Names, name)
+		}
+	}
+	if len(subject.Names) == 0 {
+		return false, nil
+	}
+
+	hits, err := s.screener.Screen(subject, trigger)
+	if err != nil {
+		return false, err
+	}
+	if len(hits) > 0 {
+		core.Log.Warn("screening hits queued for review", zap.Int("user_id", user.ID), zap.String("trigger", trigger), zap.Int("hits", len(hits)))
+	}
+
+	pending, err := s.screener.PendingHits(user.ID)
+	return pending > 0, err
+}
+
+// screenAndLog screens a user without failing the request that changed
+// them; a failure is logged and the next re-screen picks them up.
+func (s *UserService) screenAndLog(user *models.User, trigger string) {
+	if _, err := s.screenUser(user, trigger); err != nil {
+		core.Log.Error("failed to screen user", zap.Int("user_id", user.ID), zap.String("trigger", trigger), zap.Error(err))
+	}
+}
+
+// RunRescreens periodically reloads the watchlists and screens every user
+// again whenever their content changes. It blocks; run it in a goroutine.
+func (s *UserService) RunRescreens(interval time.Duration) {
+	if s.screener == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.rescreen()
+		<-ticker.C
+	}
+}
+
+func (s *UserService) rescreen() {
+	if _, err := s.screener.Reload(); err != nil {
+		core.Log.Error("failed to reload watchlists", zap.Error(err))
+	}
+
+	needed, err := s.screener.NeedsRescreen()
+	if err != nil {
+		core.Log.Error("failed to check watchlist version", zap.Error(err))
+		return
+	}
+	if !needed {
+		return
+	}
+
+	afterID := 0
+	for {
+		users, err := s.repository.Users.ListAfter(afterID, rescreenBatchSize)
+		if err != nil {
+			core.Log.Error("failed to load users to re-screen", zap.Error(err))
+			return
+		}
+		for i := range users {
+			if _, err := s.screenUser(&users[i], screening.TriggerRescreen); err != nil {
+				// Stop so the version isn't marked screened; the next run
+				// starts over.
+				core.Log.Error("failed to re-screen user", zap.Int("user_id", users[i].ID), zap.Error(err))
+				return
+			}
+		}
+		if len(users) < rescreenBatchSize {
+			break
+		}
+		afterID = users[len(users)-1].ID
+	}
+
+	if err := s.screener.MarkScreened(); err != nil {
+		core.Log.Error("failed to record re-screen", zap.Error(err))
+	}
+}
+
+// ListScreeningHits returns the screening review queue.
+func (s *UserService) ListScreeningHits(q core.ScreeningHitQuery) core.Response {
+	if s.screener == nil {
+		return core.Error(errors.New("screening not configured"), nil)
+	}
+
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	limit := core.PageSize(q.Limit)
+	hits, err := s.screener.ListHits(screening.HitStatus(q.Status), cursor.BeforeID, limit+1)
+	if err != nil {
+		return core.Error(err, core.String("failed to load screening hits"))
+	}
+
+	pagination := &core.Pagination{}
+	if len(hits) > limit {
+		hits = hits[:limit]
+		pagination.NextCursor = core.Cursor{BeforeID: hits[limit-1].ID}.Encode()
+	}
+	pagination.Count = int64(len(hits))
+
+	return core.Paginated(&map[string]interface{}{
+		"hits": hits,
+	}, pagination, nil)
+}
+
+// ResolveScreeningHit records a reviewer's decision. A confirmed hit rejects
+// the user; once a user in review has no pending hits left, the level their
+// requirements earn takes effect (see refreshKYC).
+func (s *UserService) ResolveScreeningHit(hitID int, reviewer string, req core.ScreeningDecisionRequest) core.Response {
+	if s.screener == nil {
+		return core.Error(errors.New("screening not configured"), nil)
+	}
+
+	hit, err := s.screener.FindHit(hitID)
+	if err != nil {
+		return notFoundOr(err, "screening hit not found")
+	}
+
+	status := screening.HitDismissed
+	if req.Decision == "confirm" {
+		status = screening.HitConfirmed
+	}
+	resolved, err := s.screener.Resolve(hit, status, req.Note, reviewer)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if !resolved {
+		return core.Error(core.Conflict(errors.New("hit already resolved")), core.String("this hit was already reviewed"))
+	}
+
+	user, err := s.repository.Users.FindByID(hit.UserID)
+	if err != nil {
+		return notFoundOr(err, "user not found")
+	}
+	if err := s.refreshKYC(user, "screening-"+strconv.Itoa(hit.ID)); err != nil {
+		return core.Error(err, core.String("failed to update kyc level"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"hit":        hit,
+		"kyc_status": user.KYCStatus,
+	}, nil)
+}
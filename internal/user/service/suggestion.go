@@ -0,0 +1,90 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/user/models"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const suggestionSyncBatch = 500
+
+func (s *UserService) ListFriendSuggestions(user *models.User, q core.SuggestionQuery) core.Response {
+	limit := q.Limit
+	if limit == 0 {
+		limit = 20
+	}
+
+	suggestions, err := s.repository.Suggestions.List(user.ID, limit)
+	if err != nil {
+		return core.Error(err, core.String("failed to load suggestions"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"suggestions": suggestions,
+	}, nil)
+}
+
+// DismissFriendSuggestion stops candidateID from being suggested to the user.
+func (s *UserService) DismissFriendSuggestion(user *models.User, candidateID int) core.Response {
+	if candidateID == user.ID {
+		return core.Error(core.Validation(errors.New("self suggestion")), core.String("invalid suggestion"))
+	}
+
+	if err := s.repository.Suggestions.Dismiss(user.ID, candidateID); err != nil {
+		return core.Error(err, core.String("failed to dismiss suggestion"))
+	}
+	return core.Success(nil, core.String("suggestion dismissed"))
+}
+
+// friendshipChanged updates mutual friend counts after a and b became (delta
+// +1) or stopped being (delta -1) friends: each of a's friends gains or loses
+// a mutual friend with b, and vice versa. Failures are logged rather than
+// surfaced since suggestions are best effort.
+func (s *UserService) friendshipChanged(a, b, delta int) {
+	for _, pair := range [][2]int{{a, b}, {b, a}} {
+		friends, err := s.repository.Friendships.ListOutgoing(pair[0], models.FriendshipAccepted)
+		if err != nil {
+			core.Log.Error("failed to load friends for suggestions", zap.Error(err))
+			return
+		}
+
+		ids := make([]int, 0, len(friends))
+		for _, f := range friends {
+			if f.FriendID != pair[1] {
+				ids = append(ids, f.FriendID)
+			}
+		}
+
+		if err := s.repository.Suggestions.AddMutual(pair[1], ids, delta); err != nil {
+			core.Log.Error("failed to update mutual friend counts", zap.Error(err))
+		}
+	}
+}
+
+// RunSuggestionSync keeps payment-based suggestion signals up to date by
+// folding in new ledger transfers every interval. It never returns.
+func (s *UserService) RunSuggestionSync(interval time.Duration) {
+	if err := s.repository.Suggestions.BackfillMutuals(); err != nil {
+		core.Log.Error("failed to backfill mutual friends", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := s.repository.Suggestions.SyncTransactions(suggestionSyncBatch)
+			if err != nil {
+				core.Log.Error("failed to sync transactions into suggestions", zap.Error(err))
+				break
+			}
+			if n < suggestionSyncBatch {
+				break
+			}
+		}
+		<-ticker.C
+	}
+}
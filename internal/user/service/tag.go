@@ -0,0 +1,109 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/core/database"
+	"cashapp/internal/user/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// tagChangeCooldown is the minimum time between two tag changes.
+	tagChangeCooldown = 30 * 24 * time.Hour
+	// tagHoldPeriod is how long an old tag keeps resolving to its previous
+	// owner before anyone else can claim it.
+	tagHoldPeriod = 60 * 24 * time.Hour
+)
+
+// ChangeTag moves the user to a new cash tag. The old tag is held for the
+// user so payments and links using it keep working for a while. Users whose
+// legacy tag collided with another skip the cooldown.
+func (s *UserService) ChangeTag(user *models.User, req core.ChangeTagRequest) core.Response {
+	normalized := core.NormalizeTag(req.Tag)
+	if user.NormalizedTag != nil && *user.NormalizedTag == normalized {
+		if user.Tag == core.DisplayTag(req.Tag) {
+			return core.Error(core.Validation(errors.New("same tag")), core.String("this is already your cash tag"))
+		}
+		// Only the casing changes; nothing to hold.
+		user.Tag = core.DisplayTag(req.Tag)
+		if err := s.repository.Users.Update(user); err != nil {
+			return core.Error(err, core.String("failed to change cash tag"))
+		}
+		return core.Success(&map[string]interface{}{"user": user}, core.String("cash tag updated"))
+	}
+
+	if user.TagChangedAt != nil && !user.TagRenameNeeded {
+		if next := user.TagChangedAt.Add(tagChangeCooldown); time.Now().Before(next) {
+			return core.Error(
+				core.RateLimited(errors.New("tag change cooldown")),
+				core.String(fmt.Sprintf("you can change your cash tag again after %s", next.Format(time.RFC3339))),
+			)
+		}
+	}
+
+	if resp := s.checkTagAvailable(user.ID, normalized); resp != nil {
+		return *resp
+	}
+
+	now := time.Now()
+	change := models.TagChange{
+		UserID:    user.ID,
+		OldTag:    user.Tag,
+		NewTag:    core.DisplayTag(req.Tag),
+		HeldUntil: now.Add(tagHoldPeriod),
+	}
+	if user.NormalizedTag != nil {
+		change.NormalizedOldTag = *user.NormalizedTag
+	}
+
+	user.Tag = change.NewTag
+	user.NormalizedTag = &normalized
+	user.TagChangedAt = &now
+	user.TagRenameNeeded = false
+	if err := s.repository.Users.ChangeTag(user, &change); err != nil {
+		if database.IsUniqueViolation(err) {
+			return core.Error(core.Conflict(err), core.String("cash tag has already been taken"))
+		}
+		return core.Error(err, core.String("failed to change cash tag"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"user":   user,
+		"change": change,
+	}, core.String("cash tag updated"))
+}
+
+func (s *UserService) ListTagHistory(user *models.User) core.Response {
+	changes, err := s.repository.Users.TagHistory(user.ID)
+	if err != nil {
+		return core.Error(err, core.String("failed to load cash tag history"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"changes": changes,
+	}, nil)
+}
+
+// checkTagAvailable rejects reserved tags and tags owned or held by anyone
+// other than userID. Pass 0 for a new account.
+func (s *UserService) checkTagAvailable(userID int, normalized string) *core.Response {
+	if core.IsReservedTag(normalized) {
+		resp := core.Error(core.Validation(errors.New("reserved tag")), core.String("this cash tag is not available"))
+		return &resp
+	}
+
+	owner, err := s.repository.Users.FindByTag(normalized)
+	switch {
+	case err == nil && owner.ID != userID:
+		resp := core.Error(core.Conflict(errors.New("cash tag taken")), core.String("cash tag has already been taken"))
+		return &resp
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		resp := core.Error(err, nil)
+		return &resp
+	}
+	return nil
+}
@@ -2,6 +2,13 @@ package service
 
 import (
 	"cashapp/core"
+	"cashapp/core/database"
+	"cashapp/core/fieldcrypt"
+	"cashapp/internal/identity"
+	"cashapp/internal/limits"
+	"cashapp/internal/risk"
+	"cashapp/internal/screening"
+	"cashapp/internal/storage"
 	"cashapp/internal/user/models"
 	"cashapp/internal/user/repository"
 	"errors"
@@ -15,34 +22,60 @@ import (
 type UserService struct {
 	repository repository.Repo
 	config     *core.Config
+	sender     core.MessageSender
+	notifier   core.Notifier
+	limits     *limits.Engine
+	risk       *risk.Engine
+	screener   *screening.Screener
+	identity   identity.IdentityProvider
+	blobs      storage.BlobStore
+	links      *storage.LinkSigner
 }
 
 func New(r repository.Repo, c *core.Config) *UserService {
 	return &UserService{
 		repository: r,
 		config:     c,
+		sender:     core.NewMessageSender(c),
+		notifier:   core.LogNotifier{},
 	}
 }
 
-func (s *UserService) CreateUser(req core.CreateUserRequest) core.Response {
-	user, err := s.repository.Users.FindByTag(req.Tag)
+// SetLimits enables KYC-tiered deposit limits.
+func (s *UserService) SetLimits(l *limits.Engine) {
+	s.limits = l
+}
 
-	if err == nil {
-		return core.Error(errors.New("cash tag taken"), core.String("cash tag has already been taken"))
-	}
+// SetRisk enables risk checks on deposits.
+func (s *UserService) SetRisk(r *risk.Engine) {
+	s.risk = r
+}
 
-	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return core.Error(err, nil)
+// SetNotifier replaces the notifier used for user-facing notifications.
+func (s *UserService) SetNotifier(n core.Notifier) {
+	s.notifier = n
+}
+
+func (s *UserService) CreateUser(req core.CreateUserRequest) core.Response {
+	normalized := core.NormalizeTag(req.Tag)
+	if resp := s.checkTagAvailable(0, normalized); resp != nil {
+		return *resp
 	}
 
-	user = &models.User{
-		Tag:       req.Tag,
-		KYCLevel:  0,
-		KYCStatus: models.KYCStatusPending,
-		RiskScore: 0,
+	user := &models.User{
+		Tag:           core.DisplayTag(req.Tag),
+		NormalizedTag: &normalized,
+		DisplayName:   strings.TrimSpace(req.DisplayName),
+		Discoverable:  true,
+		KYCLevel:      0,
+		KYCStatus:     models.KYCStatusPending,
+		RiskScore:     0,
 	}
 
 	if err := s.repository.Users.Create(user); err != nil {
+		if database.IsUniqueViolation(err) {
+			return core.Error(core.Conflict(err), core.String("cash tag has already been taken"))
+		}
 		return core.Error(err, nil)
 	}
 
@@ -52,6 +85,8 @@ func (s *UserService) CreateUser(req core.CreateUserRequest) core.Response {
 	}
 
 	user.Wallets = append(user.Wallets, *wallet)
+	s.screenAndLog(user, screening.TriggerOnboarding)
+
 	return core.Success(&map[string]interface{}{
 		"user": user,
 	}, core.String("user created successfully"))
@@ -60,10 +95,7 @@ func (s *UserService) CreateUser(req core.CreateUserRequest) core.Response {
 func (s *UserService) GetUser(tag string) core.Response {
 	user, err := s.repository.Users.FindByTag(tag)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return core.Error(err, core.String("user not found"))
-		}
-		return core.Error(err, nil)
+		return notFoundOr(err, "user not found")
 	}
 
 	// Fetch primary wallet
@@ -72,85 +104,44 @@ func (s *UserService) GetUser(tag string) core.Response {
 		user.Wallets = append(user.Wallets, *wallet)
 	}
 
-	return core.Success(&map[string]interface{}{
+	data := map[string]interface{}{
 		"user": user,
-	}, nil)
-}
-
-func (s *UserService) InitVerification(req core.VerifyIdentityRequest) core.Response {
-	user, err := s.repository.Users.FindByID(req.UserID)
-	if err != nil {
-		return core.Error(err, core.String("user not found"))
 	}
-
-	// Store document metadata
-	doc := &models.IdentityDocument{
-		UserID: user.ID,
-		Type:   req.DocumentType,
-		URL:    req.DocumentURL,
-		Status: "pending",
-	}
-
-	if err := s.repository.IdentityDocuments.Create(doc); err != nil {
-		return core.Error(err, core.String("failed to create document record"))
+	if user.NormalizedTag != nil && *user.NormalizedTag != core.NormalizeTag(tag) {
+		// Found through a tag the user has since changed.
+		data["previous_tag"] = core.DisplayTag(tag)
 	}
 
-	// In real world, call Stripe Identity / Onfido API here
-	// For MVP, we simulate a "pending" state and return a fake session URL
-	fmt.Printf("Mock: Initializing verification for user %d with doc %s\n", user.ID, req.DocumentType)
-
-	return core.Success(&map[string]interface{}{
-		"session_url": fmt.Sprintf("https://verify.mockprovider.com?user=%d", user.ID),
-		"status":      "pending_verification",
-		"document_id": doc.ID,
-	}, nil)
+	return core.Success(&data, nil)
 }
 
-func (s *UserService) HandleIdentityWebhook(req core.IdentityWebhookRequest) core.Response {
-	user, err := s.repository.Users.FindByID(req.UserID)
-	if err != nil {
-		return core.Error(err, core.String("user not found"))
-	}
-
-	// If DocumentID is provided, update the specific document
-	if req.DocumentID != 0 {
-		doc, err := s.repository.IdentityDocuments.FindByID(req.DocumentID)
-		if err == nil {
-			if strings.EqualFold(req.Status, "passed") {
-				doc.Status = "verified"
-			} else {
-				doc.Status = "rejected"
-			}
-			s.repository.IdentityDocuments.Update(doc)
-		}
+// Authenticate resolves the caller's cash tag to a user.
+func (s *UserService) Authenticate(tag string) (*models.User, error) {
+	if tag == "" {
+		return nil, core.Unauthorized(errors.New("missing X-User-Tag header"))
 	}
 
-	if strings.EqualFold(req.Status, "passed") {
-		// Mock Sanctions Screening (AML)
-		// In a real system, we'd check against OFAC/SDN lists here.
-		// For simulation, let's say if the user tag contains "sanction", we flag them.
-		if strings.Contains(strings.ToLower(user.Tag), "sanction") {
-			user.KYCStatus = models.KYCStatusRejected
-			user.RiskScore = 100 // Critical risk
-			core.Log.Warn("User flagged during Sanctions Screening", zap.String("tag", user.Tag))
-		} else {
-			user.KYCStatus = models.KYCStatusVerified
-			user.KYCLevel = 2   // Full verified
-			user.RiskScore = 10 // Low risk
+	user, err := s.repository.Users.FindByTag(tag)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, core.Unauthorized(errors.New("unknown user tag"))
 		}
-	} else {
-		user.KYCStatus = models.KYCStatusRejected
-		user.RiskScore = 90 // High risk
+		return nil, err
 	}
+	return user, nil
+}
 
+// UpdateDefaultPrivacy sets the privacy applied to a user's payments when
+// they don't choose one.
+func (s *UserService) UpdateDefaultPrivacy(user *models.User, req core.UpdatePrivacyRequest) core.Response {
+	user.DefaultPrivacy = req.Privacy
 	if err := s.repository.Users.Update(user); err != nil {
-		return core.Error(err, nil)
+		return core.Error(err, core.String("failed to update default privacy"))
 	}
 
 	return core.Success(&map[string]interface{}{
-		"user_id":    user.ID,
-		"kyc_status": user.KYCStatus,
-	}, nil)
+		"default_privacy": user.DefaultPrivacy,
+	}, core.String("default privacy updated"))
 }
 
 func (s *UserService) LinkFundingSource(req core.LinkFundingSourceRequest) core.Response {
@@ -160,7 +151,7 @@ func (s *UserService) LinkFundingSource(req core.LinkFundingSourceRequest) core.
 	fs := &models.FundingSource{
 		UserID:     req.UserID,
 		Type:       req.Type,
-		ProviderID: req.PaymentMethodID,
+		ProviderID: fieldcrypt.String(req.PaymentMethodID),
 		Last4:      "4242", // Mock
 		Brand:      "Visa", // Mock
 	}
@@ -178,60 +169,102 @@ func (s *UserService) Deposit(req core.DepositRequest) core.Response {
 	// 1. Validate Funding Source
 	fs, err := s.repository.FundingSources.FindByID(req.FundingSourceID)
 	if err != nil {
-		return core.Error(err, core.String("funding source not found"))
+		return notFoundOr(err, "funding source not found")
 	}
 	if fs.UserID != req.UserID {
-		return core.Error(errors.New("unauthorized"), core.String("funding source does not belong to user"))
+		return core.Error(core.Forbidden(errors.New("funding source owner mismatch")), core.String("funding source does not belong to user"))
+	}
+
+	movement := limits.Movement{UserID: req.UserID, Kind: limits.KindDeposit, Amount: req.Amount}
+	if s.limits != nil {
+		if err := s.limits.Check(movement); err != nil {
+			return core.Error(err, nil)
+		}
+	}
+
+	if err := s.assessDeposit(req); err != nil {
+		return core.Error(err, nil)
+	}
+
+	ref := core.GenerateRef()
+	if s.limits != nil {
+		if err := s.limits.Reserve(ref, movement); err != nil {
+			return core.Error(err, nil)
+		}
 	}
 
 	// 2. Mock Stripe Charge (Synchronous for now)
 	// stripe.PaymentIntents.Create(...)
-	fmt.Printf("Mock: Charging %d cents from %s for User %d\n", req.Amount, fs.ProviderID, req.UserID)
+	core.Log.Info("mock charge", zap.Int("user_id", req.UserID), zap.Int("funding_source_id", fs.ID), zap.Int64("amount", req.Amount))
 	// Simulate success
 
 	// 3. Credit Wallet
 	wallet, err := s.repository.Wallets.FindPrimaryWallet(req.UserID)
 	if err != nil {
-		return core.Error(err, core.String("wallet not found"))
+		s.releaseDeposit(ref)
+		return notFoundOr(err, "wallet not found")
 	}
 
 	wallet.Balance += req.Amount
 	if err := s.repository.Wallets.Update(wallet); err != nil {
 		// In real world, we need to reverse the charge here!
+		s.releaseDeposit(ref)
 		return core.Error(err, core.String("failed to update wallet balance"))
 	}
 
+	s.notifier.Notify(req.UserID, core.EventDepositSettled, map[string]interface{}{
+		"amount":            core.FormatAmount(req.Amount),
+		"currency":          wallet.Currency,
+		"source":            fmt.Sprintf("%s ending %s", fs.Brand, fs.Last4),
+		"funding_source_id": fs.ID,
+		"new_balance":       core.FormatAmount(wallet.Balance),
+	})
+
 	return core.Success(&map[string]interface{}{
 		"new_balance": wallet.Balance,
 		"currency":    wallet.Currency,
 	}, core.String("deposit successful"))
 }
 
-func (s *UserService) AddFriend(req core.CreateFriendshipRequest) core.Response {
-	// Check if already friends
-	if _, err := s.repository.Friendships.Find(req.UserID, req.FriendID); err == nil {
-		return core.Error(nil, core.String("already friends"))
+// releaseDeposit gives back the limit headroom reserved for a deposit that
+// didn't go through.
+func (s *UserService) releaseDeposit(ref string) {
+	if s.limits == nil {
+		return
+	}
+	if err := s.limits.Release(ref); err != nil {
+		core.Log.Error("failed to release deposit limits", zap.String("ref", ref), zap.Error(err))
 	}
+}
 
-	// Create friendship (bi-directional for simplicity, or two rows)
-	// For MVP, one row implies check both ways or create 2 rows.
-	// Let's create two rows to simplify querying.
+// assessDeposit runs risk checks on a deposit. A deposit has no transaction
+// to hold, so anything short of allow turns it down; the reasons are kept
+// with the risk assessment.
+func (s *UserService) assessDeposit(req core.DepositRequest) error {
+	if s.risk == nil {
+		return nil
+	}
 
-	f1 := models.Friendship{
-		UserID:   req.UserID,
-		FriendID: req.FriendID,
-		Status:   "accepted", // Auto-accept for MVP
+	in := risk.Input{UserID: req.UserID, Kind: risk.KindDeposit, Amount: req.Amount}
+	decision, err := s.risk.Evaluate(in)
+	if err != nil {
+		return err
 	}
-	f2 := models.Friendship{
-		UserID:   req.FriendID,
-		FriendID: req.UserID,
-		Status:   "accepted",
+	if err := s.risk.Record(in, "", decision); err != nil {
+		core.Log.Error("failed to record risk decision", zap.Int("user_id", req.UserID), zap.Error(err))
 	}
 
-	if err := s.repository.Friendships.Create(&f1); err != nil {
-		return core.Error(err, core.String("failed to add friend"))
+	if decision.Outcome != risk.Allow {
+		return core.RiskDeclined(core.ErrCodeRiskBlocked, errors.New("this deposit can't be completed right now"), nil)
 	}
-	s.repository.Friendships.Create(&f2) // Ignore error for 2nd row for now
+	return nil
+}
 
-	return core.Success(nil, core.String("friend added"))
+// notFoundOr reports a missing record as NotFound with the given message and
+// anything else as an internal failure.
+func notFoundOr(err error, message string) core.Response {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.Error(core.NotFound(err), core.String(message))
+	}
+	return core.Error(err, nil)
 }
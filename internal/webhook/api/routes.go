@@ -0,0 +1,129 @@
+package api
+
+import (
+	"cashapp/core"
+	"cashapp/internal/webhook/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWebhookRoutes mounts the endpoints partners use to manage their
+// webhooks. The hosting service supplies its auth middleware and a way to
+// read the authenticated user's ID.
+func RegisterWebhookRoutes(e *gin.Engine, s *service.WebhookService, auth gin.HandlerFunc, userID func(*gin.Context) int) {
+	g := e.Group("/webhooks", auth)
+
+	// CreateWebhookEndpoint registers a URL for event callbacks
+	// @Router /webhooks/endpoints [post]
+	g.POST("/endpoints", func(c *gin.Context) {
+		var req core.WebhookEndpointRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.CreateEndpoint(userID(c), req))
+	})
+
+	// ListWebhookEndpoints lists the caller's webhook endpoints
+	// @Router /webhooks/endpoints [get]
+	g.GET("/endpoints", func(c *gin.Context) {
+		core.Respond(c, s.ListEndpoints(userID(c)))
+	})
+
+	// GetWebhookEndpoint returns one webhook endpoint
+	// @Router /webhooks/endpoints/:id [get]
+	g.GET("/endpoints/:id", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.GetEndpoint(userID(c), id))
+	})
+
+	// UpdateWebhookEndpoint changes an endpoint's URL, events or state
+	// @Router /webhooks/endpoints/:id [put]
+	g.PUT("/endpoints/:id", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+		var req core.UpdateWebhookEndpointRequest
+		if !core.BindJSON(c, &req) {
+			return
+		}
+
+		core.Respond(c, s.UpdateEndpoint(userID(c), id, req))
+	})
+
+	// DeleteWebhookEndpoint removes a webhook endpoint
+	// @Router /webhooks/endpoints/:id [delete]
+	g.DELETE("/endpoints/:id", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.DeleteEndpoint(userID(c), id))
+	})
+
+	// RotateWebhookSecret replaces an endpoint's signing secret
+	// @Router /webhooks/endpoints/:id/rotate-secret [post]
+	g.POST("/endpoints/:id/rotate-secret", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.RotateSecret(userID(c), id))
+	})
+
+	// ListWebhookDeliveries lists an endpoint's deliveries, newest first
+	// @Router /webhooks/endpoints/:id/deliveries [get]
+	g.GET("/endpoints/:id/deliveries", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+		var q core.WebhookDeliveryQuery
+		if !core.BindQuery(c, &q) {
+			return
+		}
+
+		core.Respond(c, s.ListDeliveries(userID(c), id, q))
+	})
+
+	// GetWebhookDelivery returns a delivery and its attempts
+	// @Router /webhooks/deliveries/:id [get]
+	g.GET("/deliveries/:id", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.GetDelivery(userID(c), id))
+	})
+
+	// ReplayWebhookDelivery sends a delivery's event again
+	// @Router /webhooks/deliveries/:id/replay [post]
+	g.POST("/deliveries/:id/replay", func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		core.Respond(c, s.ReplayDelivery(userID(c), id))
+	})
+}
+
+// intParam parses a numeric path parameter, responding with a validation
+// error when it isn't one.
+func intParam(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		core.Respond(c, core.Error(core.Validation(err), core.String("invalid "+name)))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"cashapp/core"
+	"strings"
+	"time"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending" // waiting for its first attempt or a retry
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed" // gave up after the last retry, or the endpoint is gone
+)
+
+// WebhookEndpoint is a URL a user has registered for event callbacks.
+// Deliveries are signed with Secret, and also with PreviousSecret until it
+// expires so the owner can roll the secret without dropping deliveries.
+type WebhookEndpoint struct {
+	core.Model
+	UserID                int        `json:"user_id" gorm:"index"`
+	URL                   string     `json:"url"`
+	Description           string     `json:"description,omitempty"`
+	Events                string     `json:"-"` // comma-separated event types, or core.WebhookAllEvents
+	Secret                string     `json:"-"`
+	PreviousSecret        string     `json:"-"`
+	PreviousSecretExpires *time.Time `json:"-"`
+	Enabled               bool       `json:"enabled"`
+	ConsecutiveFailures   int        `json:"consecutive_failures"` // failed attempts since the last success
+	FailingSince          *time.Time `json:"failing_since,omitempty"`
+	DisabledAt            *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason        string     `json:"disabled_reason,omitempty"`
+}
+
+// EventTypes lists the event types the endpoint is subscribed to.
+func (e WebhookEndpoint) EventTypes() []string {
+	if e.Events == "" {
+		return []string{}
+	}
+	return strings.Split(e.Events, ",")
+}
+
+// Subscribed reports whether the endpoint wants event.
+func (e WebhookEndpoint) Subscribed(event string) bool {
+	for _, t := range e.EventTypes() {
+		if t == core.WebhookAllEvents || t == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Secrets returns the secrets a delivery made at now is signed with.
+func (e WebhookEndpoint) Secrets(now time.Time) []string {
+	if e.PreviousSecret != "" && e.PreviousSecretExpires != nil && now.Before(*e.PreviousSecretExpires) {
+		return []string{e.Secret, e.PreviousSecret}
+	}
+	return []string{e.Secret}
+}
+
+// WebhookDelivery is one event on its way to one endpoint. Payload is the
+// exact body sent; a replay is a new delivery with the same payload, so the
+// receiver sees the same event ID again.
+type WebhookDelivery struct {
+	core.Model
+	EndpointID    int            `json:"endpoint_id" gorm:"index"`
+	UserID        int            `json:"-" gorm:"index"`
+	EventID       string         `json:"event_id" gorm:"index"`
+	Event         string         `json:"event"`
+	Payload       string         `json:"payload"` // JSON
+	Status        DeliveryStatus `json:"status" gorm:"index"`
+	Attempts      int            `json:"attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at" gorm:"index"`
+	ResponseCode  int            `json:"response_code,omitempty"` // of the last attempt
+	LastError     string         `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time     `json:"delivered_at,omitempty"`
+	ReplayOf      *int           `json:"replay_of,omitempty"`
+}
+
+// WebhookAttempt records one try at a delivery.
+type WebhookAttempt struct {
+	core.Model
+	DeliveryID   int    `json:"delivery_id" gorm:"index"`
+	ResponseCode int    `json:"response_code,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"` // truncated
+	Error        string `json:"error,omitempty"`
+	DurationMS   int64  `json:"duration_ms"`
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"cashapp/internal/webhook/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type deliveryLayer struct {
+	db *gorm.DB
+}
+
+type DeliveryRepo interface {
+	Create(d *models.WebhookDelivery) error
+	ClaimDue(now time.Time, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	SaveAttempt(d *models.WebhookDelivery, attempt *models.WebhookAttempt) error
+	FindForUser(userID int, id int) (*models.WebhookDelivery, error)
+	ListByEndpoint(endpointID int, status string, beforeID int, limit int) ([]models.WebhookDelivery, error)
+	ListAttempts(deliveryID int) ([]models.WebhookAttempt, error)
+}
+
+func newDeliveryLayer(db *gorm.DB) *deliveryLayer {
+	return &deliveryLayer{
+		db: db,
+	}
+}
+
+func (l *deliveryLayer) Create(d *models.WebhookDelivery) error {
+	return l.db.Create(d).Error
+}
+
+// ClaimDue takes up to limit pending deliveries whose next attempt is due
+// and pushes their next attempt back by lease, so no other worker picks them
+// up while the caller sends them. Sending happens after the claim commits;
+// the caller saves each outcome with SaveAttempt. Rows locked by another
+// worker are skipped, so several instances can run side by side.
+func (l *deliveryLayer) ClaimDue(now time.Time, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	var due []models.WebhookDelivery
+	err := l.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		ids := make([]int, len(due))
+		leased := now.Add(lease)
+		for i := range due {
+			ids[i] = due[i].ID
+			due[i].NextAttemptAt = leased
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).UpdateColumn("next_attempt_at", leased).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// SaveAttempt saves a delivery along with the attempt just made at it, if
+// any.
+func (l *deliveryLayer) SaveAttempt(d *models.WebhookDelivery, attempt *models.WebhookAttempt) error {
+	return l.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(d).Error; err != nil {
+			return err
+		}
+		if attempt == nil {
+			return nil
+		}
+		attempt.DeliveryID = d.ID
+		return tx.Create(attempt).Error
+	})
+}
+
+func (l *deliveryLayer) FindForUser(userID int, id int) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := l.db.Where("id = ? AND user_id = ?", id, userID).First(&d).Error
+	return &d, err
+}
+
+func (l *deliveryLayer) ListByEndpoint(endpointID int, status string, beforeID int, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	q := l.db.Where("endpoint_id = ?", endpointID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	err := q.Order("id desc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (l *deliveryLayer) ListAttempts(deliveryID int) ([]models.WebhookAttempt, error) {
+	var attempts []models.WebhookAttempt
+	err := l.db.Where("delivery_id = ?", deliveryID).Order("id").Find(&attempts).Error
+	return attempts, err
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"cashapp/internal/webhook/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type endpointLayer struct {
+	db *gorm.DB
+}
+
+type EndpointRepo interface {
+	Create(e *models.WebhookEndpoint) error
+	Update(e *models.WebhookEndpoint) error
+	Delete(e *models.WebhookEndpoint) error
+	FindByID(id int) (*models.WebhookEndpoint, error)
+	FindForUser(userID int, id int) (*models.WebhookEndpoint, error)
+	ListByUser(userID int) ([]models.WebhookEndpoint, error)
+	ListEnabled(userID int) ([]models.WebhookEndpoint, error)
+	CountByUser(userID int) (int64, error)
+	RecordSuccess(id int) error
+	RecordFailure(id int, at time.Time) (*models.WebhookEndpoint, error)
+	Disable(id int, at time.Time, reason string) (bool, error)
+}
+
+func newEndpointLayer(db *gorm.DB) *endpointLayer {
+	return &endpointLayer{
+		db: db,
+	}
+}
+
+func (l *endpointLayer) Create(e *models.WebhookEndpoint) error {
+	return l.db.Create(e).Error
+}
+
+func (l *endpointLayer) Update(e *models.WebhookEndpoint) error {
+	return l.db.Save(e).Error
+}
+
+func (l *endpointLayer) Delete(e *models.WebhookEndpoint) error {
+	return l.db.Delete(e).Error
+}
+
+func (l *endpointLayer) FindByID(id int) (*models.WebhookEndpoint, error) {
+	var e models.WebhookEndpoint
+	err := l.db.First(&e, id).Error
+	return &e, err
+}
+
+func (l *endpointLayer) FindForUser(userID int, id int) (*models.WebhookEndpoint, error) {
+	var e models.WebhookEndpoint
+	err := l.db.Where("id = ? AND user_id = ?", id, userID).First(&e).Error
+	return &e, err
+}
+
+func (l *endpointLayer) ListByUser(userID int) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := l.db.Where("user_id = ?", userID).Order("id").Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (l *endpointLayer) ListEnabled(userID int) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := l.db.Where("user_id = ? AND enabled = ?", userID, true).Order("id").Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (l *endpointLayer) CountByUser(userID int) (int64, error) {
+	var count int64
+	err := l.db.Model(&models.WebhookEndpoint{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// RecordSuccess clears an endpoint's failure streak.
+func (l *endpointLayer) RecordSuccess(id int) error {
+	return l.db.Model(&models.WebhookEndpoint{}).
+		Where("id = ? AND consecutive_failures > 0", id).
+		Updates(map[string]interface{}{"consecutive_failures": 0, "failing_since": nil}).Error
+}
+
+// RecordFailure extends an endpoint's failure streak and returns the
+// endpoint as it now stands. The update is a single statement so attempts
+// running side by side all count.
+func (l *endpointLayer) RecordFailure(id int, at time.Time) (*models.WebhookEndpoint, error) {
+	err := l.db.Model(&models.WebhookEndpoint{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+		"failing_since":        gorm.Expr("COALESCE(failing_since, ?)", at),
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return l.FindByID(id)
+}
+
+// Disable turns an enabled endpoint off. It reports whether this call did
+// it, so only one caller tells the owner.
+func (l *endpointLayer) Disable(id int, at time.Time, reason string) (bool, error) {
+	result := l.db.Model(&models.WebhookEndpoint{}).
+		Where("id = ? AND enabled = ?", id, true).
+		Updates(map[string]interface{}{"enabled": false, "disabled_at": at, "disabled_reason": reason})
+	return result.RowsAffected == 1, result.Error
+}
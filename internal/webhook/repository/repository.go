@@ -0,0 +1,15 @@
+package repository
+
+import "gorm.io/gorm"
+
+type Repo struct {
+	Endpoints  EndpointRepo
+	Deliveries DeliveryRepo
+}
+
+func New(db *gorm.DB) Repo {
+	return Repo{
+		Endpoints:  newEndpointLayer(db),
+		Deliveries: newDeliveryLayer(db),
+	}
+}
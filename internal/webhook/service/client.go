@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// errInternalAddress is returned when an endpoint resolves somewhere inside
+// our network. Deliveries store part of the response for the owner to read,
+// so they must only ever reach the public internet.
+var errInternalAddress = errors.New("webhook endpoints must be on the public internet")
+
+// newClient returns the client deliveries are sent with. The address is
+// checked as the connection is made, after DNS resolution, so a name that
+// resolves to an internal address, or rebinds to one, is refused too.
+// allowInternal lifts that for development, where endpoints run locally.
+func newClient(allowInternal bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if allowInternal {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+				return fmt.Errorf("%w: %s", errInternalAddress, host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// No proxy: the dialer must see the endpoint's own address.
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		// A redirect counts as a failure; endpoints must be registered
+		// with their final URL.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// internalIP reports whether ip is loopback, private, link-local (which
+// includes cloud metadata services), multicast or unspecified.
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace is carrier-grade NAT space, RFC 6598; IsPrivate
+// doesn't cover it.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"cashapp/core"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInternalIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true}, // cloud metadata
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"224.0.0.1", true},
+		{"::ffff:127.0.0.1", true},
+		{"8.8.8.8", false},
+		{"172.32.0.1", false},
+		{"100.128.0.1", false},
+		{"2606:4700::1111", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := internalIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Fatalf("internalIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     core.Environment
+		url     string
+		wantErr bool
+	}{
+		{"https", core.Production, "https://hooks.example.com/cashapp", false},
+		{"http outside development", core.Production, "http://hooks.example.com/cashapp", true},
+		{"http in development", core.Development, "http://localhost:9000/hooks", false},
+		{"relative", core.Production, "/hooks", true},
+		{"other scheme", core.Development, "ftp://example.com", true},
+		{"loopback", core.Production, "https://127.0.0.1/hooks", true},
+		{"localhost", core.Production, "https://localhost/hooks", true},
+		{"localhost subdomain", core.Production, "https://api.localhost/hooks", true},
+		{"metadata service", core.Production, "https://169.254.169.254/latest/meta-data", true},
+		{"private network", core.Production, "https://10.0.0.5:8443/hooks", true},
+		{"ipv6 loopback", core.Production, "https://[::1]/hooks", true},
+		{"public address", core.Production, "https://93.184.216.34/hooks", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &WebhookService{config: &core.Config{ENVIRONMENT: tt.env}}
+			if err := s.checkURL(tt.url); (err != nil) != tt.wantErr {
+				t.Fatalf("checkURL(%q) = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer server.Close()
+
+	if _, err := newClient(false).Get(server.URL); !errors.Is(err, errInternalAddress) {
+		t.Fatalf("Get = %v, want errInternalAddress", err)
+	}
+
+	// In development the endpoint is reached, but its redirect isn't
+	// followed.
+	resp, err := newClient(true).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("status = %d, want the redirect itself", resp.StatusCode)
+	}
+}
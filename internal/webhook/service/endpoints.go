@@ -0,0 +1,294 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/internal/webhook/models"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxEndpoints = 10
+	// secretOverlap is how long a rotated-out secret keeps signing
+	// deliveries alongside its replacement.
+	secretOverlap = 24 * time.Hour
+)
+
+// endpointView is an endpoint as its owner sees it. Secret is only filled
+// in when the endpoint is created or its secret rotated.
+type endpointView struct {
+	*models.WebhookEndpoint
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+func viewOf(e *models.WebhookEndpoint) endpointView {
+	return endpointView{WebhookEndpoint: e, Events: e.EventTypes()}
+}
+
+// CreateEndpoint registers a URL for the given event types and returns its
+// signing secret. This is the only time the secret is shown.
+func (s *WebhookService) CreateEndpoint(userID int, req core.WebhookEndpointRequest) core.Response {
+	if err := s.checkURL(req.URL); err != nil {
+		return core.Error(core.Validation(err), nil)
+	}
+
+	count, err := s.repository.Endpoints.CountByUser(userID)
+	if err != nil {
+		return core.Error(err, nil)
+	}
+	if count >= maxEndpoints {
+		return core.Error(core.Conflict(errors.New("endpoint limit reached")), core.String("you can register at most 10 webhook endpoints"))
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		UserID:      userID,
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      strings.Join(req.Events, ","),
+		Secret:      newSecret(),
+		Enabled:     true,
+	}
+	if err := s.repository.Endpoints.Create(endpoint); err != nil {
+		return core.Error(err, core.String("failed to register webhook endpoint"))
+	}
+
+	view := viewOf(endpoint)
+	view.Secret = endpoint.Secret
+	return core.Success(&map[string]interface{}{
+		"endpoint": view,
+	}, core.String("webhook endpoint registered"))
+}
+
+func (s *WebhookService) ListEndpoints(userID int) core.Response {
+	endpoints, err := s.repository.Endpoints.ListByUser(userID)
+	if err != nil {
+		return core.Error(err, core.String("failed to load webhook endpoints"))
+	}
+
+	views := make([]endpointView, len(endpoints))
+	for i := range endpoints {
+		views[i] = viewOf(&endpoints[i])
+	}
+	return core.Success(&map[string]interface{}{
+		"endpoints": views,
+	}, nil)
+}
+
+func (s *WebhookService) GetEndpoint(userID int, id int) core.Response {
+	endpoint, err := s.repository.Endpoints.FindForUser(userID, id)
+	if err != nil {
+		return notFoundOr(err, "webhook endpoint not found")
+	}
+	return core.Success(&map[string]interface{}{
+		"endpoint": viewOf(endpoint),
+	}, nil)
+}
+
+// UpdateEndpoint changes an endpoint. Turning it back on clears its failure
+// streak; deliveries that failed while it was off can be replayed.
+func (s *WebhookService) UpdateEndpoint(userID int, id int, req core.UpdateWebhookEndpointRequest) core.Response {
+	endpoint, err := s.repository.Endpoints.FindForUser(userID, id)
+	if err != nil {
+		return notFoundOr(err, "webhook endpoint not found")
+	}
+
+	if req.URL != nil {
+		if err := s.checkURL(*req.URL); err != nil {
+			return core.Error(core.Validation(err), nil)
+		}
+		endpoint.URL = *req.URL
+	}
+	if req.Events != nil {
+		endpoint.Events = strings.Join(req.Events, ",")
+	}
+	if req.Description != nil {
+		endpoint.Description = *req.Description
+	}
+	if req.Enabled != nil && *req.Enabled != endpoint.Enabled {
+		endpoint.Enabled = *req.Enabled
+		endpoint.ConsecutiveFailures = 0
+		endpoint.FailingSince = nil
+		endpoint.DisabledAt = nil
+		endpoint.DisabledReason = ""
+		if !endpoint.Enabled {
+			now := time.Now()
+			endpoint.DisabledAt = &now
+			endpoint.DisabledReason = "turned off by owner"
+		}
+	}
+
+	if err := s.repository.Endpoints.Update(endpoint); err != nil {
+		return core.Error(err, core.String("failed to update webhook endpoint"))
+	}
+	return core.Success(&map[string]interface{}{
+		"endpoint": viewOf(endpoint),
+	}, core.String("webhook endpoint updated"))
+}
+
+// DeleteEndpoint removes an endpoint. Its delivery log is kept; deliveries
+// still queued fail when their turn comes.
+func (s *WebhookService) DeleteEndpoint(userID int, id int) core.Response {
+	endpoint, err := s.repository.Endpoints.FindForUser(userID, id)
+	if err != nil {
+		return notFoundOr(err, "webhook endpoint not found")
+	}
+	if err := s.repository.Endpoints.Delete(endpoint); err != nil {
+		return core.Error(err, core.String("failed to delete webhook endpoint"))
+	}
+	return core.Success(nil, core.String("webhook endpoint deleted"))
+}
+
+// RotateSecret gives an endpoint a new secret. The old one keeps signing
+// deliveries alongside it for a day so the receiver can switch over.
+func (s *WebhookService) RotateSecret(userID int, id int) core.Response {
+	endpoint, err := s.repository.Endpoints.FindForUser(userID, id)
+	if err != nil {
+		return notFoundOr(err, "webhook endpoint not found")
+	}
+
+	expires := time.Now().Add(secretOverlap)
+	endpoint.PreviousSecret = endpoint.Secret
+	endpoint.PreviousSecretExpires = &expires
+	endpoint.Secret = newSecret()
+	if err := s.repository.Endpoints.Update(endpoint); err != nil {
+		return core.Error(err, core.String("failed to rotate webhook secret"))
+	}
+
+	view := viewOf(endpoint)
+	view.Secret = endpoint.Secret
+	return core.Success(&map[string]interface{}{
+		"endpoint":                view,
+		"previous_secret_expires": expires,
+	}, core.String("webhook secret rotated"))
+}
+
+// ListDeliveries returns an endpoint's delivery log, newest first.
+func (s *WebhookService) ListDeliveries(userID int, endpointID int, q core.WebhookDeliveryQuery) core.Response {
+	if _, err := s.repository.Endpoints.FindForUser(userID, endpointID); err != nil {
+		return notFoundOr(err, "webhook endpoint not found")
+	}
+
+	cursor, err := core.DecodeCursor(q.Cursor)
+	if err != nil {
+		return core.Error(err, core.String("invalid cursor"))
+	}
+
+	limit := core.PageSize(q.Limit)
+	deliveries, err := s.repository.Deliveries.ListByEndpoint(endpointID, q.Status, cursor.BeforeID, limit+1)
+	if err != nil {
+		return core.Error(err, core.String("failed to load webhook deliveries"))
+	}
+
+	pagination := &core.Pagination{}
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		pagination.NextCursor = core.Cursor{BeforeID: deliveries[limit-1].ID}.Encode()
+	}
+	pagination.Count = int64(len(deliveries))
+
+	return core.Paginated(&map[string]interface{}{
+		"deliveries": deliveries,
+	}, pagination, nil)
+}
+
+// GetDelivery returns a delivery with every attempt made at it.
+func (s *WebhookService) GetDelivery(userID int, id int) core.Response {
+	delivery, err := s.repository.Deliveries.FindForUser(userID, id)
+	if err != nil {
+		return notFoundOr(err, "webhook delivery not found")
+	}
+
+	attempts, err := s.repository.Deliveries.ListAttempts(delivery.ID)
+	if err != nil {
+		return core.Error(err, core.String("failed to load delivery attempts"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"delivery": delivery,
+		"attempts": attempts,
+	}, nil)
+}
+
+// ReplayDelivery sends a delivery's event to its endpoint again as a new
+// delivery. The body is unchanged, so the receiver sees the same event ID.
+func (s *WebhookService) ReplayDelivery(userID int, id int) core.Response {
+	original, err := s.repository.Deliveries.FindForUser(userID, id)
+	if err != nil {
+		return notFoundOr(err, "webhook delivery not found")
+	}
+	if original.Status == models.DeliveryPending {
+		return core.Error(core.Conflict(errors.New("delivery still pending")), core.String("this delivery is still being attempted"))
+	}
+
+	endpoint, err := s.repository.Endpoints.FindForUser(userID, original.EndpointID)
+	if err != nil {
+		return notFoundOr(err, "webhook endpoint not found")
+	}
+	if !endpoint.Enabled {
+		return core.Error(core.Conflict(errors.New("endpoint disabled")), core.String("turn the endpoint back on before replaying"))
+	}
+
+	replay := &models.WebhookDelivery{
+		EndpointID:    original.EndpointID,
+		UserID:        userID,
+		EventID:       original.EventID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: time.Now(),
+		ReplayOf:      &original.ID,
+	}
+	if err := s.repository.Deliveries.Create(replay); err != nil {
+		return core.Error(err, core.String("failed to queue replay"))
+	}
+
+	return core.Success(&map[string]interface{}{
+		"delivery": replay,
+	}, core.String("delivery queued"))
+}
+
+// checkURL refuses plain HTTP endpoints, and ones plainly inside our
+// network, outside development. Names are only resolved when a delivery is
+// made; see newClient.
+func (s *WebhookService) checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("url must be an absolute URL")
+	}
+	if s.config.ENVIRONMENT == core.Development {
+		if u.Scheme == "https" || u.Scheme == "http" {
+			return nil
+		}
+		return errors.New("url must use https")
+	}
+	if u.Scheme != "https" {
+		return errors.New("url must use https")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(host); (ip != nil && internalIP(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errInternalAddress
+	}
+	return nil
+}
+
+func newSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+func notFoundOr(err error, message string) core.Response {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.Error(core.NotFound(err), core.String(message))
+	}
+	return core.Error(err, nil)
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"cashapp/core"
+	"cashapp/core/webhooks"
+	"cashapp/internal/webhook/models"
+	"cashapp/internal/webhook/repository"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Headers sent with every delivery. Receivers verify SignatureHeader with
+// webhooks.TimestampedHMAC and the endpoint's secret.
+const (
+	SignatureHeader = "Cashapp-Signature"
+	EventHeader     = "Cashapp-Event"
+	DeliveryHeader  = "Cashapp-Delivery"
+)
+
+const (
+	maxAttempts = 8
+	retryBase   = 30 * time.Second
+	// claimBatch deliveries are claimed at a time and sent one after
+	// another, so the lease must outlast claimBatch timeouts.
+	claimBatch    = 20
+	deliveryLease = 5 * time.Minute
+	// An endpoint is turned off once it has failed disableFailures attempts
+	// in a row over at least disableAfter, so a short outage doesn't cost a
+	// partner their integration.
+	disableFailures = 20
+	disableAfter    = 24 * time.Hour
+	// maxResponseBody is how much of an endpoint's response is kept.
+	maxResponseBody = 1 << 10
+)
+
+// envelope is the body of every delivery.
+type envelope struct {
+	ID      string                 `json:"id"`
+	Type    string                 `json:"type"`
+	Created int64                  `json:"created"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+type WebhookService struct {
+	repository repository.Repo
+	config     *core.Config
+	notifier   core.Notifier
+	client     *http.Client
+}
+
+func New(r repository.Repo, c *core.Config) *WebhookService {
+	return &WebhookService{
+		repository: r,
+		config:     c,
+		notifier:   core.LogNotifier{},
+		client:     newClient(c.ENVIRONMENT == core.Development),
+	}
+}
+
+// SetNotifier replaces the notifier used to tell owners an endpoint was
+// turned off.
+func (s *WebhookService) SetNotifier(n core.Notifier) {
+	s.notifier = n
+}
+
+// Publish queues event for every enabled endpoint of userID subscribed to
+// it. It implements the ledger's EventPublisher. Errors are logged rather
+// than returned, like the real-time events it sits alongside.
+func (s *WebhookService) Publish(userID int, event string, data map[string]interface{}) {
+	if err := s.publish(userID, event, data); err != nil {
+		core.Log.Error("failed to queue webhook deliveries", zap.Int("user_id", userID), zap.String("event", event), zap.Error(err))
+	}
+}
+
+func (s *WebhookService) publish(userID int, event string, data map[string]interface{}) error {
+	if !partnerEvent(event) {
+		return nil
+	}
+
+	endpoints, err := s.repository.Endpoints.ListEnabled(userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var payload []byte
+	eventID := "evt_" + core.GenerateRef()
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribed(event) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(envelope{ID: eventID, Type: event, Created: now.Unix(), Data: data})
+			if err != nil {
+				return err
+			}
+		}
+
+		err := s.repository.Deliveries.Create(&models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			UserID:        userID,
+			EventID:       eventID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunDeliveries sends queued deliveries and retries failed ones with
+// exponential backoff. It blocks; run it in a goroutine.
+func (s *WebhookService) RunDeliveries(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			due, err := s.repository.Deliveries.ClaimDue(time.Now(), claimBatch, deliveryLease)
+			if err != nil {
+				core.Log.Error("failed to claim webhook deliveries", zap.Error(err))
+				break
+			}
+			for i := range due {
+				s.deliver(&due[i])
+			}
+			if len(due) < claimBatch {
+				break
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// deliver makes one attempt at a claimed delivery and saves the outcome. If
+// the attempt can't be made it is left to be claimed again once its lease
+// runs out.
+func (s *WebhookService) deliver(d *models.WebhookDelivery) {
+	attempt, err := s.attempt(d)
+	if err != nil {
+		core.Log.Error("failed to send webhook delivery", zap.Int("delivery_id", d.ID), zap.Error(err))
+		return
+	}
+	if err := s.repository.Deliveries.SaveAttempt(d, attempt); err != nil {
+		core.Log.Error("failed to save webhook delivery", zap.Int("delivery_id", d.ID), zap.Error(err))
+	}
+}
+
+// attempt makes one delivery attempt and records the outcome on d. It only
+// returns an error when the endpoint can't be looked up.
+func (s *WebhookService) attempt(d *models.WebhookDelivery) (*models.WebhookAttempt, error) {
+	endpoint, err := s.repository.Endpoints.FindByID(d.EndpointID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			d.Status = models.DeliveryFailed
+			d.LastError = "endpoint deleted"
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !endpoint.Enabled {
+		d.Status = models.DeliveryFailed
+		d.LastError = "endpoint disabled"
+		return nil, nil
+	}
+
+	now := time.Now()
+	d.Attempts++
+	attempt := s.send(endpoint, d, now)
+	d.ResponseCode = attempt.ResponseCode
+
+	if attempt.Error == "" {
+		d.Status = models.DeliverySucceeded
+		d.DeliveredAt = &now
+		d.LastError = ""
+		if err := s.repository.Endpoints.RecordSuccess(endpoint.ID); err != nil {
+			core.Log.Error("failed to reset webhook endpoint failures", zap.Int("endpoint_id", endpoint.ID), zap.Error(err))
+		}
+		return attempt, nil
+	}
+
+	d.LastError = attempt.Error
+	if d.Attempts >= maxAttempts {
+		d.Status = models.DeliveryFailed
+	} else {
+		d.NextAttemptAt = now.Add(retryBase << (d.Attempts - 1))
+	}
+	s.recordFailure(endpoint, now)
+	return attempt, nil
+}
+
+// send posts a delivery to its endpoint, signed with the endpoint's current
+// secrets.
+func (s *WebhookService) send(endpoint *models.WebhookEndpoint, d *models.WebhookDelivery, now time.Time) *models.WebhookAttempt {
+	attempt := &models.WebhookAttempt{}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, strings.NewReader(d.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Cashapp-Webhooks/1.0")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(d.ID))
+	req.Header.Set(SignatureHeader, webhooks.Sign(now, []byte(d.Payload), endpoint.Secrets(now)...))
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	attempt.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	attempt.ResponseCode = resp.StatusCode
+	// Postgres text can't hold NULs or invalid UTF-8.
+	attempt.ResponseBody = strings.ReplaceAll(strings.ToValidUTF8(string(body), ""), "\x00", "")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = resp.Status
+	}
+	return attempt
+}
+
+// recordFailure counts a failed attempt against the endpoint and turns it
+// off once it has been failing for long enough.
+func (s *WebhookService) recordFailure(endpoint *models.WebhookEndpoint, now time.Time) {
+	updated, err := s.repository.Endpoints.RecordFailure(endpoint.ID, now)
+	if err != nil {
+		core.Log.Error("failed to record webhook endpoint failure", zap.Int("endpoint_id", endpoint.ID), zap.Error(err))
+		return
+	}
+	if updated.ConsecutiveFailures < disableFailures || updated.FailingSince == nil || now.Sub(*updated.FailingSince) < disableAfter {
+		return
+	}
+
+	disabled, err := s.repository.Endpoints.Disable(endpoint.ID, now, "too many failed deliveries")
+	if err != nil {
+		core.Log.Error("failed to disable webhook endpoint", zap.Int("endpoint_id", endpoint.ID), zap.Error(err))
+		return
+	}
+	if !disabled {
+		return
+	}
+
+	core.Log.Warn("webhook endpoint disabled", zap.Int("endpoint_id", endpoint.ID), zap.Int("failures", updated.ConsecutiveFailures))
+	s.notifier.Notify(endpoint.UserID, core.EventWebhookDisabled, map[string]interface{}{
+		"endpoint_id": endpoint.ID,
+		"url":         endpoint.URL,
+		"dedup_key":   "webhook-disabled-" + strconv.Itoa(endpoint.ID) + "-" + strconv.FormatInt(now.Unix(), 10),
+	})
+}
+
+// partnerEvent reports whether deliveries are made for event.
+func partnerEvent(event string) bool {
+	for _, t := range core.WebhookEventTypes {
+		if t == event {
+			return true
+		}
+	}
+	return false
+}